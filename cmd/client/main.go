@@ -29,10 +29,11 @@ type queryResult struct {
 func parseArgs() *clientConf {
 	keyFile := flag.String("keyfile", "./data/client/traffic_set_baseline.csv", "file with series of keys to fetch")
 	verbose := flag.Bool("verbose", false, "if you want lots of output")
+	port := flag.Int("port", 1234, "port the cache server listens on")
 	flag.Parse()
 	return &clientConf{
 		keyfile: keyFile,
-		port:    1234,
+		port:    *port,
 		host:    "localhost",
 		verbose: *verbose,
 	}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+/*settings starts from the LCR_* environment, then the config
+file if there is one, then whichever flags were given*/
+func settings(configFile string, cacheType string, size int, ttl time.Duration, idleTTL time.Duration, shards int, maxBytes int64, port int) cache.Settings {
+	s, err := cache.SettingsFromEnv("LCR")
+	if err == nil && configFile != "" {
+		s, err = cache.LoadSettings(configFile)
+	}
+	if err != nil {
+		fmt.Println("ERROR reading settings: ", err)
+		os.Exit(-1)
+	}
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "type" {
+			s.Type, err = cache.ParseCacheType(cacheType)
+		} else if f.Name == "size" {
+			s.Size = size
+		} else if f.Name == "ttl" {
+			s.TTL = ttl
+		} else if f.Name == "idle_ttl" {
+			s.IdleTTL = idleTTL
+		} else if f.Name == "shards" {
+			s.Shards = shards
+		} else if f.Name == "max_bytes" {
+			s.MaxBytes = maxBytes
+		} else if f.Name == "port" {
+			s.Port = port
+		}
+	})
+	if err == nil {
+		err = s.Validate()
+	}
+	if err != nil {
+		fmt.Println("ERROR in settings: ", err)
+		os.Exit(-1)
+	}
+	return s
+}
+
+func parseArgs() *cache.ServerConf {
+	configFile := flag.String("config", "", "json or yaml settings file (type, size, ttl, idle_ttl, shards, max_bytes, port...), flags override it")
+	cacheType := flag.String("type", "LRU", "One of (NONE, FIFO, LRU, LFU, LCR, LCRTTL, LECAR, CALECAR, TIERED, SLRU)")
+	size := flag.Int("size", 1000, "number of entries the cache is able to hold")
+	ttl := flag.Duration("ttl", 0, "how long cached entries live (e.g. 10m), 0 keeps them until evicted")
+	idleTTL := flag.Duration("idle_ttl", 0, "how long an unread entry lives, 0 for no limit")
+	shards := flag.Int("shards", 0, "split the cache into this many independently locked shards")
+	maxBytes := flag.Int64("max_bytes", 0, "budget of key and value bytes to hold the cache to, 0 for none")
+	port := flag.Int("port", 1234, "port to serve the line protocol on")
+	httpPort := flag.Int("http_port", 0, "port to serve the cache, keys and stats over HTTP on, 0 to turn it off")
+	snapshot := flag.String("snapshot", "", "file to restore the cache from on start and save it to on shutdown")
+	snapshotEvery := flag.Duration("snapshot_every", 0, "how often to save the snapshot while running, 0 for only on shutdown")
+	dataFile := flag.String("data_file", "", "csv of key,value,cost to fill misses from, none makes it a plain cache")
+	logFile := flag.String("logfile", "", "file to write log outputs to as well as stdout")
+	peers := flag.String("peers", "", "comma separated host:port list of other nodes to send invalidations to")
+	replicas := flag.String("replicas", "", "comma separated host:port list of standby nodes to replicate sets and deletes to")
+	traceFile := flag.String("trace_file", "", "file to append a trace of every cache access to, for replaying in the simulator")
+	verbose := flag.Bool("verbose", false, "wheter you want a lot of output")
+	flag.Parse()
+	s := settings(*configFile, *cacheType, *size, *ttl, *idleTTL, *shards, *maxBytes, *port)
+	return &cache.ServerConf{
+		LogFile:       logFile,
+		DataFile:      dataFile,
+		Config:        &s.Config,
+		Verbose:       *verbose,
+		Port:          s.Port,
+		Peers:         cache.ParsePeers(*peers),
+		Replicas:      cache.ParsePeers(*replicas),
+		Self:          "localhost:" + strconv.Itoa(s.Port),
+		TraceFile:     *traceFile,
+		TraceRate:     1,
+		SnapshotFile:  *snapshot,
+		SnapshotEvery: *snapshotEvery,
+		HTTPPort:      *httpPort,
+	}
+}
+
+/*saveOnSignal saves the snapshot before exiting on an
+interrupt or a TERM*/
+func saveOnSignal(server *cache.Server) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		err := server.SaveSnapshot()
+		if err != nil {
+			fmt.Println("ERROR saving snapshot: ", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}()
+}
+
+func main() {
+	conf := parseArgs()
+	server := cache.NewServer(conf)
+	saveOnSignal(server)
+	server.Listen()
+}
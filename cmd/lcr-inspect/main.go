@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+type inspectConf struct {
+	snapshot string
+	keys     bool
+	top      int
+	by       string
+	sep      string
+}
+
+func parseArgs() *inspectConf {
+	keys := flag.Bool("keys", false, "list every key with its size, cost, hits and expiry")
+	top := flag.Int("top", 10, "how many of the biggest entries to list, 0 for none")
+	by := flag.String("by", "cost", "what the top entries are ranked by, one of (cost, hits, bytes)")
+	sep := flag.String("sep", ":", "separator ending a key's namespace, empty to skip the namespace breakdown")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Println("usage: lcr-inspect [flags] <snapshot file>")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	if *by != "cost" && *by != "hits" && *by != "bytes" {
+		fmt.Println("ERROR no ranking by '" + *by + "'")
+		os.Exit(2)
+	}
+	return &inspectConf{snapshot: flag.Arg(0), keys: *keys, top: *top, by: *by, sep: *sep}
+}
+
+func recordBytes(r cache.Record) int {
+	return len(r.Key) + len(r.Entry.Value())
+}
+
+func expiry(r cache.Record) string {
+	expires := r.Entry.Expires()
+	if expires.IsZero() {
+		return "never"
+	}
+	return expires.Format(time.RFC3339)
+}
+
+func printReport(report cache.SnapshotReport) {
+	fmt.Println("records:    ", report.Records, "of", report.Declared)
+	fmt.Println("bytes:      ", report.Bytes)
+	fmt.Println("with ttl:   ", report.WithTTL, "("+strconv.Itoa(report.Expired)+" expired)")
+	fmt.Printf("cost:        min %d max %d mean %.1f p50 %d p90 %d p99 %d\n",
+		report.Cost.Min, report.Cost.Max, report.Cost.Mean, report.Cost.P50, report.Cost.P90, report.Cost.P99)
+	fmt.Printf("hits:        min %d max %d mean %.1f p50 %d p90 %d p99 %d\n",
+		report.Hits.Min, report.Hits.Max, report.Hits.Mean, report.Hits.P50, report.Hits.P90, report.Hits.P99)
+	if report.Truncated {
+		fmt.Println("TRUNCATED:  ", report.Error)
+	}
+	if len(report.Duplicates) > 0 {
+		fmt.Println("DUPLICATES: ", strings.Join(report.Duplicates, ", "))
+	}
+	if len(report.Orphans) > 0 {
+		fmt.Println("ORPHANS:    ", strings.Join(report.Orphans, ", "))
+	}
+}
+
+/*printRecords lists the records a line each, in the order
+given*/
+func printRecords(records []cache.Record) {
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, "key\tbytes\tcost\thits\texpires\t")
+	for _, r := range records {
+		fmt.Fprintf(out, "%s\t%d\t%d\t%d\t%s\t\n", r.Key, recordBytes(r), r.Entry.Cost(), r.Hits, expiry(r))
+	}
+	out.Flush()
+}
+
+func topRecords(records []cache.Record, n int, by string) []cache.Record {
+	sorted := append([]cache.Record{}, records...)
+	rank := func(r cache.Record) int {
+		if by == "hits" {
+			return r.Hits
+		} else if by == "bytes" {
+			return recordBytes(r)
+		}
+		return r.Entry.Cost()
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return rank(sorted[i]) > rank(sorted[j]) })
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+type namespace struct {
+	name    string
+	entries int
+	bytes   int
+	cost    int
+}
+
+/*printNamespaces totals the records by the part of the key
+before the separator, biggest namespaces first*/
+func printNamespaces(records []cache.Record, sep string) {
+	byName := map[string]*namespace{}
+	for _, r := range records {
+		name := "(none)"
+		if idx := strings.Index(r.Key, sep); idx >= 0 {
+			name = r.Key[:idx]
+		}
+		ns, ok := byName[name]
+		if !ok {
+			ns = &namespace{name: name}
+			byName[name] = ns
+		}
+		ns.entries++
+		ns.bytes += recordBytes(r)
+		ns.cost += r.Entry.Cost()
+	}
+	all := []*namespace{}
+	for _, ns := range byName {
+		all = append(all, ns)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].bytes != all[j].bytes {
+			return all[i].bytes > all[j].bytes
+		}
+		return all[i].name < all[j].name
+	})
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, "namespace\tentries\tbytes\tcost\t")
+	for _, ns := range all {
+		fmt.Fprintf(out, "%s\t%d\t%d\t%d\t\n", ns.name, ns.entries, ns.bytes, ns.cost)
+	}
+	out.Flush()
+}
+
+func main() {
+	conf := parseArgs()
+	data, err := os.ReadFile(conf.snapshot)
+	if err != nil {
+		fmt.Println("ERROR reading snapshot: ", err)
+		os.Exit(-1)
+	}
+	report, err := cache.InspectSnapshot(bytes.NewReader(data))
+	if err != nil {
+		fmt.Println("ERROR ", err)
+		os.Exit(-1)
+	}
+	// a damaged snapshot still lists whatever could be read
+	records, _ := cache.ReadSnapshot(bytes.NewReader(data))
+	printReport(report)
+	if conf.sep != "" {
+		fmt.Println()
+		printNamespaces(records, conf.sep)
+	}
+	if conf.top > 0 {
+		fmt.Println()
+		fmt.Println("top", conf.top, "by", conf.by+":")
+		printRecords(topRecords(records, conf.top, conf.by))
+	}
+	if conf.keys {
+		fmt.Println()
+		printRecords(records)
+	}
+	if !report.Consistent() {
+		os.Exit(1)
+	}
+}
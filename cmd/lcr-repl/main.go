@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+const help = `commands:
+  get <key>                      read a key from the cache
+  set <key> <value> [cost] [ttl] cache a value (ttl like 10m, in-process only)
+  del <key>                      drop a key
+  keys [pattern]                 list keys matching a glob, or /regexp/
+  stats                          hits, misses, costs and memory
+  resize <size>                  change the cache's size, evicting what won't fit
+  help                           this
+  quit                           leave`
+
+/*backend runs one shell command against a cache, answering in
+the server's line protocol either way*/
+type backend interface {
+	run(command string, args []string) ([]string, error)
+}
+
+/*remote is a server, over one keepalive connection*/
+type remote struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dial(addr string) (*remote, error) {
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	_, err = conn.Write([]byte("keepalive\n"))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &remote{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+/*send writes the message and reads the answer up to its END*/
+func (r *remote) send(message string) ([]string, error) {
+	_, err := r.conn.Write([]byte(message + "\n"))
+	if err != nil {
+		return nil, err
+	}
+	lines := []string{}
+	for {
+		line, err := r.reader.ReadString('\n')
+		if err != nil {
+			return lines, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "END" {
+			return lines, nil
+		}
+		lines = append(lines, line)
+	}
+}
+
+func (r *remote) run(command string, args []string) ([]string, error) {
+	if command == "get" {
+		return r.send("get," + args[0])
+	} else if command == "set" {
+		if len(args) > 3 {
+			return nil, errors.New("The server's set takes no ttl, its default applies")
+		}
+		cost := "0"
+		if len(args) > 2 {
+			cost = args[2]
+		}
+		return r.send("set," + args[0] + "," + cost + "," + args[1])
+	} else if command == "del" {
+		return r.send("delete," + args[0])
+	} else if command == "keys" {
+		return r.send("keys," + args[0])
+	} else if command == "stats" {
+		stats, err := r.send("stats")
+		if err != nil {
+			return stats, err
+		}
+		memory, err := r.send("memory")
+		return append(stats, memory...), err
+	}
+	return r.send("resize," + args[0])
+}
+
+/*local is a cache in this process*/
+type local struct {
+	policy string
+	index  *cache.Indexed
+	meter  *cache.Metered
+}
+
+func newLocal(policy string, size int, ttl time.Duration, snapshot string) (*local, error) {
+	cacheType, err := cache.ParseCacheType(policy)
+	if err != nil {
+		return nil, err
+	}
+	c, err := cache.NewCache(cacheType, size, cache.WithDefaultTTL(ttl))
+	if err != nil {
+		return nil, err
+	}
+	l := &local{policy: cacheType.String()}
+	l.index = cache.NewIndexed(c)
+	l.meter = cache.NewMetered(l.index)
+	if snapshot != "" {
+		file, err := os.Open(snapshot)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		err = cache.RestoreSnapshot(file, l.meter)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+func (l *local) run(command string, args []string) ([]string, error) {
+	if command == "get" {
+		entry, err := l.meter.GetValue(args[0])
+		if err != nil {
+			return []string{"MISS:" + args[0]}, nil
+		}
+		return []string{"VALUE:" + entry.Value(), "COST:" + strconv.Itoa(entry.Cost())}, nil
+	} else if command == "set" {
+		entry, err := localEntry(args)
+		if err != nil {
+			return nil, err
+		}
+		err = l.meter.SetValue(args[0], entry)
+		return []string{"SET:" + args[0]}, err
+	} else if command == "del" {
+		l.meter.Delete(args[0])
+		return []string{"DELETED:" + args[0]}, nil
+	} else if command == "keys" {
+		keys, err := l.index.KeysMatching(args[0])
+		lines := []string{}
+		for _, key := range keys {
+			lines = append(lines, "KEY:"+key)
+		}
+		return lines, err
+	} else if command == "stats" {
+		return l.stats(), nil
+	}
+	size, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, errors.New("Bad size '" + args[0] + "'")
+	}
+	evicted, err := cache.Resize(l.meter, size)
+	if err != nil {
+		return nil, err
+	}
+	return []string{"RESIZED:" + args[0], "EVICTED:" + strconv.Itoa(evicted)}, nil
+}
+
+func localEntry(args []string) (cache.Entry, error) {
+	cost := 0
+	var err error
+	if len(args) > 2 {
+		cost, err = strconv.Atoi(args[2])
+		if err != nil {
+			return cache.Entry{}, errors.New("Bad cost '" + args[2] + "'")
+		}
+	}
+	entry := cache.NewEntry(args[1], cost)
+	if len(args) > 3 {
+		ttl, err := time.ParseDuration(args[3])
+		if err != nil {
+			return cache.Entry{}, errors.New("Bad ttl '" + args[3] + "'")
+		}
+		entry = entry.WithTTL(ttl)
+	}
+	return entry, nil
+}
+
+func (l *local) stats() []string {
+	stats := l.meter.Stats()
+	f := cache.MemoryUsage(l.meter, nil)
+	return []string{
+		"POLICY:" + l.policy,
+		"HITS:" + strconv.FormatInt(stats.Hits, 10),
+		"MISSES:" + strconv.FormatInt(stats.Misses, 10),
+		"HIT_RATIO:" + strconv.FormatFloat(stats.HitRatio(), 'f', 4, 64),
+		"COST_SAVED:" + strconv.FormatInt(stats.CostSaved, 10),
+		"EVICTIONS:" + strconv.FormatInt(stats.Evictions, 10),
+		"ENTRIES:" + strconv.Itoa(f.Entries),
+		"TOTAL_BYTES:" + strconv.FormatInt(f.Total(), 10),
+	}
+}
+
+/*arity is how many arguments each command needs at least and
+at most*/
+var arity = map[string][2]int{
+	"get":    {1, 1},
+	"set":    {2, 4},
+	"del":    {1, 1},
+	"keys":   {0, 1},
+	"stats":  {0, 0},
+	"resize": {1, 1},
+}
+
+/*repl reads commands until the input runs out or quit*/
+func repl(b backend, in io.Reader, out io.Writer, prompt bool) {
+	scanner := bufio.NewScanner(in)
+	for {
+		if prompt {
+			fmt.Fprint(out, "lcr> ")
+		}
+		if !scanner.Scan() {
+			return
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		command := strings.ToLower(fields[0])
+		args := fields[1:]
+		if command == "quit" || command == "exit" {
+			return
+		}
+		if command == "help" {
+			fmt.Fprintln(out, help)
+			continue
+		}
+		bounds, ok := arity[command]
+		if !ok || len(args) < bounds[0] || len(args) > bounds[1] {
+			fmt.Fprintln(out, "ERROR bad command, try help")
+			continue
+		}
+		if command == "keys" && len(args) == 0 {
+			args = []string{"*"}
+		}
+		lines, err := b.run(command, args)
+		for _, line := range lines {
+			fmt.Fprintln(out, line)
+		}
+		if err != nil {
+			fmt.Fprintln(out, "ERROR", err)
+		}
+	}
+}
+
+func main() {
+	connect := flag.String("connect", "", "host:port of a running server to attach to, a cache in this process if empty")
+	cacheType := flag.String("type", "LRU", "One of (NONE, FIFO, LRU, LFU, LCR, LCRTTL, LECAR, CALECAR, TIERED, SLRU), in-process")
+	size := flag.Int("size", 1000, "number of entries the in-process cache holds")
+	ttl := flag.Duration("ttl", 0, "default TTL for the in-process cache, 0 keeps entries until evicted")
+	snapshot := flag.String("snapshot", "", "snapshot file to load the in-process cache from")
+	flag.Parse()
+	var b backend
+	var err error
+	if *connect != "" {
+		b, err = dial(*connect)
+	} else {
+		b, err = newLocal(*cacheType, *size, *ttl, *snapshot)
+	}
+	if err != nil {
+		fmt.Println("ERROR ", err)
+		os.Exit(-1)
+	}
+	repl(b, os.Stdin, os.Stdout, true)
+}
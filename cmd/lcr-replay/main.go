@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+	"github.com/evizitei/lcr-cache/pkg/sim"
+)
+
+type replayConf struct {
+	trace    string
+	format   string
+	dataset  string
+	policies []cache.CacheType
+	sizes    []int
+	csvOut   string
+	jsonOut  string
+}
+
+/*parsePolicies reads a comma separated list of policy
+names*/
+func parsePolicies(list string) ([]cache.CacheType, error) {
+	policies := []cache.CacheType{}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		policy, err := cache.ParseCacheType(name)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	if len(policies) == 0 {
+		return nil, errors.New("No policies to replay")
+	}
+	return policies, nil
+}
+
+/*parseSizes reads a comma separated list of sizes and
+ranges, "100,250" or "50-500:50" for every 50 from 50 to 500*/
+func parseSizes(list string) ([]int, error) {
+	sizes := []int{}
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		bounds := strings.SplitN(part, "-", 2)
+		if len(bounds) == 1 {
+			size, err := strconv.Atoi(part)
+			if err != nil || size <= 0 {
+				return nil, errors.New("Bad size '" + part + "'")
+			}
+			sizes = append(sizes, size)
+			continue
+		}
+		step := 1
+		upper := bounds[1]
+		if idx := strings.Index(upper, ":"); idx >= 0 {
+			var err error
+			step, err = strconv.Atoi(upper[idx+1:])
+			if err != nil || step <= 0 {
+				return nil, errors.New("Bad step in '" + part + "'")
+			}
+			upper = upper[:idx]
+		}
+		low, err := strconv.Atoi(bounds[0])
+		high, err2 := strconv.Atoi(upper)
+		if err != nil || err2 != nil || low <= 0 || high < low {
+			return nil, errors.New("Bad size range '" + part + "'")
+		}
+		for size := low; size <= high; size += step {
+			sizes = append(sizes, size)
+		}
+	}
+	if len(sizes) == 0 {
+		return nil, errors.New("No sizes to replay at")
+	}
+	return sizes, nil
+}
+
+func parseArgs() *replayConf {
+	trace := flag.String("trace", "./data/client/traffic_set_baseline.csv", "trace file to replay")
+	format := flag.String("format", "lines", "trace format, one of (lines, arc, twitter, recorded)")
+	dataset := flag.String("dataset", "", "server data file (key,value,cost) to price keys from, every miss costs 1 without one")
+	policies := flag.String("policies", "FIFO,LRU,LFU,LCR,LECAR,CALECAR", "comma separated policies to compare")
+	sizes := flag.String("sizes", "250", "comma separated sizes and ranges (50-500:50) to replay at")
+	csvOut := flag.String("csv", "", "file to save the comparison to as csv")
+	jsonOut := flag.String("json", "", "file to save the comparison to as json")
+	flag.Parse()
+	conf := &replayConf{trace: *trace, format: *format, dataset: *dataset, csvOut: *csvOut, jsonOut: *jsonOut}
+	var err error
+	conf.policies, err = parsePolicies(*policies)
+	if err == nil {
+		conf.sizes, err = parseSizes(*sizes)
+	}
+	if err != nil {
+		fmt.Println("ERROR ", err)
+		os.Exit(2)
+	}
+	return conf
+}
+
+func loadCosts(path string) (sim.CostFunc, error) {
+	if path == "" {
+		return nil, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return sim.DatasetCosts(file)
+}
+
+/*save writes the rows to the file with the writer, if a file
+was asked for*/
+func save(path string, rows []sim.Row, write func(io.Writer, []sim.Row) error) error {
+	if path == "" {
+		return nil
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = write(file, rows)
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+func main() {
+	conf := parseArgs()
+	costs, err := loadCosts(conf.dataset)
+	if err != nil {
+		fmt.Println("ERROR reading dataset: ", err)
+		os.Exit(-1)
+	}
+	rows, err := sim.Compare(sim.FileSource(conf.trace, conf.format), conf.policies, conf.sizes, costs)
+	if err != nil {
+		fmt.Println("ERROR replaying trace: ", err)
+		os.Exit(-1)
+	}
+	sim.WriteTable(os.Stdout, rows)
+	err = save(conf.csvOut, rows, sim.WriteCSV)
+	if err == nil {
+		err = save(conf.jsonOut, rows, sim.WriteJSON)
+	}
+	if err != nil {
+		fmt.Println("ERROR saving comparison: ", err)
+		os.Exit(-1)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"flag"
+	"strconv"
 
 	"github.com/evizitei/lcr-cache/pkg/cache"
 )
@@ -9,16 +10,43 @@ import (
 func parseArgs() *cache.ServerConf {
 	logFile := flag.String("logfile", "./log/server.log", "file to write log outputs to as the server runs")
 	dataFile := flag.String("data_file", "./data/test_set_1.csv", "file to read working set from")
-	cacheType := flag.String("cache_type", "FIFO", "One of (NONE, FIFO, LRU, LFU, LCR, LECAR, LECARAC)")
+	cacheType := flag.String("cache_type", "FIFO", "One of (NONE, FIFO, LRU, LFU, LCR, LCRTTL, LECAR, CALECAR, TIERED, SLRU)")
 	cacheSize := flag.Int("cache_size", 1000, "number of entries the cache is able to hold")
 	verbose := flag.Bool("verbose", false, "wheter you want a lot of output")
+	port := flag.Int("port", 1234, "port to listen for fetch requests on")
+	peers := flag.String("peers", "", "comma separated host:port list of other nodes to send invalidations to")
+	replicas := flag.String("replicas", "", "comma separated host:port list of standby nodes to replicate sets and deletes to")
+	cluster := flag.String("cluster", "", "comma separated host:port list of the other nodes sharing the key space, enables coordinated fills")
+	self := flag.String("self", "", "host:port other cluster nodes reach this one at (localhost:<port> by default)")
+	redisAddr := flag.String("redis_addr", "", "host:port of a redis to follow invalidations from (near-cache mode)")
+	redisChannel := flag.String("redis_channel", "__keyspace@0__:*", "redis channel or pattern announcing changed keys")
+	hotThreshold := flag.Int("hot_threshold", 0, "in cluster mode, requests an owner sees for a key before it is replicated to other nodes (0 disables)")
+	hotReplicas := flag.Int("hot_replicas", 3, "how many nodes (owner included) a hot key is spread across")
+	traceFile := flag.String("trace_file", "", "file to append a trace of every cache access to, for replaying in the simulator")
+	traceRate := flag.Float64("trace_rate", 1, "share of keys (by hash) whose accesses are traced")
+	defaultTTL := flag.Duration("default_ttl", 0, "how long cached entries live (e.g. 10m), 0 keeps them until evicted")
 	flag.Parse()
+	if *self == "" {
+		*self = "localhost:" + strconv.Itoa(*port)
+	}
 	return &cache.ServerConf{
-		LogFile:   logFile,
-		DataFile:  dataFile,
-		CacheType: cacheType,
-		CacheSize: *cacheSize,
-		Verbose:   *verbose,
+		LogFile:      logFile,
+		DataFile:     dataFile,
+		CacheType:    cacheType,
+		CacheSize:    *cacheSize,
+		Verbose:      *verbose,
+		Port:         *port,
+		Peers:        cache.ParsePeers(*peers),
+		Replicas:     cache.ParsePeers(*replicas),
+		Self:         *self,
+		Cluster:      cache.ParsePeers(*cluster),
+		RedisAddr:    *redisAddr,
+		RedisChannel: *redisChannel,
+		HotThreshold: *hotThreshold,
+		HotReplicas:  *hotReplicas,
+		TraceFile:    *traceFile,
+		TraceRate:    *traceRate,
+		DefaultTTL:   *defaultTTL,
 	}
 }
 
@@ -0,0 +1,68 @@
+package bench
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+/*hitRuns is how many hits HitAllocs averages over*/
+const hitRuns = 1000
+
+/*warmCache fills a policy with size keys and reads each of
+them once, so hits after that are steady state*/
+func warmCache(policy cache.CacheType, size int) (cache.Cache, []string, error) {
+	c, err := cache.NewCache(policy, size)
+	if err != nil {
+		return nil, nil, err
+	}
+	keys := make([]string, size)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+		c.SetValue(keys[i], cache.NewEntry(keys[i], i+1))
+	}
+	for _, key := range keys {
+		c.GetValue(key)
+	}
+	return c, keys, nil
+}
+
+/*HitAllocs is the average number of heap allocations one
+cache hit costs the policy*/
+func HitAllocs(policy cache.CacheType, size int) (float64, error) {
+	c, keys, err := warmCache(policy, size)
+	if err != nil {
+		return 0, err
+	}
+	// one goroutine, so nothing else allocates while we count
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+	// a first round lets the policy set up whatever it reuses
+	// from then on (LFU's spare buckets)
+	for i := 0; i < hitRuns; i++ {
+		c.GetValue(keys[i%len(keys)])
+	}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := 0; i < hitRuns; i++ {
+		c.GetValue(keys[i%len(keys)])
+	}
+	runtime.ReadMemStats(&after)
+	return float64(after.Mallocs-before.Mallocs) / hitRuns, nil
+}
+
+/*CheckZeroAllocHits is an error naming the first policy
+whose hits allocate*/
+func CheckZeroAllocHits(size int, policies ...cache.CacheType) error {
+	for _, policy := range policies {
+		allocs, err := HitAllocs(policy, size)
+		if err != nil {
+			return err
+		}
+		if allocs > 0 {
+			return fmt.Errorf("%s allocates %.2f times per hit", policy, allocs)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+	"github.com/evizitei/lcr-cache/pkg/workload"
+)
+
+/*Policies is every replacement policy NewCache knows about*/
+var Policies = []cache.CacheType{cache.FIFO, cache.LRU, cache.LFU, cache.LCR, cache.LCRTTL, cache.LECAR, cache.CALECAR}
+
+/*Workload is a named stream of lookups to drive a policy
+with.  Every miss is filled with an entry priced by the
+spec's Costs, which default to an even spread from 1 to 100*/
+type Workload struct {
+	Name string
+	Spec workload.Spec
+}
+
+/*Result is how one policy did on one workload*/
+type Result struct {
+	Policy      cache.CacheType
+	Workload    string
+	OpsPerSec   float64
+	AllocsPerOp int64
+	BytesPerOp  int64
+	HitRatio    float64
+	CostSaved   float64
+}
+
+/*trace generates the workload's keys up front, so the timed
+runs don't measure the key generator*/
+func (w Workload) trace() ([]string, []int) {
+	spec := w.Spec
+	if spec.Costs == nil {
+		spec.Costs = workload.UniformCost(1, 100)
+	}
+	generator := workload.New(spec)
+	keys := make([]string, 0, spec.Ops)
+	costs := make([]int, 0, spec.Ops)
+	for {
+		access, err := generator.Next()
+		if err != nil {
+			return keys, costs
+		}
+		keys = append(keys, access.Key)
+		costs = append(costs, access.Cost)
+	}
+}
+
+/*lookup is one read-through access, true for a hit*/
+func lookup(c cache.Cache, key string, cost int) bool {
+	if c.KeyPresent(key) {
+		_, err := c.GetValue(key)
+		if err == nil {
+			return true
+		}
+	}
+	c.SetValue(key, cache.NewEntry(key, cost))
+	return false
+}
+
+/*minTimed is how long Run times each policy for*/
+var minTimed = time.Second
+
+/*timing is what a timed run cost*/
+type timing struct {
+	ops     int
+	elapsed time.Duration
+	mallocs uint64
+	bytes   uint64
+}
+
+/*timed replays the keys against a fresh cache until at
+least minTime has gone by, counting heap allocations on the
+way*/
+func timed(policy cache.CacheType, size int, keys []string, costs []int, minTime time.Duration) (timing, error) {
+	c, err := cache.NewCache(policy, size)
+	if err != nil {
+		return timing{}, err
+	}
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	ops := 0
+	for time.Since(start) < minTime {
+		for j := range keys {
+			lookup(c, keys[j], costs[j])
+		}
+		ops += len(keys)
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	return timing{
+		ops:     ops,
+		elapsed: elapsed,
+		mallocs: after.Mallocs - before.Mallocs,
+		bytes:   after.TotalAlloc - before.TotalAlloc,
+	}, nil
+}
+
+/*Run drives the policy through the workload once to measure
+hit ratio (and the share of total cost the hits saved), then
+benchmarks it for throughput and allocations*/
+func Run(policy cache.CacheType, size int, w Workload) (Result, error) {
+	if w.Spec.Ops <= 0 {
+		return Result{}, fmt.Errorf("Workload %s has no operations", w.Name)
+	}
+	c, err := cache.NewCache(policy, size)
+	if err != nil {
+		return Result{}, err
+	}
+	keys, costs := w.trace()
+	hits := 0
+	saved := 0
+	total := 0
+	for i, key := range keys {
+		total += costs[i]
+		if lookup(c, key, costs[i]) {
+			hits++
+			saved += costs[i]
+		}
+	}
+	run, err := timed(policy, size, keys, costs, minTimed)
+	if err != nil {
+		return Result{}, err
+	}
+	result := Result{
+		Policy:      policy,
+		Workload:    w.Name,
+		AllocsPerOp: int64(run.mallocs) / int64(run.ops),
+		BytesPerOp:  int64(run.bytes) / int64(run.ops),
+		HitRatio:    float64(hits) / float64(len(keys)),
+	}
+	if run.elapsed > 0 {
+		result.OpsPerSec = float64(run.ops) / run.elapsed.Seconds()
+	}
+	if total > 0 {
+		result.CostSaved = float64(saved) / float64(total)
+	}
+	return result, nil
+}
+
+/*Compare runs every policy through every workload*/
+func Compare(policies []cache.CacheType, size int, workloads []Workload) ([]Result, error) {
+	results := []Result{}
+	for _, w := range workloads {
+		for _, policy := range policies {
+			result, err := Run(policy, size, w)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+/*Report writes the results as a table*/
+func Report(out io.Writer, results []Result) {
+	fmt.Fprintf(out, "%-12s %-8s %12s %10s %10s %8s %10s\n", "WORKLOAD", "POLICY", "OPS/SEC", "ALLOCS/OP", "BYTES/OP", "HITRATE", "COSTSAVED")
+	for _, r := range results {
+		fmt.Fprintf(out, "%-12s %-8s %12.0f %10d %10d %8.3f %10.3f\n", r.Workload, r.Policy, r.OpsPerSec, r.AllocsPerOp, r.BytesPerOp, r.HitRatio, r.CostSaved)
+	}
+}
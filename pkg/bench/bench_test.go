@@ -0,0 +1,100 @@
+package bench
+
+import (
+	"testing"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+	"github.com/evizitei/lcr-cache/pkg/workload"
+)
+
+var benchWorkloads = []Workload{
+	{Name: "zipf", Spec: workload.Spec{Keys: workload.Zipf(10000, 1.1), Ops: 100000}},
+	{Name: "scan", Spec: workload.Spec{Keys: workload.Scan(500), Ops: 100000}},
+}
+
+func BenchmarkPolicies(b *testing.B) {
+	for _, w := range benchWorkloads {
+		keys, costs := w.trace()
+		for _, policy := range Policies {
+			b.Run(w.Name+"/"+policy.String(), func(b *testing.B) {
+				c, err := cache.NewCache(policy, 250)
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					j := i % len(keys)
+					lookup(c, keys[j], costs[j])
+				}
+			})
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	minTimed = 10 * time.Millisecond
+	defer func() { minTimed = time.Second }()
+	// a scan bigger than the cache never hits under LRU
+	result, err := Run(cache.LRU, 250, Workload{Name: "scan", Spec: workload.Spec{Keys: workload.Scan(500), Ops: 5000}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.HitRatio != 0 || result.CostSaved != 0 {
+		t.Fatalf("expected no hits on a scan, got %+v", result)
+	}
+	if result.OpsPerSec <= 0 {
+		t.Fatalf("expected a throughput, got %+v", result)
+	}
+	result, err = Run(cache.LRU, 250, Workload{Name: "small", Spec: workload.Spec{Keys: workload.Uniform(100), Ops: 5000}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only the first touch of each key misses
+	if result.HitRatio < 0.97 {
+		t.Fatalf("expected nearly every lookup to hit, got %+v", result)
+	}
+}
+
+func TestRunRejectsEmptyWorkload(t *testing.T) {
+	_, err := Run(cache.LRU, 10, Workload{Name: "empty", Spec: workload.Spec{Keys: workload.Uniform(10)}})
+	if err == nil {
+		t.Fatal("a workload with no operations should be an error")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	minTimed = time.Millisecond
+	defer func() { minTimed = time.Second }()
+	results, err := Compare([]cache.CacheType{cache.LRU, cache.LFU}, 10, []Workload{{Name: "u", Spec: workload.Spec{Keys: workload.Uniform(20), Ops: 100}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Policy != cache.LRU || results[1].Policy != cache.LFU {
+		t.Fatalf("unexpected results %+v", results)
+	}
+}
+
+func TestZeroAllocHits(t *testing.T) {
+	err := CheckZeroAllocHits(1000, cache.LRU, cache.LFU, cache.FIFO, cache.LCR)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func BenchmarkHits(b *testing.B) {
+	for _, policy := range []cache.CacheType{cache.LRU, cache.LFU} {
+		b.Run(policy.String(), func(b *testing.B) {
+			c, keys, err := warmCache(policy, 1000)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c.GetValue(keys[i%len(keys)])
+			}
+		})
+	}
+}
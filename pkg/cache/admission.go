@@ -0,0 +1,150 @@
+package cache
+
+import "fmt"
+
+/*ErrNotAdmitted is returned for a write the admission policy
+turned away rather than evict for it*/
+var ErrNotAdmitted = fmt.Errorf("%w, not admitted", ErrCacheFull)
+
+/*Admission decides whether a new entry is worth the one it
+would evict.  Record is told about every read and write so it
+can keep whatever history it judges by*/
+type Admission interface {
+	Record(key string)
+	Admit(candidate Record, victim Record) bool
+}
+
+/*boundedPolicy is every policy that can say whether it's full
+and which entries could make room*/
+type boundedPolicy interface {
+	Peeker
+	full() bool
+}
+
+/*weightedPolicy picks its victim by chance (LECAR and
+CALECAR), so it can only say which entries it might pick*/
+type weightedPolicy interface {
+	possibleVictims() []string
+}
+
+/*victimsFor is the entries a write of the key might evict, if
+the policy underneath the chain is full: just the one for
+every policy but LECAR and CALECAR, which might take any of
+their experts' picks*/
+func victimsFor(c Cache, k string) []Record {
+	for c != nil {
+		policy, ok := c.(boundedPolicy)
+		if ok {
+			if !policy.full() {
+				return nil
+			}
+			if _, resident := policy.Peek(k); resident {
+				// replacing in place, nothing is evicted
+				return nil
+			}
+			keys := []string{}
+			if peeker, ok := c.(victimPeeker); ok {
+				key, ok := peeker.nextEvicted()
+				if ok {
+					keys = append(keys, key)
+				}
+			} else if weighted, ok := c.(weightedPolicy); ok {
+				keys = weighted.possibleVictims()
+			}
+			victims := []Record{}
+			for _, key := range keys {
+				// an expired victim is no loss
+				entry, ok := policy.Peek(key)
+				if ok {
+					victims = append(victims, Record{Key: key, Entry: entry})
+				}
+			}
+			return victims
+		}
+		wrapper, ok := c.(unwrapper)
+		if !ok {
+			break
+		}
+		c = wrapper.Unwrap()
+	}
+	return nil
+}
+
+/*Admitting puts an Admission in front of the cache's policy:
+once it's full, a new key only gets in if the Admission rates
+it above the entry it would push out (every entry it might,
+under LECAR and CALECAR), otherwise the write is refused with
+ErrNotAdmitted and the resident entry stays.  Only writes
+that would evict are judged.  Like Indexed it
+holds its lock around every call, and the Admission is only
+ever used under it*/
+type Admitting struct {
+	lockedInner
+	admission Admission
+}
+
+/*KeyPresent is true if the key is cached right now*/
+func (a *Admitting) KeyPresent(k string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.cache.KeyPresent(k)
+}
+
+/*GetValue reads from the wrapped cache, recording the access
+hit or miss*/
+func (a *Admitting) GetValue(k string) (Entry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.admission.Record(k)
+	return a.cache.GetValue(k)
+}
+
+/*SetValue writes the entry unless it would evict one the
+Admission rates higher*/
+func (a *Admitting) SetValue(k string, v Entry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.admission.Record(k)
+	for _, victim := range victimsFor(a.cache, k) {
+		if !a.admission.Admit(Record{Key: k, Entry: v}, victim) {
+			return fmt.Errorf("%w, %s would evict %s", ErrNotAdmitted, k, victim.Key)
+		}
+	}
+	return a.cache.SetValue(k, v)
+}
+
+/*Delete removes the key from the wrapped cache*/
+func (a *Admitting) Delete(k string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.cache.Delete(k)
+}
+
+/*Export lists the wrapped cache's entries, if it can*/
+func (a *Admitting) Export() []Record {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	exporter, ok := a.cache.(Exporter)
+	if !ok {
+		return []Record{}
+	}
+	return exporter.Export()
+}
+
+/*Import puts the record into the wrapped cache without
+judging it (a snapshot is trusted to have been admitted)*/
+func (a *Admitting) Import(r Record) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return ImportRecord(a.cache, r)
+}
+
+/*Unwrap is the cache being admitted to*/
+func (a *Admitting) Unwrap() Cache {
+	return a.cache
+}
+
+/*NewAdmitting judges the cache's writes by the admission*/
+func NewAdmitting(c Cache, admission Admission) *Admitting {
+	return &Admitting{lockedInner: lockedInner{cache: c}, admission: admission}
+}
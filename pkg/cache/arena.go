@@ -0,0 +1,236 @@
+package cache
+
+import "encoding/binary"
+
+/*handleSize is a slab index, offset and length*/
+const handleSize = 12
+
+/*Arena keeps the values of the cache it wraps in a few large
+byte slabs instead of a string each, so tens of gigabytes of
+values are a handful of pointer-free allocations the GC never
+has to look inside, and the policy only holds a 12 byte
+handle per entry.  Slabs are filled front to back and reused
+once everything in them has been removed; space freed in a
+slab that still has live values waits for the rest, so a mix
+of long and short lived entries can hold slabs mostly empty.
+A value bigger than a slab gets a slab of its own.  Reads
+copy the value out of the slab.  Everything has to go through
+the Arena (the policy underneath only sees handles), and like
+Indexed it holds its lock around every call*/
+type Arena struct {
+	lockedInner
+	slabSize int
+	slabs    [][]byte
+	live     []int
+	filled   []int
+	current  int
+	free     []int
+}
+
+func (a *Arena) alloc(n int) (int, int) {
+	if n > a.slabSize {
+		return a.newSlab(n), 0
+	}
+	if a.current < 0 || a.filled[a.current]+n > a.slabSize {
+		full := a.current
+		a.current = -1
+		a.retire(full)
+		a.current = a.newSlab(a.slabSize)
+	}
+	slab := a.current
+	offset := a.filled[slab]
+	a.filled[slab] += n
+	a.live[slab] += n
+	return slab, offset
+}
+
+/*newSlab reuses an empty slab of the right size (or at least
+its slot) before growing*/
+func (a *Arena) newSlab(size int) int {
+	if len(a.free) > 0 {
+		slab := a.free[len(a.free)-1]
+		a.free = a.free[:len(a.free)-1]
+		if len(a.slabs[slab]) != size {
+			a.slabs[slab] = make([]byte, size)
+		}
+		if size > a.slabSize {
+			a.filled[slab], a.live[slab] = size, size
+		}
+		return slab
+	}
+	a.slabs = append(a.slabs, make([]byte, size))
+	a.live = append(a.live, 0)
+	a.filled = append(a.filled, 0)
+	slab := len(a.slabs) - 1
+	if size > a.slabSize {
+		a.filled[slab], a.live[slab] = size, size
+	}
+	return slab
+}
+
+/*retire frees a slab nothing lives in any more*/
+func (a *Arena) retire(slab int) {
+	if slab < 0 || a.live[slab] > 0 {
+		return
+	}
+	a.filled[slab] = 0
+	if slab == a.current {
+		// still the one being filled, just start again from the front
+		return
+	}
+	if len(a.slabs[slab]) > a.slabSize {
+		// don't keep a one off giant around
+		a.slabs[slab] = nil
+	}
+	a.free = append(a.free, slab)
+}
+
+/*store swaps the value for a handle, an empty value takes no
+space and gets the zero handle*/
+func (a *Arena) store(v Entry) Entry {
+	var handle [handleSize]byte
+	if len(v.value) == 0 {
+		v.value = string(handle[:])
+		return v
+	}
+	slab, offset := a.alloc(len(v.value))
+	copy(a.slabs[slab][offset:], v.value)
+	binary.LittleEndian.PutUint32(handle[0:], uint32(slab))
+	binary.LittleEndian.PutUint32(handle[4:], uint32(offset))
+	binary.LittleEndian.PutUint32(handle[8:], uint32(len(v.value)))
+	v.value = string(handle[:])
+	return v
+}
+
+/*decodeHandle reads the handle's slab, offset and length*/
+func decodeHandle(handle string) (int, int, int) {
+	field := func(at int) int {
+		return int(uint32(handle[at]) | uint32(handle[at+1])<<8 | uint32(handle[at+2])<<16 | uint32(handle[at+3])<<24)
+	}
+	return field(0), field(4), field(8)
+}
+
+func (a *Arena) load(v Entry) Entry {
+	slab, offset, n := decodeHandle(v.value)
+	if n == 0 {
+		v.value = ""
+		return v
+	}
+	v.value = string(a.slabs[slab][offset : offset+n])
+	return v
+}
+
+/*KeyPresent is true if the key is cached right now*/
+func (a *Arena) KeyPresent(k string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.cache.KeyPresent(k)
+}
+
+/*GetValue reads the entry, copying its value out of the
+arena*/
+func (a *Arena) GetValue(k string) (Entry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entry, err := a.cache.GetValue(k)
+	if err != nil {
+		return entry, err
+	}
+	return a.load(entry), nil
+}
+
+/*SetValue copies the value into the arena and caches its
+handle*/
+func (a *Arena) SetValue(k string, v Entry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	stored := a.store(v)
+	err := a.cache.SetValue(k, stored)
+	if err != nil {
+		a.freeValue(stored)
+	}
+	return err
+}
+
+/*Delete removes the key, freeing its value's space*/
+func (a *Arena) Delete(k string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.cache.Delete(k)
+}
+
+func (a *Arena) freeValue(v Entry) {
+	slab, _, n := decodeHandle(v.value)
+	if n == 0 {
+		return
+	}
+	if len(a.slabs[slab]) > a.slabSize {
+		n = len(a.slabs[slab])
+	}
+	a.live[slab] -= n
+	a.retire(slab)
+}
+
+func (a *Arena) onRemoval(key string, entry Entry, reason RemovalReason) {
+	// called from inside the wrapped cache, so the lock is already held
+	a.freeValue(entry)
+}
+
+/*Usage is how many bytes the arena has allocated in slabs and
+how many of them hold live values*/
+func (a *Arena) Usage() (int64, int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	reserved, live := int64(0), int64(0)
+	for i, slab := range a.slabs {
+		reserved += int64(len(slab))
+		live += int64(a.live[i])
+	}
+	return reserved, live
+}
+
+/*Export lists the wrapped cache's entries with their values
+copied out of the arena*/
+func (a *Arena) Export() []Record {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	exporter, ok := a.cache.(Exporter)
+	if !ok {
+		return []Record{}
+	}
+	records := exporter.Export()
+	for i := range records {
+		records[i].Entry = a.load(records[i].Entry)
+	}
+	return records
+}
+
+/*Import copies the record's value into the arena*/
+func (a *Arena) Import(r Record) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r.Entry = a.store(r.Entry)
+	err := ImportRecord(a.cache, r)
+	if err != nil || !a.cache.KeyPresent(r.Key) {
+		// refused or already expired, the space isn't held
+		a.freeValue(r.Entry)
+	}
+	return err
+}
+
+/*Unwrap is the cache holding the handles*/
+func (a *Arena) Unwrap() Cache {
+	return a.cache
+}
+
+/*NewArena keeps the cache's values in slabs of slabSize
+bytes (64MB if 0 or less).  The cache should be empty, any
+value already in it isn't a handle*/
+func NewArena(c Cache, slabSize int) *Arena {
+	if slabSize <= 0 {
+		slabSize = 64 << 20
+	}
+	a := &Arena{lockedInner: lockedInner{cache: c}, slabSize: slabSize, current: -1}
+	AddRemovalListener(c, a.onRemoval)
+	return a
+}
@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestArenaStoresValuesInSlabs(t *testing.T) {
+	lru, _ := NewCache(LRU, 3)
+	a := NewArena(lru, 32)
+	a.SetValue("a", NewEntry(strings.Repeat("a", 10), 7))
+	a.SetValue("b", NewEntry(strings.Repeat("b", 10), 1))
+	a.SetValue("empty", NewEntry("", 1))
+	entry, err := a.GetValue("a")
+	if err != nil || entry.Value() != strings.Repeat("a", 10) || entry.Cost() != 7 {
+		t.Fatalf("expected a back out of the arena, got %q %v", entry.Value(), err)
+	}
+	if entry, _ := a.GetValue("empty"); entry.Value() != "" {
+		t.Fatalf("expected the empty value back, got %q", entry.Value())
+	}
+	if held, _ := lru.GetValue("b"); len(held.Value()) != handleSize {
+		t.Fatalf("expected the policy to hold a handle, not %q", held.Value())
+	}
+	if reserved, live := a.Usage(); reserved != 32 || live != 20 {
+		t.Fatalf("expected one slab with 20 live bytes, got %d %d", reserved, live)
+	}
+	// pushes b out, and doesn't fit in what's left of the first slab
+	a.SetValue("c", NewEntry(strings.Repeat("c", 20), 1))
+	if reserved, live := a.Usage(); reserved != 64 || live != 30 {
+		t.Fatalf("expected a second slab, got %d %d", reserved, live)
+	}
+	records := a.Export()
+	if len(records) != 3 || records[2].Entry.Value() != strings.Repeat("c", 20) {
+		t.Fatalf("expected values copied out on export, got %v", records)
+	}
+}
+
+func TestArenaReusesEmptySlabs(t *testing.T) {
+	lru, _ := NewCache(LRU, 10)
+	a := NewArena(lru, 16)
+	for round := 0; round < 5; round++ {
+		a.SetValue("x", NewEntry(strings.Repeat("x", 12), 1))
+		a.SetValue("y", NewEntry(strings.Repeat("y", 12), 1))
+		a.SetValue("huge", NewEntry(strings.Repeat("h", 100), 1))
+		a.Delete("x")
+		a.Delete("y")
+		a.Delete("huge")
+	}
+	if reserved, live := a.Usage(); live != 0 || reserved > 32 {
+		t.Fatalf("expected freed slabs reused and the giant dropped, holding %d", reserved)
+	}
+	if entry, _ := a.GetValue("x"); entry.Value() != "" {
+		t.Fatalf("deleted value still readable")
+	}
+}
@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+/*Peeker is a cache that can return an entry without
+counting it as an access.  Every policy from NewCache is one*/
+type Peeker interface {
+	Peek(key string) (Entry, bool)
+}
+
+/*accessBatch is how many hits a buffer holds before they
+have to be applied*/
+const accessBatch = 64
+
+/*accessBuffer collects hits that haven't been applied to
+the policy yet*/
+type accessBuffer struct {
+	keys [accessBatch]string
+	n    int
+}
+
+/*Batched makes a cache safe for concurrent use without
+every hit fighting over one lock (the BP-Wrapper scheme).
+Hits only take a read lock to peek at the entry and note the
+access in a buffer local to the goroutine's processor; the
+buffered accesses are replayed through the policy in batches
+under the write lock, opportunistically once a buffer is half
+full and unconditionally once it is full.  Writes and misses
+take the write lock.  A hit that is still buffered when its
+key is evicted just isn't counted, the bookkeeping is allowed
+to be a little behind*/
+type Batched struct {
+	mu      sync.RWMutex
+	cache   Cache
+	peeker  Peeker
+	buffers sync.Pool
+}
+
+/*KeyPresent is true if the key is cached right now*/
+func (b *Batched) KeyPresent(k string) bool {
+	if b.peeker != nil {
+		b.mu.RLock()
+		_, ok := b.peeker.Peek(k)
+		b.mu.RUnlock()
+		if ok {
+			return true
+		}
+	}
+	// a miss can change policy state (LECAR's history), so it goes through for real
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.cache.KeyPresent(k)
+}
+
+/*GetValue returns the entry, recording the hit to be
+applied later*/
+func (b *Batched) GetValue(k string) (Entry, error) {
+	if b.peeker == nil {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return b.cache.GetValue(k)
+	}
+	b.mu.RLock()
+	entry, ok := b.peeker.Peek(k)
+	b.mu.RUnlock()
+	if !ok {
+		return Entry{}, ErrKeyNotFound
+	}
+	if entry.idle != 0 {
+		// a sliding deadline can't wait in a buffer, it has to move now
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return b.cache.GetValue(k)
+	}
+	b.record(k)
+	return entry, nil
+}
+
+func (b *Batched) record(k string) {
+	buf := b.buffers.Get().(*accessBuffer)
+	buf.keys[buf.n] = k
+	buf.n++
+	if buf.n == accessBatch {
+		b.mu.Lock()
+		b.apply(buf)
+		b.mu.Unlock()
+	} else if buf.n >= accessBatch/2 && b.mu.TryLock() {
+		b.apply(buf)
+		b.mu.Unlock()
+	}
+	b.buffers.Put(buf)
+}
+
+/*apply replays buffered hits through the policy, the write
+lock must be held*/
+func (b *Batched) apply(buf *accessBuffer) {
+	for i := 0; i < buf.n; i++ {
+		// keys evicted since the hit just miss here
+		b.cache.GetValue(buf.keys[i])
+		buf.keys[i] = ""
+	}
+	buf.n = 0
+}
+
+/*SetValue writes to the wrapped cache*/
+func (b *Batched) SetValue(k string, v Entry) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.cache.SetValue(k, v)
+}
+
+/*Delete removes the key from the wrapped cache*/
+func (b *Batched) Delete(k string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.cache.Delete(k)
+}
+
+/*Export lists the wrapped cache's entries, if it can*/
+func (b *Batched) Export() []Record {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	exporter, ok := b.cache.(Exporter)
+	if !ok {
+		return []Record{}
+	}
+	return exporter.Export()
+}
+
+/*Import puts the record into the wrapped cache*/
+func (b *Batched) Import(r Record) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return ImportRecord(b.cache, r)
+}
+
+/*nextVictim is the entry the policy would evict next, false
+if there is nothing to evict*/
+func (b *Batched) nextVictim() (Record, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	exporter, ok := b.cache.(Exporter)
+	if !ok {
+		return Record{}, false
+	}
+	records := exporter.Export()
+	if len(records) == 0 {
+		return Record{}, false
+	}
+	return records[0], true
+}
+
+/*evict removes the key as an eviction*/
+func (b *Batched) evict(k string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return evictKey(b.cache, k)
+}
+
+/*SweepExpired drops expired entries from the wrapped cache
+under the write lock*/
+func (b *Batched) SweepExpired(limit int) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return sweepExpired(b.cache, limit)
+}
+
+func (b *Batched) retime(k string, ttl time.Duration, shorten bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return retime(b.cache, k, ttl, shorten)
+}
+
+func (b *Batched) startWheel(tick time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return startWheel(b.cache, tick)
+}
+
+func (b *Batched) sweepDue() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return sweepDue(b.cache)
+}
+
+/*Unwrap is the cache being synchronized*/
+func (b *Batched) Unwrap() Cache {
+	return b.cache
+}
+
+/*NewBatched wraps the cache for concurrent use.  Caches
+that can't Peek still work, every hit just takes the lock*/
+func NewBatched(c Cache) *Batched {
+	b := &Batched{cache: c}
+	b.peeker, _ = c.(Peeker)
+	b.buffers.New = func() interface{} { return &accessBuffer{} }
+	return b
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestBatchedConcurrentUse(t *testing.T) {
+	// run with -race
+	for _, policy := range []CacheType{FIFO, LRU, LFU, LCR, LECAR, CALECAR} {
+		c, _ := NewCache(policy, 50)
+		b := NewBatched(c)
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 5000; i++ {
+					key := "k" + strconv.Itoa((i*7+g)%80)
+					if b.KeyPresent(key) {
+						entry, err := b.GetValue(key)
+						if err == nil && entry.Value() != key {
+							t.Errorf("%s: %s holds %q", policy, key, entry.Value())
+						}
+					} else {
+						b.SetValue(key, NewEntry(key, i%13))
+					}
+					if i%97 == 0 {
+						b.Delete(key)
+					}
+				}
+			}(g)
+		}
+		wg.Wait()
+		if len(b.Export()) > 50 {
+			t.Fatalf("%s went over capacity", policy)
+		}
+	}
+}
+
+/*raceEnabled is set by race_test.go*/
+var raceEnabled bool
+
+func TestBatchedAppliesHits(t *testing.T) {
+	if raceEnabled {
+		t.Skip("buffers don't survive the pool under the race detector")
+	}
+	l := newLfu(3)
+	b := NewBatched(l)
+	b.SetValue("a", NewEntry("a", 1))
+	b.SetValue("b", NewEntry("b", 1))
+	for i := 0; i < 4*accessBatch; i++ {
+		b.GetValue("a")
+	}
+	if l.lookup["a"].accessCount < 2*accessBatch {
+		t.Fatalf("buffered hits were never applied, count %d", l.lookup["a"].accessCount)
+	}
+	// the hits decide what goes, just later than they would unbatched
+	b.SetValue("c", NewEntry("c", 1))
+	b.SetValue("d", NewEntry("d", 1))
+	if !b.KeyPresent("a") || b.KeyPresent("b") {
+		t.Fatalf("expected b evicted ahead of the often read a, have %v", b.Export())
+	}
+}
+
+/*opaque hides everything but the Cache methods*/
+type opaque struct {
+	Cache
+}
+
+func TestBatchedWithoutPeeker(t *testing.T) {
+	lru, _ := NewCache(LRU, 2)
+	b := NewBatched(opaque{lru})
+	b.SetValue("a", NewEntry("a", 1))
+	b.SetValue("b", NewEntry("b", 1))
+	if entry, err := b.GetValue("a"); err != nil || entry.Value() != "a" {
+		t.Fatalf("got %+v %v", entry, err)
+	}
+	// without Peek every hit goes straight through
+	b.SetValue("c", NewEntry("c", 1))
+	if !b.KeyPresent("a") || b.KeyPresent("b") {
+		t.Fatal("expected the read to count right away")
+	}
+}
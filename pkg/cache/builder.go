@@ -0,0 +1,126 @@
+package cache
+
+import "time"
+
+/*Builder layers the optional wrappers over a policy in the
+order they have to go, so callers don't need to know it: the
+policy (sharded if asked), then Batched for concurrent use,
+Metered to count what the policy serves, and ReadThrough
+outermost so loads fill through the meter.  Settings are
+checked when Build is called, not as they are made*/
+type Builder struct {
+	cfg          Config
+	synchronized bool
+	metered      bool
+	loader       Loader
+}
+
+/*Policy sets the cache type, LRU unless set*/
+func (b *Builder) Policy(t CacheType) *Builder {
+	b.cfg.Type = t
+	return b
+}
+
+/*Size sets how many entries the cache holds*/
+func (b *Builder) Size(size int) *Builder {
+	b.cfg.Size = size
+	return b
+}
+
+/*Unbounded turns eviction off, see the option of the same
+name*/
+func (b *Builder) Unbounded() *Builder {
+	b.cfg.Unbounded = true
+	return b
+}
+
+/*MaxBytes holds the cache to a budget of bytes, on top of
+its size or instead of one if the size is 0*/
+func (b *Builder) MaxBytes(bytes int64) *Builder {
+	b.cfg.MaxBytes = bytes
+	return b
+}
+
+/*TTL sets the default TTL for entries set without one*/
+func (b *Builder) TTL(ttl time.Duration) *Builder {
+	b.cfg.TTL = ttl
+	return b
+}
+
+/*IdleTTL sets the default idle TTL for entries set without
+a TTL*/
+func (b *Builder) IdleTTL(idle time.Duration) *Builder {
+	b.cfg.IdleTTL = idle
+	return b
+}
+
+/*Shards splits the cache into that many shards, which also
+makes it safe for concurrent use*/
+func (b *Builder) Shards(shards int) *Builder {
+	b.cfg.Shards = shards
+	return b
+}
+
+/*Tuning sets the parameters of LECAR, CALECAR, SLRU or LCR*/
+func (b *Builder) Tuning(t Tuning) *Builder {
+	b.cfg.Tuning = t
+	return b
+}
+
+/*Synchronized makes the cache safe for concurrent use*/
+func (b *Builder) Synchronized() *Builder {
+	b.synchronized = true
+	return b
+}
+
+/*WithMetrics counts hits and misses, read them with StatsOf*/
+func (b *Builder) WithMetrics() *Builder {
+	b.metered = true
+	return b
+}
+
+/*WithLoader fills misses from the loader*/
+func (b *Builder) WithLoader(loader Loader) *Builder {
+	b.loader = loader
+	return b
+}
+
+/*Build validates the settings and puts the cache together*/
+func (b *Builder) Build() (Cache, error) {
+	c, err := NewFromConfig(b.cfg)
+	if err != nil {
+		return nil, err
+	}
+	if b.synchronized && b.cfg.Shards <= 1 {
+		c = NewBatched(c)
+	}
+	if b.metered {
+		c = NewMetered(c)
+	}
+	if b.loader != nil {
+		c = NewReadThrough(c, b.loader)
+	}
+	return c, nil
+}
+
+/*NewBuilder starts a builder for an LRU of 1000 entries*/
+func NewBuilder() *Builder {
+	return &Builder{cfg: Config{Type: LRU, Size: 1000}}
+}
+
+/*StatsOf reads the counters of the Metered cache in the
+chain, false if nothing is being metered*/
+func StatsOf(c Cache) (Stats, bool) {
+	for c != nil {
+		metered, ok := c.(*Metered)
+		if ok {
+			return metered.Stats(), true
+		}
+		wrapper, ok := c.(unwrapper)
+		if !ok {
+			return Stats{}, false
+		}
+		c = wrapper.Unwrap()
+	}
+	return Stats{}, false
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBuilderLayersWrappers(t *testing.T) {
+	loads := 0
+	c, err := NewBuilder().Policy(LCR).Size(10).TTL(time.Minute).Synchronized().WithMetrics().
+		WithLoader(LoaderFunc(func(key string) (Entry, error) {
+			loads++
+			return NewEntry("loaded "+key, 5), nil
+		})).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		entry, err := c.GetValue("a")
+		if err != nil || entry.Value() != "loaded a" {
+			t.Fatalf("expected the loaded value, got %q, %v", entry.Value(), err)
+		}
+	}
+	stats, ok := StatsOf(c)
+	if !ok || loads != 1 || stats.Hits != 2 || stats.Misses != 1 || stats.CostMissed != 5 {
+		t.Fatalf("expected one load and two hits, got %d loads and %+v", loads, stats)
+	}
+	rt := c.(*ReadThrough)
+	policy := rt.Unwrap().(*Metered).Unwrap().(*Batched).Unwrap()
+	if _, ok := policy.(*Lcr); !ok {
+		t.Fatalf("expected an LCR at the bottom, got %T", policy)
+	}
+	entry, _ := policy.(Peeker).Peek("a")
+	if entry.Expires().IsZero() {
+		t.Fatalf("the default TTL didn't reach the policy")
+	}
+}
+
+func TestBuilderValidates(t *testing.T) {
+	if _, err := NewBuilder().Size(0).Build(); err == nil {
+		t.Fatalf("built an LRU of size 0")
+	}
+	if _, err := NewBuilder().Policy(NONE).Size(0).TTL(time.Second).Build(); err == nil {
+		t.Fatalf("built a NONE cache with a TTL")
+	}
+	if _, ok := StatsOf(NewBatched(newLru(1))); ok {
+		t.Fatalf("found stats on an unmetered cache")
+	}
+}
+
+func TestBuilderShardedIsConcurrent(t *testing.T) {
+	c, err := NewBuilder().Size(100).Shards(4).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := string(rune('a' + (i+g)%26))
+				c.SetValue(key, NewEntry(key, 1))
+				c.GetValue(key)
+			}
+		}(g)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,203 @@
+package cache
+
+import "fmt"
+
+/*Sizer says how many bytes an entry takes up*/
+type Sizer interface {
+	Size(key string, entry Entry) int64
+}
+
+/*SizerFunc lets a plain function be used as a Sizer*/
+type SizerFunc func(key string, entry Entry) int64
+
+/*Size calls the function*/
+func (f SizerFunc) Size(key string, entry Entry) int64 { return f(key, entry) }
+
+/*KeyValueSizer counts the key and value lengths, what the
+data takes without the bookkeeping around it*/
+var KeyValueSizer Sizer = SizerFunc(entryBytes)
+
+/*victimPeeker is every policy that can name its next victim
+without listing everything it holds*/
+type victimPeeker interface {
+	nextEvicted() (string, bool)
+}
+
+/*nextEvicted is the key the policy underneath the chain would
+evict next, from an Export for policies that can't say
+directly (LECAR and CALECAR, which pick by weight)*/
+func nextEvicted(c Cache) (string, bool) {
+	for next := c; next != nil; {
+		policy, ok := next.(victimPeeker)
+		if ok {
+			return policy.nextEvicted()
+		}
+		wrapper, ok := next.(unwrapper)
+		if !ok {
+			break
+		}
+		next = wrapper.Unwrap()
+	}
+	exporter, ok := c.(Exporter)
+	if !ok {
+		return "", false
+	}
+	records := exporter.Export()
+	if len(records) == 0 {
+		return "", false
+	}
+	return records[0].Key, true
+}
+
+/*ByteBounded caps a cache by the bytes its entries take (as
+the Sizer counts them) rather than how many there are: a
+write that takes it over budget is followed by evictions, in
+the policy's order, until it fits again.  The policy is best
+built Unbounded so the bytes are the only bound, and wrapped
+directly since evictions reach past anything in between.
+Like Indexed it holds its lock around every call, and the
+wrapped cache has to report its removals*/
+type ByteBounded struct {
+	lockedInner
+	sizer  Sizer
+	budget int64
+	used   int64
+}
+
+/*KeyPresent is true if the key is cached right now*/
+func (bb *ByteBounded) KeyPresent(k string) bool {
+	bb.mu.Lock()
+	defer bb.mu.Unlock()
+	return bb.cache.KeyPresent(k)
+}
+
+/*GetValue reads from the wrapped cache*/
+func (bb *ByteBounded) GetValue(k string) (Entry, error) {
+	bb.mu.Lock()
+	defer bb.mu.Unlock()
+	return bb.cache.GetValue(k)
+}
+
+/*SetValue writes the entry and evicts until the cache fits
+its budget again, sparing the entry just written.  An entry
+bigger than the whole budget is refused with ErrEntryTooLarge*/
+func (bb *ByteBounded) SetValue(k string, v Entry) error {
+	bb.mu.Lock()
+	defer bb.mu.Unlock()
+	size := bb.sizer.Size(k, v)
+	if size > bb.budget {
+		return fmt.Errorf("%w, %s takes %d bytes of a %d byte budget", ErrEntryTooLarge, k, size, bb.budget)
+	}
+	err := bb.cache.SetValue(k, v)
+	if err != nil {
+		return err
+	}
+	bb.used += size
+	bb.fit(k)
+	return nil
+}
+
+/*fit evicts until the entries fit the budget, sparing one
+key, the lock must be held*/
+func (bb *ByteBounded) fit(spare string) {
+	for bb.used > bb.budget {
+		victim, ok := nextEvicted(bb.cache)
+		if !ok || victim == spare {
+			// the policy wants the spared entry gone first, take the next one instead
+			victim, ok = bb.secondVictim(spare)
+		}
+		if !ok || evictKey(bb.cache, victim) != nil {
+			return
+		}
+	}
+}
+
+/*secondVictim is the next victim other than the key, from an
+Export*/
+func (bb *ByteBounded) secondVictim(k string) (string, bool) {
+	exporter, ok := bb.cache.(Exporter)
+	if !ok {
+		return "", false
+	}
+	for _, record := range exporter.Export() {
+		if record.Key != k {
+			return record.Key, true
+		}
+	}
+	return "", false
+}
+
+/*Delete removes the key from the wrapped cache*/
+func (bb *ByteBounded) Delete(k string) error {
+	bb.mu.Lock()
+	defer bb.mu.Unlock()
+	return bb.cache.Delete(k)
+}
+
+/*SetBudget changes the budget, evicting straight away if the
+entries no longer fit*/
+func (bb *ByteBounded) SetBudget(budget int64) {
+	bb.mu.Lock()
+	defer bb.mu.Unlock()
+	bb.budget = budget
+	bb.fit("")
+}
+
+/*Budget is how many bytes the entries may take*/
+func (bb *ByteBounded) Budget() int64 {
+	bb.mu.Lock()
+	defer bb.mu.Unlock()
+	return bb.budget
+}
+
+/*Used is how many bytes the entries take right now*/
+func (bb *ByteBounded) Used() int64 {
+	bb.mu.Lock()
+	defer bb.mu.Unlock()
+	return bb.used
+}
+
+func (bb *ByteBounded) onRemoval(key string, entry Entry, reason RemovalReason) {
+	// called from inside the wrapped cache, so the lock is already held
+	bb.used -= bb.sizer.Size(key, entry)
+}
+
+/*Export lists the wrapped cache's entries, if it can*/
+func (bb *ByteBounded) Export() []Record {
+	bb.mu.Lock()
+	defer bb.mu.Unlock()
+	exporter, ok := bb.cache.(Exporter)
+	if !ok {
+		return []Record{}
+	}
+	return exporter.Export()
+}
+
+/*Import puts the record into the wrapped cache and counts
+its bytes, evicting nothing (a snapshot is trusted to have
+fit)*/
+func (bb *ByteBounded) Import(r Record) error {
+	bb.mu.Lock()
+	defer bb.mu.Unlock()
+	err := ImportRecord(bb.cache, r)
+	if err == nil && bb.cache.KeyPresent(r.Key) {
+		bb.used += bb.sizer.Size(r.Key, r.Entry)
+	}
+	return err
+}
+
+/*Unwrap is the cache being bounded*/
+func (bb *ByteBounded) Unwrap() Cache {
+	return bb.cache
+}
+
+/*NewByteBounded caps the cache at budget bytes, counted by
+the sizer (KeyValueSizer if nil)*/
+func NewByteBounded(c Cache, budget int64, sizer Sizer) *ByteBounded {
+	if sizer == nil {
+		sizer = KeyValueSizer
+	}
+	bb := &ByteBounded{lockedInner: lockedInner{cache: c}, sizer: sizer, budget: budget}
+	AddRemovalListener(c, bb.onRemoval)
+	return bb
+}
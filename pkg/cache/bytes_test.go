@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestByteBoundedEvictsUntilItFits(t *testing.T) {
+	lru, _ := NewCache(LRU, 0, Unbounded())
+	c := NewByteBounded(lru, 100, nil)
+	for i := 0; i < 5; i++ {
+		// 2 bytes of key and 18 of value
+		c.SetValue("k"+strconv.Itoa(i), NewEntry(strings.Repeat("x", 18), 1))
+	}
+	c.SetValue("big", NewEntry(strings.Repeat("x", 57), 1))
+	if c.Used() != 100 || c.KeyPresent("k0") || c.KeyPresent("k2") || !c.KeyPresent("k3") {
+		t.Fatalf("expected the three least recently used to make room, using %d of %v", c.Used(), c.Export())
+	}
+	err := c.SetValue("huge", NewEntry(strings.Repeat("x", 100), 1))
+	if !errors.Is(err, ErrCacheFull) || c.KeyPresent("huge") {
+		t.Fatalf("expected an entry over the budget refused, got %v", err)
+	}
+	c.Delete("big")
+	if c.Used() != 40 {
+		t.Fatalf("expected a delete to give its bytes back, using %d", c.Used())
+	}
+}
+
+func TestByteBoundedKeepsTheNewEntry(t *testing.T) {
+	lcr, _ := NewCache(LCR, 0, Unbounded())
+	c := NewByteBounded(lcr, 20, SizerFunc(func(key string, entry Entry) int64 {
+		return int64(len(entry.Value()))
+	}))
+	c.SetValue("a", NewEntry("0123456789", 10))
+	c.SetValue("b", NewEntry("0123456789", 5))
+	// cheapest of all, but the entries already there are what has to go
+	c.SetValue("c", NewEntry("0123456789", 1))
+	if !c.KeyPresent("c") || c.KeyPresent("b") || !c.KeyPresent("a") || c.Used() != 20 {
+		t.Fatalf("expected b to make room for c, have %v", c.Export())
+	}
+}
+
+func TestByteBudgetHoldsForEveryPolicy(t *testing.T) {
+	for _, policy := range allPolicies {
+		c, err := NewFromConfig(Config{Type: policy, MaxBytes: 50})
+		if err != nil {
+			t.Fatalf("%s: %v", policy, err)
+		}
+		for i := 0; i < 100; i++ {
+			k := strconv.Itoa(i % 13)
+			c.GetValue(k)
+			c.SetValue(k, NewEntry(strings.Repeat("x", i%7), i%5))
+		}
+		held := int64(0)
+		for _, record := range c.(Exporter).Export() {
+			held += KeyValueSizer.Size(record.Key, record.Entry)
+		}
+		if used := c.(*ByteBounded).Used(); used != held || used > 50 {
+			t.Fatalf("%s: counted %d bytes, holding %d", policy, used, held)
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package cache
 
 import (
+	"container/heap"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 )
 
@@ -14,6 +16,32 @@ type Cache interface {
 	KeyPresent(key string) bool
 	GetValue(key string) (Entry, error)
 	SetValue(key string, value Entry) error
+	Delete(key string) error
+}
+
+/*miss errors are made once up front, so a miss doesn't
+allocate any more than a hit does.  Check for them with
+errors.Is, every miss is an ErrKeyNotFound*/
+var (
+	// ErrKeyNotFound is behind every miss, whichever cache it comes from
+	ErrKeyNotFound = errors.New("Key not present")
+	// ErrExpired is a miss on an entry that outlived its TTL
+	ErrExpired = fmt.Errorf("%w, expired", ErrKeyNotFound)
+	// ErrCacheFull is a write refused because the cache has no room it is allowed to make
+	ErrCacheFull = errors.New("Cache full")
+	// ErrEntryTooLarge is a write refused because the one entry is bigger than allowed
+	ErrEntryTooLarge = fmt.Errorf("%w, entry too large", ErrCacheFull)
+)
+
+/*GetValueOrDefault is the cached entry, or def when the
+lookup fails for any reason (a miss, or a loader behind the
+cache failing)*/
+func GetValueOrDefault(c Cache, k string, def Entry) Entry {
+	entry, err := c.GetValue(k)
+	if err != nil {
+		return def
+	}
+	return entry
 }
 
 /*NoOp is a dummy implementation.  No keys are ever present,
@@ -25,95 +53,154 @@ func (cno *NoOp) KeyPresent(k string) bool { return false }
 
 /*GetValue will always return an error for the no-op cache*/
 func (cno *NoOp) GetValue(k string) (Entry, error) {
-	return Entry{}, errors.New("Key not present")
+	return Entry{}, ErrKeyNotFound
 }
 
+/*Peek never finds anything in the no-op cache*/
+func (cno *NoOp) Peek(k string) (Entry, bool) { return Entry{}, false }
+
 /*SetValue does nothing in the no-op cache*/
 func (cno *NoOp) SetValue(k string, v Entry) error { return nil }
 
-/*useful for easily tracking the "oldest" added node in the
-cache*/
-type fifoNode struct {
-	key   string
-	entry Entry
-	prev  *fifoNode
-	next  *fifoNode
-}
+/*Delete does nothing in the no-op cache*/
+func (cno *NoOp) Delete(k string) error { return nil }
+
+/*SweepExpired has nothing to drop for the no-op cache*/
+func (cno *NoOp) SweepExpired(limit int) int { return 0 }
+
+/*Export has nothing to list for the no-op cache*/
+func (cno *NoOp) Export() []Record { return []Record{} }
 
 /*FiFo is a First-in-fist-out cache implementation.
 When full, it will always decide to evict the oldest key added.*/
 type FiFo struct {
 	maxSize int
 	length  int
-	head    *fifoNode
-	tail    *fifoNode
-	lookup  map[string]*fifoNode
+	list    indexList
+	removalHooks
+	expiry
 }
 
 /*KeyPresent is true if the key is in the cache right now*/
 func (ff *FiFo) KeyPresent(k string) bool {
-	_, ok := ff.lookup[k]
+	i, ok := ff.list.lookup[k]
+	if ok && ff.list.nodes[i].entry.expired(ff.clock()) {
+		ff.remove(k, Expired)
+		return false
+	}
 	return ok
 }
 
 /*GetValue will return the entry if present in the lookup*/
 func (ff *FiFo) GetValue(k string) (Entry, error) {
-	node, ok := ff.lookup[k]
+	node, _, ok := ff.list.get(k)
 	if !ok {
-		return Entry{}, errors.New("Key not present in lookup hash")
+		return Entry{}, ErrKeyNotFound
+	}
+	if node.entry.expired(ff.clock()) {
+		ff.remove(k, Expired)
+		return Entry{}, ErrExpired
 	}
+	node.entry.touch(ff.clock())
 	return node.entry, nil
 }
 
+/*nextEvicted is the oldest key*/
+func (ff *FiFo) nextEvicted() (string, bool) {
+	if ff.list.head == nilIndex {
+		return "", false
+	}
+	return ff.list.nodes[ff.list.head].key, true
+}
+
+/*full is true if a new key would evict*/
+func (ff *FiFo) full() bool {
+	return ff.length > 0 && ff.length == ff.maxSize
+}
+
+/*Peek returns the entry without counting an access*/
+func (ff *FiFo) Peek(k string) (Entry, bool) {
+	node, _, ok := ff.list.get(k)
+	if !ok || node.entry.expired(ff.clock()) {
+		return Entry{}, false
+	}
+	return node.entry, true
+}
+
+/*resident is the stored entry, to retime in place*/
+func (ff *FiFo) resident(k string) *Entry {
+	node, _, ok := ff.list.get(k)
+	if !ok {
+		return nil
+	}
+	return &node.entry
+}
+
 /*SetValue inserts a new cache entry, evicting one if necessary*/
 func (ff *FiFo) SetValue(k string, v Entry) error {
-	if ff.length == 0 {
-		// create list head/tail
-		node := &fifoNode{entry: v, key: k}
-		ff.head = node
-		ff.tail = node
-		ff.lookup[k] = node
-		ff.length = 1
-		return nil
-	} else if ff.length == ff.maxSize {
-		// evict one entry
-		newNode := &fifoNode{entry: v, key: k}
-		prevHead := ff.head
-		delete(ff.lookup, prevHead.key)
-		newHead := prevHead.next
-		newHead.prev = nil
-		ff.head = newHead
-		prevTail := ff.tail
-		prevTail.next = newNode
-		newNode.prev = prevTail
-		ff.tail = newNode
-		ff.lookup[k] = newNode
-		// length does not change
+	v = ff.stamp(k, v)
+	if _, ok := ff.list.lookup[k]; ok {
+		// replacing an entry, drop the old node first
+		ff.remove(k, Replaced)
+	}
+	if ff.length > 0 && ff.length == ff.maxSize {
+		// evict the oldest entry, length does not change
+		evictedKey, evictedEntry := ff.list.pop(ff.list.head)
+		ff.list.push(k, v)
+		ff.removed(evictedKey, evictedEntry, Evicted)
 		return nil
 	}
-	// just grow the list
-	newNode := &fifoNode{entry: v, key: k}
-	prevTail := ff.tail
-	prevTail.next = newNode
-	newNode.prev = prevTail
-	ff.tail = newNode
-	ff.lookup[k] = newNode
+	ff.list.push(k, v)
 	ff.length = ff.length + 1
 	return nil
 }
 
-func newFifo(size int) *FiFo {
-	lk := make(map[string]*fifoNode)
-	return &FiFo{maxSize: size, length: 0, head: nil, tail: nil, lookup: lk}
+/*Delete removes the entry from the cache if present*/
+func (ff *FiFo) Delete(k string) error {
+	return ff.remove(k, Deleted)
 }
 
-/*useful for easily tracking the "least recently accessed" added node in the
-cache*/
-type lruNode struct {
-	key   string
-	entry Entry
-	prev  *lruNode
-	next  *lruNode
+func (ff *FiFo) remove(k string, reason RemovalReason) error {
+	_, i, ok := ff.list.get(k)
+	if !ok {
+		return ErrKeyNotFound
+	}
+	_, entry := ff.list.pop(i)
+	ff.length--
+	ff.removed(k, entry, reason)
+	return nil
+}
+
+/*SweepExpired checks up to limit entries (map order, so a
+different sample each time) and drops the expired ones*/
+func (ff *FiFo) SweepExpired(limit int) int {
+	expired := []string{}
+	for k, i := range ff.list.lookup {
+		if limit <= 0 {
+			break
+		}
+		limit--
+		if ff.list.nodes[i].entry.expired(ff.clock()) {
+			expired = append(expired, k)
+		}
+	}
+	for _, k := range expired {
+		ff.remove(k, Expired)
+	}
+	return len(expired)
+}
+
+/*Export lists resident entries, oldest first*/
+func (ff *FiFo) Export() []Record {
+	records := []Record{}
+	ff.list.each(func(node *indexNode) {
+		records = append(records, Record{Key: node.key, Entry: node.entry, Hits: 1})
+	})
+	return records
+}
+
+func newFifo(size int) *FiFo {
+	return &FiFo{maxSize: size, length: 0, list: newIndexList(size)}
 }
 
 /*Lru is a cache implementation adapting to access time.
@@ -121,91 +208,133 @@ When full, it will always decide to evict the key touched the longest ago.*/
 type Lru struct {
 	maxSize int
 	length  int
-	head    *lruNode
-	tail    *lruNode
-	lookup  map[string]*lruNode
+	list    indexList
+	removalHooks
+	expiry
 }
 
 /*KeyPresent is true if the key is in the cache right now*/
 func (l *Lru) KeyPresent(k string) bool {
-	_, ok := l.lookup[k]
+	i, ok := l.list.lookup[k]
+	if ok && l.list.nodes[i].entry.expired(l.clock()) {
+		l.remove(k, Expired)
+		return false
+	}
 	return ok
 }
 
 /*GetValue will return the entry if present in the lookup*/
 func (l *Lru) GetValue(k string) (Entry, error) {
-	node, ok := l.lookup[k]
+	node, i, ok := l.list.get(k)
 	if !ok {
-		return Entry{}, errors.New("Key not present in lookup hash")
+		return Entry{}, ErrKeyNotFound
 	}
-	// promote entry to most recently accessed
-	if node == l.tail {
-		// do nothing, it's already most recently accessed
-	} else if node == l.head {
-		// just move head to tail
-		newHead := node.next
-		newHead.prev = nil
-		l.head = newHead
-		prevTail := l.tail
-		node.prev = prevTail
-		prevTail.next = node
-		l.tail = node
-		node.next = nil
-	} else {
-		// in the middle, stitch two nodes together and move to tail
-		oldPrev := node.prev
-		oldNext := node.next
-		oldPrev.next = oldNext
-		oldNext.prev = oldPrev
-		prevTail := l.tail
-		node.prev = prevTail
-		node.next = nil
-		prevTail.next = node
-		l.tail = node
+	if node.entry.expired(l.clock()) {
+		l.remove(k, Expired)
+		return Entry{}, ErrExpired
 	}
+	// promote entry to most recently accessed
+	l.list.moveToTail(i)
+	node.entry.touch(l.clock())
 	return node.entry, nil
 }
 
+/*nextEvicted is the least recently used key*/
+func (l *Lru) nextEvicted() (string, bool) {
+	if l.list.head == nilIndex {
+		return "", false
+	}
+	return l.list.nodes[l.list.head].key, true
+}
+
+/*full is true if a new key would evict*/
+func (l *Lru) full() bool {
+	return l.length > 0 && l.length == l.maxSize
+}
+
+/*Peek returns the entry without promoting it*/
+func (l *Lru) Peek(k string) (Entry, bool) {
+	node, _, ok := l.list.get(k)
+	if !ok || node.entry.expired(l.clock()) {
+		return Entry{}, false
+	}
+	return node.entry, true
+}
+
+/*resident is the stored entry, to retime in place*/
+func (l *Lru) resident(k string) *Entry {
+	node, _, ok := l.list.get(k)
+	if !ok {
+		return nil
+	}
+	return &node.entry
+}
+
 /*SetValue inserts a new cache entry, evicting one if necessary*/
 func (l *Lru) SetValue(k string, v Entry) error {
-	if l.length == 0 {
-		// create list head/tail
-		node := &lruNode{entry: v, key: k}
-		l.head = node
-		l.tail = node
-		l.lookup[k] = node
-		l.length = 1
-		return nil
-	} else if l.length == l.maxSize {
-		// evict one entry
-		newNode := &lruNode{entry: v, key: k}
-		prevHead := l.head
-		delete(l.lookup, prevHead.key)
-		newHead := prevHead.next
-		newHead.prev = nil
-		l.head = newHead
-		prevTail := l.tail
-		prevTail.next = newNode
-		newNode.prev = prevTail
-		l.tail = newNode
-		l.lookup[k] = newNode
-		// length does not change
+	v = l.stamp(k, v)
+	if _, ok := l.list.lookup[k]; ok {
+		// replacing an entry, drop the old node first
+		l.remove(k, Replaced)
+	}
+	if l.length > 0 && l.length == l.maxSize {
+		// evict the least recently used entry, length does not change
+		evictedKey, evictedEntry := l.list.pop(l.list.head)
+		l.list.push(k, v)
+		l.removed(evictedKey, evictedEntry, Evicted)
 		return nil
 	}
-	// just grow the list
-	newNode := &lruNode{entry: v, key: k}
-	prevTail := l.tail
-	prevTail.next = newNode
-	newNode.prev = prevTail
-	l.tail = newNode
-	l.lookup[k] = newNode
+	l.list.push(k, v)
 	l.length = l.length + 1
 	return nil
 }
 
+/*Delete removes the entry from the cache if present*/
+func (l *Lru) Delete(k string) error {
+	return l.remove(k, Deleted)
+}
+
+func (l *Lru) remove(k string, reason RemovalReason) error {
+	_, i, ok := l.list.get(k)
+	if !ok {
+		return ErrKeyNotFound
+	}
+	_, entry := l.list.pop(i)
+	l.length--
+	l.removed(k, entry, reason)
+	return nil
+}
+
+/*SweepExpired checks up to limit entries (map order, so a
+different sample each time) and drops the expired ones*/
+func (l *Lru) SweepExpired(limit int) int {
+	expired := []string{}
+	for k, i := range l.list.lookup {
+		if limit <= 0 {
+			break
+		}
+		limit--
+		if l.list.nodes[i].entry.expired(l.clock()) {
+			expired = append(expired, k)
+		}
+	}
+	for _, k := range expired {
+		l.remove(k, Expired)
+	}
+	return len(expired)
+}
+
+/*Export lists resident entries, least recently used first*/
+func (l *Lru) Export() []Record {
+	records := []Record{}
+	l.list.each(func(node *indexNode) {
+		records = append(records, Record{Key: node.key, Entry: node.entry, Hits: 1})
+	})
+	return records
+}
+
 func newLru(size int) *Lru {
-	lk := make(map[string]*lruNode)
-	return &Lru{maxSize: size, length: 0, head: nil, tail: nil, lookup: lk}
+	return &Lru{maxSize: size, length: 0, list: newIndexList(size)}
 }
 
 /*useful for easily tracking the "least frequently accessed" added node in the
@@ -214,316 +343,624 @@ type lfuNode struct {
 	key         string
 	entry       Entry
 	accessCount int
+	bucket      *lfuBucket
 	prev        *lfuNode
 	next        *lfuNode
 }
 
+/*lfuBucket holds every node with the same access count, in
+the order they reached it.  Buckets are linked in order of
+count, so an access only ever moves a node to the next one*/
+type lfuBucket struct {
+	count int
+	head  *lfuNode
+	tail  *lfuNode
+	prev  *lfuBucket
+	next  *lfuBucket
+}
+
 /*Lfu is a cache implementation adapting to access frequency.
-When full, it will always decide to evict the key touched the least number of times.*/
+When full, it will always decide to evict the key touched the least number of times
+(the oldest of them if there's a tie).  Both accesses and evictions are O(1).*/
 type Lfu struct {
 	maxSize int
 	length  int
-	head    *lfuNode
-	tail    *lfuNode
+	head    *lfuBucket
+	tail    *lfuBucket
+	spare   *lfuBucket
 	lookup  map[string]*lfuNode
 	debug   bool
+	removalHooks
+	expiry
 }
 
 /*KeyPresent is true if the key is in the cache right now*/
 func (l *Lfu) KeyPresent(k string) bool {
-	_, ok := l.lookup[k]
+	node, ok := l.lookup[k]
+	if ok && node.entry.expired(l.clock()) {
+		l.remove(k, Expired)
+		return false
+	}
 	return ok
 }
 
 func (l *Lfu) debugCache() {
 	fmt.Println("CACHE STATE")
 	dbg := ""
-	node := l.head
-	for {
-		dbg = dbg + "->" + node.key + ":" + strconv.Itoa(node.accessCount)
-		node = node.next
-		if node == nil {
-			break
+	for bucket := l.head; bucket != nil; bucket = bucket.next {
+		for node := bucket.head; node != nil; node = node.next {
+			dbg = dbg + "->" + node.key + ":" + strconv.Itoa(node.accessCount)
 		}
 	}
 	fmt.Println(dbg)
 }
 
-func (l *Lfu) reorderList(node *lfuNode) {
-	for {
-		if node.accessCount >= node.next.accessCount {
-			// swap positions
-			if node.prev == nil {
-				// node is currently HEAD
-				newHead := node.next
-				rightHead := newHead.next
-				newHead.prev = nil
-				node.next = rightHead
-				node.prev = newHead
-				newHead.next = node
-				l.head = newHead
-				if rightHead == nil {
-					// node is now tail
-					l.tail = node
-					return
-				}
-				rightHead.prev = node
-			} else {
-				// node in the middle of list
-				leftTail := node.prev
-				swapNode := node.next
-				rightHead := swapNode.next
-				leftTail.next = swapNode
-				swapNode.prev = leftTail
-				swapNode.next = node
-				node.prev = swapNode
-				node.next = rightHead
-				if rightHead == nil {
-					// node is now tail
-					l.tail = node
-					return
-				}
-				rightHead.prev = node
-			}
-		} else {
-			return
-		}
+/*bucketAfter finds the bucket for count right after the
+given one (or at the front of the list for nil), creating it
+if it doesn't exist yet*/
+func (l *Lfu) bucketAfter(after *lfuBucket, count int) *lfuBucket {
+	next := l.head
+	if after != nil {
+		next = after.next
+	}
+	if next != nil && next.count == count {
+		return next
+	}
+	bucket := l.spare
+	if bucket == nil {
+		bucket = &lfuBucket{}
+	} else {
+		l.spare = bucket.next
+	}
+	*bucket = lfuBucket{count: count, prev: after, next: next}
+	if after == nil {
+		l.head = bucket
+	} else {
+		after.next = bucket
+	}
+	if next == nil {
+		l.tail = bucket
+	} else {
+		next.prev = bucket
+	}
+	return bucket
+}
+
+func (l *Lfu) attach(node *lfuNode, bucket *lfuBucket) {
+	node.bucket = bucket
+	node.accessCount = bucket.count
+	node.prev = bucket.tail
+	node.next = nil
+	if bucket.tail == nil {
+		bucket.head = node
+	} else {
+		bucket.tail.next = node
 	}
+	bucket.tail = node
+}
+
+/*detach takes the node out of its bucket, dropping the
+bucket too if that leaves it empty*/
+func (l *Lfu) detach(node *lfuNode) {
+	bucket := node.bucket
+	if node.prev == nil {
+		bucket.head = node.next
+	} else {
+		node.prev.next = node.next
+	}
+	if node.next == nil {
+		bucket.tail = node.prev
+	} else {
+		node.next.prev = node.prev
+	}
+	node.prev = nil
+	node.next = nil
+	node.bucket = nil
+	if bucket.head != nil {
+		return
+	}
+	if bucket.prev == nil {
+		l.head = bucket.next
+	} else {
+		bucket.prev.next = bucket.next
+	}
+	if bucket.next == nil {
+		l.tail = bucket.prev
+	} else {
+		bucket.next.prev = bucket.prev
+	}
+	// keep it for the next new count, so steady state access doesn't allocate
+	*bucket = lfuBucket{next: l.spare}
+	l.spare = bucket
+}
+
+/*moveTo gives the node a higher access count, putting it
+behind everything else already at that count*/
+func (l *Lfu) moveTo(node *lfuNode, count int) {
+	after := node.bucket
+	for after.next != nil && after.next.count < count {
+		after = after.next
+	}
+	// find the target before detaching, the node's bucket may go away
+	target := l.bucketAfter(after, count)
+	l.detach(node)
+	l.attach(node, target)
 }
 
 /*GetValue will return the entry if present in the lookup*/
 func (l *Lfu) GetValue(k string) (Entry, error) {
 	node, ok := l.lookup[k]
 	if !ok {
-		return Entry{}, errors.New("Key not present in lookup hash")
+		return Entry{}, ErrKeyNotFound
 	}
-	node.accessCount++
-	// move node to the right until it is accessed more
-	// than prev and less than next, or until it is the tail
-	if node == l.tail {
-		// do nothing, it's already the most frequently accessed
-	} else {
-		l.reorderList(node)
+	if node.entry.expired(l.clock()) {
+		l.remove(k, Expired)
+		return Entry{}, ErrExpired
 	}
+	l.moveTo(node, node.accessCount+1)
 	if l.debug {
 		l.debugCache()
 	}
+	node.entry.touch(l.clock())
 	return node.entry, nil
 }
 
+/*nextEvicted is the oldest of the least accessed keys*/
+func (l *Lfu) nextEvicted() (string, bool) {
+	if l.head == nil {
+		return "", false
+	}
+	return l.head.head.key, true
+}
+
+/*full is true if a new key would evict*/
+func (l *Lfu) full() bool {
+	return l.length > 0 && l.length == l.maxSize
+}
+
+/*Peek returns the entry without counting an access*/
+func (l *Lfu) Peek(k string) (Entry, bool) {
+	node, ok := l.lookup[k]
+	if !ok || node.entry.expired(l.clock()) {
+		return Entry{}, false
+	}
+	return node.entry, true
+}
+
+/*resident is the stored entry, to retime in place*/
+func (l *Lfu) resident(k string) *Entry {
+	node, ok := l.lookup[k]
+	if !ok {
+		return nil
+	}
+	return &node.entry
+}
+
 /*SetValue inserts a new cache entry, evicting one if necessary*/
 func (l *Lfu) SetValue(k string, v Entry) error {
-	if l.length == 0 {
-		// create list head/tail
-		node := &lfuNode{entry: v, key: k, accessCount: 1}
-		l.head = node
-		l.tail = node
-		l.lookup[k] = node
-		l.length = 1
-		if l.debug {
-			l.debugCache()
-		}
-		return nil
-	} else if l.length == l.maxSize {
-		// evict one entry
-		newNode := &lfuNode{entry: v, key: k, accessCount: 1}
-		prevHead := l.head
-		delete(l.lookup, prevHead.key)
-		newHead := prevHead.next
-		newHead.prev = nil
-		l.head = newNode
-		newNode.next = newHead
-		newHead.prev = newNode
-		l.lookup[k] = newNode
-		l.reorderList(newNode)
-		if l.debug {
-			l.debugCache()
-		}
-		// length does not change
-		return nil
+	v = l.stamp(k, v)
+	if _, ok := l.lookup[k]; ok {
+		// replacing an entry, drop the old node first
+		l.remove(k, Replaced)
 	}
-	// just grow the list
-	newNode := &lfuNode{entry: v, key: k, accessCount: 1}
-	oldHead := l.head
-	newNode.next = oldHead
-	oldHead.prev = newNode
-	l.head = newNode
-	l.lookup[k] = newNode
-	l.reorderList(newNode)
+	var evicted *lfuNode
+	if l.length > 0 && l.length == l.maxSize {
+		// evict the oldest of the least accessed entries
+		evicted = l.head.head
+		l.detach(evicted)
+		delete(l.lookup, evicted.key)
+		l.length--
+	}
+	node := &lfuNode{entry: v, key: k}
+	l.attach(node, l.bucketAfter(nil, 1))
+	l.lookup[k] = node
 	l.length++
 	if l.debug {
 		l.debugCache()
 	}
+	if evicted != nil {
+		l.removed(evicted.key, evicted.entry, Evicted)
+	}
+	return nil
+}
+
+/*Delete removes the entry from the cache if present*/
+func (l *Lfu) Delete(k string) error {
+	return l.remove(k, Deleted)
+}
+
+func (l *Lfu) remove(k string, reason RemovalReason) error {
+	node, ok := l.lookup[k]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	l.detach(node)
+	delete(l.lookup, k)
+	l.length--
+	l.removed(k, node.entry, reason)
+	if l.debug && l.length > 0 {
+		l.debugCache()
+	}
+	return nil
+}
+
+/*SweepExpired checks up to limit entries (map order, so a
+different sample each time) and drops the expired ones*/
+func (l *Lfu) SweepExpired(limit int) int {
+	expired := []string{}
+	for k, node := range l.lookup {
+		if limit <= 0 {
+			break
+		}
+		limit--
+		if node.entry.expired(l.clock()) {
+			expired = append(expired, k)
+		}
+	}
+	for _, k := range expired {
+		l.remove(k, Expired)
+	}
+	return len(expired)
+}
+
+/*Export lists resident entries, least frequently used first*/
+func (l *Lfu) Export() []Record {
+	records := []Record{}
+	for bucket := l.head; bucket != nil; bucket = bucket.next {
+		for node := bucket.head; node != nil; node = node.next {
+			records = append(records, Record{Key: node.key, Entry: node.entry, Hits: node.accessCount})
+		}
+	}
+	return records
+}
+
+/*Import sets the record and restores its access count*/
+func (l *Lfu) Import(r Record) error {
+	err := l.SetValue(r.Key, r.Entry)
+	if err != nil {
+		return err
+	}
+	node := l.lookup[r.Key]
+	if r.Hits > node.accessCount {
+		l.moveTo(node, r.Hits)
+	}
 	return nil
 }
 
 func newLfu(size int) *Lfu {
-	lk := make(map[string]*lfuNode)
+	lk := make(map[string]*lfuNode, capacityHint(size))
 	return &Lfu{maxSize: size, length: 0, head: nil, tail: nil, lookup: lk, debug: false}
 }
 
 /*useful for easily tracking the "least costly to recompute" added node in the
-cache*/
+cache.  rank is the cost plus the cache's age when the node was set or last
+read, seq breaks ties between equal ranks (older entries go first) and index
+is the node's position in the heap*/
 type lcrNode struct {
 	key   string
 	entry Entry
-	prev  *lcrNode
-	next  *lcrNode
+	rank  float64
+	seq   int
+	index int
+}
+
+/*lcrHeap is a min-heap of nodes ordered by cost, for use
+with container/heap*/
+type lcrHeap []*lcrNode
+
+func (h lcrHeap) Len() int { return len(h) }
+
+func (h lcrHeap) Less(i, j int) bool {
+	if h[i].rank != h[j].rank {
+		return h[i].rank < h[j].rank
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h lcrHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lcrHeap) Push(x interface{}) {
+	node := x.(*lcrNode)
+	node.index = len(*h)
+	*h = append(*h, node)
+}
+
+func (h *lcrHeap) Pop() interface{} {
+	old := *h
+	node := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	node.index = -1
+	return node
 }
 
 /*Lcr is a cache implementation adapting to cost of recomputation.
-When full, it will always decide to evict the key with the lowest cost to recompute.*/
+When full, it will always decide to evict the key with the lowest cost to recompute.
+Entries are kept in a min-heap, so inserts and evictions are O(log n).
+With a decay (see Tuning) the costs age: every eviction raises the cache's age
+to decay times the rank evicted, and an entry set or read is ranked by its
+cost plus the age, so costly entries nobody reads are eventually evicted too.*/
 type Lcr struct {
 	maxSize int
 	length  int
-	head    *lcrNode
-	tail    *lcrNode
+	nodes   lcrHeap
+	seq     int
 	lookup  map[string]*lcrNode
+	decay   float64
+	age     float64
 	debug   bool
+	removalHooks
+	expiry
+}
+
+/*aged raises the age for the evicted node*/
+func (l *Lcr) aged(evicted *lcrNode) {
+	if age := l.decay * evicted.rank; age > l.age {
+		l.age = age
+	}
 }
 
 /*KeyPresent is true if the key is in the cache right now*/
 func (l *Lcr) KeyPresent(k string) bool {
-	_, ok := l.lookup[k]
+	node, ok := l.lookup[k]
+	if ok && node.entry.expired(l.clock()) {
+		l.remove(k, Expired)
+		return false
+	}
 	return ok
 }
 
+/*ordered is the resident nodes cheapest first, the order
+they would be evicted in*/
+func (l *Lcr) ordered() []*lcrNode {
+	nodes := make([]*lcrNode, len(l.nodes))
+	copy(nodes, l.nodes)
+	sort.Slice(nodes, func(i, j int) bool { return lcrHeap(nodes).Less(i, j) })
+	return nodes
+}
+
 func (l *Lcr) debugCache() {
 	fmt.Println("CACHE STATE")
 	dbg := ""
-	node := l.head
-	for {
+	for _, node := range l.ordered() {
 		dbg = dbg + "->" + node.key + ":" + strconv.Itoa(node.entry.cost)
-		node = node.next
-		if node == nil {
-			break
-		}
 	}
 	fmt.Println(dbg)
 }
 
-func (l *Lcr) reorderList(node *lcrNode) {
-	for {
-		if node.entry.cost >= node.next.entry.cost {
-			// swap positions
-			if node.prev == nil {
-				// node is currently HEAD
-				newHead := node.next
-				rightHead := newHead.next
-				newHead.prev = nil
-				node.next = rightHead
-				node.prev = newHead
-				newHead.next = node
-				l.head = newHead
-				if rightHead == nil {
-					// node is now tail
-					l.tail = node
-					return
-				}
-				rightHead.prev = node
-			} else {
-				// node in the middle of list
-				leftTail := node.prev
-				swapNode := node.next
-				rightHead := swapNode.next
-				leftTail.next = swapNode
-				swapNode.prev = leftTail
-				swapNode.next = node
-				node.prev = swapNode
-				node.next = rightHead
-				if rightHead == nil {
-					// node is now tail
-					l.tail = node
-					return
-				}
-				rightHead.prev = node
-			}
-		} else {
-			return
-		}
-	}
-}
-
 /*GetValue will return the entry if present in the lookup*/
 func (l *Lcr) GetValue(k string) (Entry, error) {
 	node, ok := l.lookup[k]
 	if !ok {
-		return Entry{}, errors.New("Key not present in lookup hash")
+		return Entry{}, ErrKeyNotFound
+	}
+	if node.entry.expired(l.clock()) {
+		l.remove(k, Expired)
+		return Entry{}, ErrExpired
 	}
 	if l.debug {
 		l.debugCache()
 	}
+	node.entry.touch(l.clock())
+	if l.decay > 0 {
+		node.rank = float64(node.entry.cost) + l.age
+		heap.Fix(&l.nodes, node.index)
+	}
 	return node.entry, nil
 }
 
+/*nextEvicted is the cheapest key*/
+func (l *Lcr) nextEvicted() (string, bool) {
+	if len(l.nodes) == 0 {
+		return "", false
+	}
+	return l.nodes[0].key, true
+}
+
+/*full is true if a new key would evict*/
+func (l *Lcr) full() bool {
+	return l.length > 0 && l.length == l.maxSize
+}
+
+/*Peek returns the entry without counting an access*/
+func (l *Lcr) Peek(k string) (Entry, bool) {
+	node, ok := l.lookup[k]
+	if !ok || node.entry.expired(l.clock()) {
+		return Entry{}, false
+	}
+	return node.entry, true
+}
+
+/*resident is the stored entry, to retime in place*/
+func (l *Lcr) resident(k string) *Entry {
+	node, ok := l.lookup[k]
+	if !ok {
+		return nil
+	}
+	return &node.entry
+}
+
 /*SetValue inserts a new cache entry, evicting one if necessary*/
 func (l *Lcr) SetValue(k string, v Entry) error {
-	if l.length == 0 {
-		// create list head/tail
-		node := &lcrNode{entry: v, key: k}
-		l.head = node
-		l.tail = node
-		l.lookup[k] = node
-		l.length = 1
-		if l.debug {
-			l.debugCache()
-		}
-		return nil
-	} else if l.length == l.maxSize {
-		// evict one entry
-		newNode := &lcrNode{entry: v, key: k}
-		prevHead := l.head
-		delete(l.lookup, prevHead.key)
-		newHead := prevHead.next
-		newHead.prev = nil
-		l.head = newNode
-		newNode.next = newHead
-		newHead.prev = newNode
-		l.lookup[k] = newNode
-		l.reorderList(newNode)
-		if l.debug {
-			l.debugCache()
-		}
-		// length does not change
-		return nil
+	v = l.stamp(k, v)
+	if _, ok := l.lookup[k]; ok {
+		// replacing an entry, drop the old node first
+		l.remove(k, Replaced)
+	}
+	var evicted *lcrNode
+	if l.length > 0 && l.length == l.maxSize {
+		// evict the cheapest entry
+		evicted = heap.Pop(&l.nodes).(*lcrNode)
+		delete(l.lookup, evicted.key)
+		l.length--
+		l.aged(evicted)
 	}
-	// just grow the list
-	newNode := &lcrNode{entry: v, key: k}
-	oldHead := l.head
-	newNode.next = oldHead
-	oldHead.prev = newNode
-	l.head = newNode
-	l.lookup[k] = newNode
-	l.reorderList(newNode)
+	l.seq++
+	node := &lcrNode{entry: v, key: k, rank: float64(v.cost) + l.age, seq: l.seq}
+	heap.Push(&l.nodes, node)
+	l.lookup[k] = node
 	l.length++
 	if l.debug {
 		l.debugCache()
 	}
+	if evicted != nil {
+		l.removed(evicted.key, evicted.entry, Evicted)
+	}
+	return nil
+}
+
+/*Delete removes the entry from the cache if present*/
+func (l *Lcr) Delete(k string) error {
+	return l.remove(k, Deleted)
+}
+
+func (l *Lcr) remove(k string, reason RemovalReason) error {
+	node, ok := l.lookup[k]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	heap.Remove(&l.nodes, node.index)
+	delete(l.lookup, k)
+	l.length--
+	if reason == Evicted {
+		l.aged(node)
+	}
+	l.removed(k, node.entry, reason)
+	if l.debug && l.length > 0 {
+		l.debugCache()
+	}
 	return nil
 }
 
+/*SweepExpired checks up to limit entries (map order, so a
+different sample each time) and drops the expired ones*/
+func (l *Lcr) SweepExpired(limit int) int {
+	expired := []string{}
+	for k, node := range l.lookup {
+		if limit <= 0 {
+			break
+		}
+		limit--
+		if node.entry.expired(l.clock()) {
+			expired = append(expired, k)
+		}
+	}
+	for _, k := range expired {
+		l.remove(k, Expired)
+	}
+	return len(expired)
+}
+
+/*Export lists resident entries, cheapest first*/
+func (l *Lcr) Export() []Record {
+	records := []Record{}
+	for _, node := range l.ordered() {
+		records = append(records, Record{Key: node.key, Entry: node.entry, Hits: 1})
+	}
+	return records
+}
+
 func newLcr(size int) *Lcr {
-	lk := make(map[string]*lcrNode)
-	return &Lcr{maxSize: size, length: 0, head: nil, tail: nil, lookup: lk, debug: false}
+	lk := make(map[string]*lcrNode, capacityHint(size))
+	return &Lcr{maxSize: size, length: 0, nodes: make(lcrHeap, 0, capacityHint(size)), lookup: lk, debug: false}
 }
 
 /*NewCache is a factory for building a cache implementation
-of the requested strategy*/
-func NewCache(cacheType string, size int) (Cache, error) {
-	if cacheType == "NONE" {
+of the requested strategy, adjusted by any options.  A size
+of 0 has no room for anything and builds a NoOp, unless the
+Unbounded option says size doesn't matter*/
+func NewCache(cacheType CacheType, size int, opts ...Option) (Cache, error) {
+	o := collectOptions(opts)
+	if size < 0 {
+		return &NoOp{}, errors.New("Cache size can't be negative")
+	}
+	if o.unbounded {
+		size = unboundedSize
+	} else if size == 0 && cacheType.known() {
 		return &NoOp{}, nil
-	} else if cacheType == "FIFO" {
+	}
+	if ctor := cacheType.ctor(); ctor != nil {
+		return ctor(Config{Type: cacheType, Size: size, Unbounded: o.unbounded, TTL: o.ttl, IdleTTL: o.idle})
+	}
+	c, err := newPolicy(cacheType, size)
+	if err != nil {
+		return c, err
+	}
+	applyOptions(c, o)
+	return c, nil
+}
+
+func newPolicy(cacheType CacheType, size int) (Cache, error) {
+	if cacheType == NONE {
+		return &NoOp{}, nil
+	} else if cacheType == FIFO {
 		return newFifo(size), nil
-	} else if cacheType == "LRU" {
+	} else if cacheType == LRU {
 		return newLru(size), nil
-	} else if cacheType == "LFU" {
+	} else if cacheType == LFU {
 		return newLfu(size), nil
-	} else if cacheType == "LCR" {
+	} else if cacheType == LCR {
 		return newLcr(size), nil
-	} else if cacheType == "LECAR" {
+	} else if cacheType == LCRTTL {
+		return newLcrTtl(size), nil
+	} else if cacheType == LECAR {
 		return newLecar(size), nil
-	} else if cacheType == "CALECAR" {
+	} else if cacheType == CALECAR {
 		return newCalecar(size), nil
+	} else if cacheType == TIERED {
+		return newTiered(size), nil
+	} else if cacheType == SLRU {
+		return newSlru(size), nil
+	}
+	return &NoOp{}, errors.New("No cache exists of type '" + cacheType.String() + "'")
+}
+
+/*Tuning overrides the parameters of the policies that have
+them.  For LECAR and CALECAR LearningRate is how hard a regret
+shifts the weights between experts, Discount how quickly a
+regret fades the longer the evicted key sat in history.
+SegmentRatio is the share of SLRU's size its protected
+segment gets (0.8 by default), and CostDecay (between 0 and 1)
+how fast LCR ages the costs of entries nobody reads, 0 not at
+all.  Zero keeps the default, and fields for other policies
+are ignored*/
+type Tuning struct {
+	LearningRate float64
+	Discount     float64
+	SegmentRatio float64
+	CostDecay    float64
+}
+
+/*NewTunedCache is NewCache with the tuning applied*/
+func NewTunedCache(cacheType CacheType, size int, t Tuning, opts ...Option) (Cache, error) {
+	c, err := NewCache(cacheType, size, opts...)
+	if err != nil {
+		return c, err
+	}
+	if l, ok := c.(*Lecar); ok {
+		if t.LearningRate > 0 {
+			l.lambda = t.LearningRate
+		}
+		if t.Discount > 0 {
+			l.discount = t.Discount
+		}
+	} else if l, ok := c.(*Calecar); ok {
+		if t.LearningRate > 0 {
+			l.lambda = t.LearningRate
+		}
+		if t.Discount > 0 {
+			l.discount = t.Discount
+		}
+	} else if s, ok := c.(*Slru); ok {
+		if t.SegmentRatio > 0 {
+			s.split(t.SegmentRatio)
+		}
+	} else if l, ok := c.(*Lcr); ok {
+		l.decay = t.CostDecay
 	}
-	return &NoOp{}, errors.New("No cache exists of type '" + cacheType + "'")
+	return c, nil
 }
@@ -0,0 +1,356 @@
+package cache
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*replay runs ops like "set a", "set a 5" (with a cost),
+"get a" and "del a" against the cache, returning the keys it
+evicted in order*/
+func replay(t *testing.T, c Cache, ops string) []string {
+	evicted := []string{}
+	AddRemovalListener(c, func(key string, entry Entry, reason RemovalReason) {
+		if reason == Evicted {
+			evicted = append(evicted, key)
+		}
+	})
+	for _, op := range strings.Split(ops, ",") {
+		fields := strings.Fields(op)
+		if fields[0] == "set" {
+			cost := 0
+			if len(fields) > 2 {
+				cost, _ = strconv.Atoi(fields[2])
+			}
+			c.SetValue(fields[1], NewEntry(fields[1], cost))
+		} else if fields[0] == "get" {
+			c.GetValue(fields[1])
+		} else if fields[0] == "del" {
+			c.Delete(fields[1])
+		} else {
+			t.Fatalf("bad op %q", op)
+		}
+	}
+	return evicted
+}
+
+func exportedKeys(c Cache) string {
+	keys := []string{}
+	for _, record := range c.(Exporter).Export() {
+		keys = append(keys, record.Key)
+	}
+	return strings.Join(keys, " ")
+}
+
+func TestLfuEvictionOrder(t *testing.T) {
+	cases := []struct {
+		ops     string
+		evicted string
+		left    string
+	}{
+		// least used goes first
+		{"set a,set b,set c,get a,get a,get b,set d", "c", "d b a"},
+		// ties go to whichever reached the count first
+		{"set a,set b,set c,set d", "a", "b c d"},
+		{"set a,set b,set c,get a,get b,get c,set d", "a", "d b c"},
+		// a replaced entry starts counting again, as the newest
+		{"set a,set b,set c,get a,get a,set a,set d", "b", "c a d"},
+		{"set a,set b,set c,del b,set d,set e", "a", "c d e"},
+	}
+	for _, tc := range cases {
+		l := newLfu(3)
+		evicted := strings.Join(replay(t, l, tc.ops), " ")
+		if evicted != tc.evicted || exportedKeys(l) != tc.left {
+			t.Fatalf("%s: evicted %q leaving %q, expected %q leaving %q", tc.ops, evicted, exportedKeys(l), tc.evicted, tc.left)
+		}
+	}
+}
+
+/*lfuModel is the list LFU the bucket version replaced: least
+accessed first, and among equal counts whichever got there
+first*/
+type lfuModel struct {
+	counts map[string]int
+	at     map[string]int
+}
+
+func (m *lfuModel) order() []string {
+	keys := []string{}
+	for key := range m.counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if m.counts[keys[i]] != m.counts[keys[j]] {
+			return m.counts[keys[i]] < m.counts[keys[j]]
+		}
+		return m.at[keys[i]] < m.at[keys[j]]
+	})
+	return keys
+}
+
+func TestLfuMatchesListOrder(t *testing.T) {
+	size := 15
+	l := newLfu(size)
+	evicted := []string{}
+	AddRemovalListener(l, func(key string, entry Entry, reason RemovalReason) {
+		if reason == Evicted {
+			evicted = append(evicted, key)
+		}
+	})
+	model := &lfuModel{counts: map[string]int{}, at: map[string]int{}}
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 20000; i++ {
+		key := "k" + strconv.Itoa(rng.Intn(40))
+		_, resident := model.counts[key]
+		var victim string
+		switch rng.Intn(5) {
+		case 0, 1:
+			if resident {
+				l.GetValue(key)
+				model.counts[key]++
+				model.at[key] = i
+			}
+		case 2, 3:
+			if !resident && len(model.counts) == size {
+				victim = model.order()[0]
+				delete(model.counts, victim)
+			}
+			l.SetValue(key, NewEntry(key, 1))
+			model.counts[key] = 1
+			model.at[key] = i
+		case 4:
+			l.Delete(key)
+			delete(model.counts, key)
+		}
+		if victim != "" && (len(evicted) == 0 || evicted[len(evicted)-1] != victim) {
+			t.Fatalf("step %d: expected %s evicted, evictions so far %v", i, victim, evicted)
+		}
+		records := l.Export()
+		expected := model.order()
+		if len(records) != len(expected) {
+			t.Fatalf("step %d: %d entries, expected %d", i, len(records), len(expected))
+		}
+		for j, record := range records {
+			if record.Key != expected[j] || record.Hits != model.counts[record.Key] {
+				t.Fatalf("step %d: order %v, expected %v", i, records, expected)
+			}
+		}
+	}
+}
+
+func TestLcrEvictionOrder(t *testing.T) {
+	cases := []struct {
+		ops     string
+		evicted string
+		left    string
+	}{
+		// cheapest goes first, however often it's read
+		{"set a 5,set b 1,set c 9,get b,get b,set d 7", "b", "a d c"},
+		// equal costs go oldest first
+		{"set a 2,set b 2,set c 2,set d 2", "a", "b c d"},
+		// a replaced entry is as new as any other insert
+		{"set a 2,set b 2,set c 2,set a 2,set d 3", "b", "c a d"},
+		// the newcomer itself can be the cheapest, it still gets in
+		{"set a 5,set b 6,set c 7,set d 1,set e 2", "a d", "e b c"},
+		{"set a 5,set b 6,set c 7,del a,set d 1,set e 8", "d", "b c e"},
+	}
+	for _, tc := range cases {
+		l := newLcr(3)
+		evicted := strings.Join(replay(t, l, tc.ops), " ")
+		if evicted != tc.evicted || exportedKeys(l) != tc.left {
+			t.Fatalf("%s: evicted %q leaving %q, expected %q leaving %q", tc.ops, evicted, exportedKeys(l), tc.evicted, tc.left)
+		}
+	}
+}
+
+func TestLcrCostDecay(t *testing.T) {
+	cases := []struct {
+		decay   float64
+		ops     string
+		evicted string
+		left    string
+	}{
+		// no decay, the costly entry stays however long it goes unread
+		{0, "set a 30,set b 10,set c 10,set d 10,set e 10", "b c d", "e a"},
+		// each eviction ages the cache, newcomers outrank a costly entry nobody reads
+		{1, "set a 30,set b 10,set c 10,set d 10,set e 10", "b c a", "d e"},
+		// a read ranks it by the age it was read at
+		{1, "set a 30,set b 10,set c 10,get a,set d 10,set e 10", "b c d", "a e"},
+		// half the decay ages too slowly to catch up with a in three evictions
+		{0.5, "set a 30,set b 10,set c 10,set d 10,set e 10", "b c d", "e a"},
+	}
+	for _, tc := range cases {
+		c, _ := NewTunedCache(LCR, 2, Tuning{CostDecay: tc.decay})
+		l := c.(*Lcr)
+		evicted := strings.Join(replay(t, l, tc.ops), " ")
+		if evicted != tc.evicted || exportedKeys(l) != tc.left {
+			t.Fatalf("decay %g, %s: evicted %q leaving %q, expected %q leaving %q", tc.decay, tc.ops, evicted, exportedKeys(l), tc.evicted, tc.left)
+		}
+		if err := l.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLcrMatchesListOrder(t *testing.T) {
+	size := 12
+	l := newLcr(size)
+	evicted := []string{}
+	AddRemovalListener(l, func(key string, entry Entry, reason RemovalReason) {
+		if reason == Evicted {
+			evicted = append(evicted, key)
+		}
+	})
+	costs := map[string]int{}
+	at := map[string]int{}
+	order := func() []string {
+		keys := []string{}
+		for key := range costs {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if costs[keys[i]] != costs[keys[j]] {
+				return costs[keys[i]] < costs[keys[j]]
+			}
+			return at[keys[i]] < at[keys[j]]
+		})
+		return keys
+	}
+	rng := rand.New(rand.NewSource(3))
+	for i := 0; i < 20000; i++ {
+		key := "k" + strconv.Itoa(rng.Intn(40))
+		if rng.Intn(4) == 0 {
+			l.Delete(key)
+			delete(costs, key)
+			continue
+		}
+		victim := ""
+		delete(costs, key)
+		if len(costs) == size {
+			victim = order()[0]
+			delete(costs, victim)
+		}
+		cost := rng.Intn(10)
+		l.SetValue(key, NewEntry(key, cost))
+		costs[key] = cost
+		at[key] = i
+		if victim != "" && evicted[len(evicted)-1] != victim {
+			t.Fatalf("step %d: expected %s evicted, evictions so far %v", i, victim, evicted)
+		}
+		records := l.Export()
+		for j, key := range order() {
+			if records[j].Key != key {
+				t.Fatalf("step %d: order %v, expected %v", i, records, order())
+			}
+		}
+	}
+}
+
+func TestLruAndFifoEvictionOrder(t *testing.T) {
+	cases := []struct {
+		policy  CacheType
+		ops     string
+		evicted string
+		left    string
+	}{
+		{LRU, "set a,set b,set c,get a,set d", "b", "c a d"},
+		{FIFO, "set a,set b,set c,get a,set d", "a", "b c d"},
+		{LRU, "set a,set b,set c,set a,set d", "b", "c a d"},
+		{FIFO, "set a,set b,set c,set a,set d", "b", "c a d"},
+		{LRU, "set a,set b,set c,del a,set d,set e,set f", "b c", "d e f"},
+		{FIFO, "set a,set b,set c,del b,get a,set d,set e", "a", "c d e"},
+	}
+	for _, tc := range cases {
+		c, _ := NewCache(tc.policy, 3)
+		evicted := strings.Join(replay(t, c, tc.ops), " ")
+		if evicted != tc.evicted || exportedKeys(c) != tc.left {
+			t.Fatalf("%s %s: evicted %q leaving %q, expected %q leaving %q", tc.policy, tc.ops, evicted, exportedKeys(c), tc.evicted, tc.left)
+		}
+	}
+}
+
+func TestLruAndFifoReuseSlots(t *testing.T) {
+	for _, policy := range []CacheType{LRU, FIFO} {
+		c, _ := NewCache(policy, 10)
+		// the model is the expected Export order, oldest first
+		order := []string{}
+		without := func(key string) {
+			for i, k := range order {
+				if k == key {
+					order = append(order[:i:i], order[i+1:]...)
+					return
+				}
+			}
+		}
+		rng := rand.New(rand.NewSource(4))
+		for i := 0; i < 20000; i++ {
+			key := "k" + strconv.Itoa(rng.Intn(30))
+			resident := c.KeyPresent(key)
+			switch rng.Intn(3) {
+			case 0:
+				if resident {
+					c.GetValue(key)
+					if policy == LRU {
+						without(key)
+						order = append(order, key)
+					}
+				}
+			case 1:
+				without(key)
+				if len(order) == 10 {
+					order = order[1:]
+				}
+				c.SetValue(key, NewEntry(key, 1))
+				order = append(order, key)
+			case 2:
+				without(key)
+				c.Delete(key)
+			}
+			if got := exportedKeys(c); got != strings.Join(order, " ") {
+				t.Fatalf("%s step %d: %q, expected %q", policy, i, got, strings.Join(order, " "))
+			}
+		}
+	}
+}
+
+func TestMissErrors(t *testing.T) {
+	for _, policy := range allPolicies {
+		c, _ := NewCache(policy, 4)
+		c.SetValue("short", NewEntry("1", 5).WithTTL(time.Millisecond))
+		time.Sleep(2 * time.Millisecond)
+		for _, wrapped := range []Cache{c, NewBatched(c), Chain(c), NewHashed(c, CheckKey)} {
+			if _, err := wrapped.GetValue("missing"); !errors.Is(err, ErrKeyNotFound) {
+				t.Fatalf("%s %T: expected ErrKeyNotFound, got %v", policy, wrapped, err)
+			}
+		}
+		if _, err := c.GetValue("short"); !errors.Is(err, ErrExpired) || !errors.Is(err, ErrKeyNotFound) {
+			t.Fatalf("%s: expected ErrExpired, got %v", policy, err)
+		}
+		if err := c.Delete("missing"); !errors.Is(err, ErrKeyNotFound) {
+			t.Fatalf("%s: expected deleting a missing key to be ErrKeyNotFound, got %v", policy, err)
+		}
+	}
+}
+
+func TestGetValueOrDefault(t *testing.T) {
+	c, _ := NewCache(LRU, 4)
+	c.SetValue("a", NewEntry("cached", 5))
+	fallback := NewEntry("fallback", 0)
+	if entry := GetValueOrDefault(c, "a", fallback); entry.Value() != "cached" || entry.Cost() != 5 {
+		t.Fatalf("expected the cached entry, got %q", entry.Value())
+	}
+	if entry := GetValueOrDefault(c, "b", fallback); entry.Value() != "fallback" {
+		t.Fatalf("expected the default on a miss, got %q", entry.Value())
+	}
+	failing := NewReadThrough(c, LoaderFunc(func(key string) (Entry, error) {
+		return Entry{}, errors.New("Backend down")
+	}))
+	if entry := GetValueOrDefault(failing, "c", fallback); entry.Value() != "fallback" {
+		t.Fatalf("expected the default when the load fails, got %q", entry.Value())
+	}
+}
@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*CacheType picks the policy NewCache builds*/
+type CacheType int
+
+const (
+	/*NONE caches nothing*/
+	NONE CacheType = iota
+	/*FIFO evicts the oldest key added*/
+	FIFO
+	/*LRU evicts the least recently used key*/
+	LRU
+	/*LFU evicts the least frequently used key*/
+	LFU
+	/*LCR evicts the key cheapest to recompute*/
+	LCR
+	/*LCRTTL is LCR weighing cost by the life an entry has left*/
+	LCRTTL
+	/*LECAR learns a mix of LRU and LFU*/
+	LECAR
+	/*CALECAR learns a mix of LRU, LFU and LCR*/
+	CALECAR
+	/*TIERED is a small LRU in front of a big LCR, a Tiered*/
+	TIERED
+	/*SLRU is a segmented LRU, keys read again protected from
+	ones read once*/
+	SLRU
+)
+
+/*cacheTypes is every type NewCache can build, the built in
+ones first and then whatever RegisterCacheType added*/
+var cacheTypes = struct {
+	sync.RWMutex
+	names []string
+	ctors map[CacheType]func(Config) (Cache, error)
+}{
+	names: []string{"NONE", "FIFO", "LRU", "LFU", "LCR", "LCRTTL", "LECAR", "CALECAR", "TIERED", "SLRU"},
+	ctors: make(map[CacheType]func(Config) (Cache, error)),
+}
+
+func (t CacheType) known() bool {
+	cacheTypes.RLock()
+	defer cacheTypes.RUnlock()
+	return t >= 0 && int(t) < len(cacheTypes.names)
+}
+
+/*ctor is the constructor a registered type was given, nil
+for the built in types*/
+func (t CacheType) ctor() func(Config) (Cache, error) {
+	cacheTypes.RLock()
+	defer cacheTypes.RUnlock()
+	return cacheTypes.ctors[t]
+}
+
+/*String is the name ParseCacheType reads*/
+func (t CacheType) String() string {
+	if !t.known() {
+		return "CacheType(" + strconv.Itoa(int(t)) + ")"
+	}
+	cacheTypes.RLock()
+	defer cacheTypes.RUnlock()
+	return cacheTypes.names[t]
+}
+
+/*RegisterCacheType adds an eviction policy from outside the
+package, so NewCache, NewFromConfig and config files can
+build it like the built in ones.  The constructor gets the
+config with the size and default TTLs filled in (the default
+TTLs are the policy's to honor).  Names are case insensitive
+and can't be taken twice*/
+func RegisterCacheType(name string, ctor func(Config) (Cache, error)) (CacheType, error) {
+	clean := strings.ToUpper(strings.TrimSpace(name))
+	if clean == "" {
+		return NONE, errors.New("A cache type needs a name")
+	}
+	if ctor == nil {
+		return NONE, errors.New("Cache type " + clean + " needs a constructor")
+	}
+	cacheTypes.Lock()
+	defer cacheTypes.Unlock()
+	for _, typeName := range cacheTypes.names {
+		if typeName == clean {
+			return NONE, errors.New("Cache type " + clean + " is already registered")
+		}
+	}
+	t := CacheType(len(cacheTypes.names))
+	cacheTypes.names = append(cacheTypes.names, clean)
+	cacheTypes.ctors[t] = ctor
+	return t, nil
+}
+
+/*ParseCacheType reads a policy name from a flag or config
+file, ignoring case and surrounding space*/
+func ParseCacheType(name string) (CacheType, error) {
+	clean := strings.ToUpper(strings.TrimSpace(name))
+	cacheTypes.RLock()
+	defer cacheTypes.RUnlock()
+	for i, typeName := range cacheTypes.names {
+		if typeName == clean {
+			return CacheType(i), nil
+		}
+	}
+	return NONE, errors.New("No cache exists of type '" + name + "'")
+}
+
+/*MarshalText writes the type by name, so json and config
+files read "LRU" rather than a number*/
+func (t CacheType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+/*UnmarshalText reads a name the way ParseCacheType does*/
+func (t *CacheType) UnmarshalText(text []byte) error {
+	parsed, err := ParseCacheType(string(text))
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCacheType(t *testing.T) {
+	for _, cacheType := range append([]CacheType{NONE}, allPolicies...) {
+		parsed, err := ParseCacheType(cacheType.String())
+		if err != nil || parsed != cacheType {
+			t.Fatalf("%s parsed as %s, %v", cacheType, parsed, err)
+		}
+	}
+	if parsed, err := ParseCacheType(" lcr\n"); err != nil || parsed != LCR {
+		t.Fatalf("expected case and space not to matter, got %s, %v", parsed, err)
+	}
+	if _, err := ParseCacheType("LUR"); err == nil {
+		t.Fatalf("parsed a typo")
+	}
+}
+
+func TestCacheTypeJSON(t *testing.T) {
+	var config struct {
+		Type CacheType `json:"type"`
+	}
+	err := json.Unmarshal([]byte(`{"type": "calecar"}`), &config)
+	if err != nil || config.Type != CALECAR {
+		t.Fatalf("expected CALECAR, got %s, %v", config.Type, err)
+	}
+	encoded, _ := json.Marshal(config)
+	if string(encoded) != `{"type":"CALECAR"}` {
+		t.Fatalf("encoded as %s", encoded)
+	}
+	if json.Unmarshal([]byte(`{"type": "LUR"}`), &config) == nil {
+		t.Fatalf("decoded a typo")
+	}
+}
+
+func TestRegisterCacheType(t *testing.T) {
+	// the registry is process wide, so the name has to be new on every run
+	name := "custom" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	built := []Config{}
+	custom, err := RegisterCacheType(name, func(cfg Config) (Cache, error) {
+		built = append(built, cfg)
+		return NewCache(FIFO, cfg.Size, WithDefaultTTL(cfg.TTL))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if custom.String() != strings.ToUpper(name) {
+		t.Fatalf("registered as %s", custom)
+	}
+	parsed, err := ParseCacheType(name)
+	if err != nil || parsed != custom {
+		t.Fatalf("expected %s to parse, got %s, %v", name, parsed, err)
+	}
+	c, err := NewFromConfig(Config{Type: parsed, Size: 8, TTL: time.Minute, Shards: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetValue("a", NewEntry("1", 1))
+	if !c.KeyPresent("a") || len(built) != 2 || built[0].Size != 4 || built[0].TTL != time.Minute {
+		t.Fatalf("expected two shards of 4 built with the ttl, got %+v", built)
+	}
+	if _, err := RegisterCacheType(strings.ToUpper(name), func(cfg Config) (Cache, error) { return nil, nil }); err == nil {
+		t.Fatalf("registered %s twice", name)
+	}
+	if _, err := RegisterCacheType("lru", func(cfg Config) (Cache, error) { return nil, nil }); err == nil {
+		t.Fatalf("replaced a built in type")
+	}
+}
@@ -1,7 +1,6 @@
 package cache
 
 import (
-	"errors"
 	"math"
 	"math/rand"
 )
@@ -63,6 +62,8 @@ type Calecar struct {
 	historyTail   *calecarHistoryNode
 	lambda        float64
 	discount      float64
+	removalHooks
+	expiry
 }
 
 func (c *Calecar) updateAlgoWeights(node *calecarHistoryNode) {
@@ -99,7 +100,12 @@ func (c *Calecar) updateAlgoWeights(node *calecarHistoryNode) {
 
 /*KeyPresent is true if the key is in the cache right now*/
 func (c *Calecar) KeyPresent(k string) bool {
-	_, ok := c.lookup[k]
+	lookupNode, ok := c.lookup[k]
+	if ok && lookupNode.entry.expired(c.clock()) {
+		// expired, not evicted, so no policy is to blame
+		c.remove(k, Expired)
+		return false
+	}
 	if !ok {
 		// key not in cache, check history and penalize if present
 		historyNode, hOk := c.historyLookup[k]
@@ -115,7 +121,11 @@ func (c *Calecar) KeyPresent(k string) bool {
 func (c *Calecar) GetValue(k string) (Entry, error) {
 	lookupNode, ok := c.lookup[k]
 	if !ok {
-		return Entry{}, errors.New("Key not present in lookup hash")
+		return Entry{}, ErrKeyNotFound
+	}
+	if lookupNode.entry.expired(c.clock()) {
+		c.remove(k, Expired)
+		return Entry{}, ErrExpired
 	}
 	lruNode := lookupNode.lruNode
 	// LRU: promote entry to most recently accessed
@@ -154,9 +164,44 @@ func (c *Calecar) GetValue(k string) (Entry, error) {
 		c.reorderLfuList(lfuNode)
 	}
 	// LCR: Do nothing; access does not change cost
+	lookupNode.entry.touch(c.clock())
 	return lookupNode.entry, nil
 }
 
+/*Peek returns the entry without counting an access or
+touching the weights*/
+func (c *Calecar) Peek(k string) (Entry, bool) {
+	lookupNode, ok := c.lookup[k]
+	if !ok || lookupNode.entry.expired(c.clock()) {
+		return Entry{}, false
+	}
+	return lookupNode.entry, true
+}
+
+/*possibleVictims is what a write could evict, the heads of
+the LRU, LFU and LCR lists, since which goes is down to
+chance*/
+func (c *Calecar) possibleVictims() []string {
+	if c.length == 0 {
+		return nil
+	}
+	return []string{c.lruHead.entryNode.key, c.lfuHead.entryNode.key, c.lcrHead.entryNode.key}
+}
+
+/*full is true if a new key would evict*/
+func (c *Calecar) full() bool {
+	return c.length > 0 && c.length == c.maxSize
+}
+
+/*resident is the stored entry, to retime in place*/
+func (c *Calecar) resident(k string) *Entry {
+	node, ok := c.lookup[k]
+	if !ok {
+		return nil
+	}
+	return &node.entry
+}
+
 func (c *Calecar) reorderLfuList(node *calecarLfuNode) {
 	for {
 		if node.accessCount >= node.next.accessCount {
@@ -364,15 +409,21 @@ func (c *Calecar) putInHistory(entryNode *calecarLookupNode, evictionType string
 	} else if c.historyLength == c.maxSize {
 		// FIFO head/tail
 		prevHistHead := c.historyHead
-		nextHistHead := prevHistHead.next
-		nextHistHead.prev = nil
-		c.historyHead = nextHistHead
-		prevHistHead.next = nil
 		delete(c.historyLookup, prevHistHead.key)
-		prevHistoryTail := c.historyTail
-		prevHistoryTail.next = historyNode
-		historyNode.prev = prevHistoryTail
-		c.historyTail = historyNode
+		if prevHistHead == c.historyTail {
+			// a history of one, the new node replaces it
+			c.historyHead = historyNode
+			c.historyTail = historyNode
+		} else {
+			nextHistHead := prevHistHead.next
+			nextHistHead.prev = nil
+			c.historyHead = nextHistHead
+			prevHistHead.next = nil
+			prevHistoryTail := c.historyTail
+			prevHistoryTail.next = historyNode
+			historyNode.prev = prevHistoryTail
+			c.historyTail = historyNode
+		}
 	} else {
 		// grow list, this is the new "tail"
 		prevHistoryTail := c.historyTail
@@ -392,6 +443,11 @@ func (c *Calecar) putInHistory(entryNode *calecarLookupNode, evictionType string
 
 /*SetValue inserts a new cache entry, evicting one if necessary*/
 func (c *Calecar) SetValue(k string, v Entry) error {
+	v = c.stamp(k, v)
+	if _, ok := c.lookup[k]; ok {
+		// replacing an entry, drop the old node first
+		c.remove(k, Replaced)
+	}
 	lookupNode := &calecarLookupNode{key: k, entry: v}
 	lruNode := &calecarLruNode{entryNode: lookupNode}
 	lfuNode := &calecarLfuNode{entryNode: lookupNode, accessCount: 1}
@@ -410,13 +466,35 @@ func (c *Calecar) SetValue(k string, v Entry) error {
 		c.lookup[k] = lookupNode
 		c.length = 1
 		return nil
+	} else if c.maxSize == 1 {
+		// the only entry makes way, the new one starts the lists
+		evictEntryNode := c.lruHead.entryNode
+		evictionType := "LCR"
+		sampleVal := rand.Float64()
+		if sampleVal <= c.weightLru {
+			evictionType = "LRU"
+		} else if sampleVal <= (c.weightLru + c.weightLfu) {
+			evictionType = "LFU"
+		}
+		delete(c.lookup, evictEntryNode.key)
+		c.putInHistory(evictEntryNode, evictionType)
+		c.lruHead = lruNode
+		c.lruTail = lruNode
+		c.lfuHead = lfuNode
+		c.lfuTail = lfuNode
+		c.lcrHead = lcrNode
+		c.lcrTail = lcrNode
+		c.lookup[k] = lookupNode
+		c.removed(evictEntryNode.key, evictEntryNode.entry, Evicted)
+		return nil
 	} else if c.length == c.maxSize {
 		// evict one entry
+		var evictEntryNode *calecarLookupNode
 		sampleVal := rand.Float64()
 		if sampleVal <= c.weightLru {
 			// evict by LRU
 			prevLruHead := c.lruHead
-			evictEntryNode := prevLruHead.entryNode
+			evictEntryNode = prevLruHead.entryNode
 			delete(c.lookup, evictEntryNode.key)
 			c.putInHistory(evictEntryNode, "LRU")
 			newLruHead := prevLruHead.next
@@ -434,7 +512,7 @@ func (c *Calecar) SetValue(k string, v Entry) error {
 		} else if sampleVal <= (c.weightLru + c.weightLfu) {
 			// evict by LFU
 			prevLfuHead := c.lfuHead
-			evictEntryNode := prevLfuHead.entryNode
+			evictEntryNode = prevLfuHead.entryNode
 			delete(c.lookup, evictEntryNode.key)
 			c.putInHistory(evictEntryNode, "LFU")
 			newLfuHead := prevLfuHead.next
@@ -452,7 +530,7 @@ func (c *Calecar) SetValue(k string, v Entry) error {
 		} else {
 			// evict by LCR
 			prevLcrHead := c.lcrHead
-			evictEntryNode := prevLcrHead.entryNode
+			evictEntryNode = prevLcrHead.entryNode
 			delete(c.lookup, evictEntryNode.key)
 			c.putInHistory(evictEntryNode, "LCR")
 			newLcrHead := prevLcrHead.next
@@ -469,7 +547,7 @@ func (c *Calecar) SetValue(k string, v Entry) error {
 			c.appendToLfu(lfuNode)
 		}
 		c.lookup[k] = lookupNode
-
+		c.removed(evictEntryNode.key, evictEntryNode.entry, Evicted)
 		// length does not change
 		return nil
 	}
@@ -483,9 +561,85 @@ func (c *Calecar) SetValue(k string, v Entry) error {
 	return nil
 }
 
+/*Delete removes the entry from the cache if present.
+Deleted keys are not written to history since no policy chose them*/
+func (c *Calecar) Delete(k string) error {
+	return c.remove(k, Deleted)
+}
+
+func (c *Calecar) remove(k string, reason RemovalReason) error {
+	lookupNode, ok := c.lookup[k]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	if c.length == 1 {
+		// last entry, lists are empty now
+		c.lruHead = nil
+		c.lruTail = nil
+		c.lfuHead = nil
+		c.lfuTail = nil
+		c.lcrHead = nil
+		c.lcrTail = nil
+	} else {
+		c.removeFromLru(lookupNode.lruNode)
+		c.removeFromLfu(lookupNode.lfuNode)
+		c.removeFromLcr(lookupNode.lcrNode)
+	}
+	delete(c.lookup, k)
+	c.length = c.length - 1
+	c.removed(k, lookupNode.entry, reason)
+	return nil
+}
+
+/*SweepExpired checks up to limit entries (map order, so a
+different sample each time) and drops the expired ones.
+Expired keys don't go to history, no policy chose them*/
+func (c *Calecar) SweepExpired(limit int) int {
+	expired := []string{}
+	for k, lookupNode := range c.lookup {
+		if limit <= 0 {
+			break
+		}
+		limit--
+		if lookupNode.entry.expired(c.clock()) {
+			expired = append(expired, k)
+		}
+	}
+	for _, k := range expired {
+		c.remove(k, Expired)
+	}
+	return len(expired)
+}
+
+/*Export lists resident entries, least recently used first*/
+func (c *Calecar) Export() []Record {
+	records := []Record{}
+	for node := c.lruHead; node != nil; node = node.next {
+		entryNode := node.entryNode
+		records = append(records, Record{Key: entryNode.key, Entry: entryNode.entry, Hits: entryNode.lfuNode.accessCount})
+	}
+	return records
+}
+
+/*Import sets the record and restores its access count*/
+func (c *Calecar) Import(r Record) error {
+	err := c.SetValue(r.Key, r.Entry)
+	if err != nil {
+		return err
+	}
+	lfuNode := c.lookup[r.Key].lfuNode
+	if r.Hits > lfuNode.accessCount {
+		lfuNode.accessCount = r.Hits
+		if lfuNode != c.lfuTail {
+			c.reorderLfuList(lfuNode)
+		}
+	}
+	return nil
+}
+
 func newCalecar(size int) *Calecar {
-	lk := make(map[string]*calecarLookupNode)
-	hk := make(map[string]*calecarHistoryNode)
+	lk := make(map[string]*calecarLookupNode, capacityHint(size))
+	hk := make(map[string]*calecarHistoryNode, capacityHint(size))
 	return &Calecar{
 		maxSize:       size,
 		length:        0,
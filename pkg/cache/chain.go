@@ -0,0 +1,96 @@
+package cache
+
+import "time"
+
+/*Chained consults a list of caches in order, fastest first
+(memory, then disk, then remote...).  A hit in a later tier
+is copied back into every earlier tier so the next read is
+cheaper.  Writes and deletes go to every tier*/
+type Chained struct {
+	tiers []Cache
+}
+
+/*KeyPresent is true if any tier has the key*/
+func (ch *Chained) KeyPresent(k string) bool {
+	for _, tier := range ch.tiers {
+		if tier.KeyPresent(k) {
+			return true
+		}
+	}
+	return false
+}
+
+/*GetValue returns the entry from the first tier that has
+it, backfilling the tiers in front of that one*/
+func (ch *Chained) GetValue(k string) (Entry, error) {
+	for i, tier := range ch.tiers {
+		if !tier.KeyPresent(k) {
+			continue
+		}
+		entry, err := tier.GetValue(k)
+		if err != nil {
+			continue
+		}
+		for j := 0; j < i; j++ {
+			ch.tiers[j].SetValue(k, entry)
+		}
+		return entry, nil
+	}
+	return Entry{}, ErrKeyNotFound
+}
+
+/*SetValue writes to every tier, slowest first, so a
+failure never leaves a faster tier ahead of the rest*/
+func (ch *Chained) SetValue(k string, v Entry) error {
+	for i := len(ch.tiers) - 1; i >= 0; i-- {
+		err := ch.tiers[i].SetValue(k, v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*Delete removes the key from every tier, returning the
+first error after trying them all*/
+func (ch *Chained) Delete(k string) error {
+	var firstErr error
+	found := false
+	for _, tier := range ch.tiers {
+		err := tier.Delete(k)
+		if err == nil {
+			found = true
+		} else if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if found {
+		return nil
+	}
+	return firstErr
+}
+
+/*retime moves the key's deadline in every tier that has it,
+like Delete*/
+func (ch *Chained) retime(k string, ttl time.Duration, shorten bool) error {
+	var firstErr error
+	found := false
+	for _, tier := range ch.tiers {
+		err := retime(tier, k, ttl, shorten)
+		if err == nil {
+			found = true
+		} else if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if found {
+		return nil
+	}
+	return firstErr
+}
+
+/*Chain builds a fallback chain out of existing caches,
+consulted in the order given*/
+func Chain(tiers ...Cache) *Chained {
+	return &Chained{tiers: tiers}
+}
@@ -0,0 +1,38 @@
+package cache
+
+import "testing"
+
+func TestChainBackfills(t *testing.T) {
+	fast, _ := NewCache(LRU, 2)
+	slow, _ := NewCache(LRU, 10)
+	ch := Chain(fast, slow)
+	slow.SetValue("k", NewEntry("v", 3))
+	entry, err := ch.GetValue("k")
+	if err != nil || entry.Value() != "v" {
+		t.Fatalf("got %+v %v", entry, err)
+	}
+	if !fast.KeyPresent("k") {
+		t.Fatal("a hit in a later tier should be copied forward")
+	}
+	if _, err := ch.GetValue("nothing"); err == nil {
+		t.Fatal("expected a miss")
+	}
+}
+
+func TestChainWritesEveryTier(t *testing.T) {
+	fast, _ := NewCache(LRU, 2)
+	slow, _ := NewCache(LRU, 10)
+	ch := Chain(fast, slow)
+	ch.SetValue("k", NewEntry("v", 3))
+	if !fast.KeyPresent("k") || !slow.KeyPresent("k") {
+		t.Fatal("writes go to every tier")
+	}
+	// only the slow tier still has it, Delete should still succeed
+	fast.Delete("k")
+	if err := ch.Delete("k"); err != nil || slow.KeyPresent("k") {
+		t.Fatalf("Delete should clear every tier, got %v", err)
+	}
+	if err := ch.Delete("k"); err == nil {
+		t.Fatal("deleting a key no tier has is an error")
+	}
+}
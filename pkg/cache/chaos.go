@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"math/rand"
+	"sync"
+)
+
+/*ChaosOptions says how much a Chaos cache loses.  HitLoss is
+the share of hits (0 to 1) that are evicted instead of
+answered, so the caller sees a miss where the policy would
+have kept the entry.  Seed makes the losses repeatable*/
+type ChaosOptions struct {
+	HitLoss float64
+	Seed    int64
+}
+
+/*Chaos loses entries on purpose, to check that what depends
+on a cache copes with it forgetting things: that a miss falls
+through to the backend, that nothing assumes a write is still
+there a moment later.  Lost entries are evicted from the
+policy underneath, so listeners hear Evicted just as for the
+policy's own choices.  Evict drops a share of everything at
+once, for drills.  It's safe for concurrent use if the
+wrapped cache is*/
+type Chaos struct {
+	cache Cache
+	mu    sync.Mutex
+	opts  ChaosOptions
+	rnd   *rand.Rand
+	lost  int64
+}
+
+/*chance is true a rate's share of the time*/
+func (c *Chaos) chance(rate float64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rnd.Float64() < rate
+}
+
+func (c *Chaos) hitLoss() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.opts.HitLoss
+}
+
+/*lockedEvict evicts the key under the first Batched in the
+chain, if there's one, so the wrapped cache can be in use*/
+func lockedEvict(c Cache, k string) error {
+	for next := c; next != nil; {
+		batched, ok := next.(*Batched)
+		if ok {
+			return batched.evict(k)
+		}
+		wrapper, ok := next.(unwrapper)
+		if !ok {
+			break
+		}
+		next = wrapper.Unwrap()
+	}
+	return evictKey(c, k)
+}
+
+/*lose evicts the key, counting it*/
+func (c *Chaos) lose(k string) {
+	if lockedEvict(c.cache, k) == nil {
+		c.mu.Lock()
+		c.lost++
+		c.mu.Unlock()
+	}
+}
+
+/*KeyPresent checks the wrapped cache*/
+func (c *Chaos) KeyPresent(k string) bool {
+	return c.cache.KeyPresent(k)
+}
+
+/*GetValue reads from the wrapped cache, losing HitLoss of the
+hits*/
+func (c *Chaos) GetValue(k string) (Entry, error) {
+	entry, err := c.cache.GetValue(k)
+	if err == nil && c.chance(c.hitLoss()) {
+		c.lose(k)
+		return Entry{}, ErrKeyNotFound
+	}
+	return entry, err
+}
+
+/*SetValue writes to the wrapped cache*/
+func (c *Chaos) SetValue(k string, v Entry) error {
+	return c.cache.SetValue(k, v)
+}
+
+/*Delete removes the key from the wrapped cache*/
+func (c *Chaos) Delete(k string) error {
+	return c.cache.Delete(k)
+}
+
+/*Evict loses the share (0 to 1) of resident entries picked at
+random, returning how many went.  It takes an Export, so the
+wrapped cache has to be an Exporter for it to find any*/
+func (c *Chaos) Evict(share float64) int {
+	count := 0
+	for _, record := range c.Export() {
+		if c.chance(share) {
+			c.lose(record.Key)
+			count++
+		}
+	}
+	return count
+}
+
+/*Lost is how many entries the chaos has evicted*/
+func (c *Chaos) Lost() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lost
+}
+
+/*SetHitLoss changes the share of hits lost, 0 turns it off.
+The seed only counts in NewChaos*/
+func (c *Chaos) SetHitLoss(rate float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.opts.HitLoss = rate
+}
+
+/*Export lists the wrapped cache's entries, if it can*/
+func (c *Chaos) Export() []Record {
+	exporter, ok := c.cache.(Exporter)
+	if !ok {
+		return []Record{}
+	}
+	return exporter.Export()
+}
+
+/*Import puts the record into the wrapped cache*/
+func (c *Chaos) Import(r Record) error {
+	return ImportRecord(c.cache, r)
+}
+
+/*Unwrap is the cache losing entries*/
+func (c *Chaos) Unwrap() Cache {
+	return c.cache
+}
+
+/*NewChaos loses the cache's entries as the options say*/
+func NewChaos(c Cache, opts ChaosOptions) *Chaos {
+	return &Chaos{cache: c, opts: opts, rnd: rand.New(rand.NewSource(opts.Seed))}
+}
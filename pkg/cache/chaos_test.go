@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestChaosLosesItsShareOfHits(t *testing.T) {
+	lru, _ := NewCache(LRU, 1000)
+	c := NewChaos(NewBatched(lru), ChaosOptions{HitLoss: 0.2, Seed: 1})
+	evicted := 0
+	AddRemovalListener(c, func(key string, entry Entry, reason RemovalReason) {
+		if reason == Evicted {
+			evicted++
+		}
+	})
+	for i := 0; i < 1000; i++ {
+		c.SetValue(strconv.Itoa(i), NewEntry("v", 1))
+	}
+	missed := 0
+	for i := 0; i < 1000; i++ {
+		if _, err := c.GetValue(strconv.Itoa(i)); errors.Is(err, ErrKeyNotFound) {
+			missed++
+		}
+	}
+	if missed < 150 || missed > 250 || int64(missed) != c.Lost() || evicted != missed {
+		t.Fatalf("expected about 20%% of hits lost as evictions, got %d missed, %d lost, %d heard", missed, c.Lost(), evicted)
+	}
+	c.SetHitLoss(0)
+	left := len(c.Export())
+	if _, err := c.GetValue(c.Export()[0].Key); err != nil {
+		t.Fatalf("expected no losses once turned off, got %v", err)
+	}
+	gone := c.Evict(0.5)
+	if gone < left/4 || gone > 3*left/4 || len(c.Export()) != left-gone {
+		t.Fatalf("expected about half of %d evicted, got %d", left, gone)
+	}
+}
+
+func TestChaosIsRepeatable(t *testing.T) {
+	run := func() []string {
+		lru, _ := NewCache(LRU, 100)
+		c := NewChaos(lru, ChaosOptions{Seed: 7})
+		for i := 0; i < 100; i++ {
+			c.SetValue(strconv.Itoa(i), NewEntry("v", 1))
+		}
+		c.Evict(0.1)
+		keys := []string{}
+		for _, record := range c.Export() {
+			keys = append(keys, record.Key)
+		}
+		return keys
+	}
+	first, second := run(), run()
+	if len(first) != len(second) || len(first) == 100 {
+		t.Fatalf("expected the same losses from the same seed, got %d and %d left", len(first), len(second))
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("expected the same losses from the same seed")
+		}
+	}
+}
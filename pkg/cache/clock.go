@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*Clock is where the caches get the time: TTL deadlines,
+timing wheels, janitors and the other background loops all
+read it.  Load timings (an entry's cost) stay on the real
+clock, they measure real work*/
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+/*clockBox keeps the type stored in currentClock the same
+whatever Clock is set*/
+type clockBox struct {
+	clock Clock
+}
+
+var currentClock atomic.Value
+
+func init() {
+	currentClock.Store(clockBox{clock: realClock{}})
+}
+
+/*SetClock replaces the clock every cache in the process
+reads unless it was built WithClock, returning the one it
+replaces.  nil puts the real clock back*/
+func SetClock(c Clock) Clock {
+	if c == nil {
+		c = realClock{}
+	}
+	return currentClock.Swap(clockBox{clock: c}).(clockBox).clock
+}
+
+func clockNow() time.Time {
+	return currentClock.Load().(clockBox).clock.Now()
+}
+
+func clockAfter(d time.Duration) <-chan time.Time {
+	return currentClock.Load().(clockBox).clock.After(d)
+}
+
+/*processClock reads whatever SetClock last set, for caches
+built without a clock of their own*/
+type processClock struct{}
+
+func (processClock) Now() time.Time { return clockNow() }
+
+func (processClock) After(d time.Duration) <-chan time.Time { return clockAfter(d) }
+
+/*clocked is anything built with a clock to read*/
+type clocked interface {
+	clock() Clock
+}
+
+/*clockOf is the clock the policy underneath the wrappers was
+built with, so a wrapper's background loop keeps the same time
+as the entries it looks after*/
+func clockOf(c Cache) Clock {
+	for c != nil {
+		holder, ok := c.(clocked)
+		if ok {
+			return holder.clock()
+		}
+		wrapper, ok := c.(unwrapper)
+		if !ok {
+			break
+		}
+		c = wrapper.Unwrap()
+	}
+	return processClock{}
+}
+
+/*manualTimer is an After waiting for the manual clock to
+reach its deadline*/
+type manualTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+/*ManualClock only moves when Advance is called, for tests
+and simulations that want expiry to happen on cue*/
+type ManualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []manualTimer
+}
+
+/*Now is the time the clock has been advanced to*/
+func (m *ManualClock) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now
+}
+
+/*After fires once the clock is advanced d past now*/
+func (m *ManualClock) After(d time.Duration) <-chan time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- m.now
+		return ch
+	}
+	m.timers = append(m.timers, manualTimer{at: m.now.Add(d), ch: ch})
+	return ch
+}
+
+/*Advance moves the clock forward, firing every After that
+comes due on the way*/
+func (m *ManualClock) Advance(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = m.now.Add(d)
+	sort.Slice(m.timers, func(i, j int) bool { return m.timers[i].at.Before(m.timers[j].at) })
+	fired := 0
+	for fired < len(m.timers) && !m.timers[fired].at.After(m.now) {
+		m.timers[fired].ch <- m.now
+		fired++
+	}
+	m.timers = m.timers[fired:]
+}
+
+/*Waiting is how many Afters haven't fired yet, so a test
+can tell a background loop has gone back to sleep*/
+func (m *ManualClock) Waiting() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.timers)
+}
+
+/*NewManualClock starts a manual clock at the given time*/
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+/*useManualClock swaps in a manual clock for the rest of the
+test*/
+func useManualClock(t *testing.T) *ManualClock {
+	clock := NewManualClock(time.Unix(1000, 0))
+	old := SetClock(clock)
+	t.Cleanup(func() { SetClock(old) })
+	return clock
+}
+
+/*waitFor gives a background goroutine up to a second to get
+where the test needs it*/
+func waitFor(t *testing.T, done func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("gave up waiting")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestManualClockDrivesExpiry(t *testing.T) {
+	clock := useManualClock(t)
+	for _, policy := range allPolicies {
+		c, _ := NewCache(policy, 4)
+		c.SetValue("ttl", NewEntry("1", 5).WithTTL(time.Minute))
+		c.SetValue("idle", NewEntry("2", 5).WithIdleTTL(time.Minute))
+		clock.Advance(50 * time.Second)
+		c.GetValue("idle")
+		if !c.KeyPresent("ttl") {
+			t.Fatalf("%s: ttl expired before the clock got there", policy)
+		}
+		clock.Advance(20 * time.Second)
+		if c.KeyPresent("ttl") {
+			t.Fatalf("%s: ttl outlived its deadline", policy)
+		}
+		if !c.KeyPresent("idle") {
+			t.Fatalf("%s: the hit didn't slide idle's deadline", policy)
+		}
+		clock.Advance(time.Minute)
+		if c.KeyPresent("idle") {
+			t.Fatalf("%s: idle outlived its deadline", policy)
+		}
+	}
+}
+
+func TestManualClockDrivesJanitor(t *testing.T) {
+	clock := useManualClock(t)
+	c, _ := NewCache(LRU, 10)
+	shared := NewBatched(c)
+	shared.SetValue("a", NewEntry("1", 5).WithTTL(time.Minute))
+	shared.SetValue("b", NewEntry("2", 5))
+	janitor := NewJanitor(shared, time.Second, 10)
+	defer janitor.Close()
+	waitFor(t, func() bool { return clock.Waiting() == 1 })
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return clock.Waiting() == 1 })
+	if janitor.Swept() != 0 {
+		t.Fatalf("swept %d entries before any expired", janitor.Swept())
+	}
+	clock.Advance(time.Minute)
+	waitFor(t, func() bool { return janitor.Swept() == 1 })
+	if !shared.KeyPresent("b") {
+		t.Fatalf("janitor dropped an entry without a ttl")
+	}
+}
+
+func TestManualClockAfter(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	late := clock.After(2 * time.Second)
+	soon := clock.After(time.Second)
+	clock.Advance(time.Second)
+	select {
+	case at := <-soon:
+		if !at.Equal(time.Unix(1, 0)) {
+			t.Fatalf("fired at %v", at)
+		}
+	default:
+		t.Fatalf("After didn't fire once its time came")
+	}
+	select {
+	case <-late:
+		t.Fatalf("After fired early")
+	default:
+	}
+	if clock.Waiting() != 1 {
+		t.Fatalf("expected one timer left, got %d", clock.Waiting())
+	}
+}
+
+func TestWithClockIsPerCache(t *testing.T) {
+	for _, policy := range allPolicies {
+		clock := NewManualClock(time.Unix(1000, 0))
+		c, _ := NewCache(policy, 4, WithClock(clock), WithDefaultTTL(time.Minute))
+		other, _ := NewCache(policy, 4)
+		for _, cache := range []Cache{c, other} {
+			cache.SetValue("ttl", NewEntry("1", 5).WithTTL(2*time.Minute))
+			cache.SetValue("default", NewEntry("2", 5))
+		}
+		// set against the process clock, moved onto the cache's own
+		entry, _ := c.(Peeker).Peek("ttl")
+		if left := entry.Expires().Sub(clock.Now()); left > 2*time.Minute || left < time.Minute {
+			t.Fatalf("%s: expected ttl due two minutes into the cache's clock, got %v", policy, entry.Expires())
+		}
+		clock.Advance(90 * time.Second)
+		if c.KeyPresent("default") || !c.KeyPresent("ttl") {
+			t.Fatalf("%s: expected only the default ttl run out", policy)
+		}
+		if !other.KeyPresent("default") || !other.KeyPresent("ttl") {
+			t.Fatalf("%s: a cache on the process clock followed another's", policy)
+		}
+		clock.Advance(time.Minute)
+		if c.KeyPresent("ttl") {
+			t.Fatalf("%s: ttl outlived its deadline", policy)
+		}
+	}
+}
+
+func TestWithClockDrivesJanitors(t *testing.T) {
+	for _, wheel := range []bool{false, true} {
+		clock := NewManualClock(time.Unix(1000, 0))
+		c, _ := NewCache(LRU, 10, WithClock(clock))
+		shared := NewBatched(c)
+		shared.SetValue("a", NewEntry("1", 5).WithTTL(time.Minute))
+		shared.SetValue("b", NewEntry("2", 5))
+		var janitor *Janitor
+		if wheel {
+			janitor = NewWheelJanitor(shared, time.Second)
+		} else {
+			janitor = NewJanitor(shared, time.Second, 10)
+		}
+		waitFor(t, func() bool { return clock.Waiting() == 1 })
+		clock.Advance(2 * time.Minute)
+		waitFor(t, func() bool { return janitor.Swept() == 1 })
+		if !shared.KeyPresent("b") {
+			t.Fatalf("wheel %v: janitor dropped an entry without a ttl", wheel)
+		}
+		janitor.Close()
+	}
+}
+
+func TestWithClockDrivesLoaders(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	c, _ := NewCache(LRU, 10, WithClock(clock))
+	refreshed := &countingLoader{}
+	r := NewRefresher(c, refreshed, time.Minute, time.Second, 1)
+	defer r.Close()
+	r.SetValue("a", NewEntry("stale", 5))
+	r.GetValue("a")
+	waitFor(t, func() bool { return clock.Waiting() == 1 })
+	clock.Advance(2 * time.Minute)
+	waitFor(t, func() bool { return refreshed.loaded() == "a" })
+
+	fetched := &countingLoader{}
+	other, _ := NewCache(LRU, 10, WithClock(clock))
+	x := NewXFetch(NewBatched(other), fetched, time.Minute, 1)
+	x.GetValue("k")
+	x.GetValue("k")
+	if fetched.loaded() != "k" {
+		t.Fatalf("expected one load before the ttl ran out, got %q", fetched.loaded())
+	}
+	clock.Advance(2 * time.Minute)
+	x.GetValue("k")
+	if fetched.loaded() != "k k" {
+		t.Fatalf("expected a reload once the cache's clock passed the ttl, got %q", fetched.loaded())
+	}
+}
@@ -0,0 +1,281 @@
+package cache
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*hotKeyLifetime is how long a hot mark lasts.  An owner
+still seeing the traffic marks the key again once it lapses*/
+const hotKeyLifetime = time.Minute
+
+/*hotKeys is the set of keys the cluster has agreed are
+hot enough to be served from several nodes, each until
+its mark lapses*/
+type hotKeys struct {
+	mu       sync.Mutex
+	keys     map[string]time.Time
+	lifetime time.Duration
+}
+
+func (h *hotKeys) mark(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := clockNow()
+	for k, until := range h.keys {
+		if now.After(until) {
+			delete(h.keys, k)
+		}
+	}
+	h.keys[key] = now.Add(h.lifetime)
+}
+
+func (h *hotKeys) isHot(key string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	until, ok := h.keys[key]
+	if ok && clockNow().After(until) {
+		delete(h.keys, key)
+		return false
+	}
+	return ok
+}
+
+func newHotKeys(lifetime time.Duration) *hotKeys {
+	return &hotKeys{keys: make(map[string]time.Time), lifetime: lifetime}
+}
+
+/*parseFetchReply reads the VALUE/COST lines a node
+answers a fetch or fill with*/
+func parseFetchReply(reply string) (Entry, int, bool, error) {
+	entry := Entry{}
+	cost := 0
+	found := false
+	for _, line := range strings.Split(reply, "\n") {
+		if strings.HasPrefix(line, "VALUE:") {
+			entry.value = strings.TrimPrefix(line, "VALUE:")
+			found = true
+		} else if strings.HasPrefix(line, "COST:") {
+			parsed, err := strconv.Atoi(strings.TrimPrefix(line, "COST:"))
+			if err != nil {
+				return Entry{}, 0, false, err
+			}
+			cost = parsed
+		} else if strings.HasPrefix(line, "No Entry For Key") {
+			return Entry{}, 0, false, nil
+		}
+	}
+	if !found {
+		return Entry{}, 0, false, errors.New("Unexpected reply from owner: " + reply)
+	}
+	entry.cost = cost
+	return entry, cost, true, nil
+}
+
+/*routeMiss picks the node a miss gets forwarded to.  Normally
+that's the owner, but hot keys are spread at random across
+their replicas so one shard doesn't take all of the traffic*/
+func (s *Server) routeMiss(key string) string {
+	owner := s.cluster.Owner(key)
+	if !s.hot.isHot(key) {
+		return owner
+	}
+	replicas := s.cluster.Owners(key, s.config.HotReplicas)
+	pick := replicas[rand.Intn(len(replicas))]
+	if pick == s.config.Self {
+		// we're a replica that lost its copy, let the owner load it
+		return owner
+	}
+	return pick
+}
+
+/*observeOwned counts a request this node served as the
+key's owner, replicating the key out once it gets hot (and
+again whenever the mark lapses while it's still hot)*/
+func (s *Server) observeOwned(key string, entry Entry) {
+	if s.config.HotThreshold <= 0 {
+		return
+	}
+	if s.hotTracker.Observe(key) < s.config.HotThreshold || s.hot.isHot(key) {
+		return
+	}
+	s.hot.mark(key)
+	if s.config.Verbose {
+		s.logger.Println("Hot key, replicating ", key)
+	}
+	replicate := replicationOp{command: "replicate_set", key: key, entry: entry}.message()
+	replicas := s.cluster.Owners(key, s.config.HotReplicas)
+	for _, node := range s.cluster.Nodes() {
+		if node == s.config.Self {
+			continue
+		}
+		go func(node string, isReplica bool) {
+			if isReplica {
+				_, err := s.transport.Send(node, replicate)
+				if err != nil {
+					s.logger.Println("WARNING: failed to replicate hot key ", key, " to ", node, ": ", err)
+				}
+			}
+			_, err := s.transport.Send(node, "hot,"+key)
+			if err != nil {
+				s.logger.Println("WARNING: failed to announce hot key ", key, " to ", node, ": ", err)
+			}
+		}(node, containsNode(replicas, node))
+	}
+}
+
+func containsNode(nodes []string, node string) bool {
+	for _, n := range nodes {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
+
+/*fillForPeer answers a miss a peer forwarded.  The owner
+loads it, while a hot replica that lost its copy asks the
+owner for it rather than loading it a second time*/
+func (s *Server) fillForPeer(key string) (Entry, int, bool) {
+	owned := s.cluster == nil || s.cluster.Owner(key) == s.config.Self
+	entry, ok := s.cached(key)
+	if ok {
+		if owned {
+			s.observeOwned(key, entry)
+		}
+		return entry, 0, true
+	}
+	if owned {
+		entry, cost, ok := s.fill(key)
+		if ok {
+			s.observeOwned(key, entry)
+		}
+		return entry, cost, ok
+	}
+	owner := s.cluster.Owner(key)
+	entry, cost, ok, err := s.fetchFromOwner(owner, key)
+	if err != nil {
+		s.logger.Println("WARNING: ", owner, " unavailable for ", key, ", loading locally: ", err)
+		return s.fill(key)
+	}
+	if ok && s.hot.isHot(key) {
+		// put our replica copy back
+		s.cache.SetValue(key, s.stamped(entry))
+	}
+	return entry, cost, ok
+}
+
+/*invalidateHot drops the copies a key's hot replicas may
+be holding after a write.  Marks lapse at different times
+on different nodes, so this doesn't wait for the key to be
+marked here*/
+func (s *Server) invalidateHot(key string) {
+	if s.cluster == nil || s.config.HotThreshold <= 0 {
+		return
+	}
+	for _, node := range s.cluster.Owners(key, s.config.HotReplicas) {
+		if node == s.config.Self {
+			continue
+		}
+		_, err := s.transport.Send(node, "invalidate,"+key)
+		if err != nil {
+			s.logger.Println("WARNING: failed to invalidate hot key ", key, " on ", node, ": ", err)
+		}
+	}
+}
+
+/*fetchFromOwner forwards a miss to the node that owns
+the key so it does the single load for the cluster*/
+func (s *Server) fetchFromOwner(owner string, key string) (Entry, int, bool, error) {
+	reply, err := s.transport.Send(owner, "fill,"+key)
+	if err != nil {
+		return Entry{}, 0, false, err
+	}
+	return parseFetchReply(reply)
+}
+
+func migrateMessage(r Record) string {
+	// value goes last so commas inside it survive the split
+	return "migrate," + r.Key + "," + strconv.Itoa(r.Entry.cost) + "," + strconv.Itoa(r.Hits) + "," + ttlFields(r.Entry) + "," + r.Entry.value
+}
+
+func parseMigrateMessage(message string) (Record, error) {
+	parts := strings.SplitN(message, ",", 8)
+	if len(parts) < 8 {
+		return Record{}, errors.New("Malformed migrate message")
+	}
+	cost, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return Record{}, err
+	}
+	hits, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return Record{}, err
+	}
+	entry, err := withTTLFields(Entry{value: strings.TrimRight(parts[7], "\n"), cost: cost}, parts[4:7])
+	if err != nil {
+		return Record{}, err
+	}
+	return Record{Key: strings.TrimSpace(parts[1]), Entry: entry, Hits: hits}, nil
+}
+
+/*migrateAway streams every resident entry this node no
+longer owns to its new owner, cost and access counts
+included, so a resize doesn't turn into a wave of cold
+misses.  Entries that reach their new owner are dropped here*/
+func (s *Server) migrateAway() {
+	exporter, ok := s.cache.(Exporter)
+	if !ok {
+		return
+	}
+	moved := 0
+	for _, record := range exporter.Export() {
+		owner := s.cluster.Owner(record.Key)
+		if owner == s.config.Self || owner == "" {
+			continue
+		}
+		_, err := s.transport.Send(owner, migrateMessage(record))
+		if err != nil {
+			s.logger.Println("WARNING: failed to migrate ", record.Key, " to ", owner, ": ", err)
+			continue
+		}
+		s.cache.Delete(record.Key)
+		moved++
+	}
+	s.logger.Println("Migrated ", moved, " entries to their new owners")
+}
+
+/*announceJoin tells every other member this node is on the
+ring, so they hand over the keys it now owns*/
+func (s *Server) announceJoin() {
+	for _, node := range s.cluster.Nodes() {
+		if node == s.config.Self {
+			continue
+		}
+		_, err := s.transport.Send(node, "join,"+s.config.Self)
+		if err != nil {
+			s.logger.Println("WARNING: could not announce join to ", node, ": ", err)
+		}
+	}
+}
+
+/*leaveCluster takes this node off every member's ring and
+hands all of its entries to their new owners*/
+func (s *Server) leaveCluster() {
+	nodes := s.cluster.Nodes()
+	s.cluster.Remove(s.config.Self)
+	for _, node := range nodes {
+		if node == s.config.Self {
+			continue
+		}
+		_, err := s.transport.Send(node, "leave,"+s.config.Self)
+		if err != nil {
+			s.logger.Println("WARNING: could not announce leave to ", node, ": ", err)
+		}
+	}
+	s.migrateAway()
+}
@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+/*localTransport delivers messages straight to in-process
+servers*/
+type localTransport struct {
+	nodes map[string]*Server
+}
+
+func (t *localTransport) Send(peer string, message string) (string, error) {
+	node, ok := t.nodes[peer]
+	if !ok {
+		return "", errors.New("No such node: " + peer)
+	}
+	var reply bytes.Buffer
+	node.handleCommand(&reply, message)
+	return reply.String(), nil
+}
+
+func testCluster(datasets map[string]map[string]Entry, hotThreshold int) (*localTransport, *Ring) {
+	names := []string{}
+	for name := range datasets {
+		names = append(names, name)
+	}
+	transport := &localTransport{nodes: make(map[string]*Server)}
+	logger := log.New(io.Discard, "", 0)
+	for name, dataset := range datasets {
+		dataset := dataset
+		lru, _ := NewCache(LRU, 10)
+		meter := NewMetered(NewBatched(lru))
+		cacheType := "LRU"
+		transport.nodes[name] = &Server{
+			config:     &ServerConf{Self: name, CacheType: &cacheType, HotThreshold: hotThreshold, HotReplicas: 2},
+			dataset:    &dataset,
+			logger:     logger,
+			cache:      meter,
+			peers:      NewBroadcaster(nil, transport, logger),
+			replica:    NewReplicator(nil, transport, logger, 10),
+			cluster:    NewRing(names, 100),
+			loads:      &flightGroup{},
+			transport:  transport,
+			hotTracker: NewTopK(100, 0),
+			hot:        newHotKeys(hotKeyLifetime),
+			meter:      meter,
+		}
+	}
+	return transport, NewRing(names, 100)
+}
+
+func TestHotMarksLapse(t *testing.T) {
+	hot := newHotKeys(10 * time.Millisecond)
+	hot.mark("k")
+	if !hot.isHot("k") {
+		t.Fatal("k should be hot right after it's marked")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if hot.isHot("k") {
+		t.Fatal("the mark should have lapsed")
+	}
+	hot.mark("other")
+	if len(hot.keys) != 1 {
+		t.Fatalf("lapsed marks should be pruned, have %v", hot.keys)
+	}
+}
+
+func TestOwnerMarksAgainAfterLapse(t *testing.T) {
+	transport, ring := testCluster(map[string]map[string]Entry{
+		"a": {"k": NewEntry("v", 5)}, "b": {"k": NewEntry("v", 5)},
+	}, 2)
+	owner := transport.nodes[ring.Owner("k")]
+	owner.hot = newHotKeys(10 * time.Millisecond)
+	owner.fetch("k")
+	owner.fetch("k")
+	if !owner.hot.isHot("k") {
+		t.Fatal("k should be hot after reaching the threshold")
+	}
+	time.Sleep(20 * time.Millisecond)
+	owner.fetch("k")
+	if !owner.hot.isHot("k") {
+		t.Fatal("a key still getting traffic should be marked again")
+	}
+}
+
+func TestReplicaMissGoesToOwner(t *testing.T) {
+	datasets := map[string]map[string]Entry{"a": {}, "b": {}, "c": {}}
+	ring := NewRing([]string{"a", "b", "c"}, 100)
+	// only the owner can load k, a replica that tries gets nothing
+	datasets[ring.Owner("k")]["k"] = NewEntry("v", 5)
+	transport, _ := testCluster(datasets, 2)
+	replica := transport.nodes[ring.Owners("k", 2)[1]]
+	replica.hot.mark("k")
+	entry, _, ok := parseFetchReplyOrFail(t, transport, replica.config.Self, "fill,k")
+	if !ok || entry.Value() != "v" {
+		t.Fatalf("replica should have asked the owner, got %+v %v", entry, ok)
+	}
+	if !replica.cache.KeyPresent("k") {
+		t.Fatal("the replica should keep its copy of a hot key")
+	}
+}
+
+func TestWriteInvalidatesHotReplicas(t *testing.T) {
+	transport, ring := testCluster(map[string]map[string]Entry{"a": {}, "b": {}, "c": {}}, 2)
+	owners := ring.Owners("k", 2)
+	owner := transport.nodes[owners[0]]
+	replica := transport.nodes[owners[1]]
+	owner.cache.SetValue("k", NewEntry("old", 5))
+	replica.cache.SetValue("k", NewEntry("old", 5))
+	transport.Send(owner.config.Self, "set,k,5,new")
+	if replica.cache.KeyPresent("k") {
+		t.Fatal("a set should drop the hot replica's copy")
+	}
+	replica.cache.SetValue("k", NewEntry("new", 5))
+	transport.Send(owner.config.Self, "delete,k")
+	if replica.cache.KeyPresent("k") {
+		t.Fatal("a delete should drop the hot replica's copy")
+	}
+}
+
+func parseFetchReplyOrFail(t *testing.T, transport *localTransport, node string, message string) (Entry, int, bool) {
+	reply, err := transport.Send(node, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, cost, ok, err := parseFetchReply(reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry, cost, ok
+}
+
+func TestAdminMatchCommands(t *testing.T) {
+	transport, _ := testCluster(map[string]map[string]Entry{"a": {}}, 0)
+	node := transport.nodes["a"]
+	node.cluster = nil
+	for _, k := range []string{"user:1", "user:2", "order:1"} {
+		node.cache.SetValue(k, NewEntry(k, 1))
+	}
+	listed, _ := transport.Send("a", "keys,user:*")
+	if listed != "KEY:user:1\nKEY:user:2\n" {
+		t.Fatalf("unexpected listing %q", listed)
+	}
+	dropped, _ := transport.Send("a", "invalidate_match,/^(user|order):1$/")
+	if dropped != "MATCHED:2\n" || node.cache.KeyPresent("user:1") || !node.cache.KeyPresent("user:2") {
+		t.Fatalf("unexpected invalidation %q", dropped)
+	}
+	bad, _ := transport.Send("a", "keys,[oops")
+	if bad != "Bad Pattern\n" {
+		t.Fatalf("expected a bad pattern, got %q", bad)
+	}
+}
@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+/*Codec turns Go values into bytes and back, JSONCodec and
+GobCodec come built in and anything with the same two methods
+(a msgpack library, protobuf) can be plugged in*/
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := gob.NewEncoder(buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+/*JSONCodec encodes values as JSON*/
+var JSONCodec Codec = jsonCodec{}
+
+/*GobCodec encodes values with encoding/gob, more compact
+than JSON and keeping Go types exactly*/
+var GobCodec Codec = gobCodec{}
+
+/*Typed stores Go values in a cache of byte entries, encoding
+them with its Codec on the way in and decoding on the way out,
+so what a ByteBounded cache or an Arena counts is the encoded
+size.  It isn't a Cache itself, the cache underneath still is
+for anything that only needs the bytes.  It keeps no state
+beyond the codec, so it adds no locking*/
+type Typed struct {
+	cache Cache
+	codec Codec
+}
+
+/*Set encodes the value and caches it with the cost*/
+func (t *Typed) Set(k string, v interface{}, cost int) error {
+	return t.SetEntry(k, v, NewEntry("", cost))
+}
+
+/*SetEntry encodes the value into the entry, keeping its cost
+and TTLs*/
+func (t *Typed) SetEntry(k string, v interface{}, entry Entry) error {
+	encoded, err := t.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	entry.value = string(encoded)
+	return t.cache.SetValue(k, entry)
+}
+
+/*Get decodes the cached value into out, which should be a
+pointer, returning the entry it came from*/
+func (t *Typed) Get(k string, out interface{}) (Entry, error) {
+	entry, err := t.cache.GetValue(k)
+	if err != nil {
+		return entry, err
+	}
+	return entry, t.codec.Unmarshal([]byte(entry.value), out)
+}
+
+/*Present is true if the key is cached right now*/
+func (t *Typed) Present(k string) bool {
+	return t.cache.KeyPresent(k)
+}
+
+/*Delete removes the key*/
+func (t *Typed) Delete(k string) error {
+	return t.cache.Delete(k)
+}
+
+/*Cache is the cache of encoded entries underneath*/
+func (t *Typed) Cache() Cache {
+	return t.cache
+}
+
+/*NewTyped stores values in the cache encoded with the codec
+(GobCodec if nil)*/
+func NewTyped(c Cache, codec Codec) *Typed {
+	if codec == nil {
+		codec = GobCodec
+	}
+	return &Typed{cache: c, codec: codec}
+}
@@ -0,0 +1,27 @@
+package cache
+
+import "testing"
+
+type invoice struct {
+	ID    int
+	Lines []string
+}
+
+func TestTypedRoundTrips(t *testing.T) {
+	for _, codec := range []Codec{JSONCodec, GobCodec} {
+		lru, _ := NewCache(LRU, 10)
+		c := NewTyped(lru, codec)
+		err := c.Set("invoice:7", invoice{ID: 7, Lines: []string{"widget", "gadget"}}, 30)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		var got invoice
+		entry, err := c.Get("invoice:7", &got)
+		if err != nil || got.ID != 7 || len(got.Lines) != 2 || entry.Cost() != 30 {
+			t.Fatalf("expected the invoice back, got %+v and %v", got, err)
+		}
+		if _, err := c.Get("invoice:8", &got); err == nil {
+			t.Fatalf("expected a miss")
+		}
+	}
+}
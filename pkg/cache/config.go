@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"errors"
+	"time"
+)
+
+/*Config describes a cache for NewFromConfig: the policy and
+its size (or Unbounded for no eviction at all), a budget of
+bytes to hold it to as well (or instead, with no size), default TTLs
+for entries set without one, a shard count to split it into
+and tuning for the adaptive policies*/
+type Config struct {
+	Type      CacheType
+	Size      int
+	Unbounded bool
+	MaxBytes  int64
+	TTL       time.Duration
+	IdleTTL   time.Duration
+	Shards    int
+	Tuning    Tuning
+}
+
+/*Validate is an error describing the first thing wrong with
+the config, nil if it can be built*/
+func (cfg Config) Validate() error {
+	if !cfg.Type.known() {
+		return errors.New("Unknown cache type " + cfg.Type.String())
+	}
+	if cfg.Size < 0 {
+		return errors.New("Cache size can't be negative")
+	}
+	if cfg.TTL < 0 || cfg.IdleTTL < 0 {
+		return errors.New("Default TTLs can't be negative")
+	}
+	if cfg.Shards < 0 {
+		return errors.New("Shard count can't be negative")
+	}
+	if cfg.MaxBytes < 0 {
+		return errors.New("Byte budget can't be negative")
+	}
+	if cfg.Type == NONE {
+		if cfg.TTL > 0 || cfg.IdleTTL > 0 {
+			return errors.New("A NONE cache holds nothing to expire, it can't take a TTL")
+		}
+		if cfg.Shards > 1 {
+			return errors.New("A NONE cache holds nothing to shard")
+		}
+		if cfg.Unbounded {
+			return errors.New("A NONE cache holds nothing, it can't be unbounded")
+		}
+		if cfg.MaxBytes > 0 {
+			return errors.New("A NONE cache holds nothing, it can't take a byte budget")
+		}
+		return nil
+	}
+	if cfg.Size == 0 && !cfg.countless() {
+		return errors.New("Cache size must be positive for " + cfg.Type.String() + " unless it's unbounded or has a byte budget")
+	}
+	if cfg.Shards > cfg.Size && !cfg.countless() {
+		return errors.New("More shards than the cache has room for, every shard needs at least one entry")
+	}
+	learning := cfg.Tuning.LearningRate != 0 || cfg.Tuning.Discount != 0
+	if learning && cfg.Type != LECAR && cfg.Type != CALECAR {
+		return errors.New("Only LECAR and CALECAR take tuning, not " + cfg.Type.String())
+	} else if cfg.Tuning.SegmentRatio != 0 && cfg.Type != SLRU {
+		return errors.New("Only SLRU takes a segment ratio, not " + cfg.Type.String())
+	} else if cfg.Tuning.CostDecay != 0 && cfg.Type != LCR {
+		return errors.New("Only LCR takes a cost decay, not " + cfg.Type.String())
+	}
+	t := cfg.Tuning
+	if t.LearningRate < 0 || t.Discount < 0 || t.SegmentRatio < 0 || t.CostDecay < 0 {
+		return errors.New("Tuning parameters can't be negative")
+	}
+	if t.SegmentRatio >= 1 || t.CostDecay > 1 {
+		return errors.New("A segment ratio must be under 1 and a cost decay at most 1")
+	}
+	return nil
+}
+
+/*countless is true if the policy doesn't count entries, a
+config with only a byte budget is bounded by that alone*/
+func (cfg Config) countless() bool {
+	return cfg.Unbounded || (cfg.Size == 0 && cfg.MaxBytes > 0)
+}
+
+/*options are the Options the config asks for*/
+func (cfg Config) options() []Option {
+	opts := []Option{WithDefaultTTL(cfg.TTL), WithDefaultIdleTTL(cfg.IdleTTL)}
+	if cfg.countless() {
+		opts = append(opts, Unbounded())
+	}
+	return opts
+}
+
+/*NewFromConfig validates the config and builds it.  With
+more than one shard the result is a Sharded (safe for
+concurrent use), the size and byte budget split evenly
+between the shards; otherwise it is the bare policy, just as
+NewCache builds it, in a ByteBounded if it has a budget*/
+func NewFromConfig(cfg Config) (Cache, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Shards <= 1 {
+		return cfg.build(cfg.Size, cfg.MaxBytes)
+	}
+	perShard := cfg.Size / cfg.Shards
+	extra := cfg.Size % cfg.Shards
+	return NewSharded(cfg.Shards, func(shard int) Cache {
+		size := perShard
+		if shard < extra {
+			size++
+		}
+		// the config is valid, so the policy builds
+		c, _ := cfg.build(size, cfg.MaxBytes/int64(cfg.Shards))
+		return c
+	}), nil
+}
+
+func (cfg Config) build(size int, maxBytes int64) (Cache, error) {
+	c, err := NewTunedCache(cfg.Type, size, cfg.Tuning, cfg.options()...)
+	if err != nil || maxBytes == 0 {
+		return c, err
+	}
+	return NewByteBounded(c, maxBytes, nil), nil
+}
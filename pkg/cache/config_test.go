@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(t *testing.T) {
+	cases := []struct {
+		cfg   Config
+		valid bool
+	}{
+		{Config{Type: LRU, Size: 100}, true},
+		{Config{Type: NONE}, true},
+		{Config{Type: LCR, Size: 100, TTL: time.Minute, Shards: 4}, true},
+		{Config{Type: LECAR, Size: 100, Tuning: Tuning{LearningRate: 0.3}}, true},
+		{Config{Type: LRU, Unbounded: true, TTL: time.Minute, Shards: 4}, true},
+		{Config{Type: LRU}, false},
+		{Config{Type: NONE, Unbounded: true}, false},
+		{Config{Type: LRU, Size: -1}, false},
+		{Config{Type: LRU, Size: 4, Shards: 5}, false},
+		{Config{Type: NONE, TTL: time.Minute}, false},
+		{Config{Type: LRU, Size: 10, IdleTTL: -time.Second}, false},
+		{Config{Type: LRU, Size: 10, Tuning: Tuning{Discount: 0.5}}, false},
+		{Config{Type: SLRU, Size: 10, Tuning: Tuning{SegmentRatio: 0.5}}, true},
+		{Config{Type: SLRU, Size: 10, Tuning: Tuning{SegmentRatio: 1}}, false},
+		{Config{Type: LCR, Size: 10, Tuning: Tuning{CostDecay: 1}}, true},
+		{Config{Type: LRU, Size: 10, Tuning: Tuning{CostDecay: 0.5}}, false},
+		{Config{Type: CacheType(99), Size: 10}, false},
+	}
+	for _, tc := range cases {
+		err := tc.cfg.Validate()
+		if (err == nil) != tc.valid {
+			t.Fatalf("%+v: expected valid %v, got %v", tc.cfg, tc.valid, err)
+		}
+		_, buildErr := NewFromConfig(tc.cfg)
+		if (buildErr == nil) != tc.valid {
+			t.Fatalf("%+v: NewFromConfig disagreed with Validate: %v", tc.cfg, buildErr)
+		}
+	}
+}
+
+func TestNewFromConfigShards(t *testing.T) {
+	c, err := NewFromConfig(Config{Type: LRU, Size: 10, Shards: 3, TTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sharded := c.(*Sharded)
+	total := 0
+	for _, shard := range sharded.shards {
+		total += shard.Unwrap().(*Lru).maxSize
+	}
+	if len(sharded.shards) != 3 || total != 10 {
+		t.Fatalf("expected 10 entries over 3 shards, got %d over %d", total, len(sharded.shards))
+	}
+	removed := 0
+	AddRemovalListener(c, func(key string, entry Entry, reason RemovalReason) { removed++ })
+	for i := 0; i < 100; i++ {
+		c.SetValue("k"+strconv.Itoa(i), NewEntry("v", 1))
+	}
+	if len(c.(Exporter).Export())+removed != 100 {
+		t.Fatalf("lost track of entries, %d resident and %d removed", len(c.(Exporter).Export()), removed)
+	}
+	if c.(Exporter).Export()[0].Entry.Expires().IsZero() {
+		t.Fatalf("shards didn't get the default TTL")
+	}
+}
+
+func TestByteBudgetConfig(t *testing.T) {
+	if err := (Config{Type: LRU, MaxBytes: -1}).Validate(); err == nil {
+		t.Fatalf("took a negative byte budget")
+	}
+	if err := (Config{Type: NONE, MaxBytes: 10}).Validate(); err == nil {
+		t.Fatalf("gave a NONE cache a byte budget")
+	}
+	c, err := NewFromConfig(Config{Type: LRU, MaxBytes: 1000, Shards: 4})
+	if err != nil {
+		t.Fatalf("couldn't shard a byte budget: %v", err)
+	}
+	c.SetValue("a", NewEntry("1", 1))
+	if !c.KeyPresent("a") {
+		t.Fatalf("a sharded byte budget cached nothing")
+	}
+}
@@ -0,0 +1,41 @@
+package cache
+
+/*CostAdmission is an Admission for LCR and CALECAR that won't
+evict an entry costing more to recompute than the one coming
+in, so a burst of cheap writes can't push out the expensive
+results the cache is there for.  With a Reuse sketch each cost
+is weighed by how often its key has been asked for lately, so
+an expensive entry nobody reads any more can still go.  Ties
+are admitted*/
+type CostAdmission struct {
+	Reuse *TinyLFU
+}
+
+/*Record counts the access in the Reuse sketch, if there is
+one*/
+func (ca *CostAdmission) Record(key string) {
+	if ca.Reuse != nil {
+		ca.Reuse.Record(key)
+	}
+}
+
+/*Admit is true unless the victim is worth more than the
+candidate*/
+func (ca *CostAdmission) Admit(candidate Record, victim Record) bool {
+	return ca.worth(victim) <= ca.worth(candidate)
+}
+
+/*worth is the record's cost, weighed by its reuse (plus one,
+so a key not seen yet still counts its cost)*/
+func (ca *CostAdmission) worth(r Record) int {
+	if ca.Reuse == nil {
+		return r.Entry.cost
+	}
+	return r.Entry.cost * (ca.Reuse.Estimate(r.Key) + 1)
+}
+
+/*NewCostAdmission weighs costs by reuse from the sketch, which
+can be nil to compare the costs alone*/
+func NewCostAdmission(reuse *TinyLFU) *CostAdmission {
+	return &CostAdmission{Reuse: reuse}
+}
@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCostAdmissionKeepsExpensiveEntries(t *testing.T) {
+	for _, cacheType := range []CacheType{LCR, CALECAR} {
+		policy, _ := NewCache(cacheType, 2)
+		c := NewAdmitting(policy, NewCostAdmission(nil))
+		c.SetValue("report", NewEntry("r", 500))
+		c.SetValue("rollup", NewEntry("r", 400))
+		err := c.SetValue("cheap", NewEntry("c", 1))
+		if !errors.Is(err, ErrNotAdmitted) || c.KeyPresent("cheap") {
+			t.Fatalf("%s: expected a cheap entry kept out, got %v", cacheType, err)
+		}
+		if err := c.SetValue("dear", NewEntry("d", 600)); err != nil || !c.KeyPresent("dear") {
+			t.Fatalf("%s: expected a dearer entry admitted, got %v", cacheType, err)
+		}
+	}
+}
+
+func TestCostAdmissionWeighsReuse(t *testing.T) {
+	lcr, _ := NewCache(LCR, 1)
+	c := NewAdmitting(lcr, NewCostAdmission(NewTinyLFU(10)))
+	c.SetValue("stale", NewEntry("s", 10))
+	for i := 0; i < 5; i++ {
+		c.GetValue("popular")
+	}
+	if err := c.SetValue("popular", NewEntry("p", 5)); err != nil {
+		t.Fatalf("expected a cheaper entry read far more often admitted, got %v", err)
+	}
+}
@@ -0,0 +1,288 @@
+package cache
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+/*Stats is a snapshot of a Metered cache's counters.
+CostSaved is the summed cost of every entry served from the
+cache (recompute work avoided), CostMissed the summed cost
+of the entries that had to be filled after a miss, Evictions
+how many entries the policy pushed out to make room*/
+type Stats struct {
+	Hits       int64
+	Misses     int64
+	CostSaved  int64
+	CostMissed int64
+	Evictions  int64
+}
+
+/*HitRatio is the share of lookups that hit*/
+func (s Stats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+/*CostHitRatio is the share of recompute cost the cache
+saved, the number LCR tries to push up*/
+func (s Stats) CostHitRatio() float64 {
+	if s.CostSaved+s.CostMissed == 0 {
+		return 0
+	}
+	return float64(s.CostSaved) / float64(s.CostSaved+s.CostMissed)
+}
+
+/*Metered wraps a cache and keeps count of hits and misses
+and what they were worth.  Every failed lookup is a miss (a
+KeyPresent and the GetValue right after it count once).  A
+miss only gets its cost once the SetValue that fills it comes
+in, so misses nobody fills count towards Misses but not
+CostMissed.  Evictions are counted if the wrapped cache
+reports its removals.  Counters are
+atomic, a hit costs no locking beyond the wrapped cache's.
+Built with NewBucketedMetered it also keeps the counts per
+bucket of keys (a team's namespace, a key prefix), so it's
+clear who sharing a cache is thrashing it*/
+type Metered struct {
+	mu      sync.Mutex
+	cache   Cache
+	pending map[string]bool
+	total   counters
+	bucket  func(key string) string
+	buckets sync.Map
+}
+
+type counters struct {
+	hits       int64
+	misses     int64
+	costSaved  int64
+	costMissed int64
+	evictions  int64
+}
+
+/*the counting methods do nothing on the nil counters of a
+Metered without buckets*/
+func (c *counters) hit(cost int) {
+	if c != nil {
+		atomic.AddInt64(&c.hits, 1)
+		atomic.AddInt64(&c.costSaved, int64(cost))
+	}
+}
+
+func (c *counters) miss() {
+	if c != nil {
+		atomic.AddInt64(&c.misses, 1)
+	}
+}
+
+func (c *counters) filled(cost int) {
+	if c != nil {
+		atomic.AddInt64(&c.costMissed, int64(cost))
+	}
+}
+
+func (c *counters) evicted() {
+	if c != nil {
+		atomic.AddInt64(&c.evictions, 1)
+	}
+}
+
+func (c *counters) stats() Stats {
+	return Stats{
+		Hits:       atomic.LoadInt64(&c.hits),
+		Misses:     atomic.LoadInt64(&c.misses),
+		CostSaved:  atomic.LoadInt64(&c.costSaved),
+		CostMissed: atomic.LoadInt64(&c.costMissed),
+		Evictions:  atomic.LoadInt64(&c.evictions),
+	}
+}
+
+func (c *counters) reset() Stats {
+	return Stats{
+		Hits:       atomic.SwapInt64(&c.hits, 0),
+		Misses:     atomic.SwapInt64(&c.misses, 0),
+		CostSaved:  atomic.SwapInt64(&c.costSaved, 0),
+		CostMissed: atomic.SwapInt64(&c.costMissed, 0),
+		Evictions:  atomic.SwapInt64(&c.evictions, 0),
+	}
+}
+
+/*bucketOf is the key's bucket, nil without buckets*/
+func (m *Metered) bucketOf(k string) *counters {
+	if m.bucket == nil {
+		return nil
+	}
+	name := m.bucket(k)
+	found, ok := m.buckets.Load(name)
+	if !ok {
+		found, _ = m.buckets.LoadOrStore(name, &counters{})
+	}
+	return found.(*counters)
+}
+
+/*miss counts a miss and leaves the key waiting for its
+fill.  pending is true while a KeyPresent miss waits for the
+GetValue that usually follows it, which is the same lookup
+and isn't counted twice*/
+func (m *Metered) miss(k string, probe bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	probed, waiting := m.pending[k]
+	if !probe && probed {
+		m.pending[k] = false
+		return
+	}
+	m.total.miss()
+	m.bucketOf(k).miss()
+	if !waiting && len(m.pending) >= maxPendingMisses {
+		m.pending = make(map[string]bool)
+	}
+	m.pending[k] = probe
+}
+
+func (m *Metered) removed(key string, entry Entry, reason RemovalReason) {
+	if reason == Evicted {
+		m.total.evicted()
+		m.bucketOf(key).evicted()
+	}
+}
+
+/*KeyPresent checks the wrapped cache, counting a miss when
+the key isn't there (a hit is counted by the GetValue that
+follows)*/
+func (m *Metered) KeyPresent(k string) bool {
+	present := m.cache.KeyPresent(k)
+	if !present {
+		m.miss(k, true)
+	}
+	return present
+}
+
+/*GetValue reads from the wrapped cache, counting the hit or
+miss*/
+func (m *Metered) GetValue(k string) (Entry, error) {
+	entry, err := m.cache.GetValue(k)
+	if err != nil {
+		m.miss(k, false)
+		return entry, err
+	}
+	m.total.hit(entry.cost)
+	m.bucketOf(k).hit(entry.cost)
+	return entry, nil
+}
+
+/*SetValue writes to the wrapped cache, charging the cost to
+the miss it fills if there was one*/
+func (m *Metered) SetValue(k string, v Entry) error {
+	m.mu.Lock()
+	if _, waiting := m.pending[k]; waiting {
+		delete(m.pending, k)
+		m.total.filled(v.cost)
+		m.bucketOf(k).filled(v.cost)
+	}
+	m.mu.Unlock()
+	return m.cache.SetValue(k, v)
+}
+
+/*Delete removes the key from the wrapped cache*/
+func (m *Metered) Delete(k string) error {
+	m.mu.Lock()
+	delete(m.pending, k)
+	m.mu.Unlock()
+	return m.cache.Delete(k)
+}
+
+/*Export lists the wrapped cache's entries, if it can*/
+func (m *Metered) Export() []Record {
+	exporter, ok := m.cache.(Exporter)
+	if !ok {
+		return []Record{}
+	}
+	return exporter.Export()
+}
+
+/*Import puts the record into the wrapped cache without
+counting it as a fill*/
+func (m *Metered) Import(r Record) error {
+	return ImportRecord(m.cache, r)
+}
+
+/*Unwrap is the cache being metered*/
+func (m *Metered) Unwrap() Cache {
+	return m.cache
+}
+
+/*Stats reads the counters*/
+func (m *Metered) Stats() Stats {
+	return m.total.stats()
+}
+
+/*BucketStats reads the counters of every bucket seen so far,
+empty without buckets*/
+func (m *Metered) BucketStats() map[string]Stats {
+	stats := make(map[string]Stats)
+	m.buckets.Range(func(name, found interface{}) bool {
+		stats[name.(string)] = found.(*counters).stats()
+		return true
+	})
+	return stats
+}
+
+/*Reset zeroes the counters, the buckets' too, returning what
+the totals were*/
+func (m *Metered) Reset() Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pending = make(map[string]bool)
+	m.buckets.Range(func(name, found interface{}) bool {
+		found.(*counters).reset()
+		return true
+	})
+	return m.total.reset()
+}
+
+/*NewMetered starts counting the cache's hits and misses*/
+func NewMetered(c Cache) *Metered {
+	return NewBucketedMetered(c, nil)
+}
+
+/*NewBucketedMetered also counts per bucket, bucket naming the
+one a key falls in (ByNamespace and ByPrefix are the usual
+ones).  Every name it returns keeps its own counters, so it
+should only return a handful*/
+func NewBucketedMetered(c Cache, bucket func(key string) string) *Metered {
+	m := &Metered{cache: c, pending: make(map[string]bool), bucket: bucket}
+	AddRemovalListener(c, m.removed)
+	return m
+}
+
+/*ByNamespace buckets keys by what comes before the first
+separator, "billing" for "billing:invoice:7" split on ":".
+Keys without one fall in the "" bucket*/
+func ByNamespace(sep string) func(key string) string {
+	return func(key string) string {
+		idx := strings.Index(key, sep)
+		if idx < 0 {
+			return ""
+		}
+		return key[:idx]
+	}
+}
+
+/*ByPrefix buckets keys by the longest of the prefixes they
+start with, or "" if none*/
+func ByPrefix(prefixes ...string) func(key string) string {
+	return func(key string) string {
+		longest := ""
+		for _, prefix := range prefixes {
+			if len(prefix) > len(longest) && strings.HasPrefix(key, prefix) {
+				longest = prefix
+			}
+		}
+		return longest
+	}
+}
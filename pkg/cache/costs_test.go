@@ -0,0 +1,79 @@
+package cache
+
+import "testing"
+
+func TestMeteredCountsEveryMiss(t *testing.T) {
+	lru, _ := NewCache(LRU, 10)
+	m := NewMetered(lru)
+	for i := 0; i < 100; i++ {
+		m.KeyPresent("absent")
+	}
+	if stats := m.Stats(); stats.Misses != 100 || stats.CostMissed != 0 {
+		t.Fatalf("expected 100 unfilled misses, got %+v", stats)
+	}
+}
+
+func TestMeteredCountsProbeAndGetOnce(t *testing.T) {
+	lru, _ := NewCache(LRU, 10)
+	m := NewMetered(lru)
+	if !m.KeyPresent("k") {
+		m.GetValue("k")
+	}
+	m.GetValue("k")
+	if stats := m.Stats(); stats.Misses != 2 {
+		t.Fatalf("expected the probe and get to count once plus one plain get miss, got %+v", stats)
+	}
+}
+
+func TestMeteredChargesFillCost(t *testing.T) {
+	lru, _ := NewCache(LRU, 10)
+	m := NewMetered(lru)
+	m.KeyPresent("k")
+	m.SetValue("k", NewEntry("v", 7))
+	m.SetValue("k", NewEntry("v", 9))
+	m.GetValue("k")
+	m.GetValue("k")
+	stats := m.Stats()
+	if stats.Hits != 2 || stats.Misses != 1 || stats.CostMissed != 7 || stats.CostSaved != 18 {
+		t.Fatalf("unexpected counts %+v", stats)
+	}
+	if stats.HitRatio() < 0.66 || stats.HitRatio() > 0.67 {
+		t.Fatalf("hit ratio %f", stats.HitRatio())
+	}
+	if reset := m.Reset(); reset != stats || m.Stats() != (Stats{}) {
+		t.Fatalf("reset returned %+v, left %+v", reset, m.Stats())
+	}
+}
+
+func TestMeteredCountsPerBucket(t *testing.T) {
+	lru, _ := NewCache(LRU, 2)
+	m := NewBucketedMetered(lru, ByNamespace(":"))
+	m.GetValue("billing:1")
+	m.SetValue("billing:1", NewEntry("a", 5))
+	m.GetValue("billing:1")
+	m.SetValue("search:1", NewEntry("b", 1))
+	m.SetValue("search:2", NewEntry("c", 1))
+	m.SetValue("search:3", NewEntry("d", 1))
+	buckets := m.BucketStats()
+	billing, search := buckets["billing"], buckets["search"]
+	if billing.Hits != 1 || billing.Misses != 1 || billing.CostMissed != 5 || billing.Evictions != 1 {
+		t.Fatalf("unexpected billing stats %+v", billing)
+	}
+	if search.Evictions != 1 || search.Hits != 0 {
+		t.Fatalf("unexpected search stats %+v", search)
+	}
+	if total := m.Stats(); total.Evictions != 2 || total.Hits != 1 {
+		t.Fatalf("unexpected totals %+v", total)
+	}
+	m.Reset()
+	if m.BucketStats()["billing"] != (Stats{}) {
+		t.Fatalf("reset left the buckets counting")
+	}
+}
+
+func TestByPrefixTakesTheLongest(t *testing.T) {
+	bucket := ByPrefix("img/", "img/thumbs/", "api/")
+	if bucket("img/thumbs/1") != "img/thumbs/" || bucket("img/2") != "img/" || bucket("css/3") != "" {
+		t.Fatalf("bucketed into the wrong prefixes")
+	}
+}
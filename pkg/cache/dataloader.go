@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+/*BatchLoader computes the entries for many keys in one go, a
+single query with an IN clause say.  Keys it leaves out of the
+map don't exist*/
+type BatchLoader interface {
+	LoadBatch(keys []string) (map[string]Entry, error)
+}
+
+/*BatchLoaderFunc lets a plain function be used as a
+BatchLoader*/
+type BatchLoaderFunc func(keys []string) (map[string]Entry, error)
+
+/*LoadBatch calls the function*/
+func (f BatchLoaderFunc) LoadBatch(keys []string) (map[string]Entry, error) { return f(keys) }
+
+/*DataLoaderOptions configures a DataLoader.  Wait is how long
+the first miss waits for others to join its batch (a
+millisecond if 0), MaxBatch sends a batch early once it has
+that many keys (0 for no limit)*/
+type DataLoaderOptions struct {
+	Wait     time.Duration
+	MaxBatch int
+}
+
+/*pendingLoad is one key's result, closed once it's in*/
+type pendingLoad struct {
+	done  chan struct{}
+	entry Entry
+	err   error
+}
+
+/*DataLoader is made for one request (a GraphQL query usually)
+to stop its resolvers loading the same expensive things one
+at a time, N+1 times.  Keys are looked up in the shared cache
+first; the misses are collected for a short wait and loaded
+with a single LoadBatch call, whose entries go into the shared
+cache for later requests.  Within the request every key is
+only looked up once, asking again gets the same result even
+if the shared cache has since dropped it.  Entries the loader
+returns without a cost get an equal share of the batch's
+microseconds.  It's safe for the request's goroutines to share,
+the shared cache must be safe for concurrent use too*/
+type DataLoader struct {
+	shared  Cache
+	loader  BatchLoader
+	opts    DataLoaderOptions
+	mu      sync.Mutex
+	seen    map[string]*pendingLoad
+	batch   []string
+	pending map[string]*pendingLoad
+	timer   *time.Timer
+}
+
+/*Load is the key's entry, from the request's results, the
+shared cache or the next batch, ErrKeyNotFound if the loader
+didn't return it*/
+func (dl *DataLoader) Load(k string) (Entry, error) {
+	dl.mu.Lock()
+	load, ok := dl.seen[k]
+	if ok {
+		dl.mu.Unlock()
+		<-load.done
+		return load.entry, load.err
+	}
+	load = &pendingLoad{done: make(chan struct{})}
+	dl.seen[k] = load
+	entry, err := dl.shared.GetValue(k)
+	if err == nil {
+		load.entry = entry
+		close(load.done)
+		dl.mu.Unlock()
+		return entry, nil
+	}
+	dl.batch = append(dl.batch, k)
+	dl.pending[k] = load
+	if dl.opts.MaxBatch > 0 && len(dl.batch) >= dl.opts.MaxBatch {
+		dl.dispatchLocked()
+	} else if dl.timer == nil {
+		dl.timer = time.AfterFunc(dl.opts.Wait, dl.dispatch)
+	}
+	dl.mu.Unlock()
+	<-load.done
+	return load.entry, load.err
+}
+
+/*LoadMany loads the keys together, an entry and an error
+for each*/
+func (dl *DataLoader) LoadMany(keys []string) ([]Entry, []error) {
+	entries := make([]Entry, len(keys))
+	errs := make([]error, len(keys))
+	var wg sync.WaitGroup
+	for i, k := range keys {
+		wg.Add(1)
+		go func(i int, k string) {
+			defer wg.Done()
+			entries[i], errs[i] = dl.Load(k)
+		}(i, k)
+	}
+	wg.Wait()
+	return entries, errs
+}
+
+func (dl *DataLoader) dispatch() {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.dispatchLocked()
+}
+
+/*dispatchLocked sends the batch off to be loaded, the caller
+holds the lock*/
+func (dl *DataLoader) dispatchLocked() {
+	if dl.timer != nil {
+		dl.timer.Stop()
+		dl.timer = nil
+	}
+	if len(dl.batch) == 0 {
+		return
+	}
+	keys, loads := dl.batch, dl.pending
+	dl.batch = nil
+	dl.pending = map[string]*pendingLoad{}
+	go dl.run(keys, loads)
+}
+
+/*run loads a batch and hands out the results*/
+func (dl *DataLoader) run(keys []string, loads map[string]*pendingLoad) {
+	start := time.Now()
+	found, err := dl.loader.LoadBatch(keys)
+	share := int(time.Since(start)/time.Microsecond) / len(keys)
+	for _, k := range keys {
+		load := loads[k]
+		entry, ok := found[k]
+		if err != nil {
+			load.err = err
+		} else if !ok {
+			load.err = ErrKeyNotFound
+		} else {
+			if entry.cost == 0 {
+				entry.cost = share
+			}
+			dl.shared.SetValue(k, entry)
+			load.entry = entry
+		}
+		close(load.done)
+	}
+}
+
+/*NewDataLoader batches one request's misses on the shared
+cache into calls to the loader:
+
+	loader := cache.NewDataLoader(shared, authors, cache.DataLoaderOptions{})
+	author, err := loader.Load(post.AuthorID)*/
+func NewDataLoader(shared Cache, loader BatchLoader, opts DataLoaderOptions) *DataLoader {
+	if opts.Wait <= 0 {
+		opts.Wait = time.Millisecond
+	}
+	return &DataLoader{shared: shared, loader: loader, opts: opts, seen: map[string]*pendingLoad{}, pending: map[string]*pendingLoad{}}
+}
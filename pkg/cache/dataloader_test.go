@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDataLoaderBatchesMisses(t *testing.T) {
+	var mu sync.Mutex
+	batches := [][]string{}
+	authors := BatchLoaderFunc(func(keys []string) (map[string]Entry, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		sorted := append([]string{}, keys...)
+		sort.Strings(sorted)
+		batches = append(batches, sorted)
+		found := map[string]Entry{}
+		for _, k := range keys {
+			if k != "ghost" {
+				found[k] = NewEntry("author "+k, 0)
+			}
+		}
+		return found, nil
+	})
+	lru, _ := NewCache(LRU, 10)
+	shared := NewBatched(lru)
+	request := NewDataLoader(shared, authors, DataLoaderOptions{Wait: 50 * time.Millisecond})
+	entries, errs := request.LoadMany([]string{"ann", "bob", "ann", "ghost"})
+	if len(batches) != 1 || len(batches[0]) != 3 {
+		t.Fatalf("expected one batch of the three distinct keys, got %v", batches)
+	}
+	if entries[0].Value() != "author ann" || entries[2].Value() != "author ann" || errs[1] != nil {
+		t.Fatalf("unexpected entries %v %v", entries, errs)
+	}
+	if !errors.Is(errs[3], ErrKeyNotFound) {
+		t.Fatalf("expected a key the loader left out not found, got %v", errs[3])
+	}
+	next := NewDataLoader(shared, authors, DataLoaderOptions{MaxBatch: 1})
+	if entry, err := next.Load("bob"); err != nil || entry.Value() != "author bob" || len(batches) != 1 {
+		t.Fatalf("expected the next request served from the shared cache")
+	}
+	next.LoadMany([]string{"cat", "dan"})
+	if len(batches) != 3 {
+		t.Fatalf("expected MaxBatch to send each miss on its own, got %v", batches)
+	}
+}
+
+func TestDataLoaderSharesErrors(t *testing.T) {
+	lru, _ := NewCache(LRU, 10)
+	failing := BatchLoaderFunc(func(keys []string) (map[string]Entry, error) {
+		return nil, errors.New("Database down")
+	})
+	_, errs := NewDataLoader(NewBatched(lru), failing, DataLoaderOptions{}).LoadMany([]string{"a", "b"})
+	if errs[0] == nil || errs[1] == nil || lru.KeyPresent("a") {
+		t.Fatalf("expected the batch's error for every key, got %v", errs)
+	}
+}
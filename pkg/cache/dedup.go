@@ -0,0 +1,152 @@
+package cache
+
+import "crypto/sha256"
+
+/*blob is one stored value and how many keys point at it*/
+type blob struct {
+	value string
+	refs  int
+}
+
+/*Deduped stores each distinct value once, however many keys
+hold it: the policy holds the value's SHA-256 for each key,
+and the value itself lives in a table keyed by that hash,
+counting the keys pointing at it and dropped with the last of
+them.  It pays off when many keys map to the same big computed
+blob; a value no longer than the 32 byte hash saves nothing.
+Everything has to go through the Deduped (the policy
+underneath only sees hashes), and like Indexed it holds its
+lock around every call*/
+type Deduped struct {
+	lockedInner
+	blobs map[string]*blob
+}
+
+func (d *Deduped) store(v Entry) Entry {
+	sum := sha256.Sum256([]byte(v.value))
+	hash := string(sum[:])
+	found, ok := d.blobs[hash]
+	if !ok {
+		found = &blob{value: v.value}
+		d.blobs[hash] = found
+	}
+	found.refs++
+	v.value = hash
+	return v
+}
+
+func (d *Deduped) release(v Entry) {
+	found, ok := d.blobs[v.value]
+	if !ok {
+		return
+	}
+	found.refs--
+	if found.refs <= 0 {
+		delete(d.blobs, v.value)
+	}
+}
+
+func (d *Deduped) load(v Entry) Entry {
+	found, ok := d.blobs[v.value]
+	if ok {
+		v.value = found.value
+	}
+	return v
+}
+
+/*KeyPresent is true if the key is cached right now*/
+func (d *Deduped) KeyPresent(k string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.cache.KeyPresent(k)
+}
+
+/*GetValue reads the entry, looking its value up by hash*/
+func (d *Deduped) GetValue(k string) (Entry, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entry, err := d.cache.GetValue(k)
+	if err != nil {
+		return entry, err
+	}
+	return d.load(entry), nil
+}
+
+/*SetValue stores the value if it's new and caches its hash*/
+func (d *Deduped) SetValue(k string, v Entry) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stored := d.store(v)
+	err := d.cache.SetValue(k, stored)
+	if err != nil {
+		d.release(stored)
+	}
+	return err
+}
+
+/*Delete removes the key, dropping its value if nothing else
+holds it*/
+func (d *Deduped) Delete(k string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.cache.Delete(k)
+}
+
+func (d *Deduped) onRemoval(key string, entry Entry, reason RemovalReason) {
+	// called from inside the wrapped cache, so the lock is already held
+	d.release(entry)
+}
+
+/*Usage is how many bytes of values the keys hold between them
+and how many are actually stored*/
+func (d *Deduped) Usage() (int64, int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	logical, stored := int64(0), int64(0)
+	for _, found := range d.blobs {
+		logical += int64(len(found.value) * found.refs)
+		stored += int64(len(found.value))
+	}
+	return logical, stored
+}
+
+/*Export lists the wrapped cache's entries with their values*/
+func (d *Deduped) Export() []Record {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	exporter, ok := d.cache.(Exporter)
+	if !ok {
+		return []Record{}
+	}
+	records := exporter.Export()
+	for i := range records {
+		records[i].Entry = d.load(records[i].Entry)
+	}
+	return records
+}
+
+/*Import stores the record's value like SetValue*/
+func (d *Deduped) Import(r Record) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r.Entry = d.store(r.Entry)
+	err := ImportRecord(d.cache, r)
+	if err != nil || !d.cache.KeyPresent(r.Key) {
+		// refused or already expired, nothing holds the value
+		d.release(r.Entry)
+	}
+	return err
+}
+
+/*Unwrap is the cache holding the hashes*/
+func (d *Deduped) Unwrap() Cache {
+	return d.cache
+}
+
+/*NewDeduped stores the cache's values once each.  The cache
+should be empty, any value already in it isn't a hash*/
+func NewDeduped(c Cache) *Deduped {
+	d := &Deduped{lockedInner: lockedInner{cache: c}, blobs: make(map[string]*blob)}
+	AddRemovalListener(c, d.onRemoval)
+	return d
+}
@@ -0,0 +1,31 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDedupedStoresValuesOnce(t *testing.T) {
+	lru, _ := NewCache(LRU, 3)
+	c := NewDeduped(lru)
+	big := strings.Repeat("x", 1000)
+	c.SetValue("a", NewEntry(big, 1))
+	c.SetValue("b", NewEntry(big, 1))
+	c.SetValue("c", NewEntry(big, 1))
+	if logical, stored := c.Usage(); logical != 3000 || stored != 1000 {
+		t.Fatalf("expected one copy for three keys, got %d for %d", stored, logical)
+	}
+	if entry, err := c.GetValue("b"); err != nil || entry.Value() != big {
+		t.Fatalf("expected the value back, got %v", err)
+	}
+	c.SetValue("b", NewEntry("other", 1))
+	c.Delete("a")
+	c.SetValue("d", NewEntry("other", 1))
+	if _, stored := c.Usage(); stored != 1005 {
+		t.Fatalf("expected big kept for c and other stored once, got %d", stored)
+	}
+	c.Delete("c")
+	if _, stored := c.Usage(); stored != 5 {
+		t.Fatalf("expected big dropped with its last key, got %d", stored)
+	}
+}
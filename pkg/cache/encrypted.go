@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"sync"
+)
+
+/*ErrDecrypt is returned for a value that can't be decrypted,
+because its key is gone or it was tampered with*/
+var ErrDecrypt = errors.New("Value failed to decrypt")
+
+/*KeyProvider hands out AES keys (16, 24 or 32 bytes) by id,
+so keys can live in a KMS and be rotated: new values are
+sealed with the current key, and each value remembers the id
+of the one that sealed it*/
+type KeyProvider interface {
+	CurrentKey() (id string, key []byte, err error)
+	Key(id string) ([]byte, error)
+}
+
+/*staticKey is a single key that never rotates*/
+type staticKey []byte
+
+func (s staticKey) CurrentKey() (string, []byte, error) { return "", s, nil }
+
+func (s staticKey) Key(id string) ([]byte, error) {
+	if id != "" {
+		return nil, errors.New("No key " + id)
+	}
+	return s, nil
+}
+
+/*StaticKey provides the one key for everything*/
+func StaticKey(key []byte) KeyProvider {
+	return staticKey(key)
+}
+
+/*Encrypted seals every value with AES-GCM before it reaches
+the cache underneath, and opens it again on the way out, so
+what the policy holds (and what Export hands a snapshot or a
+disk tier) is ciphertext.  The cache key is bound into each
+seal, so a value moved to another key won't open.  A stored
+value is the key id, a nonce and the sealed value; the cost
+and deadlines are left as they are.  It adds no locking, the
+ciphers it has built are kept in a sync.Map (so a key the
+provider revokes still opens old values until a restart)*/
+type Encrypted struct {
+	cache    Cache
+	provider KeyProvider
+	ciphers  sync.Map
+}
+
+func (e *Encrypted) aead(id string, key []byte) (cipher.AEAD, error) {
+	found, ok := e.ciphers.Load(id)
+	if ok {
+		return found.(cipher.AEAD), nil
+	}
+	if key == nil {
+		var err error
+		key, err = e.provider.Key(id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	e.ciphers.Store(id, aead)
+	return aead, nil
+}
+
+func (e *Encrypted) seal(k string, v Entry) (Entry, error) {
+	id, key, err := e.provider.CurrentKey()
+	if err != nil {
+		return v, err
+	}
+	if len(id) > 255 {
+		return v, errors.New("Key id " + id + " is too long")
+	}
+	aead, err := e.aead(id, key)
+	if err != nil {
+		return v, err
+	}
+	out := make([]byte, 1+len(id)+aead.NonceSize(), 1+len(id)+aead.NonceSize()+len(v.value)+aead.Overhead())
+	out[0] = byte(len(id))
+	copy(out[1:], id)
+	nonce := out[1+len(id):]
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return v, err
+	}
+	v.value = string(aead.Seal(out, nonce, []byte(v.value), []byte(k)))
+	return v, nil
+}
+
+func (e *Encrypted) open(k string, v Entry) (Entry, error) {
+	if len(v.value) < 1 || len(v.value) < 1+int(v.value[0]) {
+		return Entry{}, ErrDecrypt
+	}
+	id := v.value[1 : 1+int(v.value[0])]
+	aead, err := e.aead(id, nil)
+	if err != nil {
+		return Entry{}, ErrDecrypt
+	}
+	sealed := v.value[1+len(id):]
+	if len(sealed) < aead.NonceSize() {
+		return Entry{}, ErrDecrypt
+	}
+	plain, err := aead.Open(nil, []byte(sealed[:aead.NonceSize()]), []byte(sealed[aead.NonceSize():]), []byte(k))
+	if err != nil {
+		return Entry{}, ErrDecrypt
+	}
+	v.value = string(plain)
+	return v, nil
+}
+
+/*KeyPresent is true if the key is cached right now*/
+func (e *Encrypted) KeyPresent(k string) bool {
+	return e.cache.KeyPresent(k)
+}
+
+/*GetValue reads the entry and decrypts it*/
+func (e *Encrypted) GetValue(k string) (Entry, error) {
+	entry, err := e.cache.GetValue(k)
+	if err != nil {
+		return entry, err
+	}
+	return e.open(k, entry)
+}
+
+/*SetValue encrypts the entry and writes it*/
+func (e *Encrypted) SetValue(k string, v Entry) error {
+	sealed, err := e.seal(k, v)
+	if err != nil {
+		return err
+	}
+	return e.cache.SetValue(k, sealed)
+}
+
+/*Delete removes the key from the wrapped cache*/
+func (e *Encrypted) Delete(k string) error {
+	return e.cache.Delete(k)
+}
+
+/*Export lists the wrapped cache's entries still encrypted, so
+a snapshot of them is too*/
+func (e *Encrypted) Export() []Record {
+	exporter, ok := e.cache.(Exporter)
+	if !ok {
+		return []Record{}
+	}
+	return exporter.Export()
+}
+
+/*Import puts an encrypted record (from Export) back as it is*/
+func (e *Encrypted) Import(r Record) error {
+	return ImportRecord(e.cache, r)
+}
+
+/*Unwrap is the cache holding the ciphertext*/
+func (e *Encrypted) Unwrap() Cache {
+	return e.cache
+}
+
+/*NewEncrypted encrypts the cache's values with keys from the
+provider, checking the current key works first*/
+func NewEncrypted(c Cache, provider KeyProvider) (*Encrypted, error) {
+	e := &Encrypted{cache: c, provider: provider}
+	id, key, err := provider.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	_, err = e.aead(id, key)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+/*rotatingKeys is a KeyProvider whose current key can change*/
+type rotatingKeys struct {
+	current string
+	keys    map[string][]byte
+}
+
+func (r *rotatingKeys) CurrentKey() (string, []byte, error) {
+	return r.current, r.keys[r.current], nil
+}
+
+func (r *rotatingKeys) Key(id string) ([]byte, error) {
+	key, ok := r.keys[id]
+	if !ok {
+		return nil, errors.New("No key " + id)
+	}
+	return key, nil
+}
+
+func TestEncryptedValuesAtRest(t *testing.T) {
+	lru, _ := NewCache(LRU, 10)
+	c, err := NewEncrypted(lru, StaticKey([]byte("0123456789abcdef")))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	c.SetValue("card", NewEntry("4111 1111 1111 1111", 7))
+	stored, _ := lru.GetValue("card")
+	if strings.Contains(stored.Value(), "4111") || stored.Cost() != 7 {
+		t.Fatalf("expected the value sealed and the cost kept, got %q", stored.Value())
+	}
+	entry, err := c.GetValue("card")
+	if err != nil || entry.Value() != "4111 1111 1111 1111" {
+		t.Fatalf("expected the value back, got %q and %v", entry.Value(), err)
+	}
+	lru.SetValue("moved", stored)
+	if _, err := c.GetValue("moved"); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("expected a value under another key to fail, got %v", err)
+	}
+	if _, err := NewEncrypted(lru, StaticKey([]byte("short"))); err == nil {
+		t.Fatalf("expected a bad key refused")
+	}
+}
+
+func TestEncryptedKeysRotate(t *testing.T) {
+	keys := &rotatingKeys{current: "v1", keys: map[string][]byte{
+		"v1": []byte("0123456789abcdef"),
+		"v2": []byte("fedcba9876543210fedcba9876543210"),
+	}}
+	lru, _ := NewCache(LRU, 10)
+	c, _ := NewEncrypted(lru, keys)
+	c.SetValue("old", NewEntry("a", 1))
+	keys.current = "v2"
+	c.SetValue("new", NewEntry("b", 1))
+	old, err := c.GetValue("old")
+	if err != nil || old.Value() != "a" {
+		t.Fatalf("expected a value sealed with the old key to open, got %v", err)
+	}
+	delete(keys.keys, "v1")
+	if entry, err := c.GetValue("new"); err != nil || entry.Value() != "b" {
+		t.Fatalf("expected the new key used, got %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+package cache
+
+/*Record is one resident entry along with the policy
+metadata needed to rebuild it in another cache*/
+type Record struct {
+	Key   string
+	Entry Entry
+	Hits  int
+}
+
+/*Exporter is implemented by caches that can list their
+resident entries.  Records come out in eviction order (the
+next victim first) so importing them in order rebuilds the
+same recency ordering*/
+type Exporter interface {
+	Export() []Record
+}
+
+/*Importer is implemented by caches that can take a record
+back in without losing its metadata (access counts etc)*/
+type Importer interface {
+	Import(r Record) error
+}
+
+/*ImportRecord puts a record into any cache, keeping its
+metadata if the cache knows how*/
+func ImportRecord(c Cache, r Record) error {
+	importer, ok := c.(Importer)
+	if ok {
+		return importer.Import(r)
+	}
+	return c.SetValue(r.Key, r.Entry)
+}
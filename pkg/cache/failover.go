@@ -0,0 +1,185 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var errPrimaryTimeout = errors.New("Primary cache timed out")
+
+/*FailoverOptions configures a Failover.  Timeout bounds each
+call to the primary (0 waits as long as it takes), Cooldown is
+how long to stay on the secondary after the primary fails
+before trying it again (a second if 0)*/
+type FailoverOptions struct {
+	Timeout  time.Duration
+	Cooldown time.Duration
+}
+
+/*FailoverStats counts how a Failover has gone: Failovers is
+how many times the primary failed and was taken out, Degraded
+how many calls the secondary answered instead*/
+type FailoverStats struct {
+	Failovers int64
+	Degraded  int64
+	Down      bool
+}
+
+/*Failover puts a secondary cache (or nothing, to pass every
+call through as a miss) behind a primary that can fail, a
+remote tier usually.  A call to the primary that errors (a
+miss isn't an error) or runs past the timeout takes the
+primary out for the cooldown, and the call and everything
+after it go to the secondary until then.  A call that timed
+out is abandoned, left to finish on its own.  While the
+primary is up, writes and deletes also drop the key from the
+secondary, so it never has anything older than the primary
+in the next outage.  KeyPresent can't see a primary's errors,
+only its timeouts*/
+type Failover struct {
+	primary   Cache
+	secondary Cache
+	opts      FailoverOptions
+	mu        sync.Mutex
+	downUntil time.Time
+	failovers int64
+	degraded  int64
+}
+
+/*up is true if the primary should be tried, counting a call
+to the secondary if not*/
+func (f *Failover) up() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if clockNow().Before(f.downUntil) {
+		atomic.AddInt64(&f.degraded, 1)
+		return false
+	}
+	return true
+}
+
+/*failed takes the primary out for the cooldown*/
+func (f *Failover) failed() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if clockNow().Before(f.downUntil) {
+		// a call that started before it went down
+		atomic.AddInt64(&f.degraded, 1)
+		return
+	}
+	f.downUntil = clockNow().Add(f.opts.Cooldown)
+	atomic.AddInt64(&f.failovers, 1)
+	atomic.AddInt64(&f.degraded, 1)
+}
+
+/*call runs fn against the primary, giving up after the
+timeout*/
+func (f *Failover) call(fn func() error) error {
+	if f.opts.Timeout <= 0 {
+		return fn()
+	}
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+	timer := time.NewTimer(f.opts.Timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return errPrimaryTimeout
+	}
+}
+
+func failure(err error) bool {
+	return err != nil && !errors.Is(err, ErrKeyNotFound)
+}
+
+/*KeyPresent asks the primary, or the secondary while it's
+down*/
+func (f *Failover) KeyPresent(k string) bool {
+	if f.up() {
+		present := false
+		err := f.call(func() error {
+			present = f.primary.KeyPresent(k)
+			return nil
+		})
+		if err == nil {
+			return present
+		}
+		f.failed()
+	}
+	return f.secondary.KeyPresent(k)
+}
+
+/*GetValue reads from the primary, or the secondary while it's
+down*/
+func (f *Failover) GetValue(k string) (Entry, error) {
+	if f.up() {
+		var entry Entry
+		err := f.call(func() error {
+			var err error
+			entry, err = f.primary.GetValue(k)
+			return err
+		})
+		if !failure(err) {
+			return entry, err
+		}
+		f.failed()
+	}
+	return f.secondary.GetValue(k)
+}
+
+/*SetValue writes to the primary, or the secondary while it's
+down*/
+func (f *Failover) SetValue(k string, v Entry) error {
+	if f.up() {
+		err := f.call(func() error { return f.primary.SetValue(k, v) })
+		if !failure(err) {
+			f.secondary.Delete(k)
+			return err
+		}
+		f.failed()
+	}
+	return f.secondary.SetValue(k, v)
+}
+
+/*Delete removes the key from the primary, or the secondary
+while it's down*/
+func (f *Failover) Delete(k string) error {
+	if f.up() {
+		err := f.call(func() error { return f.primary.Delete(k) })
+		if !failure(err) {
+			f.secondary.Delete(k)
+			return err
+		}
+		f.failed()
+	}
+	return f.secondary.Delete(k)
+}
+
+/*Stats reads the counters*/
+func (f *Failover) Stats() FailoverStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return FailoverStats{
+		Failovers: atomic.LoadInt64(&f.failovers),
+		Degraded:  atomic.LoadInt64(&f.degraded),
+		Down:      clockNow().Before(f.downUntil),
+	}
+}
+
+/*NewFailover falls back from the primary to the secondary,
+which can be nil to pass calls through as misses.  The
+secondary is used from whichever goroutine made the call, so
+it should be safe for concurrent use if they are*/
+func NewFailover(primary Cache, secondary Cache, opts FailoverOptions) *Failover {
+	if secondary == nil {
+		secondary = &NoOp{}
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = time.Second
+	}
+	return &Failover{primary: primary, secondary: secondary, opts: opts}
+}
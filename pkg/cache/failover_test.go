@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+/*flakyCache fails every call while down*/
+type flakyCache struct {
+	Cache
+	down bool
+	slow chan bool
+}
+
+func (f *flakyCache) GetValue(k string) (Entry, error) {
+	if f.slow != nil {
+		<-f.slow
+	}
+	if f.down {
+		return Entry{}, errors.New("connection refused")
+	}
+	return f.Cache.GetValue(k)
+}
+
+func (f *flakyCache) SetValue(k string, v Entry) error {
+	if f.down {
+		return errors.New("connection refused")
+	}
+	return f.Cache.SetValue(k, v)
+}
+
+func TestFailoverFallsBackAndRecovers(t *testing.T) {
+	clock := useManualClock(t)
+	remote, _ := NewCache(LRU, 10)
+	primary := &flakyCache{Cache: remote}
+	local, _ := NewCache(LRU, 10)
+	c := NewFailover(primary, local, FailoverOptions{Cooldown: time.Minute})
+	c.SetValue("a", NewEntry("remote", 1))
+	if _, err := c.GetValue("b"); !errors.Is(err, ErrKeyNotFound) || c.Stats().Failovers != 0 {
+		t.Fatalf("expected a miss not to count as a failure, got %v", err)
+	}
+	primary.down = true
+	c.SetValue("a", NewEntry("local", 1))
+	if entry, err := c.GetValue("a"); err != nil || entry.Value() != "local" {
+		t.Fatalf("expected the secondary to take over, got %v", err)
+	}
+	if stats := c.Stats(); stats.Failovers != 1 || stats.Degraded != 2 || !stats.Down {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	primary.down = false
+	clock.Advance(2 * time.Minute)
+	if entry, _ := c.GetValue("a"); entry.Value() != "remote" {
+		t.Fatalf("expected the primary back after the cooldown, got %q", entry.Value())
+	}
+	c.SetValue("a", NewEntry("remote2", 1))
+	if local.KeyPresent("a") {
+		t.Fatalf("expected a healthy write to drop the secondary's stale copy")
+	}
+}
+
+func TestFailoverTimesOut(t *testing.T) {
+	remote, _ := NewCache(LRU, 10)
+	primary := &flakyCache{Cache: remote, slow: make(chan bool)}
+	defer close(primary.slow)
+	c := NewFailover(primary, nil, FailoverOptions{Timeout: 10 * time.Millisecond})
+	if _, err := c.GetValue("a"); !errors.Is(err, ErrKeyNotFound) || c.Stats().Failovers != 1 {
+		t.Fatalf("expected a slow primary passed through as a miss, got %v", err)
+	}
+}
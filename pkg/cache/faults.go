@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+/*ErrInjected is what a FaultInjected cache fails calls with
+when FaultOptions doesn't give an error of its own*/
+var ErrInjected = errors.New("Injected fault")
+
+/*FaultOptions says how a FaultInjected cache misbehaves.  The
+rates are the share of calls (0 to 1) that fail with Err (or
+ErrInjected); GetErrorRate fails reads as though the backend
+were down, which callers should treat like a miss.  The
+latencies are added to every call of the kind, and
+DropEvictions is the share of evictions the removal listeners
+never hear about.  Seed makes the failures repeatable*/
+type FaultOptions struct {
+	SetErrorRate    float64
+	GetErrorRate    float64
+	DeleteErrorRate float64
+	GetLatency      time.Duration
+	SetLatency      time.Duration
+	DropEvictions   float64
+	Err             error
+	Seed            int64
+}
+
+/*FaultInjected makes the wrapped cache fail and slow down on
+purpose, so code embedding a cache can be tested in degraded
+mode: what it does when writes fail, reads are slow or it
+misses an eviction.  SetFaults changes the faults as the
+test goes.  It's safe for concurrent use if the wrapped cache
+is*/
+type FaultInjected struct {
+	cache Cache
+	mu    sync.Mutex
+	opts  FaultOptions
+	rnd   *rand.Rand
+}
+
+/*faults is the current options, with the error to fail
+with*/
+func (f *FaultInjected) faults() FaultOptions {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	opts := f.opts
+	if opts.Err == nil {
+		opts.Err = ErrInjected
+	}
+	return opts
+}
+
+/*chance is true a rate's share of the time*/
+func (f *FaultInjected) chance(rate float64) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rnd.Float64() < rate
+}
+
+/*KeyPresent checks the wrapped cache, slowed like a read*/
+func (f *FaultInjected) KeyPresent(k string) bool {
+	opts := f.faults()
+	time.Sleep(opts.GetLatency)
+	if f.chance(opts.GetErrorRate) {
+		return false
+	}
+	return f.cache.KeyPresent(k)
+}
+
+/*GetValue reads from the wrapped cache, unless it's chosen to
+fail*/
+func (f *FaultInjected) GetValue(k string) (Entry, error) {
+	opts := f.faults()
+	time.Sleep(opts.GetLatency)
+	if f.chance(opts.GetErrorRate) {
+		return Entry{}, opts.Err
+	}
+	return f.cache.GetValue(k)
+}
+
+/*SetValue writes to the wrapped cache, unless it's chosen to
+fail*/
+func (f *FaultInjected) SetValue(k string, v Entry) error {
+	opts := f.faults()
+	time.Sleep(opts.SetLatency)
+	if f.chance(opts.SetErrorRate) {
+		return opts.Err
+	}
+	return f.cache.SetValue(k, v)
+}
+
+/*Delete removes the key from the wrapped cache, unless it's
+chosen to fail*/
+func (f *FaultInjected) Delete(k string) error {
+	opts := f.faults()
+	if f.chance(opts.DeleteErrorRate) {
+		return opts.Err
+	}
+	return f.cache.Delete(k)
+}
+
+/*OnRemoval adds a listener to the wrapped cache that misses
+DropEvictions of the evictions*/
+func (f *FaultInjected) OnRemoval(fn RemovalListener) {
+	AddRemovalListener(f.cache, func(key string, entry Entry, reason RemovalReason) {
+		if reason == Evicted && f.chance(f.faults().DropEvictions) {
+			return
+		}
+		fn(key, entry, reason)
+	})
+}
+
+/*SetFaults changes the faults, the zero FaultOptions turns
+them all off.  The seed only counts in NewFaultInjected*/
+func (f *FaultInjected) SetFaults(opts FaultOptions) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.opts = opts
+}
+
+/*Export lists the wrapped cache's entries, if it can*/
+func (f *FaultInjected) Export() []Record {
+	exporter, ok := f.cache.(Exporter)
+	if !ok {
+		return []Record{}
+	}
+	return exporter.Export()
+}
+
+/*Import puts the record into the wrapped cache*/
+func (f *FaultInjected) Import(r Record) error {
+	return ImportRecord(f.cache, r)
+}
+
+/*Unwrap is the cache being made to fail*/
+func (f *FaultInjected) Unwrap() Cache {
+	return f.cache
+}
+
+/*NewFaultInjected injects the faults into the cache's calls*/
+func NewFaultInjected(c Cache, opts FaultOptions) *FaultInjected {
+	return &FaultInjected{cache: c, opts: opts, rnd: rand.New(rand.NewSource(opts.Seed))}
+}
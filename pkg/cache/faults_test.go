@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFaultInjectedFailsItsShare(t *testing.T) {
+	lru, _ := NewCache(LRU, 100)
+	c := NewFaultInjected(lru, FaultOptions{SetErrorRate: 0.3, DropEvictions: 0.5, Seed: 1})
+	heard := 0
+	AddRemovalListener(c, func(key string, entry Entry, reason RemovalReason) {
+		if reason == Evicted {
+			heard++
+		}
+	})
+	failed := 0
+	for i := 0; i < 1000; i++ {
+		if err := c.SetValue(strconv.Itoa(i), NewEntry("v", 1)); errors.Is(err, ErrInjected) {
+			failed++
+		}
+	}
+	if failed < 200 || failed > 400 {
+		t.Fatalf("expected about 30%% of sets to fail, got %d of 1000", failed)
+	}
+	evicted := 1000 - failed - 100
+	if heard < evicted/4 || heard > 3*evicted/4 {
+		t.Fatalf("expected about half of %d evictions heard, got %d", evicted, heard)
+	}
+	slow := errors.New("Backend down")
+	c.SetFaults(FaultOptions{GetErrorRate: 1, GetLatency: 20 * time.Millisecond, Err: slow})
+	start := time.Now()
+	if _, err := c.GetValue("999"); err != slow || time.Since(start) < 20*time.Millisecond {
+		t.Fatalf("expected a slow failed read, got %v", err)
+	}
+	c.SetFaults(FaultOptions{})
+	if err := c.SetValue("a", NewEntry("v", 1)); err != nil || !c.KeyPresent("a") {
+		t.Fatalf("expected the faults turned off")
+	}
+}
@@ -0,0 +1,48 @@
+package cache
+
+import "sync"
+
+/*flightCall is one in-progress load that
+other callers for the same key can wait on*/
+type flightCall struct {
+	wg    sync.WaitGroup
+	entry Entry
+	cost  int
+	err   error
+}
+
+/*flightGroup makes sure only one load for a key is
+running at a time.  Everyone who asks while it runs
+waits and shares the result instead of recomputing it*/
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+/*Do runs fn for the key unless a run is already in
+flight, in which case it waits for that one.  Callers
+who shared someone else's load report zero cost since
+they didn't cause a recompute*/
+func (g *flightGroup) Do(key string, fn func() (Entry, int, error)) (Entry, int, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		call.wg.Wait()
+		return call.entry, 0, call.err
+	}
+	call := &flightCall{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	call.entry, call.cost, call.err = fn()
+	call.wg.Done()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	return call.entry, call.cost, call.err
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+/*Fragments caches rendered pieces of pages (HTML, JSON) by
+template name and a hash of the params they were rendered
+with, each costing the microseconds its render took so LCR
+keeps the expensive ones.  Fragments are tagged with the
+models they show ("product:7"), and InvalidateTag drops every
+fragment showing one when it changes.  Concurrent renders of
+the same fragment share a single render.  It's safe for
+concurrent use, the Indexed underneath holds its lock*/
+type Fragments struct {
+	index   *Indexed
+	ttl     time.Duration
+	renders *flightGroup
+}
+
+/*Executor is a parsed template set, an html/template or
+text/template Template*/
+type Executor interface {
+	ExecuteTemplate(w io.Writer, name string, data interface{}) error
+}
+
+/*FragmentKey is where a fragment is cached, params are hashed
+as JSON (so maps are in key order)*/
+func FragmentKey(name string, params interface{}) (string, error) {
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return "fragment:" + name + ":" + hex.EncodeToString(sum[:16]), nil
+}
+
+/*Render is the cached fragment, or what render returns cached
+with the tags*/
+func (f *Fragments) Render(name string, params interface{}, tags []string, render func() (string, error)) (string, error) {
+	key, err := FragmentKey(name, params)
+	if err != nil {
+		return "", err
+	}
+	entry, err := f.index.GetValue(key)
+	if err == nil {
+		return entry.value, nil
+	}
+	entry, _, err = f.renders.Do(key, func() (Entry, int, error) {
+		start := time.Now()
+		out, err := render()
+		if err != nil {
+			return Entry{}, 0, err
+		}
+		entry := NewEntry(out, int(time.Since(start)/time.Microsecond)).WithTTL(f.ttl)
+		f.index.SetTagged(key, entry, tags...)
+		return entry, entry.cost, nil
+	})
+	return entry.value, err
+}
+
+/*RenderTemplate renders the named template with the params
+as its data, through the cache*/
+func (f *Fragments) RenderTemplate(t Executor, name string, params interface{}, tags ...string) (string, error) {
+	return f.Render(name, params, tags, func() (string, error) {
+		buf := &bytes.Buffer{}
+		err := t.ExecuteTemplate(buf, name, params)
+		return buf.String(), err
+	})
+}
+
+/*InvalidateTag drops every fragment tagged with the model,
+returning how many there were*/
+func (f *Fragments) InvalidateTag(tag string) int {
+	return f.index.InvalidateTag(tag)
+}
+
+/*NewFragments caches fragments in c for ttl (0 until they're
+evicted), indexing it for the tags unless it's an Indexed
+already*/
+func NewFragments(c Cache, ttl time.Duration) *Fragments {
+	index, ok := c.(*Indexed)
+	if !ok {
+		index = NewIndexed(c)
+	}
+	return &Fragments{index: index, ttl: ttl, renders: &flightGroup{}}
+}
@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"html/template"
+	"io"
+	"testing"
+)
+
+/*countingExecutor counts the renders*/
+type countingExecutor struct {
+	t       *template.Template
+	renders *int
+}
+
+func (c countingExecutor) ExecuteTemplate(w io.Writer, name string, data interface{}) error {
+	*c.renders++
+	return c.t.ExecuteTemplate(w, name, data)
+}
+
+func TestFragmentsCacheRenders(t *testing.T) {
+	lru, _ := NewCache(LCR, 10)
+	f := NewFragments(lru, 0)
+	card := template.Must(template.New("card").Parse(`<b>{{.name}}</b>`))
+	renders := 0
+	counting := countingExecutor{card, &renders}
+	for i := 0; i < 2; i++ {
+		out, err := f.RenderTemplate(counting, "card", map[string]string{"name": "Lamp", "id": "7"}, "product:7")
+		if err != nil || out != "<b>Lamp</b>" {
+			t.Fatalf("unexpected render %q %v", out, err)
+		}
+	}
+	f.RenderTemplate(counting, "card", map[string]string{"id": "8", "name": "Desk"}, "product:8")
+	if renders != 2 {
+		t.Fatalf("expected each params rendered once, got %d renders", renders)
+	}
+	if f.InvalidateTag("product:7") != 1 {
+		t.Fatalf("expected the tagged fragment dropped")
+	}
+	f.RenderTemplate(counting, "card", map[string]string{"name": "Lamp", "id": "7"}, "product:7")
+	f.RenderTemplate(counting, "card", map[string]string{"name": "Desk", "id": "8"}, "product:8")
+	if renders != 3 {
+		t.Fatalf("expected only the invalidated fragment rendered again, got %d renders", renders)
+	}
+}
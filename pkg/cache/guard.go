@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+/*ErrLoadTimeout is returned when a guarded load (or the
+wait for a free load slot) runs past its deadline*/
+var ErrLoadTimeout = errors.New("Load timed out")
+
+/*ErrCircuitOpen is returned instead of calling a backing
+source that has been failing*/
+var ErrCircuitOpen = errors.New("Circuit open, backing source is failing")
+
+/*ContextLoader is a Loader that can be cancelled.  Guarded
+loads use it when the wrapped loader supports it, otherwise
+a timed out load is abandoned (left to finish on its own)*/
+type ContextLoader interface {
+	LoadContext(ctx context.Context, key string) (Entry, error)
+}
+
+/*LoadGuard configures a GuardedLoader.  Zero values turn the
+matching control off*/
+type LoadGuard struct {
+	Timeout          time.Duration
+	KeyTimeout       func(key string) time.Duration
+	MaxConcurrent    int
+	FailureThreshold int
+	Cooldown         time.Duration
+}
+
+/*GuardedLoader protects a backing source: every load gets a
+deadline, only so many loads run at once, and after enough
+consecutive failures the circuit opens and loads fail fast
+with ErrCircuitOpen until the cooldown passes.  The first
+load after that is a trial, success closes the circuit again*/
+type GuardedLoader struct {
+	loader    Loader
+	guard     LoadGuard
+	slots     chan bool
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+func (gl *GuardedLoader) timeoutFor(key string) time.Duration {
+	if gl.guard.KeyTimeout != nil {
+		timeout := gl.guard.KeyTimeout(key)
+		if timeout > 0 {
+			return timeout
+		}
+	}
+	return gl.guard.Timeout
+}
+
+/*allow says whether a load may go ahead right now*/
+func (gl *GuardedLoader) allow() bool {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
+	if gl.guard.FailureThreshold <= 0 || gl.failures < gl.guard.FailureThreshold {
+		return true
+	}
+	if clockNow().Before(gl.openUntil) || gl.trial {
+		return false
+	}
+	// half open, let one trial through
+	gl.trial = true
+	return true
+}
+
+func (gl *GuardedLoader) record(err error) {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
+	gl.trial = false
+	if err == nil {
+		gl.failures = 0
+		return
+	}
+	gl.failures++
+	if gl.guard.FailureThreshold > 0 && gl.failures >= gl.guard.FailureThreshold {
+		gl.openUntil = clockNow().Add(gl.guard.Cooldown)
+	}
+}
+
+func (gl *GuardedLoader) run(ctx context.Context, key string) (Entry, error) {
+	ctxLoader, ok := gl.loader.(ContextLoader)
+	if ok {
+		entry, err := ctxLoader.LoadContext(ctx, key)
+		if err != nil && ctx.Err() != nil {
+			return Entry{}, ErrLoadTimeout
+		}
+		return entry, err
+	}
+	type result struct {
+		entry Entry
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		entry, err := gl.loader.Load(key)
+		done <- result{entry: entry, err: err}
+	}()
+	select {
+	case res := <-done:
+		return res.entry, res.err
+	case <-ctx.Done():
+		return Entry{}, ErrLoadTimeout
+	}
+}
+
+/*LoadContext runs a guarded load under the caller's context*/
+func (gl *GuardedLoader) LoadContext(ctx context.Context, key string) (Entry, error) {
+	if !gl.allow() {
+		return Entry{}, ErrCircuitOpen
+	}
+	timeout := gl.timeoutFor(key)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if gl.slots != nil {
+		select {
+		case gl.slots <- true:
+			defer func() { <-gl.slots }()
+		case <-ctx.Done():
+			gl.record(ErrLoadTimeout)
+			return Entry{}, ErrLoadTimeout
+		}
+	}
+	entry, err := gl.run(ctx, key)
+	gl.record(err)
+	return entry, err
+}
+
+/*Load runs a guarded load with no deadline beyond the guard's*/
+func (gl *GuardedLoader) Load(key string) (Entry, error) {
+	return gl.LoadContext(context.Background(), key)
+}
+
+/*NewGuardedLoader wraps the loader with the guard's controls*/
+func NewGuardedLoader(loader Loader, guard LoadGuard) *GuardedLoader {
+	gl := &GuardedLoader{loader: loader, guard: guard}
+	if guard.MaxConcurrent > 0 {
+		gl.slots = make(chan bool, guard.MaxConcurrent)
+	}
+	return gl
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGuardTimesOutSlowLoads(t *testing.T) {
+	gl := NewGuardedLoader(LoaderFunc(func(k string) (Entry, error) {
+		time.Sleep(50 * time.Millisecond)
+		return NewEntry("late", 1), nil
+	}), LoadGuard{Timeout: 100 * time.Millisecond, KeyTimeout: func(key string) time.Duration {
+		if key == "impatient" {
+			return time.Millisecond
+		}
+		return 0
+	}})
+	if _, err := gl.Load("impatient"); err != ErrLoadTimeout {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if entry, err := gl.Load("patient"); err != nil || entry.Value() != "late" {
+		t.Fatalf("the default timeout is long enough, got %v %v", entry, err)
+	}
+}
+
+func TestGuardOpensCircuit(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	calls := 0
+	gl := NewGuardedLoader(LoaderFunc(func(k string) (Entry, error) {
+		calls++
+		if failing.Load() {
+			return Entry{}, errors.New("Backend down")
+		}
+		return NewEntry("ok", 1), nil
+	}), LoadGuard{FailureThreshold: 3, Cooldown: 20 * time.Millisecond})
+	for i := 0; i < 3; i++ {
+		gl.Load("a")
+	}
+	if _, err := gl.Load("a"); err != ErrCircuitOpen || calls != 3 {
+		t.Fatalf("expected the open circuit to fail fast, got %v after %d calls", err, calls)
+	}
+	time.Sleep(30 * time.Millisecond)
+	// the trial fails, so the circuit opens again
+	if _, err := gl.Load("a"); err == nil || err == ErrCircuitOpen {
+		t.Fatalf("expected the trial load to run, got %v", err)
+	}
+	if _, err := gl.Load("a"); err != ErrCircuitOpen {
+		t.Fatalf("a failed trial should reopen the circuit, got %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	failing.Store(false)
+	if entry, err := gl.Load("a"); err != nil || entry.Value() != "ok" {
+		t.Fatalf("expected the trial to succeed, got %v %v", entry, err)
+	}
+	if _, err := gl.Load("a"); err != nil {
+		t.Fatalf("a good trial should close the circuit, got %v", err)
+	}
+}
+
+func TestGuardLimitsConcurrency(t *testing.T) {
+	var running, most int64
+	gl := NewGuardedLoader(LoaderFunc(func(k string) (Entry, error) {
+		now := atomic.AddInt64(&running, 1)
+		for {
+			seen := atomic.LoadInt64(&most)
+			if now <= seen || atomic.CompareAndSwapInt64(&most, seen, now) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt64(&running, -1)
+		return NewEntry("v", 1), nil
+	}), LoadGuard{MaxConcurrent: 2})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			gl.Load("k")
+		}()
+	}
+	wg.Wait()
+	if most > 2 {
+		t.Fatalf("%d loads ran at once, the limit is 2", most)
+	}
+}
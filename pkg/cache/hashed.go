@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+/*CollisionPolicy says what a Hashed cache does about two
+keys hashing to the same 64 bits*/
+type CollisionPolicy int
+
+const (
+	/*IgnoreCollisions trusts the hash, a collision returns
+	the other key's entry.  Smallest and fastest*/
+	IgnoreCollisions CollisionPolicy = iota
+	/*CheckFingerprint stores a second, independent 32 bit hash
+	with each entry and treats a mismatch as a miss*/
+	CheckFingerprint
+	/*CheckKey stores the full key with each entry, so a
+	collision can never be served (but nothing is saved on the
+	entry side)*/
+	CheckKey
+)
+
+var errHashCollision = fmt.Errorf("%w, hash collision", ErrKeyNotFound)
+
+/*Hashed wraps a cache so it is keyed by a 64 bit hash of
+each key instead of the key itself, which keeps very long
+keys out of the policy's maps and lists.  KeyPresent only
+looks at the hash, a collision shows up in GetValue.  The
+wrapped cache (and its removal listeners) only ever see the
+hashed keys*/
+type Hashed struct {
+	cache      Cache
+	collisions CollisionPolicy
+}
+
+/*hash64 is FNV-1a over the key, written out so hashing
+doesn't allocate*/
+func hash64(k string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(k); i++ {
+		h ^= uint64(k[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+/*fingerprint32 is FNV-1 (multiply first), which doesn't
+collide along with hash64*/
+func fingerprint32(k string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(k); i++ {
+		h *= 16777619
+		h ^= uint32(k[i])
+	}
+	return h
+}
+
+func (h *Hashed) hashedKey(k string) string {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], hash64(k))
+	return string(buf[:])
+}
+
+/*check is what gets stored in front of the value to detect
+collisions*/
+func (h *Hashed) check(k string) string {
+	if h.collisions == CheckFingerprint {
+		var buf [4]byte
+		binary.LittleEndian.PutUint32(buf[:], fingerprint32(k))
+		return string(buf[:])
+	} else if h.collisions == CheckKey {
+		var buf [binary.MaxVarintLen64]byte
+		n := binary.PutUvarint(buf[:], uint64(len(k)))
+		return string(buf[:n]) + k
+	}
+	return ""
+}
+
+/*KeyPresent is true if something is cached under the key's hash*/
+func (h *Hashed) KeyPresent(k string) bool {
+	return h.cache.KeyPresent(h.hashedKey(k))
+}
+
+/*GetValue returns the entry, or a miss if it belongs to a
+different key with the same hash*/
+func (h *Hashed) GetValue(k string) (Entry, error) {
+	entry, err := h.cache.GetValue(h.hashedKey(k))
+	if err != nil {
+		return Entry{}, err
+	}
+	check := h.check(k)
+	if len(entry.value) < len(check) || entry.value[:len(check)] != check {
+		return Entry{}, errHashCollision
+	}
+	entry.value = entry.value[len(check):]
+	return entry, nil
+}
+
+/*SetValue caches the entry under the key's hash*/
+func (h *Hashed) SetValue(k string, v Entry) error {
+	v.value = h.check(k) + v.value
+	return h.cache.SetValue(h.hashedKey(k), v)
+}
+
+/*Delete drops whatever is cached under the key's hash (on a
+collision that is the other key's entry, which only costs it
+a miss)*/
+func (h *Hashed) Delete(k string) error {
+	return h.cache.Delete(h.hashedKey(k))
+}
+
+func (h *Hashed) retime(k string, ttl time.Duration, shorten bool) error {
+	return retime(h.cache, h.hashedKey(k), ttl, shorten)
+}
+
+/*Unwrap is the cache holding the hashed keys*/
+func (h *Hashed) Unwrap() Cache {
+	return h.cache
+}
+
+/*NewHashed keys the cache by 64 bit hashes, handling
+collisions as the policy says*/
+func NewHashed(c Cache, collisions CollisionPolicy) *Hashed {
+	return &Hashed{cache: c, collisions: collisions}
+}
@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHashedRoundTrip(t *testing.T) {
+	for _, collisions := range []CollisionPolicy{IgnoreCollisions, CheckFingerprint, CheckKey} {
+		lru, _ := NewCache(LRU, 10)
+		h := NewHashed(lru, collisions)
+		long := strings.Repeat("a-very-long-key/", 64)
+		h.SetValue(long, NewEntry("v1", 3))
+		entry, err := h.GetValue(long)
+		if err != nil || entry.Value() != "v1" || entry.Cost() != 3 {
+			t.Fatalf("policy %d: got %+v %v", collisions, entry, err)
+		}
+		if _, err := h.GetValue("other"); err == nil {
+			t.Fatalf("policy %d: expected a miss", collisions)
+		}
+		for _, record := range lru.(Exporter).Export() {
+			if len(record.Key) != 8 {
+				t.Fatalf("policy %d: the policy should only see 8 byte hashes, got %q", collisions, record.Key)
+			}
+		}
+		h.Delete(long)
+		if h.KeyPresent(long) {
+			t.Fatalf("policy %d: delete missed", collisions)
+		}
+	}
+}
+
+func TestHashedCollisions(t *testing.T) {
+	cases := []struct {
+		collisions CollisionPolicy
+		served     bool
+	}{
+		{IgnoreCollisions, true},
+		{CheckFingerprint, false},
+		{CheckKey, false},
+	}
+	for _, tc := range cases {
+		lru, _ := NewCache(LRU, 10)
+		h := NewHashed(lru, tc.collisions)
+		// forge y's entry under x's hash, as a real collision would leave it
+		lru.SetValue(h.hashedKey("x"), NewEntry(h.check("y")+"vy", 1))
+		if !h.KeyPresent("x") {
+			t.Fatalf("policy %d: KeyPresent only looks at the hash", tc.collisions)
+		}
+		_, err := h.GetValue("x")
+		if (err == nil) != tc.served {
+			t.Fatalf("policy %d: served the colliding entry %v, expected %v", tc.collisions, err == nil, tc.served)
+		}
+	}
+}
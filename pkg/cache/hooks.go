@@ -0,0 +1,105 @@
+package cache
+
+/*RemovalReason says why an entry left a cache*/
+type RemovalReason int
+
+const (
+	// Evicted entries were pushed out by the policy to make room
+	Evicted RemovalReason = iota
+	// Deleted entries were removed by a call to Delete
+	Deleted
+	// Replaced entries were overwritten by a SetValue on the same key
+	Replaced
+	// Expired entries outlived their TTL
+	Expired
+)
+
+func (r RemovalReason) String() string {
+	switch r {
+	case Evicted:
+		return "EVICTED"
+	case Deleted:
+		return "DELETED"
+	case Replaced:
+		return "REPLACED"
+	case Expired:
+		return "EXPIRED"
+	}
+	return "UNKNOWN"
+}
+
+/*RemovalListener is told about every entry that leaves a
+cache, after the cache is back in a consistent state*/
+type RemovalListener func(key string, entry Entry, reason RemovalReason)
+
+/*RemovalNotifier is implemented by caches that can report
+entries leaving them*/
+type RemovalNotifier interface {
+	OnRemoval(fn RemovalListener)
+}
+
+/*removalHooks is embedded in every policy so they all
+report removals the same way*/
+type removalHooks struct {
+	listeners []RemovalListener
+}
+
+/*OnRemoval adds a listener, earlier listeners are kept*/
+func (h *removalHooks) OnRemoval(fn RemovalListener) {
+	h.listeners = append(h.listeners, fn)
+}
+
+func (h *removalHooks) removed(key string, entry Entry, reason RemovalReason) {
+	for _, fn := range h.listeners {
+		fn(key, entry, reason)
+	}
+}
+
+/*unwrapper is implemented by wrappers around a single cache
+so helpers can reach the policy underneath*/
+type unwrapper interface {
+	Unwrap() Cache
+}
+
+/*AddRemovalListener registers the listener with the cache or
+the first thing it wraps that reports removals.  It returns
+false if nothing underneath can*/
+func AddRemovalListener(c Cache, fn RemovalListener) bool {
+	for c != nil {
+		notifier, ok := c.(RemovalNotifier)
+		if ok {
+			notifier.OnRemoval(fn)
+			return true
+		}
+		wrapper, ok := c.(unwrapper)
+		if !ok {
+			return false
+		}
+		c = wrapper.Unwrap()
+	}
+	return false
+}
+
+/*remover is the removal every policy from NewCache does
+internally, telling its listeners why*/
+type remover interface {
+	remove(k string, reason RemovalReason) error
+}
+
+/*evictKey evicts the key from the policy underneath the
+chain, so listeners hear it was Evicted.  Chains without a
+policy that can do that just Delete it*/
+func evictKey(c Cache, k string) error {
+	for next := c; next != nil; {
+		policy, ok := next.(remover)
+		if ok {
+			return policy.remove(k, Evicted)
+		}
+		wrapper, ok := next.(unwrapper)
+		if !ok {
+			break
+		}
+		next = wrapper.Unwrap()
+	}
+	return c.Delete(k)
+}
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestRemovalReasons(t *testing.T) {
+	for _, policy := range allPolicies {
+		c, _ := NewCache(policy, 2)
+		reasons := map[string]RemovalReason{}
+		AddRemovalListener(c, func(key string, entry Entry, reason RemovalReason) {
+			reasons[key+"="+entry.Value()] = reason
+		})
+		c.SetValue("a", NewEntry("1", 1))
+		c.SetValue("a", NewEntry("2", 1))
+		c.SetValue("b", NewEntry("1", 1))
+		c.Delete("b")
+		c.SetValue("c", NewEntry("1", 1))
+		c.SetValue("d", NewEntry("1", 1))
+		if reasons["a=1"] != Replaced || reasons["b=1"] != Deleted || len(reasons) != 3 {
+			t.Fatalf("%s: unexpected removals %v", policy, reasons)
+		}
+		for key, reason := range reasons {
+			if key != "a=1" && key != "b=1" && reason != Evicted {
+				t.Fatalf("%s: %s should have been evicted, got %v", policy, key, reason)
+			}
+		}
+	}
+}
+
+func TestRemovalListenersTrackResidents(t *testing.T) {
+	for _, policy := range allPolicies {
+		c, _ := NewCache(policy, 20)
+		// what the listener says is resident has to match Export
+		resident := map[string]bool{}
+		AddRemovalListener(c, func(key string, entry Entry, reason RemovalReason) {
+			if reason != Replaced {
+				delete(resident, key)
+			}
+		})
+		rng := rand.New(rand.NewSource(1))
+		for i := 0; i < 20000; i++ {
+			key := "k" + strconv.Itoa(rng.Intn(60))
+			switch rng.Intn(4) {
+			case 0, 1:
+				if c.KeyPresent(key) {
+					entry, err := c.GetValue(key)
+					if err != nil || entry.Value() != "v"+key {
+						t.Fatalf("%s: bad read of %s: %v %v", policy, key, entry, err)
+					}
+				}
+			case 2:
+				c.SetValue(key, NewEntry("v"+key, rng.Intn(100)))
+				resident[key] = true
+			case 3:
+				was := resident[key]
+				err := c.Delete(key)
+				if (err == nil) != was {
+					t.Fatalf("%s: Delete(%s) = %v, resident %v", policy, key, err, was)
+				}
+			}
+			records := c.(Exporter).Export()
+			if len(records) != len(resident) || len(records) > 20 {
+				t.Fatalf("%s step %d: %d exported, listener says %d", policy, i, len(records), len(resident))
+			}
+			for _, record := range records {
+				if !resident[record.Key] {
+					t.Fatalf("%s step %d: %s exported but reported removed", policy, i, record.Key)
+				}
+			}
+		}
+	}
+}
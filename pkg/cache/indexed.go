@@ -0,0 +1,265 @@
+package cache
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+/*Indexed wraps a cache with an index of the keys in it, so
+a family of keys can be dropped in one call.  Entries set
+with SetTagged carry tags ("user:42", "report") and
+InvalidateTag drops every entry with the tag; DeletePrefix
+drops every key starting with a prefix, from a sorted list of
+the keys (so a write of a new key costs a copy of the part of
+the list after it), and InvalidateMatch every key matching a
+pattern.  The index
+follows the wrapped cache's removals, so the wrapped cache
+has to report them (every policy from NewCache does).  Like
+WriteBack it holds its lock around every call*/
+type Indexed struct {
+	lockedInner
+	sorted  []string
+	tags    map[string]map[string]bool
+	keyTags map[string][]string
+}
+
+/*KeyPresent is true if the key is cached right now*/
+func (ix *Indexed) KeyPresent(k string) bool {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return ix.cache.KeyPresent(k)
+}
+
+/*GetValue reads from the wrapped cache*/
+func (ix *Indexed) GetValue(k string) (Entry, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return ix.cache.GetValue(k)
+}
+
+/*SetValue writes the entry without tags, any the key had
+before go with the old entry*/
+func (ix *Indexed) SetValue(k string, v Entry) error {
+	return ix.SetTagged(k, v)
+}
+
+/*SetTagged writes the entry carrying the tags*/
+func (ix *Indexed) SetTagged(k string, v Entry, tags ...string) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	err := ix.cache.SetValue(k, v)
+	if err != nil {
+		return err
+	}
+	ix.insertKey(k)
+	if len(tags) == 0 {
+		return nil
+	}
+	ix.keyTags[k] = tags
+	for _, tag := range tags {
+		keys, ok := ix.tags[tag]
+		if !ok {
+			keys = make(map[string]bool)
+			ix.tags[tag] = keys
+		}
+		keys[k] = true
+	}
+	return nil
+}
+
+/*Delete removes the key from the wrapped cache*/
+func (ix *Indexed) Delete(k string) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return ix.cache.Delete(k)
+}
+
+/*InvalidateTag deletes every entry carrying the tag,
+returning how many there were*/
+func (ix *Indexed) InvalidateTag(tag string) int {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	dropped := 0
+	for k := range ix.tags[tag] {
+		// onRemoval takes the key out of the index as it goes
+		if ix.cache.Delete(k) == nil {
+			dropped++
+		}
+	}
+	return dropped
+}
+
+/*DeletePrefix deletes every key starting with the prefix,
+returning how many there were*/
+func (ix *Indexed) DeletePrefix(prefix string) int {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	start := sort.SearchStrings(ix.sorted, prefix)
+	end := start
+	for end < len(ix.sorted) && strings.HasPrefix(ix.sorted[end], prefix) {
+		end++
+	}
+	// deleting changes ix.sorted under us, so work from a copy
+	keys := append([]string(nil), ix.sorted[start:end]...)
+	dropped := 0
+	for _, k := range keys {
+		if ix.cache.Delete(k) == nil {
+			dropped++
+		}
+	}
+	return dropped
+}
+
+/*KeysMatching lists the keys matching the pattern in order,
+see compilePattern for what patterns look like*/
+func (ix *Indexed) KeysMatching(pattern string) ([]string, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return ix.matching(pattern)
+}
+
+/*InvalidateMatch deletes every key matching the pattern,
+returning how many there were*/
+func (ix *Indexed) InvalidateMatch(pattern string) (int, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	keys, err := ix.matching(pattern)
+	if err != nil {
+		return 0, err
+	}
+	dropped := 0
+	for _, k := range keys {
+		if ix.cache.Delete(k) == nil {
+			dropped++
+		}
+	}
+	return dropped, nil
+}
+
+/*matching only has to look at the keys starting with the
+pattern's literal prefix, if it has one*/
+func (ix *Indexed) matching(pattern string) ([]string, error) {
+	re, err := compilePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	prefix, _ := re.LiteralPrefix()
+	keys := []string{}
+	for i := sort.SearchStrings(ix.sorted, prefix); i < len(ix.sorted); i++ {
+		k := ix.sorted[i]
+		if !strings.HasPrefix(k, prefix) {
+			break
+		}
+		if re.MatchString(k) {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+/*compilePattern reads a glob, where * matches any run of
+characters, ? any one, [abc] or [a-z] one of a set ([!abc]
+one outside it) and \ escapes the next character.  A pattern
+between slashes, /^user:[0-9]+$/, is a regexp instead*/
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) >= 2 && pattern[0] == '/' && pattern[len(pattern)-1] == '/' {
+		return regexp.Compile(pattern[1 : len(pattern)-1])
+	}
+	var expr strings.Builder
+	expr.WriteString("(?s)^")
+	for i := 0; i < len(pattern); i++ {
+		ch := pattern[i]
+		if ch == '*' {
+			expr.WriteString(".*")
+		} else if ch == '?' {
+			expr.WriteString(".")
+		} else if ch == '\\' && i+1 < len(pattern) {
+			i++
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		} else if ch == '[' {
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return nil, errors.New("Unclosed [ in pattern " + pattern)
+			}
+			set := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(set, "!") {
+				set = "^" + set[1:]
+			}
+			expr.WriteString("[" + set + "]")
+			i += end + 1
+		} else {
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
+
+func (ix *Indexed) insertKey(k string) {
+	i := sort.SearchStrings(ix.sorted, k)
+	if i < len(ix.sorted) && ix.sorted[i] == k {
+		return
+	}
+	ix.sorted = append(ix.sorted, "")
+	copy(ix.sorted[i+1:], ix.sorted[i:])
+	ix.sorted[i] = k
+}
+
+func (ix *Indexed) removeKey(k string) {
+	i := sort.SearchStrings(ix.sorted, k)
+	if i == len(ix.sorted) || ix.sorted[i] != k {
+		return
+	}
+	copy(ix.sorted[i:], ix.sorted[i+1:])
+	ix.sorted[len(ix.sorted)-1] = ""
+	ix.sorted = ix.sorted[:len(ix.sorted)-1]
+}
+
+func (ix *Indexed) onRemoval(key string, entry Entry, reason RemovalReason) {
+	// called from inside the wrapped cache, so the lock is already held
+	ix.removeKey(key)
+	for _, tag := range ix.keyTags[key] {
+		keys := ix.tags[tag]
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(ix.tags, tag)
+		}
+	}
+	delete(ix.keyTags, key)
+}
+
+/*Export lists the wrapped cache's entries, if it can*/
+func (ix *Indexed) Export() []Record {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	exporter, ok := ix.cache.(Exporter)
+	if !ok {
+		return []Record{}
+	}
+	return exporter.Export()
+}
+
+/*Import puts the record into the wrapped cache and the index*/
+func (ix *Indexed) Import(r Record) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	err := ImportRecord(ix.cache, r)
+	if err == nil && ix.cache.KeyPresent(r.Key) {
+		ix.insertKey(r.Key)
+	}
+	return err
+}
+
+/*Unwrap is the cache being indexed*/
+func (ix *Indexed) Unwrap() Cache {
+	return ix.cache
+}
+
+/*NewIndexed starts indexing the cache*/
+func NewIndexed(c Cache) *Indexed {
+	ix := &Indexed{lockedInner: lockedInner{cache: c}, tags: make(map[string]map[string]bool), keyTags: make(map[string][]string)}
+	AddRemovalListener(c, ix.onRemoval)
+	return ix
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInvalidateTag(t *testing.T) {
+	c, _ := NewCache(LRU, 4)
+	ix := NewIndexed(c)
+	ix.SetTagged("user:42:profile", NewEntry("1", 1), "user:42")
+	ix.SetTagged("user:42:feed", NewEntry("2", 1), "user:42", "feed")
+	ix.SetTagged("user:7:feed", NewEntry("3", 1), "user:7", "feed")
+	ix.SetValue("plain", NewEntry("4", 1))
+	if dropped := ix.InvalidateTag("user:42"); dropped != 2 {
+		t.Fatalf("expected both of user 42's entries dropped, got %d", dropped)
+	}
+	if ix.KeyPresent("user:42:feed") || !ix.KeyPresent("user:7:feed") || !ix.KeyPresent("plain") {
+		t.Fatalf("dropped the wrong entries")
+	}
+	if len(ix.tags["feed"]) != 1 {
+		t.Fatalf("the feed tag still lists a dropped key: %v", ix.tags["feed"])
+	}
+	if dropped := ix.InvalidateTag("nothing"); dropped != 0 {
+		t.Fatalf("dropped %d entries for an unused tag", dropped)
+	}
+}
+
+func TestTagIndexFollowsRemovals(t *testing.T) {
+	c, _ := NewCache(FIFO, 2)
+	ix := NewIndexed(NewBatched(c))
+	ix.SetTagged("a", NewEntry("1", 1), "t")
+	ix.SetTagged("b", NewEntry("2", 1).WithTTL(time.Millisecond), "t")
+	// a rewrite without tags leaves the old ones behind
+	ix.SetValue("b", NewEntry("3", 1))
+	ix.SetTagged("c", NewEntry("4", 1), "t")
+	if len(ix.keyTags) != 1 || !ix.tags["t"]["c"] {
+		t.Fatalf("expected only c tagged after a's eviction and b's rewrite, got %v", ix.keyTags)
+	}
+	ix.SetTagged("d", NewEntry("5", 1).WithTTL(time.Millisecond), "t")
+	time.Sleep(2 * time.Millisecond)
+	if dropped := sweepExpired(ix, 10); dropped != 1 || len(ix.tags["t"]) != 1 || ix.tags["t"]["d"] {
+		t.Fatalf("expected the sweep to clear d from the index, dropped %d leaving %v", dropped, ix.tags)
+	}
+}
+
+func TestDeletePrefix(t *testing.T) {
+	c, _ := NewCache(LRU, 10)
+	ix := NewIndexed(c)
+	for _, k := range []string{"order:1:total", "order:1:items", "order:10:total", "order:2:total", "user:1"} {
+		ix.SetValue(k, NewEntry(k, 1))
+	}
+	ix.SetValue("order:1:total", NewEntry("again", 1))
+	if dropped := ix.DeletePrefix("order:1:"); dropped != 2 {
+		t.Fatalf("expected order 1's two keys dropped, got %d", dropped)
+	}
+	if ix.KeyPresent("order:1:items") || !ix.KeyPresent("order:10:total") || !ix.KeyPresent("order:2:total") {
+		t.Fatalf("dropped the wrong keys")
+	}
+	if dropped := ix.DeletePrefix("order:"); dropped != 2 || len(ix.sorted) != 1 || ix.sorted[0] != "user:1" {
+		t.Fatalf("expected only user:1 left, dropped %d leaving %v", dropped, ix.sorted)
+	}
+	if dropped := ix.DeletePrefix("zzz"); dropped != 0 {
+		t.Fatalf("dropped %d keys past the end", dropped)
+	}
+}
+
+func TestInvalidateMatch(t *testing.T) {
+	c, _ := NewCache(LRU, 10)
+	ix := NewIndexed(c)
+	for _, k := range []string{"user:1:name", "user:1:email", "user:12:name", "user:2:name", "report:a,b"} {
+		ix.SetValue(k, NewEntry(k, 1))
+	}
+	keys, _ := ix.KeysMatching("user:?:name")
+	if len(keys) != 2 || keys[0] != "user:1:name" || keys[1] != "user:2:name" {
+		t.Fatalf("expected the one digit users' names, got %v", keys)
+	}
+	keys, _ = ix.KeysMatching("/^user:1[0-9]*:name$/")
+	if len(keys) != 2 || keys[0] != "user:12:name" || keys[1] != "user:1:name" {
+		t.Fatalf("expected users 1 and 12 by regexp, got %v", keys)
+	}
+	keys, _ = ix.KeysMatching("report:[!x],*")
+	if len(keys) != 1 {
+		t.Fatalf("expected the report by set, got %v", keys)
+	}
+	if _, err := ix.KeysMatching("user:[1"); err == nil {
+		t.Fatalf("expected an unclosed set to be an error")
+	}
+	dropped, err := ix.InvalidateMatch("user:1:*")
+	if err != nil || dropped != 2 || ix.KeyPresent("user:1:email") || !ix.KeyPresent("user:12:name") {
+		t.Fatalf("expected user 1's keys dropped, got %d %v", dropped, err)
+	}
+}
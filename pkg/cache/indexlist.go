@@ -0,0 +1,128 @@
+package cache
+
+import "math"
+
+/*nilIndex marks the end of an index linked list*/
+const nilIndex = -1
+
+/*indexNode is a list node kept in a slice and linked by
+index rather than pointer, so a large cache is one block of
+memory the GC doesn't have to chase pointers through*/
+type indexNode struct {
+	key   string
+	entry Entry
+	prev  int32
+	next  int32
+}
+
+/*indexList is the doubly linked list under FiFo and Lru.
+Nodes live in one slice and freed slots are chained through
+next and reused, so the slice never grows past the largest
+the cache has been*/
+type indexList struct {
+	nodes  []indexNode
+	free   int32
+	head   int32
+	tail   int32
+	lookup map[string]int32
+}
+
+/*unboundedSize is the size of a cache built Unbounded, it
+never fills up*/
+const unboundedSize = math.MaxInt
+
+/*capacityHint is how much to preallocate for a cache of the
+given size (nothing when the size isn't a real bound)*/
+func capacityHint(size int) int {
+	if size > 0 && size != unboundedSize {
+		return size
+	}
+	return 0
+}
+
+func (il *indexList) get(k string) (*indexNode, int32, bool) {
+	i, ok := il.lookup[k]
+	if !ok {
+		return nil, nilIndex, false
+	}
+	return &il.nodes[i], i, true
+}
+
+/*push stores a new node at the tail*/
+func (il *indexList) push(k string, v Entry) {
+	i := il.free
+	if i == nilIndex {
+		il.nodes = append(il.nodes, indexNode{})
+		i = int32(len(il.nodes) - 1)
+	} else {
+		il.free = il.nodes[i].next
+	}
+	il.nodes[i] = indexNode{key: k, entry: v, prev: nilIndex, next: nilIndex}
+	il.link(i)
+	il.lookup[k] = i
+}
+
+/*link puts a detached node at the tail*/
+func (il *indexList) link(i int32) {
+	node := &il.nodes[i]
+	node.prev = il.tail
+	node.next = nilIndex
+	if il.tail == nilIndex {
+		il.head = i
+	} else {
+		il.nodes[il.tail].next = i
+	}
+	il.tail = i
+}
+
+func (il *indexList) unlink(i int32) {
+	node := &il.nodes[i]
+	if node.prev == nilIndex {
+		il.head = node.next
+	} else {
+		il.nodes[node.prev].next = node.next
+	}
+	if node.next == nilIndex {
+		il.tail = node.prev
+	} else {
+		il.nodes[node.next].prev = node.prev
+	}
+}
+
+/*moveToTail makes the node the newest in the list*/
+func (il *indexList) moveToTail(i int32) {
+	if i == il.tail {
+		return
+	}
+	il.unlink(i)
+	il.link(i)
+}
+
+/*pop removes the node, returning what it held and freeing
+its slot*/
+func (il *indexList) pop(i int32) (string, Entry) {
+	il.unlink(i)
+	node := il.nodes[i]
+	delete(il.lookup, node.key)
+	// clear the slot so the key and value can be collected
+	il.nodes[i] = indexNode{prev: nilIndex, next: il.free}
+	il.free = i
+	return node.key, node.entry
+}
+
+/*each walks the list head to tail*/
+func (il *indexList) each(fn func(node *indexNode)) {
+	for i := il.head; i != nilIndex; i = il.nodes[i].next {
+		fn(&il.nodes[i])
+	}
+}
+
+func newIndexList(size int) indexList {
+	return indexList{
+		nodes:  make([]indexNode, 0, capacityHint(size)),
+		free:   nilIndex,
+		head:   nilIndex,
+		tail:   nilIndex,
+		lookup: make(map[string]int32, capacityHint(size)),
+	}
+}
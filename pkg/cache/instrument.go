@@ -0,0 +1,226 @@
+package cache
+
+import (
+	"errors"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+/*defaultLatencyBuckets cover a map lookup up to a cache that
+has gone over the network*/
+var defaultLatencyBuckets = []time.Duration{
+	time.Microsecond,
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+}
+
+/*InstrumentOptions configures Instrument.  LatencyBuckets are
+the upper bounds of the latency histogram, in order (a few
+from a microsecond to a second if empty), Sizer counts the
+bytes in Metrics (KeyValueSizer if nil)*/
+type InstrumentOptions struct {
+	LatencyBuckets []time.Duration
+	Sizer          Sizer
+}
+
+/*OpMetrics is how one kind of call has gone.  Buckets counts
+the calls at or under each of the LatencyBuckets, with one
+more at the end for the slower ones*/
+type OpMetrics struct {
+	Calls   int64
+	Errors  int64
+	Total   time.Duration
+	Max     time.Duration
+	Buckets []int64
+}
+
+/*Mean is the average latency*/
+func (o OpMetrics) Mean() time.Duration {
+	if o.Calls == 0 {
+		return 0
+	}
+	return o.Total / time.Duration(o.Calls)
+}
+
+/*Metrics is a snapshot of an Instrumented cache.  Ops are
+keyed "get", "set", "delete" and "present", Entries and Bytes
+are what the cache holds right now*/
+type Metrics struct {
+	Ops     map[string]OpMetrics
+	Hits    int64
+	Misses  int64
+	Entries int
+	Bytes   int64
+}
+
+/*HitRatio is the share of GetValues that hit*/
+func (m Metrics) HitRatio() float64 {
+	if m.Hits+m.Misses == 0 {
+		return 0
+	}
+	return float64(m.Hits) / float64(m.Hits+m.Misses)
+}
+
+/*opMetrics are the atomic counters behind an OpMetrics*/
+type opMetrics struct {
+	calls   int64
+	errors  int64
+	total   int64
+	max     int64
+	buckets []int64
+}
+
+func (o *opMetrics) observe(bounds []time.Duration, took time.Duration, err error) {
+	atomic.AddInt64(&o.calls, 1)
+	if err != nil {
+		atomic.AddInt64(&o.errors, 1)
+	}
+	atomic.AddInt64(&o.total, int64(took))
+	for {
+		max := atomic.LoadInt64(&o.max)
+		if int64(took) <= max || atomic.CompareAndSwapInt64(&o.max, max, int64(took)) {
+			break
+		}
+	}
+	i := sort.Search(len(bounds), func(i int) bool { return took <= bounds[i] })
+	atomic.AddInt64(&o.buckets[i], 1)
+}
+
+func (o *opMetrics) snapshot() OpMetrics {
+	snapshot := OpMetrics{
+		Calls:   atomic.LoadInt64(&o.calls),
+		Errors:  atomic.LoadInt64(&o.errors),
+		Total:   time.Duration(atomic.LoadInt64(&o.total)),
+		Max:     time.Duration(atomic.LoadInt64(&o.max)),
+		Buckets: make([]int64, len(o.buckets)),
+	}
+	for i := range o.buckets {
+		snapshot.Buckets[i] = atomic.LoadInt64(&o.buckets[i])
+	}
+	return snapshot
+}
+
+/*Instrumented times every call to the cache it wraps and
+counts hits and misses, whatever the cache is, so no policy
+has to be built with metrics of its own.  A miss is a GetValue
+that failed.  Counters are atomic, so it adds no locking, and
+the size is only worked out (from an Export) when Metrics is
+read*/
+type Instrumented struct {
+	cache   Cache
+	opts    InstrumentOptions
+	get     opMetrics
+	set     opMetrics
+	delete  opMetrics
+	present opMetrics
+	hits    int64
+	misses  int64
+}
+
+/*KeyPresent checks the wrapped cache, timing it*/
+func (in *Instrumented) KeyPresent(k string) bool {
+	start := time.Now()
+	present := in.cache.KeyPresent(k)
+	in.present.observe(in.opts.LatencyBuckets, time.Since(start), nil)
+	return present
+}
+
+/*GetValue reads from the wrapped cache, timing it and
+counting the hit or miss*/
+func (in *Instrumented) GetValue(k string) (Entry, error) {
+	start := time.Now()
+	entry, err := in.cache.GetValue(k)
+	in.get.observe(in.opts.LatencyBuckets, time.Since(start), nil)
+	if err != nil {
+		atomic.AddInt64(&in.misses, 1)
+	} else {
+		atomic.AddInt64(&in.hits, 1)
+	}
+	return entry, err
+}
+
+/*SetValue writes to the wrapped cache, timing it*/
+func (in *Instrumented) SetValue(k string, v Entry) error {
+	start := time.Now()
+	err := in.cache.SetValue(k, v)
+	in.set.observe(in.opts.LatencyBuckets, time.Since(start), err)
+	return err
+}
+
+/*Delete removes the key from the wrapped cache, timing it (a
+key that wasn't there isn't counted as an error)*/
+func (in *Instrumented) Delete(k string) error {
+	start := time.Now()
+	err := in.cache.Delete(k)
+	failed := err
+	if errors.Is(err, ErrKeyNotFound) {
+		failed = nil
+	}
+	in.delete.observe(in.opts.LatencyBuckets, time.Since(start), failed)
+	return err
+}
+
+/*Metrics reads the counters and sizes up the cache*/
+func (in *Instrumented) Metrics() Metrics {
+	m := Metrics{
+		Ops: map[string]OpMetrics{
+			"get":     in.get.snapshot(),
+			"set":     in.set.snapshot(),
+			"delete":  in.delete.snapshot(),
+			"present": in.present.snapshot(),
+		},
+		Hits:   atomic.LoadInt64(&in.hits),
+		Misses: atomic.LoadInt64(&in.misses),
+	}
+	for _, record := range in.Export() {
+		m.Entries++
+		m.Bytes += in.opts.Sizer.Size(record.Key, record.Entry)
+	}
+	return m
+}
+
+/*Export lists the wrapped cache's entries, if it can*/
+func (in *Instrumented) Export() []Record {
+	exporter, ok := in.cache.(Exporter)
+	if !ok {
+		return []Record{}
+	}
+	return exporter.Export()
+}
+
+/*Import puts the record into the wrapped cache*/
+func (in *Instrumented) Import(r Record) error {
+	return ImportRecord(in.cache, r)
+}
+
+/*Unwrap is the cache being instrumented*/
+func (in *Instrumented) Unwrap() Cache {
+	return in.cache
+}
+
+func newOpMetrics(bounds []time.Duration) opMetrics {
+	return opMetrics{buckets: make([]int64, len(bounds)+1)}
+}
+
+/*Instrument starts timing and counting the cache's calls*/
+func Instrument(c Cache, opts InstrumentOptions) *Instrumented {
+	if len(opts.LatencyBuckets) == 0 {
+		opts.LatencyBuckets = defaultLatencyBuckets
+	}
+	if opts.Sizer == nil {
+		opts.Sizer = KeyValueSizer
+	}
+	return &Instrumented{
+		cache:   c,
+		opts:    opts,
+		get:     newOpMetrics(opts.LatencyBuckets),
+		set:     newOpMetrics(opts.LatencyBuckets),
+		delete:  newOpMetrics(opts.LatencyBuckets),
+		present: newOpMetrics(opts.LatencyBuckets),
+	}
+}
@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInstrumentCountsAnyCache(t *testing.T) {
+	lru, _ := NewCache(LRU, 1)
+	c := Instrument(lru, InstrumentOptions{LatencyBuckets: []time.Duration{time.Hour}})
+	c.SetValue("a", NewEntry("12", 1))
+	c.GetValue("a")
+	c.GetValue("b")
+	c.KeyPresent("a")
+	c.Delete("b")
+	m := c.Metrics()
+	if m.Hits != 1 || m.Misses != 1 || m.HitRatio() != 0.5 {
+		t.Fatalf("unexpected hits and misses %+v", m)
+	}
+	if m.Entries != 1 || m.Bytes != 3 {
+		t.Fatalf("expected one entry of 3 bytes, got %d and %d", m.Entries, m.Bytes)
+	}
+	get := m.Ops["get"]
+	if get.Calls != 2 || get.Buckets[0] != 2 || get.Buckets[1] != 0 || get.Max > time.Hour {
+		t.Fatalf("unexpected get latencies %+v", get)
+	}
+	if m.Ops["delete"].Errors != 0 || m.Ops["present"].Calls != 1 {
+		t.Fatalf("unexpected ops %+v", m.Ops)
+	}
+	limited := Instrument(NewEntryLimited(lru, EntryLimits{MaxCost: 1}), InstrumentOptions{})
+	err := limited.SetValue("b", NewEntry("b", 2))
+	if !errors.Is(err, ErrEntryTooLarge) || limited.Metrics().Ops["set"].Errors != 1 {
+		t.Fatalf("expected the refused write counted as an error")
+	}
+}
@@ -0,0 +1,402 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*ErrInvariant is what CheckInvariants wraps when a policy's
+structure has gone wrong, with what it found*/
+var ErrInvariant = errors.New("Cache invariant broken")
+
+/*invariantChecker is a policy (or a composite) that can check
+its own structure*/
+type invariantChecker interface {
+	CheckInvariants() error
+}
+
+func broken(format string, args ...interface{}) error {
+	return fmt.Errorf("%w, "+format, append([]interface{}{ErrInvariant}, args...)...)
+}
+
+/*CheckInvariants checks the structure under the cache or the
+first thing it wraps that can check itself: that its lists
+link both ways and hold what its lookup map holds, that it's
+no bigger than its size, and that its eviction order is in
+order (access counts or costs never falling from the head to
+the tail).  It's nil if everything's right or nothing can be
+checked.  It's O(n), for tests and debugging*/
+func CheckInvariants(c Cache) error {
+	for c != nil {
+		checker, ok := c.(invariantChecker)
+		if ok {
+			return checker.CheckInvariants()
+		}
+		wrapper, ok := c.(unwrapper)
+		if !ok {
+			return nil
+		}
+		c = wrapper.Unwrap()
+	}
+	return nil
+}
+
+/*check walks the list both ways against its lookup map*/
+func (il *indexList) check(length int, maxSize int) error {
+	if length > maxSize || length != len(il.lookup) {
+		return broken("length %d, %d keys looked up, size %d", length, len(il.lookup), maxSize)
+	}
+	count := 0
+	prev := int32(nilIndex)
+	for i := il.head; i != nilIndex; i = il.nodes[i].next {
+		node := &il.nodes[i]
+		if count == length {
+			return broken("list longer than its length %d", length)
+		}
+		if node.prev != prev {
+			return broken("%s links back to the wrong node", node.key)
+		}
+		found, ok := il.lookup[node.key]
+		if !ok || found != i {
+			return broken("%s is listed but not looked up", node.key)
+		}
+		prev = i
+		count++
+	}
+	if count != length || il.tail != prev {
+		return broken("list of %d nodes for a length of %d", count, length)
+	}
+	return nil
+}
+
+/*CheckInvariants checks the list against the lookup*/
+func (ff *FiFo) CheckInvariants() error {
+	return ff.list.check(ff.length, ff.maxSize)
+}
+
+/*CheckInvariants checks the list against the lookup*/
+func (l *Lru) CheckInvariants() error {
+	return l.list.check(l.length, l.maxSize)
+}
+
+/*CheckInvariants checks the buckets are in count order and
+hold every node looked up*/
+func (l *Lfu) CheckInvariants() error {
+	if l.length > l.maxSize || l.length != len(l.lookup) {
+		return broken("length %d, %d keys looked up, size %d", l.length, len(l.lookup), l.maxSize)
+	}
+	count := 0
+	var prevBucket *lfuBucket
+	for bucket := l.head; bucket != nil; bucket = bucket.next {
+		if bucket.prev != prevBucket {
+			return broken("bucket %d links back to the wrong bucket", bucket.count)
+		}
+		if prevBucket != nil && prevBucket.count >= bucket.count {
+			return broken("bucket %d after bucket %d", bucket.count, prevBucket.count)
+		}
+		if bucket.head == nil {
+			return broken("bucket %d is empty", bucket.count)
+		}
+		var prev *lfuNode
+		for node := bucket.head; node != nil; node = node.next {
+			if count == l.length {
+				return broken("buckets hold more than the length %d", l.length)
+			}
+			if node.prev != prev || node.bucket != bucket || node.accessCount != bucket.count {
+				return broken("%s is linked into bucket %d wrong", node.key, bucket.count)
+			}
+			if l.lookup[node.key] != node {
+				return broken("%s is in a bucket but not looked up", node.key)
+			}
+			prev = node
+			count++
+		}
+		if bucket.tail != prev {
+			return broken("bucket %d has the wrong tail", bucket.count)
+		}
+		prevBucket = bucket
+	}
+	if count != l.length || l.tail != prevBucket {
+		return broken("buckets hold %d nodes for a length of %d", count, l.length)
+	}
+	return nil
+}
+
+/*CheckInvariants checks the heap is a heap of the nodes
+looked up, ranked between their cost and their cost plus the
+age*/
+func (l *Lcr) CheckInvariants() error {
+	if l.length > l.maxSize || l.length != len(l.lookup) || l.length != len(l.nodes) {
+		return broken("length %d, %d keys looked up, %d in the heap, size %d", l.length, len(l.lookup), len(l.nodes), l.maxSize)
+	}
+	for i, node := range l.nodes {
+		if node.index != i || l.lookup[node.key] != node {
+			return broken("%s is at %d of the heap but thinks it's at %d", node.key, i, node.index)
+		}
+		if i > 0 && l.nodes.Less(i, (i-1)/2) {
+			return broken("%s costs less than its parent in the heap", node.key)
+		}
+		cost := float64(node.entry.cost)
+		if node.rank < cost || node.rank > cost+l.age {
+			return broken("%s costing %d is ranked %g at age %g", node.key, node.entry.cost, node.rank, l.age)
+		}
+	}
+	return nil
+}
+
+/*CheckInvariants checks the lookup, the only structure
+there is*/
+func (l *LcrTtl) CheckInvariants() error {
+	if len(l.lookup) > l.maxSize {
+		return broken("%d keys looked up, size %d", len(l.lookup), l.maxSize)
+	}
+	for k, node := range l.lookup {
+		if node.key != k {
+			return broken("%s is looked up as %s", node.key, k)
+		}
+	}
+	return nil
+}
+
+/*CheckInvariants checks both lists hold every node looked up,
+the LFU list in access count order, and the history*/
+func (l *Lecar) CheckInvariants() error {
+	if l.length > l.maxSize || l.length != len(l.lookup) {
+		return broken("length %d, %d keys looked up, size %d", l.length, len(l.lookup), l.maxSize)
+	}
+	count := 0
+	var prevLru *lecarLruNode
+	for node := l.lruHead; node != nil; node = node.next {
+		if count == l.length {
+			return broken("LRU list longer than the length %d", l.length)
+		}
+		if node.prev != prevLru || node.entryNode.lruNode != node || l.lookup[node.entryNode.key] != node.entryNode {
+			return broken("%s is linked into the LRU list wrong", node.entryNode.key)
+		}
+		prevLru = node
+		count++
+	}
+	if count != l.length || l.lruTail != prevLru {
+		return broken("LRU list of %d nodes for a length of %d", count, l.length)
+	}
+	count = 0
+	var prevLfu *lecarLfuNode
+	for node := l.lfuHead; node != nil; node = node.next {
+		if count == l.length {
+			return broken("LFU list longer than the length %d", l.length)
+		}
+		if node.prev != prevLfu || node.entryNode.lfuNode != node || l.lookup[node.entryNode.key] != node.entryNode {
+			return broken("%s is linked into the LFU list wrong", node.entryNode.key)
+		}
+		if prevLfu != nil && prevLfu.accessCount > node.accessCount {
+			return broken("%s read %d times after %s read %d", node.entryNode.key, node.accessCount, prevLfu.entryNode.key, prevLfu.accessCount)
+		}
+		prevLfu = node
+		count++
+	}
+	if count != l.length || l.lfuTail != prevLfu {
+		return broken("LFU list of %d nodes for a length of %d", count, l.length)
+	}
+	if l.historyLength > l.maxSize || l.historyLength != len(l.historyLookup) {
+		return broken("history of %d, %d keys looked up, size %d", l.historyLength, len(l.historyLookup), l.maxSize)
+	}
+	return nil
+}
+
+/*CheckInvariants checks the three lists hold every node
+looked up, the LFU list in access count order and the LCR
+list in cost order, and the history*/
+func (c *Calecar) CheckInvariants() error {
+	if c.length > c.maxSize || c.length != len(c.lookup) {
+		return broken("length %d, %d keys looked up, size %d", c.length, len(c.lookup), c.maxSize)
+	}
+	count := 0
+	var prevLru *calecarLruNode
+	for node := c.lruHead; node != nil; node = node.next {
+		if count == c.length {
+			return broken("LRU list longer than the length %d", c.length)
+		}
+		if node.prev != prevLru || node.entryNode.lruNode != node || c.lookup[node.entryNode.key] != node.entryNode {
+			return broken("%s is linked into the LRU list wrong", node.entryNode.key)
+		}
+		prevLru = node
+		count++
+	}
+	if count != c.length || c.lruTail != prevLru {
+		return broken("LRU list of %d nodes for a length of %d", count, c.length)
+	}
+	count = 0
+	var prevLfu *calecarLfuNode
+	for node := c.lfuHead; node != nil; node = node.next {
+		if count == c.length {
+			return broken("LFU list longer than the length %d", c.length)
+		}
+		if node.prev != prevLfu || node.entryNode.lfuNode != node || c.lookup[node.entryNode.key] != node.entryNode {
+			return broken("%s is linked into the LFU list wrong", node.entryNode.key)
+		}
+		if prevLfu != nil && prevLfu.accessCount > node.accessCount {
+			return broken("%s read %d times after %s read %d", node.entryNode.key, node.accessCount, prevLfu.entryNode.key, prevLfu.accessCount)
+		}
+		prevLfu = node
+		count++
+	}
+	if count != c.length || c.lfuTail != prevLfu {
+		return broken("LFU list of %d nodes for a length of %d", count, c.length)
+	}
+	count = 0
+	var prevLcr *calecarLcrNode
+	for node := c.lcrHead; node != nil; node = node.next {
+		if count == c.length {
+			return broken("LCR list longer than the length %d", c.length)
+		}
+		if node.prev != prevLcr || node.entryNode.lcrNode != node || c.lookup[node.entryNode.key] != node.entryNode {
+			return broken("%s is linked into the LCR list wrong", node.entryNode.key)
+		}
+		if prevLcr != nil && prevLcr.entryNode.entry.cost > node.entryNode.entry.cost {
+			return broken("%s costing %d after %s costing %d", node.entryNode.key, node.entryNode.entry.cost, prevLcr.entryNode.key, prevLcr.entryNode.entry.cost)
+		}
+		prevLcr = node
+		count++
+	}
+	if count != c.length || c.lcrTail != prevLcr {
+		return broken("LCR list of %d nodes for a length of %d", count, c.length)
+	}
+	if c.historyLength > c.maxSize || c.historyLength != len(c.historyLookup) {
+		return broken("history of %d, %d keys looked up, size %d", c.historyLength, len(c.historyLookup), c.maxSize)
+	}
+	return nil
+}
+
+/*CheckInvariants checks both tiers, and that no key is in
+both*/
+func (t *Tiered) CheckInvariants() error {
+	for _, tier := range []Cache{t.hot, t.cold} {
+		if err := CheckInvariants(tier); err != nil {
+			return err
+		}
+	}
+	if t.coldPeeker == nil {
+		return nil
+	}
+	exporter, ok := t.hot.(Exporter)
+	if !ok {
+		return nil
+	}
+	for _, record := range exporter.Export() {
+		if _, ok := t.coldPeeker.Peek(record.Key); ok {
+			return broken("%s is in both tiers", record.Key)
+		}
+	}
+	return nil
+}
+
+/*CheckInvariants checks both segments, that together they
+fit the size and that no key is in both*/
+func (s *Slru) CheckInvariants() error {
+	for _, segment := range []Cache{s.probation, s.protected} {
+		if err := CheckInvariants(segment); err != nil {
+			return err
+		}
+	}
+	if s.size != unboundedSize && s.probation.length+s.protected.length > s.size {
+		return broken("segments of %d and %d for a size of %d", s.probation.length, s.protected.length, s.size)
+	}
+	for _, record := range s.protected.Export() {
+		if s.probation.resident(record.Key) != nil {
+			return broken("%s is in both segments", record.Key)
+		}
+	}
+	return nil
+}
+
+/*CheckInvariants checks the wrapped cache under the lock*/
+func (b *Batched) CheckInvariants() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return CheckInvariants(b.cache)
+}
+
+/*CheckInvariants checks every shard*/
+func (s *Sharded) CheckInvariants() error {
+	for _, shard := range s.shards {
+		if err := shard.CheckInvariants(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*Checked runs CheckInvariants after every call that can
+change the cache, and hands anything it finds to fail, so a
+structural bug shows up at the operation that caused it
+rather than much later.  In a test:
+
+	c := cache.NewChecked(lru, func(err error) { t.Fatal(err) })
+
+It's O(n) a call, so it's for tests and debugging only.  It
+adds no locking of its own*/
+type Checked struct {
+	cache Cache
+	fail  func(err error)
+}
+
+func (ch *Checked) check() {
+	if err := CheckInvariants(ch.cache); err != nil {
+		ch.fail(err)
+	}
+}
+
+/*KeyPresent checks the wrapped cache, which can drop an
+expired entry*/
+func (ch *Checked) KeyPresent(k string) bool {
+	present := ch.cache.KeyPresent(k)
+	ch.check()
+	return present
+}
+
+/*GetValue reads from the wrapped cache*/
+func (ch *Checked) GetValue(k string) (Entry, error) {
+	entry, err := ch.cache.GetValue(k)
+	ch.check()
+	return entry, err
+}
+
+/*SetValue writes to the wrapped cache*/
+func (ch *Checked) SetValue(k string, v Entry) error {
+	err := ch.cache.SetValue(k, v)
+	ch.check()
+	return err
+}
+
+/*Delete removes the key from the wrapped cache*/
+func (ch *Checked) Delete(k string) error {
+	err := ch.cache.Delete(k)
+	ch.check()
+	return err
+}
+
+/*Export lists the wrapped cache's entries, if it can*/
+func (ch *Checked) Export() []Record {
+	exporter, ok := ch.cache.(Exporter)
+	if !ok {
+		return []Record{}
+	}
+	return exporter.Export()
+}
+
+/*Import puts the record into the wrapped cache*/
+func (ch *Checked) Import(r Record) error {
+	err := ImportRecord(ch.cache, r)
+	ch.check()
+	return err
+}
+
+/*Unwrap is the cache being checked*/
+func (ch *Checked) Unwrap() Cache {
+	return ch.cache
+}
+
+/*NewChecked checks the cache after every change*/
+func NewChecked(c Cache, fail func(err error)) *Checked {
+	return &Checked{cache: c, fail: fail}
+}
@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestInvariantsHoldUnderRandomOperations(t *testing.T) {
+	for _, policy := range append(allPolicies, TIERED) {
+		inner, _ := NewCache(policy, 16)
+		c := NewChecked(inner, func(err error) { t.Fatalf("%s: %v", policy, err) })
+		rnd := rand.New(rand.NewSource(1))
+		for i := 0; i < 2000; i++ {
+			k := strconv.Itoa(rnd.Intn(40))
+			op := rnd.Intn(10)
+			if op < 5 {
+				c.GetValue(k)
+			} else if op < 9 {
+				c.SetValue(k, NewEntry("v", rnd.Intn(100)))
+			} else {
+				c.Delete(k)
+			}
+		}
+	}
+}
+
+func TestInvariantsCatchBrokenLinks(t *testing.T) {
+	lru := newLru(4)
+	for _, k := range []string{"a", "b", "c"} {
+		lru.SetValue(k, NewEntry(k, 1))
+	}
+	if err := CheckInvariants(NewBatched(lru)); err != nil {
+		t.Fatalf("expected a healthy cache to pass, got %v", err)
+	}
+	lru.list.nodes[lru.list.lookup["c"]].prev = lru.list.lookup["a"]
+	if err := CheckInvariants(NewBatched(lru)); !errors.Is(err, ErrInvariant) {
+		t.Fatalf("expected the dangling link caught, got %v", err)
+	}
+	lcr := newLcr(4)
+	for i, k := range []string{"a", "b", "c"} {
+		lcr.SetValue(k, NewEntry(k, 10*(i+1)))
+	}
+	lcr.nodes[0].entry.cost = 100
+	if err := lcr.CheckInvariants(); !errors.Is(err, ErrInvariant) {
+		t.Fatalf("expected the heap out of order caught, got %v", err)
+	}
+}
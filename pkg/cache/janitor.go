@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+/*Sweeper is implemented by caches that can find and drop
+their own expired entries.  SweepExpired looks at no more
+than limit entries and returns how many it dropped*/
+type Sweeper interface {
+	SweepExpired(limit int) int
+}
+
+/*sweeperFor is the outermost thing in the wrapper chain that
+can sweep, so a wrapper with a lock of its own (Batched,
+WriteBack...) takes it before the policy's removal hooks call
+back into it*/
+func sweeperFor(c Cache) Sweeper {
+	for c != nil {
+		sweeper, ok := c.(Sweeper)
+		if ok {
+			return sweeper
+		}
+		wrapper, ok := c.(unwrapper)
+		if !ok {
+			return nil
+		}
+		c = wrapper.Unwrap()
+	}
+	return nil
+}
+
+/*sweepExpired sweeps through whatever in the chain can*/
+func sweepExpired(c Cache, limit int) int {
+	sweeper := sweeperFor(c)
+	if sweeper == nil {
+		return 0
+	}
+	return sweeper.SweepExpired(limit)
+}
+
+/*Janitor reclaims expired entries nobody asks for again.
+Every interval it samples up to limit entries and drops the
+expired ones, and if more than a quarter of them were
+expired it goes again straight away (up to 16 rounds), so a
+wave of expiries is cleared quickly while a mostly live cache
+costs one small sample per tick.  Expiry never depends on it,
+lookups drop expired entries on their own.  The cache is
+touched from the janitor's goroutine, so give it the same
+synchronized cache (a Batched) everything else uses*/
+type Janitor struct {
+	sweeper  Sweeper
+	due      dueSweeper
+	interval time.Duration
+	limit    int
+	swept    int64
+	clock    Clock
+	stop     chan bool
+}
+
+/*SweepNow runs one sweep, returning how many entries it
+dropped*/
+func (j *Janitor) SweepNow() int {
+	if j.due != nil {
+		dropped := j.due.sweepDue()
+		atomic.AddInt64(&j.swept, int64(dropped))
+		return dropped
+	}
+	if j.sweeper == nil {
+		return 0
+	}
+	total := 0
+	for round := 0; round < 16; round++ {
+		dropped := j.sweeper.SweepExpired(j.limit)
+		total += dropped
+		if dropped*4 <= j.limit {
+			break
+		}
+	}
+	atomic.AddInt64(&j.swept, int64(total))
+	return total
+}
+
+/*Swept is how many entries the janitor has dropped so far*/
+func (j *Janitor) Swept() int64 {
+	return atomic.LoadInt64(&j.swept)
+}
+
+func (j *Janitor) run() {
+	for {
+		select {
+		case <-j.clock.After(j.interval):
+			j.SweepNow()
+		case <-j.stop:
+			return
+		}
+	}
+}
+
+/*Close stops the background sweeping*/
+func (j *Janitor) Close() {
+	close(j.stop)
+}
+
+/*NewJanitor starts sweeping the cache every interval,
+looking at up to limit entries a round.  A cache with nothing
+underneath that can sweep is left alone*/
+func NewJanitor(c Cache, interval time.Duration, limit int) *Janitor {
+	j := &Janitor{
+		sweeper:  sweeperFor(c),
+		interval: interval,
+		limit:    limit,
+		clock:    clockOf(c),
+		stop:     make(chan bool),
+	}
+	go j.run()
+	return j
+}
+
+/*NewWheelJanitor keeps every deadline in the cache's policy
+on a timing wheel and drops entries as they come due, one
+tick at a time, instead of sampling.  It costs a little
+memory per entry with a TTL but finds every expired entry
+within a tick, which sampling can't promise for a big cache
+where few entries are expired at once*/
+func NewWheelJanitor(c Cache, tick time.Duration) *Janitor {
+	j := &Janitor{
+		interval: tick,
+		clock:    clockOf(c),
+		stop:     make(chan bool),
+	}
+	due := dueSweeperFor(c)
+	if due != nil && due.startWheel(tick) {
+		j.due = due
+	}
+	go j.run()
+	return j
+}
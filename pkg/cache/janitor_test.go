@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestJanitorSweepsExpired(t *testing.T) {
+	for _, policy := range []CacheType{FIFO, LRU, LFU, LCR, LECAR, CALECAR} {
+		c, _ := NewCache(policy, 1000)
+		shared := NewBatched(c)
+		for i := 0; i < 300; i++ {
+			ttl := time.Hour
+			if i%3 == 0 {
+				ttl = time.Millisecond
+			}
+			shared.SetValue("k"+strconv.Itoa(i), NewEntry("v", 1).WithTTL(ttl))
+		}
+		time.Sleep(5 * time.Millisecond)
+		j := NewJanitor(shared, time.Hour, 1000)
+		j.Close()
+		if dropped := j.SweepNow(); dropped != 100 {
+			t.Fatalf("%s: dropped %d, expected 100", policy, dropped)
+		}
+		if records := shared.Export(); len(records) != 200 {
+			t.Fatalf("%s: %d entries left, expected 200", policy, len(records))
+		}
+	}
+}
+
+func TestJanitorTakesWrapperLocks(t *testing.T) {
+	// run with -race: the sweep has to hold WriteBack's lock while its onRemoval runs
+	lru, _ := NewCache(LRU, 1000, WithDefaultTTL(time.Millisecond))
+	wb := NewWriteBack(NewBatched(lru), &recordingStore{flushed: make(map[string]Entry)}, 1000000, time.Hour)
+	defer wb.Close()
+	j := NewJanitor(wb, time.Millisecond, 100)
+	wheel := NewWheelJanitor(wb, time.Millisecond)
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 2000; i++ {
+			wb.SetValue("k"+strconv.Itoa(i%300), NewEntry("v", 1))
+		}
+		close(done)
+	}()
+	<-done
+	time.Sleep(10 * time.Millisecond)
+	j.Close()
+	wheel.Close()
+}
@@ -0,0 +1,196 @@
+package cache
+
+import (
+	"sort"
+	"time"
+)
+
+/*lifetimeHorizon is how far ahead LcrTtl looks.  An entry
+with at least this long to live (or no TTL at all) is worth
+its full cost*/
+const lifetimeHorizon = time.Hour
+
+/*LcrTtl is LCR weighing each entry's cost by how much of its
+useful life is left: an entry expiring soon can only save its
+recompute a little while longer, so an expensive one about to
+expire anyway isn't protected over a cheaper one that will be
+good for hours.  The score changes as time passes, so the
+victim is found with a scan over the residents, O(n) per
+eviction*/
+type LcrTtl struct {
+	maxSize int
+	seq     int
+	lookup  map[string]*lcrNode
+	removalHooks
+	expiry
+}
+
+/*worth is the node's cost scaled by the share of the horizon
+it has left to live*/
+func (l *LcrTtl) worth(node *lcrNode, now int64) float64 {
+	deadline := node.entry.deadline()
+	if deadline == 0 {
+		return float64(node.entry.cost)
+	}
+	left := deadline - now
+	if left <= 0 {
+		return 0
+	}
+	if left >= int64(lifetimeHorizon) {
+		return float64(node.entry.cost)
+	}
+	return float64(node.entry.cost) * float64(left) / float64(lifetimeHorizon)
+}
+
+/*cheaper is the eviction order, lowest worth first and older
+entries first between equals*/
+func (l *LcrTtl) cheaper(a *lcrNode, b *lcrNode, now int64) bool {
+	wa := l.worth(a, now)
+	wb := l.worth(b, now)
+	if wa != wb {
+		return wa < wb
+	}
+	return a.seq < b.seq
+}
+
+/*victim is the resident worth least right now*/
+func (l *LcrTtl) victim() *lcrNode {
+	now := l.clock().Now().UnixNano()
+	var min *lcrNode
+	for _, node := range l.lookup {
+		if min == nil || l.cheaper(node, min, now) {
+			min = node
+		}
+	}
+	return min
+}
+
+/*KeyPresent is true if the key is in the cache right now*/
+func (l *LcrTtl) KeyPresent(k string) bool {
+	node, ok := l.lookup[k]
+	if ok && node.entry.expired(l.clock()) {
+		l.remove(k, Expired)
+		return false
+	}
+	return ok
+}
+
+/*GetValue will return the entry if present in the lookup*/
+func (l *LcrTtl) GetValue(k string) (Entry, error) {
+	node, ok := l.lookup[k]
+	if !ok {
+		return Entry{}, ErrKeyNotFound
+	}
+	if node.entry.expired(l.clock()) {
+		l.remove(k, Expired)
+		return Entry{}, ErrExpired
+	}
+	node.entry.touch(l.clock())
+	return node.entry, nil
+}
+
+/*nextEvicted is the key worth least right now*/
+func (l *LcrTtl) nextEvicted() (string, bool) {
+	node := l.victim()
+	if node == nil {
+		return "", false
+	}
+	return node.key, true
+}
+
+/*full is true if a new key would evict*/
+func (l *LcrTtl) full() bool {
+	return len(l.lookup) > 0 && len(l.lookup) == l.maxSize
+}
+
+/*Peek returns the entry without counting an access*/
+func (l *LcrTtl) Peek(k string) (Entry, bool) {
+	node, ok := l.lookup[k]
+	if !ok || node.entry.expired(l.clock()) {
+		return Entry{}, false
+	}
+	return node.entry, true
+}
+
+/*resident is the stored entry, to retime in place*/
+func (l *LcrTtl) resident(k string) *Entry {
+	node, ok := l.lookup[k]
+	if !ok {
+		return nil
+	}
+	return &node.entry
+}
+
+/*SetValue inserts a new cache entry, evicting the one worth
+least if necessary*/
+func (l *LcrTtl) SetValue(k string, v Entry) error {
+	v = l.stamp(k, v)
+	if _, ok := l.lookup[k]; ok {
+		l.remove(k, Replaced)
+	}
+	var evicted *lcrNode
+	if len(l.lookup) > 0 && len(l.lookup) == l.maxSize {
+		evicted = l.victim()
+		delete(l.lookup, evicted.key)
+	}
+	l.seq++
+	l.lookup[k] = &lcrNode{entry: v, key: k, seq: l.seq}
+	if evicted != nil {
+		l.removed(evicted.key, evicted.entry, Evicted)
+	}
+	return nil
+}
+
+/*Delete removes the entry from the cache if present*/
+func (l *LcrTtl) Delete(k string) error {
+	return l.remove(k, Deleted)
+}
+
+func (l *LcrTtl) remove(k string, reason RemovalReason) error {
+	node, ok := l.lookup[k]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	delete(l.lookup, k)
+	l.removed(k, node.entry, reason)
+	return nil
+}
+
+/*SweepExpired checks up to limit entries (map order, so a
+different sample each time) and drops the expired ones*/
+func (l *LcrTtl) SweepExpired(limit int) int {
+	expired := []string{}
+	for k, node := range l.lookup {
+		if limit <= 0 {
+			break
+		}
+		limit--
+		if node.entry.expired(l.clock()) {
+			expired = append(expired, k)
+		}
+	}
+	for _, k := range expired {
+		l.remove(k, Expired)
+	}
+	return len(expired)
+}
+
+/*Export lists resident entries in the order they would be
+evicted right now*/
+func (l *LcrTtl) Export() []Record {
+	nodes := make([]*lcrNode, 0, len(l.lookup))
+	for _, node := range l.lookup {
+		nodes = append(nodes, node)
+	}
+	now := l.clock().Now().UnixNano()
+	sort.Slice(nodes, func(i, j int) bool { return l.cheaper(nodes[i], nodes[j], now) })
+	records := []Record{}
+	for _, node := range nodes {
+		records = append(records, Record{Key: node.key, Entry: node.entry, Hits: 1})
+	}
+	return records
+}
+
+func newLcrTtl(size int) *LcrTtl {
+	return &LcrTtl{maxSize: size, lookup: make(map[string]*lcrNode, capacityHint(size))}
+}
@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLcrTtlEvictsExpensiveEntriesAboutToExpire(t *testing.T) {
+	c, _ := NewCache(LCRTTL, 3)
+	c.SetValue("expiring", NewEntry("1", 100).WithTTL(time.Minute))
+	c.SetValue("lasting", NewEntry("2", 10))
+	c.SetValue("cheap", NewEntry("3", 1).WithTTL(2*time.Hour))
+	evicted := []string{}
+	AddRemovalListener(c, func(key string, entry Entry, reason RemovalReason) {
+		evicted = append(evicted, key)
+	})
+	// expiring is worth 100 * 1/60, below lasting but above cheap
+	c.SetValue("d", NewEntry("4", 50))
+	c.SetValue("e", NewEntry("5", 50))
+	if len(evicted) != 2 || evicted[0] != "cheap" || evicted[1] != "expiring" {
+		t.Fatalf("expected cheap then expiring to go, got %v", evicted)
+	}
+	if !c.KeyPresent("lasting") {
+		t.Fatalf("lost the entry with the most value left")
+	}
+}
+
+func TestLcrTtlWithoutTtlsIsLcr(t *testing.T) {
+	ops := "set a 5,set b 1,set c 3,get b,set d 4,set e 6,set f 2"
+	lcr, _ := NewCache(LCR, 3)
+	lcrTtl, _ := NewCache(LCRTTL, 3)
+	want := replay(t, lcr, ops)
+	got := replay(t, lcrTtl, ops)
+	if len(want) != len(got) {
+		t.Fatalf("expected evictions %v, got %v", want, got)
+	}
+	for i := range want {
+		if want[i] != got[i] {
+			t.Fatalf("expected evictions %v, got %v", want, got)
+		}
+	}
+	if keys, wantKeys := exportedKeys(lcrTtl), exportedKeys(lcr); keys != wantKeys {
+		t.Fatalf("expected residents %s, got %s", wantKeys, keys)
+	}
+}
@@ -1,7 +1,6 @@
 package cache
 
 import (
-	"errors"
 	"fmt"
 	"math"
 	"math/rand"
@@ -53,6 +52,8 @@ type Lecar struct {
 	historyTail   *lecarHistoryNode
 	lambda        float64
 	discount      float64
+	removalHooks
+	expiry
 }
 
 func (l *Lecar) updateAlgoWeights(node *lecarHistoryNode) {
@@ -86,7 +87,12 @@ func (l *Lecar) updateAlgoWeights(node *lecarHistoryNode) {
 
 /*KeyPresent is true if the key is in the cache right now*/
 func (l *Lecar) KeyPresent(k string) bool {
-	_, ok := l.lookup[k]
+	lookupNode, ok := l.lookup[k]
+	if ok && lookupNode.entry.expired(l.clock()) {
+		// expired, not evicted, so no policy is to blame
+		l.remove(k, Expired)
+		return false
+	}
 	if !ok {
 		// key not in cache, check history and penalize if present
 		historyNode, hOk := l.historyLookup[k]
@@ -102,7 +108,11 @@ func (l *Lecar) KeyPresent(k string) bool {
 func (l *Lecar) GetValue(k string) (Entry, error) {
 	lookupNode, ok := l.lookup[k]
 	if !ok {
-		return Entry{}, errors.New("Key not present in lookup hash")
+		return Entry{}, ErrKeyNotFound
+	}
+	if lookupNode.entry.expired(l.clock()) {
+		l.remove(k, Expired)
+		return Entry{}, ErrExpired
 	}
 	lruNode := lookupNode.lruNode
 	// LRU: promote entry to most recently accessed
@@ -140,9 +150,43 @@ func (l *Lecar) GetValue(k string) (Entry, error) {
 	} else {
 		l.reorderLfuList(lfuNode)
 	}
+	lookupNode.entry.touch(l.clock())
 	return lookupNode.entry, nil
 }
 
+/*Peek returns the entry without counting an access or
+touching the weights*/
+func (l *Lecar) Peek(k string) (Entry, bool) {
+	lookupNode, ok := l.lookup[k]
+	if !ok || lookupNode.entry.expired(l.clock()) {
+		return Entry{}, false
+	}
+	return lookupNode.entry, true
+}
+
+/*possibleVictims is what a write could evict, the heads of
+the LRU and LFU lists, since which goes is down to chance*/
+func (l *Lecar) possibleVictims() []string {
+	if l.length == 0 {
+		return nil
+	}
+	return []string{l.lruHead.entryNode.key, l.lfuHead.entryNode.key}
+}
+
+/*full is true if a new key would evict*/
+func (l *Lecar) full() bool {
+	return l.length > 0 && l.length == l.maxSize
+}
+
+/*resident is the stored entry, to retime in place*/
+func (l *Lecar) resident(k string) *Entry {
+	node, ok := l.lookup[k]
+	if !ok {
+		return nil
+	}
+	return &node.entry
+}
+
 func (l *Lecar) reorderLfuList(node *lecarLfuNode) {
 	for {
 		if node.accessCount >= node.next.accessCount {
@@ -278,15 +322,21 @@ func (l *Lecar) putInHistory(entryNode *lecarLookupNode, evictionType string) {
 	} else if l.historyLength == l.maxSize {
 		// FIFO head/tail
 		prevHistHead := l.historyHead
-		nextHistHead := prevHistHead.next
-		nextHistHead.prev = nil
-		l.historyHead = nextHistHead
-		prevHistHead.next = nil
 		delete(l.historyLookup, prevHistHead.key)
-		prevHistoryTail := l.historyTail
-		prevHistoryTail.next = historyNode
-		historyNode.prev = prevHistoryTail
-		l.historyTail = historyNode
+		if prevHistHead == l.historyTail {
+			// a history of one, the new node replaces it
+			l.historyHead = historyNode
+			l.historyTail = historyNode
+		} else {
+			nextHistHead := prevHistHead.next
+			nextHistHead.prev = nil
+			l.historyHead = nextHistHead
+			prevHistHead.next = nil
+			prevHistoryTail := l.historyTail
+			prevHistoryTail.next = historyNode
+			historyNode.prev = prevHistoryTail
+			l.historyTail = historyNode
+		}
 	} else {
 		// grow list, this is the new "tail"
 		prevHistoryTail := l.historyTail
@@ -306,6 +356,11 @@ func (l *Lecar) putInHistory(entryNode *lecarLookupNode, evictionType string) {
 
 /*SetValue inserts a new cache entry, evicting one if necessary*/
 func (l *Lecar) SetValue(k string, v Entry) error {
+	v = l.stamp(k, v)
+	if _, ok := l.lookup[k]; ok {
+		// replacing an entry, drop the old node first
+		l.remove(k, Replaced)
+	}
 	lookupNode := &lecarLookupNode{key: k, entry: v}
 	lruNode := &lecarLruNode{entryNode: lookupNode}
 	lfuNode := &lecarLfuNode{entryNode: lookupNode, accessCount: 1}
@@ -320,13 +375,30 @@ func (l *Lecar) SetValue(k string, v Entry) error {
 		l.lookup[k] = lookupNode
 		l.length = 1
 		return nil
+	} else if l.maxSize == 1 {
+		// the only entry makes way, the new one starts the lists
+		evictEntryNode := l.lruHead.entryNode
+		evictionType := "LRU"
+		if rand.Float64() > l.weightLru {
+			evictionType = "LFU"
+		}
+		delete(l.lookup, evictEntryNode.key)
+		l.putInHistory(evictEntryNode, evictionType)
+		l.lruHead = lruNode
+		l.lruTail = lruNode
+		l.lfuHead = lfuNode
+		l.lfuTail = lfuNode
+		l.lookup[k] = lookupNode
+		l.removed(evictEntryNode.key, evictEntryNode.entry, Evicted)
+		return nil
 	} else if l.length == l.maxSize {
 		// evict one entry
+		var evictEntryNode *lecarLookupNode
 		sampleVal := rand.Float64()
 		if sampleVal <= l.weightLru {
 			// evict by LRU
 			prevLruHead := l.lruHead
-			evictEntryNode := prevLruHead.entryNode
+			evictEntryNode = prevLruHead.entryNode
 			delete(l.lookup, evictEntryNode.key)
 			l.putInHistory(evictEntryNode, "LRU")
 			newLruHead := prevLruHead.next
@@ -341,7 +413,7 @@ func (l *Lecar) SetValue(k string, v Entry) error {
 		} else {
 			// evict by LFU
 			prevLfuHead := l.lfuHead
-			evictEntryNode := prevLfuHead.entryNode
+			evictEntryNode = prevLfuHead.entryNode
 			delete(l.lookup, evictEntryNode.key)
 			l.putInHistory(evictEntryNode, "LFU")
 			newLfuHead := prevLfuHead.next
@@ -355,7 +427,7 @@ func (l *Lecar) SetValue(k string, v Entry) error {
 			l.appendToLru(lruNode)
 		}
 		l.lookup[k] = lookupNode
-
+		l.removed(evictEntryNode.key, evictEntryNode.entry, Evicted)
 		// length does not change
 		return nil
 	}
@@ -369,9 +441,82 @@ func (l *Lecar) SetValue(k string, v Entry) error {
 	return nil
 }
 
+/*Delete removes the entry from the cache if present.
+Deleted keys are not written to history since no policy chose them*/
+func (l *Lecar) Delete(k string) error {
+	return l.remove(k, Deleted)
+}
+
+func (l *Lecar) remove(k string, reason RemovalReason) error {
+	lookupNode, ok := l.lookup[k]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	if l.length == 1 {
+		// last entry, lists are empty now
+		l.lruHead = nil
+		l.lruTail = nil
+		l.lfuHead = nil
+		l.lfuTail = nil
+	} else {
+		l.removeFromLru(lookupNode.lruNode)
+		l.removeFromLfu(lookupNode.lfuNode)
+	}
+	delete(l.lookup, k)
+	l.length = l.length - 1
+	l.removed(k, lookupNode.entry, reason)
+	return nil
+}
+
+/*SweepExpired checks up to limit entries (map order, so a
+different sample each time) and drops the expired ones.
+Expired keys don't go to history, no policy chose them*/
+func (l *Lecar) SweepExpired(limit int) int {
+	expired := []string{}
+	for k, lookupNode := range l.lookup {
+		if limit <= 0 {
+			break
+		}
+		limit--
+		if lookupNode.entry.expired(l.clock()) {
+			expired = append(expired, k)
+		}
+	}
+	for _, k := range expired {
+		l.remove(k, Expired)
+	}
+	return len(expired)
+}
+
+/*Export lists resident entries, least recently used first*/
+func (l *Lecar) Export() []Record {
+	records := []Record{}
+	for node := l.lruHead; node != nil; node = node.next {
+		entryNode := node.entryNode
+		records = append(records, Record{Key: entryNode.key, Entry: entryNode.entry, Hits: entryNode.lfuNode.accessCount})
+	}
+	return records
+}
+
+/*Import sets the record and restores its access count*/
+func (l *Lecar) Import(r Record) error {
+	err := l.SetValue(r.Key, r.Entry)
+	if err != nil {
+		return err
+	}
+	lfuNode := l.lookup[r.Key].lfuNode
+	if r.Hits > lfuNode.accessCount {
+		lfuNode.accessCount = r.Hits
+		if lfuNode != l.lfuTail {
+			l.reorderLfuList(lfuNode)
+		}
+	}
+	return nil
+}
+
 func newLecar(size int) *Lecar {
-	lk := make(map[string]*lecarLookupNode)
-	hk := make(map[string]*lecarHistoryNode)
+	lk := make(map[string]*lecarLookupNode, capacityHint(size))
+	hk := make(map[string]*lecarHistoryNode, capacityHint(size))
 	return &Lecar{
 		maxSize:       size,
 		length:        0,
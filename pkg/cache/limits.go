@@ -0,0 +1,101 @@
+package cache
+
+import "fmt"
+
+/*EntryLimits caps what a single entry can be, so one
+pathological value can't push out the whole working set.
+MaxBytes is counted by the Sizer (KeyValueSizer if nil), a
+zero limit is no limit.  Entries over a limit are refused
+with ErrEntryTooLarge, or with Bypass just not cached: the
+write succeeds and any older entry for the key is dropped,
+since it's no longer the value*/
+type EntryLimits struct {
+	MaxBytes int64
+	MaxCost  int
+	Sizer    Sizer
+	Bypass   bool
+}
+
+/*EntryLimited holds every write to its EntryLimits.  It
+keeps no state of its own beyond them, so it adds no
+locking*/
+type EntryLimited struct {
+	cache  Cache
+	limits EntryLimits
+}
+
+/*KeyPresent is true if the key is cached right now*/
+func (el *EntryLimited) KeyPresent(k string) bool {
+	return el.cache.KeyPresent(k)
+}
+
+/*GetValue reads from the wrapped cache*/
+func (el *EntryLimited) GetValue(k string) (Entry, error) {
+	return el.cache.GetValue(k)
+}
+
+/*SetValue writes the entry if it's within the limits*/
+func (el *EntryLimited) SetValue(k string, v Entry) error {
+	err := el.check(k, v)
+	if err == nil {
+		return el.cache.SetValue(k, v)
+	}
+	if !el.limits.Bypass {
+		return err
+	}
+	el.cache.Delete(k)
+	return nil
+}
+
+func (el *EntryLimited) check(k string, v Entry) error {
+	if el.limits.MaxCost > 0 && v.cost > el.limits.MaxCost {
+		return fmt.Errorf("%w, %s costs %d over the limit of %d", ErrEntryTooLarge, k, v.cost, el.limits.MaxCost)
+	}
+	if el.limits.MaxBytes > 0 {
+		size := el.limits.Sizer.Size(k, v)
+		if size > el.limits.MaxBytes {
+			return fmt.Errorf("%w, %s takes %d bytes over the limit of %d", ErrEntryTooLarge, k, size, el.limits.MaxBytes)
+		}
+	}
+	return nil
+}
+
+/*Delete removes the key from the wrapped cache*/
+func (el *EntryLimited) Delete(k string) error {
+	return el.cache.Delete(k)
+}
+
+/*Export lists the wrapped cache's entries, if it can*/
+func (el *EntryLimited) Export() []Record {
+	exporter, ok := el.cache.(Exporter)
+	if !ok {
+		return []Record{}
+	}
+	return exporter.Export()
+}
+
+/*Import holds records to the limits too, a snapshot from a
+cache without them can still carry a huge entry*/
+func (el *EntryLimited) Import(r Record) error {
+	err := el.check(r.Key, r.Entry)
+	if err != nil {
+		if el.limits.Bypass {
+			return nil
+		}
+		return err
+	}
+	return ImportRecord(el.cache, r)
+}
+
+/*Unwrap is the cache being limited*/
+func (el *EntryLimited) Unwrap() Cache {
+	return el.cache
+}
+
+/*NewEntryLimited holds the cache's entries to the limits*/
+func NewEntryLimited(c Cache, limits EntryLimits) *EntryLimited {
+	if limits.Sizer == nil {
+		limits.Sizer = KeyValueSizer
+	}
+	return &EntryLimited{cache: c, limits: limits}
+}
@@ -0,0 +1,37 @@
+package cache
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEntryLimitsRejectOversized(t *testing.T) {
+	lru, _ := NewCache(LRU, 10)
+	c := NewEntryLimited(lru, EntryLimits{MaxBytes: 20, MaxCost: 100})
+	if err := c.SetValue("ok", NewEntry("small", 100)); err != nil {
+		t.Fatalf("refused an entry within the limits: %v", err)
+	}
+	err := c.SetValue("big", NewEntry(strings.Repeat("x", 50), 1))
+	if !errors.Is(err, ErrEntryTooLarge) || !errors.Is(err, ErrCacheFull) || c.KeyPresent("big") {
+		t.Fatalf("expected an oversized entry refused, got %v", err)
+	}
+	if err := c.SetValue("ok", NewEntry("small", 101)); !errors.Is(err, ErrEntryTooLarge) {
+		t.Fatalf("expected a costly entry refused, got %v", err)
+	}
+	if entry, _ := c.GetValue("ok"); entry.Cost() != 100 {
+		t.Fatalf("a refused write replaced the entry")
+	}
+}
+
+func TestEntryLimitsBypassOversized(t *testing.T) {
+	lru, _ := NewCache(LRU, 10)
+	c := NewEntryLimited(lru, EntryLimits{MaxBytes: 20, Bypass: true})
+	c.SetValue("k", NewEntry("small", 1))
+	if err := c.SetValue("k", NewEntry(strings.Repeat("x", 50), 1)); err != nil {
+		t.Fatalf("expected a bypassed write to succeed, got %v", err)
+	}
+	if c.KeyPresent("k") {
+		t.Fatalf("kept the stale entry for a key written past the cache")
+	}
+}
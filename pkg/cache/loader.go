@@ -0,0 +1,119 @@
+package cache
+
+import "time"
+
+/*Loader computes the entry for a key the cache doesn't
+have, measuring (or estimating) its cost along the way*/
+type Loader interface {
+	Load(key string) (Entry, error)
+}
+
+/*LoaderFunc lets a plain function be used as a Loader*/
+type LoaderFunc func(key string) (Entry, error)
+
+/*Load calls the function*/
+func (f LoaderFunc) Load(key string) (Entry, error) { return f(key) }
+
+/*CostFunc turns how long a load took into the cost stored
+on the entry, for callers who want something other than
+wall time (cpu time, rows scanned, dollars...)*/
+type CostFunc func(key string, elapsed time.Duration, entry Entry) int
+
+/*CostInUnits measures cost as load time in the given unit*/
+func CostInUnits(unit time.Duration) CostFunc {
+	return func(key string, elapsed time.Duration, entry Entry) int {
+		return int(elapsed / unit)
+	}
+}
+
+/*TimedLoader wraps a loader so every entry it returns has
+its cost set from the load, replacing whatever the loader
+put there*/
+type TimedLoader struct {
+	loader Loader
+	costFn CostFunc
+}
+
+/*Load runs the wrapped loader and measures it*/
+func (tl *TimedLoader) Load(key string) (Entry, error) {
+	start := time.Now()
+	entry, err := tl.loader.Load(key)
+	if err != nil {
+		return entry, err
+	}
+	entry.cost = tl.costFn(key, time.Since(start), entry)
+	return entry, nil
+}
+
+/*NewTimedLoader measures the loader with costFn*/
+func NewTimedLoader(loader Loader, costFn CostFunc) *TimedLoader {
+	return &TimedLoader{loader: loader, costFn: costFn}
+}
+
+/*ReadThrough wraps a cache so misses are populated from a
+loader automatically.  Concurrent misses on the same key
+share a single load.  Entries the loader returns without a
+cost get one measured from the load (microseconds of wall
+time unless SetCostFunc says otherwise), so cost aware
+policies work without the caller estimating anything*/
+type ReadThrough struct {
+	cache  Cache
+	loader Loader
+	loads  *flightGroup
+	costFn CostFunc
+}
+
+/*KeyPresent is true if the key is cached right now,
+it never triggers a load*/
+func (rt *ReadThrough) KeyPresent(k string) bool {
+	return rt.cache.KeyPresent(k)
+}
+
+/*GetValue returns the cached entry, loading and caching
+it first on a miss*/
+func (rt *ReadThrough) GetValue(k string) (Entry, error) {
+	if rt.cache.KeyPresent(k) {
+		entry, err := rt.cache.GetValue(k)
+		if err == nil {
+			return entry, nil
+		}
+	}
+	entry, _, err := rt.loads.Do(k, func() (Entry, int, error) {
+		start := time.Now()
+		entry, err := rt.loader.Load(k)
+		if err != nil {
+			return Entry{}, 0, err
+		}
+		if entry.cost == 0 {
+			entry.cost = rt.costFn(k, time.Since(start), entry)
+		}
+		rt.cache.SetValue(k, entry)
+		return entry, entry.cost, nil
+	})
+	return entry, err
+}
+
+/*SetValue writes straight to the wrapped cache*/
+func (rt *ReadThrough) SetValue(k string, v Entry) error {
+	return rt.cache.SetValue(k, v)
+}
+
+/*Delete removes the key from the wrapped cache*/
+func (rt *ReadThrough) Delete(k string) error {
+	return rt.cache.Delete(k)
+}
+
+/*SetCostFunc changes how unpriced loads are measured*/
+func (rt *ReadThrough) SetCostFunc(fn CostFunc) {
+	rt.costFn = fn
+}
+
+/*Unwrap is the cache being read through to*/
+func (rt *ReadThrough) Unwrap() Cache {
+	return rt.cache
+}
+
+/*NewReadThrough wraps the cache with the loader*/
+func NewReadThrough(c Cache, loader Loader) *ReadThrough {
+	return &ReadThrough{cache: c, loader: loader, loads: &flightGroup{}, costFn: CostInUnits(time.Microsecond)}
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadThroughLoadsMisses(t *testing.T) {
+	lru, _ := NewCache(LRU, 10)
+	loads := 0
+	rt := NewReadThrough(lru, LoaderFunc(func(k string) (Entry, error) {
+		loads++
+		if k == "missing" {
+			return Entry{}, errors.New("No such key")
+		}
+		return NewEntry("v:"+k, 7), nil
+	}))
+	if rt.KeyPresent("a") {
+		t.Fatal("KeyPresent must not load")
+	}
+	for i := 0; i < 3; i++ {
+		entry, err := rt.GetValue("a")
+		if err != nil || entry.Value() != "v:a" || entry.Cost() != 7 {
+			t.Fatalf("got %+v %v", entry, err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("expected one load, got %d", loads)
+	}
+	if _, err := rt.GetValue("missing"); err == nil || lru.KeyPresent("missing") {
+		t.Fatal("a failed load should be returned and not cached")
+	}
+}
+
+func TestReadThroughSharesConcurrentLoads(t *testing.T) {
+	lru, _ := NewCache(LRU, 10)
+	var loads int32
+	rt := NewReadThrough(NewBatched(lru), LoaderFunc(func(k string) (Entry, error) {
+		atomic.AddInt32(&loads, 1)
+		time.Sleep(20 * time.Millisecond)
+		return NewEntry("v", 1), nil
+	}))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rt.GetValue("k")
+		}()
+	}
+	wg.Wait()
+	if loads != 1 {
+		t.Fatalf("concurrent misses should share a load, got %d", loads)
+	}
+}
+
+func TestLoadCostIsMeasured(t *testing.T) {
+	slow := LoaderFunc(func(k string) (Entry, error) {
+		time.Sleep(20 * time.Millisecond)
+		return NewEntry("v", 0), nil
+	})
+	lru, _ := NewCache(LRU, 10)
+	rt := NewReadThrough(lru, slow)
+	rt.SetCostFunc(CostInUnits(time.Millisecond))
+	entry, _ := rt.GetValue("k")
+	if entry.Cost() < 20 || entry.Cost() > 200 {
+		t.Fatalf("expected about 20ms of cost, got %d", entry.Cost())
+	}
+	// a timed loader always overrides the loader's own guess
+	timed := NewTimedLoader(LoaderFunc(func(k string) (Entry, error) {
+		return NewEntry("v", 999), nil
+	}), func(key string, elapsed time.Duration, entry Entry) int {
+		return len(key)
+	})
+	entry, _ = timed.Load("four")
+	if entry.Cost() != 4 {
+		t.Fatalf("expected the cost func's 4, got %d", entry.Cost())
+	}
+}
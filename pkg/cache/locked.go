@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+/*lockedInner is the lock a wrapper holds around the cache it
+wraps, embedded so the maintenance paths (the janitor's
+sweeps, the wheel, Touch, Expire and Resize) take it too.
+Those go into the policy from the side, and the removals they
+cause reach the wrapper's onRemoval just like the ones its
+own calls cause, so onRemoval can rely on the lock being held
+whichever way in it came*/
+type lockedInner struct {
+	mu    sync.Mutex
+	cache Cache
+}
+
+/*SweepExpired drops expired entries from the wrapped cache
+under the lock*/
+func (li *lockedInner) SweepExpired(limit int) int {
+	li.mu.Lock()
+	defer li.mu.Unlock()
+	return sweepExpired(li.cache, limit)
+}
+
+func (li *lockedInner) startWheel(tick time.Duration) bool {
+	li.mu.Lock()
+	defer li.mu.Unlock()
+	return startWheel(li.cache, tick)
+}
+
+func (li *lockedInner) sweepDue() int {
+	li.mu.Lock()
+	defer li.mu.Unlock()
+	return sweepDue(li.cache)
+}
+
+func (li *lockedInner) retime(k string, ttl time.Duration, shorten bool) error {
+	li.mu.Lock()
+	defer li.mu.Unlock()
+	return retime(li.cache, k, ttl, shorten)
+}
+
+func (li *lockedInner) resize(size int) (int, error) {
+	li.mu.Lock()
+	defer li.mu.Unlock()
+	return Resize(li.cache, size)
+}
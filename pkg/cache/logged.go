@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+/*Logger is anything that can print a formatted line, a
+*log.Logger usually*/
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+/*LogLevel says which operations a Logged cache writes out*/
+type LogLevel int
+
+const (
+	/*LogErrors only writes out failed operations*/
+	LogErrors LogLevel = iota
+	/*LogWrites adds writes, deletes and entries the policy
+	evicts or lets expire*/
+	LogWrites
+	/*LogAll adds every read as well*/
+	LogAll
+)
+
+/*LogOptions configures a Logged cache.  Rate is the share of
+keys whose operations are written out (0 or 1 for all of
+them), errors are written out whatever it is*/
+type LogOptions struct {
+	Level LogLevel
+	Rate  float64
+}
+
+/*Logged writes a line per operation to its Logger, with the
+key, the outcome and how long the wrapped cache took, and one
+per entry the cache evicts or expires with why, for watching
+what a cache does in staging.  Like the Recorder it samples by
+key hash, so a sampled key's whole history is there.  It adds
+no locking, a *log.Logger is safe to share*/
+type Logged struct {
+	cache     Cache
+	logger    Logger
+	level     LogLevel
+	all       bool
+	threshold uint64
+}
+
+func (l *Logged) sampled(k string) bool {
+	return l.all || SampleHash(k) <= l.threshold
+}
+
+func (l *Logged) log(level LogLevel, op string, k string, outcome string, took time.Duration) {
+	if level > l.level || !l.sampled(k) {
+		return
+	}
+	l.logger.Printf("cache %s %s %s in %s", op, k, outcome, took)
+}
+
+func (l *Logged) logError(op string, k string, err error, took time.Duration) {
+	l.logger.Printf("cache %s %s failed in %s: %s", op, k, took, err.Error())
+}
+
+/*KeyPresent checks the wrapped cache*/
+func (l *Logged) KeyPresent(k string) bool {
+	start := time.Now()
+	present := l.cache.KeyPresent(k)
+	outcome := "absent"
+	if present {
+		outcome = "present"
+	}
+	l.log(LogAll, "present", k, outcome, time.Since(start))
+	return present
+}
+
+/*GetValue reads from the wrapped cache, a miss isn't an
+error*/
+func (l *Logged) GetValue(k string) (Entry, error) {
+	start := time.Now()
+	entry, err := l.cache.GetValue(k)
+	if err == nil {
+		l.log(LogAll, "get", k, "hit", time.Since(start))
+	} else {
+		l.log(LogAll, "get", k, "miss", time.Since(start))
+	}
+	return entry, err
+}
+
+/*SetValue writes to the wrapped cache*/
+func (l *Logged) SetValue(k string, v Entry) error {
+	start := time.Now()
+	err := l.cache.SetValue(k, v)
+	if err != nil {
+		l.logError("set", k, err, time.Since(start))
+	} else {
+		l.log(LogWrites, "set", k, "stored", time.Since(start))
+	}
+	return err
+}
+
+/*Delete removes the key from the wrapped cache, a key that
+wasn't there isn't an error*/
+func (l *Logged) Delete(k string) error {
+	start := time.Now()
+	err := l.cache.Delete(k)
+	if err == nil {
+		l.log(LogWrites, "delete", k, "deleted", time.Since(start))
+	} else if errors.Is(err, ErrKeyNotFound) {
+		l.log(LogWrites, "delete", k, "absent", time.Since(start))
+	} else {
+		l.logError("delete", k, err, time.Since(start))
+	}
+	return err
+}
+
+func (l *Logged) onRemoval(key string, entry Entry, reason RemovalReason) {
+	if reason == Evicted || reason == Expired {
+		// deletes and replacements were already logged by the call that made them
+		if l.level >= LogWrites && l.sampled(key) {
+			l.logger.Printf("cache %s %s", strings.ToLower(reason.String()), key)
+		}
+	}
+}
+
+/*Export lists the wrapped cache's entries, if it can*/
+func (l *Logged) Export() []Record {
+	exporter, ok := l.cache.(Exporter)
+	if !ok {
+		return []Record{}
+	}
+	return exporter.Export()
+}
+
+/*Import puts the record into the wrapped cache*/
+func (l *Logged) Import(r Record) error {
+	return ImportRecord(l.cache, r)
+}
+
+/*Unwrap is the cache being logged*/
+func (l *Logged) Unwrap() Cache {
+	return l.cache
+}
+
+/*NewLogged logs the cache's operations to the logger*/
+func NewLogged(c Cache, logger Logger, opts LogOptions) *Logged {
+	l := &Logged{cache: c, logger: logger, level: opts.Level}
+	if opts.Rate <= 0 || opts.Rate >= 1 {
+		l.all = true
+	} else {
+		l.threshold = uint64(opts.Rate * float64(1<<63) * 2)
+	}
+	AddRemovalListener(c, l.onRemoval)
+	return l
+}
@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestLoggedWritesOutOperations(t *testing.T) {
+	out := &bytes.Buffer{}
+	lru, _ := NewCache(LRU, 1)
+	c := NewLogged(lru, log.New(out, "", 0), LogOptions{Level: LogWrites})
+	c.SetValue("a", NewEntry("a", 1))
+	c.GetValue("a")
+	c.SetValue("b", NewEntry("b", 1))
+	c.Delete("b")
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected the writes and the eviction without the read, got %q", lines)
+	}
+	if !strings.HasPrefix(lines[0], "cache set a stored in ") || lines[1] != "cache evicted a" || !strings.HasPrefix(lines[3], "cache delete b deleted") {
+		t.Fatalf("unexpected lines %q", lines)
+	}
+	out.Reset()
+	limited := NewLogged(NewEntryLimited(lru, EntryLimits{MaxCost: 1}), log.New(out, "", 0), LogOptions{Level: LogErrors, Rate: 0.0001})
+	limited.SetValue("c", NewEntry("c", 1))
+	limited.SetValue("c", NewEntry("c", 5))
+	if !strings.Contains(out.String(), "cache set c failed") || strings.Contains(out.String(), "stored") {
+		t.Fatalf("expected only the failure logged, got %q", out.String())
+	}
+}
@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+var memoizers int64
+
+/*Memoize caches fn's results in c.  Arguments are keyed with
+fmt's %v (inside a key space of their own for each Memoize
+call, so two memoized functions can share a cache), results
+are stored JSON encoded, the cost is how long fn took in
+microseconds, and concurrent calls with the same argument
+share one run of fn.  Errors are returned, never cached*/
+func Memoize[K comparable, V any](c Cache, fn func(K) (V, error)) func(K) (V, error) {
+	prefix := "memo" + strconv.FormatInt(atomic.AddInt64(&memoizers, 1), 10) + ":"
+	loads := &flightGroup{}
+	return func(arg K) (V, error) {
+		var result V
+		key := prefix + fmt.Sprintf("%v", arg)
+		if c.KeyPresent(key) {
+			entry, err := c.GetValue(key)
+			if err == nil && json.Unmarshal([]byte(entry.value), &result) == nil {
+				return result, nil
+			}
+		}
+		entry, _, err := loads.Do(key, func() (Entry, int, error) {
+			start := time.Now()
+			computed, err := fn(arg)
+			if err != nil {
+				return Entry{}, 0, err
+			}
+			encoded, err := json.Marshal(computed)
+			if err != nil {
+				return Entry{}, 0, err
+			}
+			entry := Entry{value: string(encoded), cost: int(time.Since(start) / time.Microsecond)}
+			c.SetValue(key, entry)
+			return entry, entry.cost, nil
+		})
+		if err != nil {
+			return result, err
+		}
+		err = json.Unmarshal([]byte(entry.value), &result)
+		return result, err
+	}
+}
@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoize(t *testing.T) {
+	c, _ := NewCache(LCR, 10)
+	calls := 0
+	square := Memoize(c, func(n int) ([]int, error) {
+		calls++
+		return []int{n, n * n}, nil
+	})
+	first, _ := square(3)
+	again, _ := square(3)
+	if calls != 1 || again[0] != 3 || again[1] != 9 || first[1] != 9 {
+		t.Fatalf("expected one call for repeated arguments, got %d (%v %v)", calls, first, again)
+	}
+	square(4)
+	if calls != 2 {
+		t.Fatalf("a new argument should call through, %d calls", calls)
+	}
+}
+
+func TestMemoizeDoesNotCacheErrors(t *testing.T) {
+	c, _ := NewCache(LRU, 10)
+	calls := 0
+	flaky := Memoize(c, func(s string) (string, error) {
+		calls++
+		if calls == 1 {
+			return "", errors.New("Try again")
+		}
+		return s + "!", nil
+	})
+	if _, err := flaky("a"); err == nil {
+		t.Fatal("expected the first call's error")
+	}
+	if result, err := flaky("a"); err != nil || result != "a!" || calls != 2 {
+		t.Fatalf("the error shouldn't have been cached, got %q %v after %d calls", result, err, calls)
+	}
+}
+
+func TestMemoizeKeySpaces(t *testing.T) {
+	c, _ := NewCache(LRU, 10)
+	double := Memoize(c, func(n int) (int, error) { return n * 2, nil })
+	negate := Memoize(c, func(n int) (int, error) { return -n, nil })
+	double(5)
+	if result, _ := negate(5); result != -5 {
+		t.Fatalf("memoized functions sharing a cache mixed up results, got %d", result)
+	}
+}
+
+func TestMemoizeSharesConcurrentCalls(t *testing.T) {
+	c, _ := NewCache(LRU, 10)
+	var calls int64
+	slow := Memoize(NewBatched(c), func(n int) (int, error) {
+		atomic.AddInt64(&calls, 1)
+		time.Sleep(20 * time.Millisecond)
+		return n, nil
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slow(1)
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("concurrent calls should share one run, got %d", calls)
+	}
+}
+
+func TestMemoizeResultsDontAlias(t *testing.T) {
+	c, _ := NewCache(LRU, 10)
+	lookup := Memoize(c, func(n int) (map[string][]int, error) {
+		return map[string][]int{"n": {n}}, nil
+	})
+	first, _ := lookup(1)
+	first["n"][0] = 99
+	first["extra"] = []int{1}
+	again, _ := lookup(1)
+	if again["n"][0] != 1 || len(again) != 1 {
+		t.Fatalf("changing one result reached the cache: %v", again)
+	}
+}
@@ -0,0 +1,136 @@
+package cache
+
+import "unsafe"
+
+/*Footprint estimates what a cache's entries take in memory,
+by component.  Keys and Values are the bytes of the data (the
+values as a Sizer counts them, if given one), Overhead the
+policy's bookkeeping around each entry: its nodes, map slots
+and the string headers.  History is what LECAR and CALECAR's
+record of evicted keys comes to once full, which it is soon
+after they start evicting.  It is worked out from the sizes
+of the structures, not measured off the heap, so allocator
+rounding and spare map capacity aren't in it*/
+type Footprint struct {
+	Entries  int
+	Keys     int64
+	Values   int64
+	Overhead int64
+	History  int64
+}
+
+func (f Footprint) add(other Footprint) Footprint {
+	return Footprint{
+		Entries:  f.Entries + other.Entries,
+		Keys:     f.Keys + other.Keys,
+		Values:   f.Values + other.Values,
+		Overhead: f.Overhead + other.Overhead,
+		History:  f.History + other.History,
+	}
+}
+
+/*Total is every component together*/
+func (f Footprint) Total() int64 {
+	return f.Keys + f.Values + f.Overhead + f.History
+}
+
+/*mapSlot is roughly what a map entry of a string key and a
+value of that size takes, buckets run about 80% full*/
+func mapSlot(valueSize uintptr) int64 {
+	return int64(unsafe.Sizeof("")+valueSize+1) * 5 / 4
+}
+
+/*policyOverhead is the bookkeeping per entry of the policy at
+the bottom of the chain, and of each key in its history*/
+func policyOverhead(c Cache) (int64, int64) {
+	for c != nil {
+		wrapper, ok := c.(unwrapper)
+		if !ok {
+			break
+		}
+		c = wrapper.Unwrap()
+	}
+	pointer := unsafe.Sizeof(&Entry{})
+	if _, ok := c.(*FiFo); ok {
+		return int64(unsafe.Sizeof(indexNode{})) + mapSlot(4), 0
+	} else if _, ok := c.(*Lru); ok {
+		return int64(unsafe.Sizeof(indexNode{})) + mapSlot(4), 0
+	} else if _, ok := c.(*Lfu); ok {
+		return int64(unsafe.Sizeof(lfuNode{})) + mapSlot(pointer), 0
+	} else if _, ok := c.(*Lcr); ok {
+		// plus the heap's slot
+		return int64(unsafe.Sizeof(lcrNode{})+pointer) + mapSlot(pointer), 0
+	} else if _, ok := c.(*LcrTtl); ok {
+		return int64(unsafe.Sizeof(lcrNode{})) + mapSlot(pointer), 0
+	} else if _, ok := c.(*Lecar); ok {
+		nodes := unsafe.Sizeof(lecarLookupNode{}) + unsafe.Sizeof(lecarLruNode{}) + unsafe.Sizeof(lecarLfuNode{})
+		return int64(nodes) + mapSlot(pointer), int64(unsafe.Sizeof(lecarHistoryNode{})) + mapSlot(pointer)
+	} else if _, ok := c.(*Calecar); ok {
+		nodes := unsafe.Sizeof(calecarLookupNode{}) + unsafe.Sizeof(calecarLruNode{}) + unsafe.Sizeof(calecarLfuNode{}) + unsafe.Sizeof(calecarLcrNode{})
+		return int64(nodes) + mapSlot(pointer), int64(unsafe.Sizeof(calecarHistoryNode{})) + mapSlot(pointer)
+	}
+	// a registered policy, guess at an entry in a map
+	return int64(unsafe.Sizeof(Entry{})) + mapSlot(pointer), 0
+}
+
+/*historySize is how many keys the policy at the bottom of the
+chain remembers once its history is full, its size never
+changes so this reads it without the policy's lock*/
+func historySize(c Cache) int {
+	for c != nil {
+		wrapper, ok := c.(unwrapper)
+		if !ok {
+			break
+		}
+		c = wrapper.Unwrap()
+	}
+	if l, ok := c.(*Lecar); ok && l.maxSize != unboundedSize {
+		return l.maxSize
+	} else if l, ok := c.(*Calecar); ok && l.maxSize != unboundedSize {
+		return l.maxSize
+	}
+	return 0
+}
+
+/*MemoryUsage estimates the cache's footprint from an Export,
+so it takes whatever locks that does and costs a pass over
+every entry.  sizer counts a value's bytes, must not count the
+key, and can be nil to count the value's length.  Sharded and
+Namespaced caches are added up from their parts*/
+func MemoryUsage(c Cache, sizer Sizer) Footprint {
+	f := Footprint{}
+	if sharded, ok := c.(*Sharded); ok {
+		for _, shard := range sharded.shards {
+			f = f.add(MemoryUsage(shard, sizer))
+		}
+		return f
+	} else if namespaced, ok := c.(*Namespaced); ok {
+		for _, space := range namespaced.all() {
+			f = f.add(MemoryUsage(space.Batched, sizer))
+		}
+		return f
+	}
+	exporter, ok := c.(Exporter)
+	if !ok {
+		return f
+	}
+	for _, record := range exporter.Export() {
+		f.Entries++
+		f.Keys += int64(len(record.Key))
+		if sizer != nil {
+			f.Values += sizer.Size(record.Key, record.Entry)
+		} else {
+			f.Values += int64(len(record.Entry.value))
+		}
+	}
+	perEntry, perHistory := policyOverhead(c)
+	f.Overhead = int64(f.Entries) * perEntry
+	if history := historySize(c); history > 0 {
+		averageKey := int64(0)
+		if f.Entries > 0 {
+			averageKey = f.Keys / int64(f.Entries)
+		}
+		f.History = int64(history) * (perHistory + averageKey)
+	}
+	return f
+}
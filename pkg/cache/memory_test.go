@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestMemoryUsageByComponent(t *testing.T) {
+	for _, policy := range allPolicies {
+		c, _ := NewCache(policy, 100)
+		for i := 0; i < 10; i++ {
+			c.SetValue("key"+strconv.Itoa(i), NewEntry("value", 1))
+		}
+		f := MemoryUsage(NewBatched(c), nil)
+		if f.Entries != 10 || f.Keys != 40 || f.Values != 50 {
+			t.Fatalf("%s: unexpected data %+v", policy, f)
+		}
+		if f.Overhead <= 0 || f.Total() <= f.Keys+f.Values {
+			t.Fatalf("%s: expected bookkeeping counted, got %+v", policy, f)
+		}
+		if (policy == LECAR || policy == CALECAR) != (f.History > 0) {
+			t.Fatalf("%s: history only belongs to the adaptive policies, got %+v", policy, f)
+		}
+	}
+}
+
+func TestMemoryUsageAddsUpShards(t *testing.T) {
+	c, _ := NewFromConfig(Config{Type: LRU, Size: 100, Shards: 4})
+	for i := 0; i < 20; i++ {
+		c.SetValue("key"+strconv.Itoa(i), NewEntry("v", 1))
+	}
+	f := MemoryUsage(c, SizerFunc(func(key string, entry Entry) int64 { return 10 }))
+	if f.Entries != 20 || f.Values != 200 {
+		t.Fatalf("expected every shard counted, got %+v", f)
+	}
+}
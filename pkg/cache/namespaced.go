@@ -0,0 +1,381 @@
+package cache
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*Namespaced is one cache object over several policies: keys
+are routed by namespace (as named by the namespace func,
+ByNamespace usually) to that namespace's own cache, so
+sessions can live in an LRU and rendered reports in an LCR
+behind the same facade.  Keys in a namespace that has no
+cache go to the fallback.  Like Sharded each cache is made a
+Batched, so the whole thing is safe for concurrent use, and
+removal listeners are called from whichever cache the key
+lives in.
+
+Built with NewNamespacePool the namespaces also share a pool
+of entries: each still has its own size, but together they
+can't hold more than the pool, and once it's full a write
+into the pool has to make room first, from the namespace its
+Arbitration picks.  Writes take the pool's lock so it can't
+be overfilled*/
+type Namespaced struct {
+	mu          sync.RWMutex
+	namespace   func(key string) string
+	spaces      map[string]*namespaceCache
+	fallback    *namespaceCache
+	listeners   []RemovalListener
+	pool        int64
+	poolMu      sync.Mutex
+	resident    int64
+	arbitration Arbitration
+	priorities  map[string]int
+}
+
+/*Arbitration says which namespace gives up an entry when a
+pool is full*/
+type Arbitration int
+
+const (
+	/*OwnNamespace makes the writer's namespace make room, or
+	the biggest one if the writer's is empty*/
+	OwnNamespace Arbitration = iota
+	/*Proportional takes from the namespace furthest over its
+	share of the pool, the shares in proportion to the
+	namespaces' sizes*/
+	Proportional
+	/*ByPriority takes from the least important namespace with
+	anything in it, in the order given to SetArbitration (most
+	important first, unlisted namespaces least of all)*/
+	ByPriority
+	/*CheapestVictim looks at every namespace's next victim and
+	evicts the one that costs least to recompute, an Export of
+	every namespace for each entry it evicts*/
+	CheapestVictim
+)
+
+/*namespaceCache is one namespace's cache and what the pool
+needs to know about it*/
+type namespaceCache struct {
+	*Batched
+	name     string
+	size     int
+	resident int64
+}
+
+func (n *Namespaced) space(k string) *namespaceCache {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	space, ok := n.spaces[n.namespace(k)]
+	if !ok {
+		return n.fallback
+	}
+	return space
+}
+
+func (n *Namespaced) all() []*namespaceCache {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	all := []*namespaceCache{n.fallback}
+	for _, space := range n.spaces {
+		all = append(all, space)
+	}
+	return all
+}
+
+/*KeyPresent checks the key's namespace*/
+func (n *Namespaced) KeyPresent(k string) bool {
+	return n.space(k).KeyPresent(k)
+}
+
+/*GetValue reads from the key's namespace*/
+func (n *Namespaced) GetValue(k string) (Entry, error) {
+	return n.space(k).GetValue(k)
+}
+
+/*SetValue writes to the key's namespace, making room in the
+pool first if there is one and it's full*/
+func (n *Namespaced) SetValue(k string, v Entry) error {
+	if n.pool == 0 {
+		return n.space(k).SetValue(k, v)
+	}
+	n.poolMu.Lock()
+	defer n.poolMu.Unlock()
+	space := n.space(k)
+	if atomic.LoadInt64(&n.resident) >= n.pool && !space.KeyPresent(k) {
+		if !n.makeRoom(space) {
+			return ErrCacheFull
+		}
+	}
+	err := space.SetValue(k, v)
+	if err == nil && space.KeyPresent(k) {
+		n.added(space)
+	}
+	return err
+}
+
+/*makeRoom evicts one entry from the namespace the
+arbitration picks*/
+func (n *Namespaced) makeRoom(writer *namespaceCache) bool {
+	var victim *namespaceCache
+	var record Record
+	if n.arbitration == CheapestVictim {
+		for _, space := range n.all() {
+			next, ok := space.nextVictim()
+			if ok && (victim == nil || next.Entry.cost < record.Entry.cost) {
+				victim, record = space, next
+			}
+		}
+	} else {
+		victim = n.arbitrate(writer)
+		if victim == nil {
+			return false
+		}
+		var ok bool
+		record, ok = victim.nextVictim()
+		if !ok {
+			return false
+		}
+	}
+	return victim != nil && victim.evict(record.Key) == nil
+}
+
+/*arbitrate picks the namespace to take from, out of those
+holding anything*/
+func (n *Namespaced) arbitrate(writer *namespaceCache) *namespaceCache {
+	if n.arbitration == OwnNamespace && atomic.LoadInt64(&writer.resident) > 0 {
+		return writer
+	}
+	var picked *namespaceCache
+	for _, space := range n.all() {
+		if atomic.LoadInt64(&space.resident) == 0 {
+			continue
+		}
+		if picked == nil || n.before(space, picked) {
+			picked = space
+		}
+	}
+	return picked
+}
+
+/*before is true if a should give up an entry ahead of b*/
+func (n *Namespaced) before(a *namespaceCache, b *namespaceCache) bool {
+	if n.arbitration == Proportional {
+		// over share is resident/(pool*size/total), the pool and total are the same for both
+		return float64(atomic.LoadInt64(&a.resident))*float64(n.weight(b)) > float64(atomic.LoadInt64(&b.resident))*float64(n.weight(a))
+	} else if n.arbitration == ByPriority {
+		return n.rank(a) > n.rank(b)
+	}
+	// the biggest goes first
+	return atomic.LoadInt64(&a.resident) > atomic.LoadInt64(&b.resident)
+}
+
+/*weight is a namespace's size, an unbounded one (or one with
+no config) counts as the whole pool*/
+func (n *Namespaced) weight(space *namespaceCache) int64 {
+	if space.size <= 0 || int64(space.size) > n.pool {
+		return n.pool
+	}
+	return int64(space.size)
+}
+
+func (n *Namespaced) rank(space *namespaceCache) int {
+	rank, ok := n.priorities[space.name]
+	if !ok {
+		return len(n.priorities)
+	}
+	return rank
+}
+
+/*SetArbitration changes how a full pool picks the namespace
+to make room in, priorities are the namespaces most important
+first for ByPriority*/
+func (n *Namespaced) SetArbitration(a Arbitration, priorities ...string) {
+	n.poolMu.Lock()
+	defer n.poolMu.Unlock()
+	n.arbitration = a
+	n.priorities = make(map[string]int)
+	for i, name := range priorities {
+		n.priorities[name] = i
+	}
+}
+
+/*Delete removes the key from its namespace*/
+func (n *Namespaced) Delete(k string) error {
+	return n.space(k).Delete(k)
+}
+
+/*OnRemoval adds the listener to every namespace, including
+ones created later*/
+func (n *Namespaced) OnRemoval(fn RemovalListener) {
+	n.mu.Lock()
+	n.listeners = append(n.listeners, fn)
+	n.mu.Unlock()
+	for _, space := range n.all() {
+		AddRemovalListener(space, fn)
+	}
+}
+
+/*Namespaces lists the namespaces, in order*/
+func (n *Namespaced) Namespaces() []string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	names := []string{}
+	for name := range n.spaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*CreateNamespace adds a namespace with a cache built from
+the config.  Keys in it that the fallback was holding stay
+there out of reach until they're evicted*/
+func (n *Namespaced) CreateNamespace(name string, cfg Config) error {
+	c, err := NewFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, exists := n.spaces[name]; exists {
+		return errors.New("Namespace " + name + " already exists")
+	}
+	size := cfg.Size
+	if cfg.Unbounded {
+		size = 0
+	}
+	n.spaces[name] = n.adopt(name, c, size)
+	return nil
+}
+
+/*DropNamespace removes the namespace and every entry in it,
+returning how many it held.  The namespace is gone the
+moment it is taken out (its keys go to the fallback from
+then on), its entries are deleted after, so removal
+listeners hear about each and a pool gets the room back*/
+func (n *Namespaced) DropNamespace(name string) (int, error) {
+	n.mu.Lock()
+	space, ok := n.spaces[name]
+	delete(n.spaces, name)
+	n.mu.Unlock()
+	if !ok {
+		return 0, errors.New("No namespace " + name)
+	}
+	records := space.Export()
+	for _, record := range records {
+		space.Delete(record.Key)
+	}
+	return len(records), nil
+}
+
+/*adopt wraps a namespace's cache and hooks it up to the
+listeners and the pool, the lock must be held*/
+func (n *Namespaced) adopt(name string, c Cache, size int) *namespaceCache {
+	space := &namespaceCache{Batched: NewBatched(c), name: name, size: size}
+	if n.pool > 0 {
+		AddRemovalListener(space.Batched, func(key string, entry Entry, reason RemovalReason) {
+			atomic.AddInt64(&space.resident, -1)
+			atomic.AddInt64(&n.resident, -1)
+		})
+	}
+	for _, fn := range n.listeners {
+		AddRemovalListener(space.Batched, fn)
+	}
+	return space
+}
+
+func (n *Namespaced) added(space *namespaceCache) {
+	atomic.AddInt64(&space.resident, 1)
+	atomic.AddInt64(&n.resident, 1)
+}
+
+/*Export lists every namespace's entries, namespace by
+namespace*/
+func (n *Namespaced) Export() []Record {
+	records := []Record{}
+	for _, space := range n.all() {
+		records = append(records, space.Export()...)
+	}
+	return records
+}
+
+/*Import puts the record into its key's namespace, counting
+it against the pool without making room (a snapshot is
+trusted to have fit)*/
+func (n *Namespaced) Import(r Record) error {
+	space := n.space(r.Key)
+	err := space.Import(r)
+	if err == nil && n.pool > 0 && space.KeyPresent(r.Key) {
+		n.added(space)
+	}
+	return err
+}
+
+/*SweepExpired splits the limit between the namespaces*/
+func (n *Namespaced) SweepExpired(limit int) int {
+	all := n.all()
+	perSpace := limit / len(all)
+	if perSpace < 1 {
+		perSpace = 1
+	}
+	dropped := 0
+	for _, space := range all {
+		dropped += space.SweepExpired(perSpace)
+	}
+	return dropped
+}
+
+func (n *Namespaced) startWheel(tick time.Duration) bool {
+	started := true
+	for _, space := range n.all() {
+		started = space.startWheel(tick) && started
+	}
+	return started
+}
+
+func (n *Namespaced) sweepDue() int {
+	dropped := 0
+	for _, space := range n.all() {
+		dropped += space.sweepDue()
+	}
+	return dropped
+}
+
+func (n *Namespaced) retime(k string, ttl time.Duration, shorten bool) error {
+	return n.space(k).retime(k, ttl, shorten)
+}
+
+/*NewNamespaced routes each namespace to its cache and the
+rest to the fallback, which can be nil to cache nothing
+outside the namespaces*/
+func NewNamespaced(namespace func(key string) string, spaces map[string]Cache, fallback Cache) *Namespaced {
+	if fallback == nil {
+		fallback = &NoOp{}
+	}
+	n := &Namespaced{namespace: namespace, spaces: make(map[string]*namespaceCache)}
+	n.fallback = n.adopt("", fallback, 0)
+	for name, c := range spaces {
+		n.spaces[name] = n.adopt(name, c, 0)
+	}
+	return n
+}
+
+/*NewNamespacePool starts a pool of that many entries with no
+namespaces in it yet, add them with CreateNamespace.  Keys
+outside every namespace aren't cached.  A full pool makes
+room in the writer's namespace until SetArbitration says
+otherwise*/
+func NewNamespacePool(namespace func(key string) string, capacity int) *Namespaced {
+	n := NewNamespaced(namespace, nil, nil)
+	n.pool = int64(capacity)
+	if n.pool < 1 {
+		n.pool = 1
+	}
+	return n
+}
@@ -0,0 +1,103 @@
+package cache
+
+import "testing"
+
+func TestNamespacesKeepTheirOwnPolicies(t *testing.T) {
+	sessions, _ := NewCache(LRU, 2)
+	reports, _ := NewCache(LCR, 2)
+	c := NewNamespaced(ByNamespace(":"), map[string]Cache{"session": sessions, "report": reports}, nil)
+	// the same writes to both, Batched may not have applied hits yet so there are none
+	for _, ns := range []string{"session:", "report:"} {
+		c.SetValue(ns+"a", NewEntry("a", 100))
+		c.SetValue(ns+"b", NewEntry("b", 1))
+		c.SetValue(ns+"c", NewEntry("c", 50))
+	}
+	if c.KeyPresent("session:a") || !c.KeyPresent("session:b") {
+		t.Fatalf("expected sessions to evict the least recently used")
+	}
+	if c.KeyPresent("report:b") || !c.KeyPresent("report:a") {
+		t.Fatalf("expected reports to evict the cheapest")
+	}
+	if c.SetValue("other:a", NewEntry("a", 1)); c.KeyPresent("other:a") {
+		t.Fatalf("cached a key outside every namespace without a fallback")
+	}
+	if len(c.Export()) != 4 {
+		t.Fatalf("expected both namespaces exported, got %v", c.Export())
+	}
+}
+
+func TestNamespaceLifecycle(t *testing.T) {
+	c := NewNamespacePool(ByNamespace(":"), 4)
+	if err := c.CreateNamespace("session", Config{Type: LRU, Size: 3}); err != nil {
+		t.Fatalf("couldn't create a namespace: %v", err)
+	}
+	c.CreateNamespace("report", Config{Type: LCR, Size: 3})
+	if err := c.CreateNamespace("report", Config{Type: LRU, Size: 1}); err == nil {
+		t.Fatalf("created a namespace twice")
+	}
+	if err := c.CreateNamespace("bad", Config{Type: LRU}); err == nil {
+		t.Fatalf("created a namespace from a bad config")
+	}
+	if names := c.Namespaces(); len(names) != 2 || names[0] != "report" || names[1] != "session" {
+		t.Fatalf("unexpected namespaces %v", names)
+	}
+	c.SetValue("session:a", NewEntry("a", 1))
+	c.SetValue("session:b", NewEntry("b", 1))
+	c.SetValue("report:a", NewEntry("a", 1))
+	c.SetValue("report:b", NewEntry("b", 1))
+	// the pool is full, so the writer's namespace gives up its next victim
+	c.SetValue("report:c", NewEntry("c", 5))
+	if c.KeyPresent("report:a") || !c.KeyPresent("session:a") || len(c.Export()) != 4 {
+		t.Fatalf("expected report to make its own room, have %v", c.Export())
+	}
+	dropped, err := c.DropNamespace("report")
+	if err != nil || dropped != 2 || c.KeyPresent("report:c") {
+		t.Fatalf("expected report's 2 entries dropped, got %d %v", dropped, err)
+	}
+	if _, err := c.DropNamespace("report"); err == nil {
+		t.Fatalf("dropped a namespace that's gone")
+	}
+	// the dropped entries are back in the pool for the rest
+	c.SetValue("session:c", NewEntry("c", 1))
+	if !c.KeyPresent("session:a") || !c.KeyPresent("session:c") || c.resident != 3 {
+		t.Fatalf("expected session to use the freed room, resident %d", c.resident)
+	}
+}
+
+func TestPoolArbitration(t *testing.T) {
+	fill := func(a Arbitration, priorities ...string) *Namespaced {
+		c := NewNamespacePool(ByNamespace(":"), 4)
+		c.SetArbitration(a, priorities...)
+		c.CreateNamespace("big", Config{Type: LRU, Size: 4})
+		c.CreateNamespace("small", Config{Type: LRU, Size: 2})
+		c.SetValue("big:a", NewEntry("a", 10))
+		c.SetValue("small:a", NewEntry("a", 1))
+		c.SetValue("big:b", NewEntry("b", 10))
+		c.SetValue("big:c", NewEntry("c", 10))
+		return c
+	}
+	c := fill(OwnNamespace)
+	c.SetValue("small:b", NewEntry("b", 1))
+	if c.KeyPresent("small:a") || !c.KeyPresent("big:a") {
+		t.Fatalf("expected the writer's namespace to make room")
+	}
+	// small holds 1 of its 2, big 3 of its 4
+	c = fill(Proportional)
+	c.SetValue("small:b", NewEntry("b", 1))
+	if c.KeyPresent("big:a") || !c.KeyPresent("small:a") {
+		t.Fatalf("expected the namespace furthest over its share to make room")
+	}
+	c = fill(ByPriority, "small", "big")
+	c.SetValue("small:b", NewEntry("b", 1))
+	if c.KeyPresent("big:a") || !c.KeyPresent("small:a") {
+		t.Fatalf("expected the less important namespace to make room")
+	}
+	c = fill(CheapestVictim)
+	c.SetValue("big:d", NewEntry("d", 10))
+	if c.KeyPresent("small:a") || !c.KeyPresent("big:a") {
+		t.Fatalf("expected the cheapest next victim to go")
+	}
+	if len(c.Export()) != 4 {
+		t.Fatalf("overfilled the pool: %v", c.Export())
+	}
+}
@@ -0,0 +1,83 @@
+package cache
+
+import "time"
+
+/*NamespaceTTL is the default TTL and idle TTL for a
+namespace's entries set without any of their own*/
+type NamespaceTTL struct {
+	TTL     time.Duration
+	IdleTTL time.Duration
+}
+
+/*NamespaceTTLs gives the namespaces (as named by the
+namespace func, ByNamespace usually) sharing one cache their
+own default TTLs, so sessions can slide on an idle TTL while
+reports expire an hour after they're rendered.  Entries
+stamped here carry the deadline, a default the policy has
+only applies to namespaces without one.  The defaults are
+fixed when it's built, so it adds no locking*/
+type NamespaceTTLs struct {
+	cache     Cache
+	namespace func(key string) string
+	defaults  map[string]NamespaceTTL
+}
+
+/*KeyPresent is true if the key is cached right now*/
+func (n *NamespaceTTLs) KeyPresent(k string) bool {
+	return n.cache.KeyPresent(k)
+}
+
+/*GetValue reads from the wrapped cache*/
+func (n *NamespaceTTLs) GetValue(k string) (Entry, error) {
+	return n.cache.GetValue(k)
+}
+
+/*SetValue gives an entry without a TTL its namespace's
+defaults and writes it*/
+func (n *NamespaceTTLs) SetValue(k string, v Entry) error {
+	defaults, ok := n.defaults[n.namespace(k)]
+	if ok && v.expires == 0 && v.idle == 0 {
+		if defaults.TTL > 0 {
+			v = v.WithTTL(defaults.TTL)
+		}
+		if defaults.IdleTTL > 0 {
+			v = v.WithIdleTTL(defaults.IdleTTL)
+		}
+	}
+	return n.cache.SetValue(k, v)
+}
+
+/*Delete removes the key from the wrapped cache*/
+func (n *NamespaceTTLs) Delete(k string) error {
+	return n.cache.Delete(k)
+}
+
+/*Export lists the wrapped cache's entries, if it can*/
+func (n *NamespaceTTLs) Export() []Record {
+	exporter, ok := n.cache.(Exporter)
+	if !ok {
+		return []Record{}
+	}
+	return exporter.Export()
+}
+
+/*Import puts the record into the wrapped cache as it is, it
+already carries its deadlines*/
+func (n *NamespaceTTLs) Import(r Record) error {
+	return ImportRecord(n.cache, r)
+}
+
+/*Unwrap is the cache being shared*/
+func (n *NamespaceTTLs) Unwrap() Cache {
+	return n.cache
+}
+
+/*NewNamespaceTTLs stamps each namespace's entries with its
+defaults*/
+func NewNamespaceTTLs(c Cache, namespace func(key string) string, defaults map[string]NamespaceTTL) *NamespaceTTLs {
+	n := &NamespaceTTLs{cache: c, namespace: namespace, defaults: make(map[string]NamespaceTTL)}
+	for ns, ttls := range defaults {
+		n.defaults[ns] = ttls
+	}
+	return n
+}
@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNamespacesHaveTheirOwnTTLs(t *testing.T) {
+	clock := useManualClock(t)
+	lru, _ := NewCache(LRU, 10, WithDefaultTTL(time.Hour))
+	c := NewNamespaceTTLs(lru, ByNamespace(":"), map[string]NamespaceTTL{
+		"session": {IdleTTL: 10 * time.Minute},
+		"report":  {TTL: 5 * time.Minute},
+	})
+	c.SetValue("session:a", NewEntry("a", 1))
+	c.SetValue("report:a", NewEntry("a", 1))
+	c.SetValue("report:b", NewEntry("b", 1).WithTTL(20*time.Minute))
+	c.SetValue("other:a", NewEntry("a", 1))
+	clock.Advance(6 * time.Minute)
+	c.GetValue("session:a")
+	if c.KeyPresent("report:a") || !c.KeyPresent("report:b") {
+		t.Fatalf("expected reports to expire after five minutes unless set otherwise")
+	}
+	clock.Advance(6 * time.Minute)
+	if !c.KeyPresent("session:a") {
+		t.Fatalf("expected the session's idle deadline to slide")
+	}
+	clock.Advance(50 * time.Minute)
+	if c.KeyPresent("other:a") || c.KeyPresent("session:a") {
+		t.Fatalf("expected the policy's default for namespaces without one")
+	}
+}
@@ -0,0 +1,66 @@
+package cache
+
+import "time"
+
+/*cacheOptions collects what the Options passed to NewCache
+ask for*/
+type cacheOptions struct {
+	ttl       time.Duration
+	idle      time.Duration
+	unbounded bool
+	clock     Clock
+}
+
+/*Option adjusts a cache built by NewCache*/
+type Option func(*cacheOptions)
+
+/*WithDefaultTTL expires every entry set without a TTL of its
+own ttl after it's set.  Entries with a TTL (or idle TTL)
+keep theirs*/
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(o *cacheOptions) { o.ttl = ttl }
+}
+
+/*WithDefaultIdleTTL gives every entry set without a TTL of
+its own a sliding idle deadline, like Entry.WithIdleTTL*/
+func WithDefaultIdleTTL(idle time.Duration) Option {
+	return func(o *cacheOptions) { o.idle = idle }
+}
+
+/*Unbounded turns eviction off, the cache holds everything
+it's given whatever size it was built with.  Meant for caches
+whose entries all expire (so a janitor bounds them), or that
+hold a set of keys known to fit*/
+func Unbounded() Option {
+	return func(o *cacheOptions) { o.unbounded = true }
+}
+
+/*WithClock gives the cache a clock of its own for its TTLs,
+its timing wheel and the janitors and loaders wrapped around
+it, instead of the one SetClock sets for the whole process.
+Entries given a TTL before they're set are moved onto it as
+they're set, keeping the time they had left*/
+func WithClock(c Clock) Option {
+	return func(o *cacheOptions) { o.clock = c }
+}
+
+/*defaultable is every policy with expiry embedded*/
+type defaultable interface {
+	setDefaults(o cacheOptions)
+}
+
+func collectOptions(opts []Option) cacheOptions {
+	o := cacheOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+/*applyOptions configures a freshly built policy*/
+func applyOptions(c Cache, o cacheOptions) {
+	policy, ok := c.(defaultable)
+	if ok {
+		policy.setDefaults(o)
+	}
+}
@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDefaultTTL(t *testing.T) {
+	for _, policy := range allPolicies {
+		c, _ := NewCache(policy, 4, WithDefaultTTL(20*time.Millisecond))
+		c.SetValue("default", NewEntry("1", 5))
+		c.SetValue("own", NewEntry("1", 5).WithTTL(time.Hour))
+		c.SetValue("idle", NewEntry("1", 5).WithIdleTTL(time.Hour))
+		time.Sleep(30 * time.Millisecond)
+		if c.KeyPresent("default") {
+			t.Fatalf("%s: the default ttl wasn't applied", policy)
+		}
+		if !c.KeyPresent("own") || !c.KeyPresent("idle") {
+			t.Fatalf("%s: entries with their own ttl should keep it", policy)
+		}
+		plain, _ := NewCache(policy, 4)
+		plain.SetValue("k", NewEntry("1", 5))
+		if entry, _ := plain.GetValue("k"); !entry.Expires().IsZero() {
+			t.Fatalf("%s: no option, no deadline", policy)
+		}
+	}
+}
+
+func TestDefaultIdleTTL(t *testing.T) {
+	c, _ := NewCache(LRU, 4, WithDefaultIdleTTL(50*time.Millisecond))
+	c.SetValue("read", NewEntry("1", 5))
+	c.SetValue("unread", NewEntry("1", 5))
+	for i := 0; i < 4; i++ {
+		time.Sleep(20 * time.Millisecond)
+		c.GetValue("read")
+	}
+	if !c.KeyPresent("read") || c.KeyPresent("unread") {
+		t.Fatal("the default idle ttl should slide on reads only")
+	}
+}
+
+func TestSizeZeroCachesNothing(t *testing.T) {
+	for _, policy := range allPolicies {
+		c, err := NewCache(policy, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", policy, err)
+		}
+		c.SetValue("a", NewEntry("1", 5))
+		if c.KeyPresent("a") {
+			t.Fatalf("%s: a size 0 cache kept an entry", policy)
+		}
+	}
+	if _, err := NewCache(LRU, -1); err == nil {
+		t.Fatalf("built a cache of negative size")
+	}
+	if _, err := NewCache(CacheType(99), 0); err == nil {
+		t.Fatalf("size 0 hid an unknown cache type")
+	}
+}
+
+func TestSizeOneHoldsTheLatest(t *testing.T) {
+	for _, policy := range append([]CacheType{TIERED}, allPolicies...) {
+		c, err := NewCache(policy, 1)
+		if err != nil {
+			t.Fatalf("%s: %v", policy, err)
+		}
+		for i := 0; i < 50; i++ {
+			k := strconv.Itoa(i % 3)
+			c.SetValue(k, NewEntry("v", i%4))
+			c.GetValue(strconv.Itoa(i % 2))
+			if !c.KeyPresent(k) {
+				t.Fatalf("%s: a size 1 cache lost the entry just set", policy)
+			}
+			if i%7 == 0 {
+				c.Delete(k)
+			}
+		}
+		if err = CheckInvariants(c); err != nil {
+			t.Fatalf("%s: %v", policy, err)
+		}
+	}
+	c, _ := NewCache(NONE, 1)
+	c.SetValue("a", NewEntry("1", 5))
+	if c.KeyPresent("a") {
+		t.Fatal("NONE kept an entry")
+	}
+}
+
+func TestUnboundedNeverEvicts(t *testing.T) {
+	for _, policy := range allPolicies {
+		c, _ := NewCache(policy, 2, Unbounded(), WithDefaultTTL(20*time.Millisecond))
+		evictions := 0
+		AddRemovalListener(c, func(key string, entry Entry, reason RemovalReason) {
+			if reason == Evicted {
+				evictions++
+			}
+		})
+		for i := 0; i < 500; i++ {
+			c.SetValue("k"+strconv.Itoa(i), NewEntry("v", i%7))
+			c.GetValue("k" + strconv.Itoa(i/2))
+		}
+		if evictions != 0 || len(c.(Exporter).Export()) != 500 {
+			t.Fatalf("%s: evicted %d, holding %d", policy, evictions, len(c.(Exporter).Export()))
+		}
+		time.Sleep(30 * time.Millisecond)
+		if dropped := c.(Sweeper).SweepExpired(1000); dropped != 500 {
+			t.Fatalf("%s: expected the ttl to clear everything, dropped %d", policy, dropped)
+		}
+	}
+}
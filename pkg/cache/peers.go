@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*Transport is how one node delivers a protocol
+message to another node.  The default speaks the same
+tcp protocol the server listens on, but anything that can
+get a message to a peer (udp, a message bus, etc) will do*/
+type Transport interface {
+	Send(peer string, message string) (string, error)
+}
+
+/*TCPTransport dials the peer for every message, writes it,
+and reads the reply until the peer closes the connection*/
+type TCPTransport struct {
+	Timeout time.Duration
+}
+
+/*Send delivers one message and returns the raw reply*/
+func (t *TCPTransport) Send(peer string, message string) (string, error) {
+	conn, err := net.DialTimeout("tcp", peer, t.Timeout)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(t.Timeout))
+	_, err = conn.Write([]byte(message))
+	if err != nil {
+		return "", err
+	}
+	reply := ""
+	connBuff := bufio.NewReader(conn)
+	for {
+		line, err := connBuff.ReadString('\n')
+		reply = reply + line
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return reply, err
+		}
+	}
+	return reply, nil
+}
+
+func defaultTransport() Transport {
+	return &TCPTransport{Timeout: 2 * time.Second}
+}
+
+/*Broadcaster fans invalidation messages out to every
+known peer so multi-node deployments drop stale entries
+when one of them takes a write*/
+type Broadcaster struct {
+	peers     []string
+	transport Transport
+	logger    *log.Logger
+}
+
+/*Invalidate tells every peer to drop the key, waiting
+until each one has answered (or failed)*/
+func (b *Broadcaster) Invalidate(key string) error {
+	failures := 0
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, peer := range b.peers {
+		wg.Add(1)
+		go func(peer string) {
+			defer wg.Done()
+			_, err := b.transport.Send(peer, "invalidate,"+key)
+			if err != nil {
+				b.logger.Println("WARNING: failed to invalidate ", key, " on ", peer, ": ", err)
+				mu.Lock()
+				failures++
+				mu.Unlock()
+			}
+		}(peer)
+	}
+	wg.Wait()
+	if failures > 0 {
+		return errors.New("Invalidation did not reach every peer")
+	}
+	return nil
+}
+
+/*NewBroadcaster builds a broadcaster for the given peer
+addresses (host:port).  A nil transport means plain tcp*/
+func NewBroadcaster(peers []string, transport Transport, logger *log.Logger) *Broadcaster {
+	if transport == nil {
+		transport = defaultTransport()
+	}
+	return &Broadcaster{peers: peers, transport: transport, logger: logger}
+}
+
+/*ParsePeers splits a comma separated flag value
+into a list of peer addresses, dropping blanks*/
+func ParsePeers(peerList string) []string {
+	peers := []string{}
+	for _, peer := range strings.Split(peerList, ",") {
+		peer = strings.TrimSpace(peer)
+		if peer != "" {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
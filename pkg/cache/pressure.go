@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+/*PressureSignal is how many bytes of memory are in use*/
+type PressureSignal func() uint64
+
+/*HeapInUse is the Go heap in use from runtime.MemStats.
+Reading it briefly stops the world, which is why the
+controller only checks every so often.  A container would do
+better to read its cgroup's usage*/
+func HeapInUse() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapInuse
+}
+
+/*PressureController shrinks a ByteBounded cache's budget
+while memory is short and grows it back once it isn't.
+Every interval it reads the signal: above high the budget
+loses a quarter (never going under a tenth of where it
+started), below low it grows back a quarter at a time up to
+where it started, and in between it's left alone so it
+doesn't flap*/
+type PressureController struct {
+	cache    *ByteBounded
+	signal   PressureSignal
+	high     uint64
+	low      uint64
+	full     int64
+	interval time.Duration
+	clock    Clock
+	shrinks  int64
+	stop     chan bool
+}
+
+/*CheckNow reads the signal once and adjusts the budget,
+returning the budget it left*/
+func (p *PressureController) CheckNow() int64 {
+	budget := p.cache.Budget()
+	usage := p.signal()
+	if usage > p.high {
+		shrunk := budget - budget/4
+		if floor := p.full / 10; shrunk < floor {
+			shrunk = floor
+		}
+		if shrunk < budget {
+			p.cache.SetBudget(shrunk)
+			atomic.AddInt64(&p.shrinks, 1)
+			return shrunk
+		}
+	} else if usage < p.low && budget < p.full {
+		grown := budget + p.full/4
+		if grown > p.full {
+			grown = p.full
+		}
+		p.cache.SetBudget(grown)
+		return grown
+	}
+	return budget
+}
+
+/*Shrinks is how many times pressure has shrunk the budget*/
+func (p *PressureController) Shrinks() int64 {
+	return atomic.LoadInt64(&p.shrinks)
+}
+
+func (p *PressureController) run() {
+	for {
+		select {
+		case <-p.clock.After(p.interval):
+			p.CheckNow()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+/*Close stops watching and puts the budget back where it
+started*/
+func (p *PressureController) Close() {
+	close(p.stop)
+	p.cache.SetBudget(p.full)
+}
+
+/*NewPressureController starts watching the signal (HeapInUse
+if nil) every interval, high and low in the signal's bytes*/
+func NewPressureController(c *ByteBounded, signal PressureSignal, high uint64, low uint64, interval time.Duration) *PressureController {
+	if signal == nil {
+		signal = HeapInUse
+	}
+	p := &PressureController{
+		cache:    c,
+		signal:   signal,
+		high:     high,
+		low:      low,
+		full:     c.Budget(),
+		interval: interval,
+		clock:    clockOf(c),
+		stop:     make(chan bool),
+	}
+	go p.run()
+	return p
+}
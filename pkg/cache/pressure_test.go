@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPressureShrinksAndRestoresTheBudget(t *testing.T) {
+	lru, _ := NewCache(LRU, 0, Unbounded())
+	c := NewByteBounded(lru, 1000, nil)
+	for i := 0; i < 10; i++ {
+		c.SetValue("k"+strconv.Itoa(i), NewEntry(strings.Repeat("x", 98), 1))
+	}
+	var usage uint64 = 900
+	p := NewPressureController(c, func() uint64 { return atomic.LoadUint64(&usage) }, 800, 500, time.Hour)
+	defer p.Close()
+	if budget := p.CheckNow(); budget != 750 || c.Used() > 750 || c.KeyPresent("k0") {
+		t.Fatalf("expected a quarter shrunk under pressure, budget %d using %d", budget, c.Used())
+	}
+	for i := 0; i < 20; i++ {
+		p.CheckNow()
+	}
+	if c.Budget() != 100 || p.Shrinks() == 0 {
+		t.Fatalf("expected the budget to stop at a tenth, got %d", c.Budget())
+	}
+	atomic.StoreUint64(&usage, 600)
+	if p.CheckNow() != 100 {
+		t.Fatalf("grew between the thresholds")
+	}
+	atomic.StoreUint64(&usage, 100)
+	p.CheckNow()
+	if budget := p.CheckNow(); budget != 600 {
+		t.Fatalf("expected the budget to grow back a quarter at a time, got %d", budget)
+	}
+	for i := 0; i < 5; i++ {
+		p.CheckNow()
+	}
+	if c.Budget() != 1000 {
+		t.Fatalf("expected the budget back where it started, got %d", c.Budget())
+	}
+}
@@ -0,0 +1,160 @@
+package cache
+
+import "fmt"
+
+/*Quotas wraps a cache shared by several tenants and gives
+each namespace (as named by the namespace func, ByNamespace
+usually) a budget of bytes, the key and value lengths of its
+entries.  A write that takes a tenant over its budget is made
+room for by dropping that tenant's own entries, in the order
+the policy would evict them, so a noisy tenant pushes out its
+own entries before the policy gets to anyone else's.  That
+order comes from an Export of the cache, a scan each time a
+tenant is over.  Namespaces without a budget are only bounded
+by the cache.  Like Indexed it holds its lock around every
+call, and the wrapped cache has to report its removals*/
+type Quotas struct {
+	lockedInner
+	namespace func(key string) string
+	budgets   map[string]int64
+	used      map[string]int64
+}
+
+func entryBytes(k string, v Entry) int64 {
+	return int64(len(k) + len(v.value))
+}
+
+/*KeyPresent is true if the key is cached right now*/
+func (q *Quotas) KeyPresent(k string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.cache.KeyPresent(k)
+}
+
+/*GetValue reads from the wrapped cache*/
+func (q *Quotas) GetValue(k string) (Entry, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.cache.GetValue(k)
+}
+
+/*SetValue writes the entry and then brings its tenant back
+under budget.  An entry bigger than the whole budget is
+refused with ErrEntryTooLarge*/
+func (q *Quotas) SetValue(k string, v Entry) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	ns := q.namespace(k)
+	budget, limited := q.budgets[ns]
+	if limited && entryBytes(k, v) > budget {
+		return fmt.Errorf("%w, %s is bigger than namespace %s's budget", ErrEntryTooLarge, k, ns)
+	}
+	err := q.cache.SetValue(k, v)
+	if err != nil {
+		return err
+	}
+	q.used[ns] += entryBytes(k, v)
+	if limited && q.used[ns] > budget {
+		q.trim(ns, k, budget)
+	}
+	return nil
+}
+
+/*trim deletes the tenant's entries, next victim first, until
+it fits its budget, sparing the entry just written*/
+func (q *Quotas) trim(ns string, written string, budget int64) {
+	exporter, ok := q.cache.(Exporter)
+	if !ok {
+		return
+	}
+	for _, record := range exporter.Export() {
+		if q.used[ns] <= budget {
+			return
+		}
+		if record.Key != written && q.namespace(record.Key) == ns {
+			// onRemoval gives the bytes back
+			q.cache.Delete(record.Key)
+		}
+	}
+}
+
+/*Delete removes the key from the wrapped cache*/
+func (q *Quotas) Delete(k string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.cache.Delete(k)
+}
+
+/*SetBudget changes a namespace's budget, a budget of 0 or
+less removes it.  A tenant already over its new budget is
+trimmed on its next write*/
+func (q *Quotas) SetBudget(ns string, bytes int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if bytes <= 0 {
+		delete(q.budgets, ns)
+		return
+	}
+	q.budgets[ns] = bytes
+}
+
+/*Usage is how many bytes each namespace holds right now*/
+func (q *Quotas) Usage() map[string]int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	usage := make(map[string]int64, len(q.used))
+	for ns, bytes := range q.used {
+		usage[ns] = bytes
+	}
+	return usage
+}
+
+func (q *Quotas) onRemoval(key string, entry Entry, reason RemovalReason) {
+	// called from inside the wrapped cache, so the lock is already held
+	ns := q.namespace(key)
+	q.used[ns] -= entryBytes(key, entry)
+	if q.used[ns] <= 0 {
+		delete(q.used, ns)
+	}
+}
+
+/*Export lists the wrapped cache's entries, if it can*/
+func (q *Quotas) Export() []Record {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	exporter, ok := q.cache.(Exporter)
+	if !ok {
+		return []Record{}
+	}
+	return exporter.Export()
+}
+
+/*Import puts the record into the wrapped cache, counting it
+against its tenant without trimming (a snapshot is trusted to
+have fit)*/
+func (q *Quotas) Import(r Record) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	err := ImportRecord(q.cache, r)
+	if err == nil && q.cache.KeyPresent(r.Key) {
+		q.used[q.namespace(r.Key)] += entryBytes(r.Key, r.Entry)
+	}
+	return err
+}
+
+/*Unwrap is the cache being shared*/
+func (q *Quotas) Unwrap() Cache {
+	return q.cache
+}
+
+/*NewQuotas starts enforcing the budgets, in bytes by namespace*/
+func NewQuotas(c Cache, namespace func(key string) string, budgets map[string]int64) *Quotas {
+	q := &Quotas{lockedInner: lockedInner{cache: c}, namespace: namespace, budgets: make(map[string]int64), used: make(map[string]int64)}
+	for ns, bytes := range budgets {
+		if bytes > 0 {
+			q.budgets[ns] = bytes
+		}
+	}
+	AddRemovalListener(c, q.onRemoval)
+	return q
+}
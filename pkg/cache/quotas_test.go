@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestQuotasTrimTheNoisyTenant(t *testing.T) {
+	lru, _ := NewCache(LRU, 10)
+	q := NewQuotas(lru, ByNamespace(":"), map[string]int64{"a": 20})
+	// each entry is 3 bytes of key and 7 of value
+	q.SetValue("b:1", NewEntry("xxxxxxx", 1))
+	q.SetValue("a:1", NewEntry("xxxxxxx", 1))
+	q.SetValue("a:2", NewEntry("xxxxxxx", 1))
+	q.GetValue("a:1")
+	q.SetValue("a:3", NewEntry("xxxxxxx", 1))
+	if q.KeyPresent("a:2") || !q.KeyPresent("a:1") || !q.KeyPresent("a:3") {
+		t.Fatalf("expected a's least recently used entry to go")
+	}
+	if !q.KeyPresent("b:1") {
+		t.Fatalf("b paid for a going over budget")
+	}
+	if usage := q.Usage(); usage["a"] != 20 || usage["b"] != 10 {
+		t.Fatalf("unexpected usage %v", usage)
+	}
+	err := q.SetValue("a:4", NewEntry("far too long for the budget", 1))
+	if !errors.Is(err, ErrCacheFull) || q.KeyPresent("a:4") {
+		t.Fatalf("expected an entry over the budget refused, got %v", err)
+	}
+}
+
+func TestQuotasFollowRemovals(t *testing.T) {
+	lru, _ := NewCache(LRU, 2)
+	q := NewQuotas(lru, ByNamespace(":"), nil)
+	q.SetValue("a:1", NewEntry("12345", 1))
+	q.SetValue("a:1", NewEntry("12", 1))
+	q.SetValue("b:1", NewEntry("1", 1))
+	q.SetValue("b:2", NewEntry("1", 1))
+	q.Delete("b:2")
+	if usage := q.Usage(); len(usage) != 1 || usage["b"] != 4 {
+		t.Fatalf("expected only b:1 left counted, got %v", usage)
+	}
+	q.SetBudget("b", 3)
+	q.SetValue("b:3", NewEntry("", 1))
+	if q.KeyPresent("b:1") || q.Usage()["b"] != 3 {
+		t.Fatalf("expected a lowered budget to trim on the next write, got %v", q.Usage())
+	}
+}
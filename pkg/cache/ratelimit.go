@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+/*ErrRateLimited is returned by a write or load over its rate
+limit*/
+var ErrRateLimited = errors.New("Rate limit exceeded")
+
+/*rateLimiter is a token bucket: it fills at rate tokens a
+second up to burst, and each call takes one*/
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func (rl *rateLimiter) allow() bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := clockNow()
+	rl.tokens += now.Sub(rl.last).Seconds() * rl.rate
+	if rl.tokens > rl.burst {
+		rl.tokens = rl.burst
+	}
+	rl.last = now
+	if rl.tokens < 1 {
+		return false
+	}
+	rl.tokens--
+	return true
+}
+
+/*newRateLimiter starts full, burst is at least 1*/
+func newRateLimiter(perSecond float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: perSecond, burst: float64(burst), tokens: float64(burst), last: clockNow()}
+}
+
+/*RateLimited lets perSecond writes through to the cache it
+wraps (with bursts of up to burst), refusing the rest with
+ErrRateLimited, so a storm of misses refilling the cache
+can't swamp whatever sits behind it.  Reads and deletes
+aren't limited.  The limiter has its own lock, it adds none
+around the cache*/
+type RateLimited struct {
+	cache   Cache
+	limiter *rateLimiter
+}
+
+/*KeyPresent is true if the key is cached right now*/
+func (rl *RateLimited) KeyPresent(k string) bool {
+	return rl.cache.KeyPresent(k)
+}
+
+/*GetValue reads from the wrapped cache*/
+func (rl *RateLimited) GetValue(k string) (Entry, error) {
+	return rl.cache.GetValue(k)
+}
+
+/*SetValue writes to the wrapped cache if the limit allows*/
+func (rl *RateLimited) SetValue(k string, v Entry) error {
+	if !rl.limiter.allow() {
+		return ErrRateLimited
+	}
+	return rl.cache.SetValue(k, v)
+}
+
+/*Delete removes the key from the wrapped cache*/
+func (rl *RateLimited) Delete(k string) error {
+	return rl.cache.Delete(k)
+}
+
+/*Export lists the wrapped cache's entries, if it can*/
+func (rl *RateLimited) Export() []Record {
+	exporter, ok := rl.cache.(Exporter)
+	if !ok {
+		return []Record{}
+	}
+	return exporter.Export()
+}
+
+/*Import puts the record into the wrapped cache, a snapshot
+isn't held to the limit*/
+func (rl *RateLimited) Import(r Record) error {
+	return ImportRecord(rl.cache, r)
+}
+
+/*Unwrap is the cache being limited*/
+func (rl *RateLimited) Unwrap() Cache {
+	return rl.cache
+}
+
+/*NewRateLimited limits the cache to perSecond writes*/
+func NewRateLimited(c Cache, perSecond float64, burst int) *RateLimited {
+	return &RateLimited{cache: c, limiter: newRateLimiter(perSecond, burst)}
+}
+
+/*RateLimitedLoader only runs perSecond loads (with bursts of
+up to burst), failing the rest with ErrRateLimited.  Given to
+a ReadThrough it protects a fragile backing computation from
+a miss storm, the misses over the limit just fail*/
+type RateLimitedLoader struct {
+	loader  Loader
+	limiter *rateLimiter
+}
+
+/*Load runs the wrapped loader if the limit allows*/
+func (rl *RateLimitedLoader) Load(key string) (Entry, error) {
+	if !rl.limiter.allow() {
+		return Entry{}, ErrRateLimited
+	}
+	return rl.loader.Load(key)
+}
+
+/*NewRateLimitedLoader limits the loader to perSecond loads*/
+func NewRateLimitedLoader(loader Loader, perSecond float64, burst int) *RateLimitedLoader {
+	return &RateLimitedLoader{loader: loader, limiter: newRateLimiter(perSecond, burst)}
+}
@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimitedWrites(t *testing.T) {
+	clock := useManualClock(t)
+	lru, _ := NewCache(LRU, 10)
+	c := NewRateLimited(lru, 2, 2)
+	c.SetValue("a", NewEntry("a", 1))
+	c.SetValue("b", NewEntry("b", 1))
+	if err := c.SetValue("c", NewEntry("c", 1)); !errors.Is(err, ErrRateLimited) || c.KeyPresent("c") {
+		t.Fatalf("expected the write past the burst refused, got %v", err)
+	}
+	clock.Advance(500 * time.Millisecond)
+	if err := c.SetValue("c", NewEntry("c", 1)); err != nil {
+		t.Fatalf("expected a write once the bucket refilled, got %v", err)
+	}
+}
+
+func TestRateLimitedLoads(t *testing.T) {
+	useManualClock(t)
+	loads := 0
+	loader := NewRateLimitedLoader(LoaderFunc(func(key string) (Entry, error) {
+		loads++
+		return NewEntry(key, 1), nil
+	}), 1, 1)
+	lru, _ := NewCache(LRU, 10)
+	c := NewReadThrough(lru, loader)
+	c.GetValue("a")
+	if _, err := c.GetValue("b"); !errors.Is(err, ErrRateLimited) || loads != 1 {
+		t.Fatalf("expected the second miss turned away, got %v after %d loads", err, loads)
+	}
+	if _, err := c.GetValue("a"); err != nil {
+		t.Fatalf("expected hits to go on, got %v", err)
+	}
+}
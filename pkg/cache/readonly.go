@@ -0,0 +1,54 @@
+package cache
+
+import "errors"
+
+/*ErrReadOnly is returned by a write to a read-only view*/
+var ErrReadOnly = errors.New("Cache is read only")
+
+/*ReadOnlyView lets its holder read a cache but not change
+it, for handing a shared cache to plugins or request handlers.
+It has no Unwrap on purpose, so nothing can reach past it to
+the cache underneath.  Reads still count as accesses to the
+policy*/
+type ReadOnlyView struct {
+	cache Cache
+}
+
+/*KeyPresent is true if the key is cached right now*/
+func (ro *ReadOnlyView) KeyPresent(k string) bool {
+	return ro.cache.KeyPresent(k)
+}
+
+/*GetValue reads from the cache*/
+func (ro *ReadOnlyView) GetValue(k string) (Entry, error) {
+	return ro.cache.GetValue(k)
+}
+
+/*SetValue is refused with ErrReadOnly*/
+func (ro *ReadOnlyView) SetValue(k string, v Entry) error {
+	return ErrReadOnly
+}
+
+/*Delete is refused with ErrReadOnly*/
+func (ro *ReadOnlyView) Delete(k string) error {
+	return ErrReadOnly
+}
+
+/*Export lists the cache's entries, if it can*/
+func (ro *ReadOnlyView) Export() []Record {
+	exporter, ok := ro.cache.(Exporter)
+	if !ok {
+		return []Record{}
+	}
+	return exporter.Export()
+}
+
+/*Import is refused with ErrReadOnly*/
+func (ro *ReadOnlyView) Import(r Record) error {
+	return ErrReadOnly
+}
+
+/*ReadOnly is a view of the cache that can't change it*/
+func ReadOnly(c Cache) *ReadOnlyView {
+	return &ReadOnlyView{cache: c}
+}
@@ -0,0 +1,27 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReadOnlyRefusesChanges(t *testing.T) {
+	lru, _ := NewCache(LRU, 2)
+	lru.SetValue("a", NewEntry("a", 1))
+	view := ReadOnly(lru)
+	if entry, err := view.GetValue("a"); err != nil || entry.Value() != "a" || !view.KeyPresent("a") {
+		t.Fatalf("expected reads through the view, got %v", err)
+	}
+	if err := view.SetValue("b", NewEntry("b", 1)); !errors.Is(err, ErrReadOnly) || lru.KeyPresent("b") {
+		t.Fatalf("expected the write refused, got %v", err)
+	}
+	if err := view.Delete("a"); !errors.Is(err, ErrReadOnly) || !lru.KeyPresent("a") {
+		t.Fatalf("expected the delete refused, got %v", err)
+	}
+	if err := ImportRecord(view, Record{Key: "c", Entry: NewEntry("c", 1)}); err == nil || lru.KeyPresent("c") {
+		t.Fatalf("expected an import refused")
+	}
+	if _, ok := Cache(view).(unwrapper); ok {
+		t.Fatalf("expected no way past the view")
+	}
+}
@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*maxPendingMisses bounds how many misses wait for their fill
+before being written out without a cost*/
+const maxPendingMisses = 10000
+
+/*pendingMiss is a miss waiting for the SetValue that fills it*/
+type pendingMiss struct {
+	at time.Time
+}
+
+/*Recorder wraps a cache and writes every access to a trace
+as csv rows of timestamp (unix nanos), op (get, set,
+delete), key, result (hit or miss for gets) and cost, which
+sim.OpenTrace(path, "recorded") replays.  A miss is written
+once the SetValue that fills it comes in, so it carries the
+real recompute cost and the fill itself isn't written as a
+separate set.  Put it right around the policy, under any
+wrapper that loads misses (a ReadThrough outside the recorder
+makes its misses look like hits).  Sampling is by key hash,
+so a sampled key's whole history is kept*/
+type Recorder struct {
+	mu        sync.Mutex
+	cache     Cache
+	out       *csv.Writer
+	all       bool
+	threshold uint64
+	pending   map[string]pendingMiss
+	err       error
+}
+
+/*sampled is true if the key's accesses are recorded*/
+func (r *Recorder) sampled(k string) bool {
+	if r.all {
+		return true
+	}
+	return SampleHash(k) <= r.threshold
+}
+
+/*SampleHash spreads keys evenly over the uint64s, to sample
+them by.  The Recorder, Logged and sim's SHARDS sample with
+it, so at the same rate they all pick the same keys*/
+func SampleHash(k string) uint64 {
+	h := hash64(k)
+	// hash64's high bits barely move between similar keys, mix them first
+	h = (h ^ (h >> 30)) * 0xbf58476d1ce4e5b9
+	h = (h ^ (h >> 27)) * 0x94d049bb133111eb
+	return h ^ (h >> 31)
+}
+
+func (r *Recorder) write(at time.Time, op string, k string, result string, cost int) {
+	if r.err != nil {
+		return
+	}
+	r.err = r.out.Write([]string{strconv.FormatInt(at.UnixNano(), 10), op, k, result, strconv.Itoa(cost)})
+}
+
+/*flushMiss writes out a miss nobody filled (yet)*/
+func (r *Recorder) flushMiss(k string) {
+	miss, ok := r.pending[k]
+	if ok {
+		r.write(miss.at, "get", k, "miss", 0)
+		delete(r.pending, k)
+	}
+}
+
+func (r *Recorder) miss(k string) {
+	r.flushMiss(k)
+	if len(r.pending) >= maxPendingMisses {
+		for key := range r.pending {
+			r.flushMiss(key)
+		}
+	}
+	r.pending[k] = pendingMiss{at: time.Now()}
+}
+
+/*KeyPresent checks the wrapped cache, recording a miss when
+the key isn't there (a hit is recorded by the GetValue that
+follows)*/
+func (r *Recorder) KeyPresent(k string) bool {
+	present := r.cache.KeyPresent(k)
+	if !present && r.sampled(k) {
+		r.mu.Lock()
+		r.miss(k)
+		r.mu.Unlock()
+	}
+	return present
+}
+
+/*GetValue reads from the wrapped cache, recording the hit
+or miss*/
+func (r *Recorder) GetValue(k string) (Entry, error) {
+	entry, err := r.cache.GetValue(k)
+	if !r.sampled(k) {
+		return entry, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		if _, ok := r.pending[k]; !ok {
+			r.miss(k)
+		}
+		return entry, err
+	}
+	r.flushMiss(k)
+	r.write(time.Now(), "get", k, "hit", entry.cost)
+	return entry, nil
+}
+
+/*SetValue writes to the wrapped cache, completing a pending
+miss or recording a set*/
+func (r *Recorder) SetValue(k string, v Entry) error {
+	err := r.cache.SetValue(k, v)
+	if !r.sampled(k) {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	miss, ok := r.pending[k]
+	if ok {
+		delete(r.pending, k)
+		r.write(miss.at, "get", k, "miss", v.cost)
+	} else {
+		r.write(time.Now(), "set", k, "", v.cost)
+	}
+	return err
+}
+
+/*Delete removes the key from the wrapped cache, recording it*/
+func (r *Recorder) Delete(k string) error {
+	err := r.cache.Delete(k)
+	if r.sampled(k) {
+		r.mu.Lock()
+		r.flushMiss(k)
+		r.write(time.Now(), "delete", k, "", 0)
+		r.mu.Unlock()
+	}
+	return err
+}
+
+/*Unwrap is the cache being recorded*/
+func (r *Recorder) Unwrap() Cache {
+	return r.cache
+}
+
+/*Flush writes out buffered rows (and misses still waiting on
+a fill), returning the first write error seen*/
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key := range r.pending {
+		r.flushMiss(key)
+	}
+	r.out.Flush()
+	if r.err != nil {
+		return r.err
+	}
+	return r.out.Error()
+}
+
+/*NewRecorder records accesses to a rate share of the keys
+(1 for all of them) to w*/
+func NewRecorder(c Cache, w io.Writer, rate float64) *Recorder {
+	r := &Recorder{cache: c, out: csv.NewWriter(w), pending: make(map[string]pendingMiss)}
+	if rate >= 1 {
+		r.all = true
+	} else {
+		r.threshold = uint64(rate * float64(1<<63) * 2)
+	}
+	return r
+}
@@ -0,0 +1,196 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*RedisInvalidator lets a local cache act as a near-cache
+in front of redis.  It subscribes to a channel and deletes
+every key published on it, or (with a keyspace pattern like
+"__keyspace@0__:*") listens to keyspace notifications and
+drops a key whenever redis reports it changed*/
+type RedisInvalidator struct {
+	addr    string
+	channel string
+	cache   Cache
+	logger  *log.Logger
+	mu      sync.Mutex
+	conn    net.Conn
+	closed  bool
+}
+
+func writeRespCommand(w io.Writer, args ...string) error {
+	cmd := "*" + strconv.Itoa(len(args)) + "\r\n"
+	for _, arg := range args {
+		cmd = cmd + "$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n"
+	}
+	_, err := w.Write([]byte(cmd))
+	return err
+}
+
+/*readResp reads one reply, flattening arrays into
+a list of strings (which is all pub/sub needs)*/
+func readResp(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) == 0 {
+		return nil, errors.New("Empty redis reply")
+	}
+	switch line[0] {
+	case '+', ':':
+		return []string{line[1:]}, nil
+	case '-':
+		return nil, errors.New("redis: " + line[1:])
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return []string{""}, nil
+		}
+		buf := make([]byte, size+2)
+		_, err = io.ReadFull(r, buf)
+		if err != nil {
+			return nil, err
+		}
+		return []string{string(buf[:size])}, nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		items := []string{}
+		for i := 0; i < count; i++ {
+			item, err := readResp(r)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item...)
+		}
+		return items, nil
+	}
+	return nil, errors.New("Unknown redis reply: " + line)
+}
+
+func (ri *RedisInvalidator) isPattern() bool {
+	return strings.ContainsAny(ri.channel, "*?[")
+}
+
+/*keyFor works out which local key a pub/sub message is about*/
+func (ri *RedisInvalidator) keyFor(msg []string) (string, bool) {
+	if len(msg) == 3 && msg[0] == "message" {
+		return keyInMessage(msg[1], msg[2]), true
+	}
+	if len(msg) == 4 && msg[0] == "pmessage" {
+		return keyInMessage(msg[2], msg[3]), true
+	}
+	return "", false
+}
+
+/*keyInMessage is the key a message on the channel is about,
+whether it came from SUBSCRIBE or PSUBSCRIBE*/
+func keyInMessage(channel string, payload string) string {
+	if strings.HasPrefix(channel, "__keyspace@") {
+		// __keyspace@0__:<key> with the event as payload
+		idx := strings.Index(channel, "__:")
+		if idx >= 0 {
+			return channel[idx+3:]
+		}
+	}
+	// __keyevent@0__:<event> or a user channel, payload is the key
+	return payload
+}
+
+func (ri *RedisInvalidator) isClosed() bool {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+	return ri.closed
+}
+
+/*Listen subscribes and invalidates keys until the connection
+drops or Close is called*/
+func (ri *RedisInvalidator) Listen() error {
+	conn, err := net.Dial("tcp", ri.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	ri.mu.Lock()
+	if ri.closed {
+		ri.mu.Unlock()
+		return errors.New("Redis invalidator closed")
+	}
+	ri.conn = conn
+	ri.mu.Unlock()
+	subscribe := "SUBSCRIBE"
+	if ri.isPattern() {
+		subscribe = "PSUBSCRIBE"
+	}
+	err = writeRespCommand(conn, subscribe, ri.channel)
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReader(conn)
+	for {
+		msg, err := readResp(reader)
+		if err != nil {
+			return err
+		}
+		key, ok := ri.keyFor(msg)
+		if !ok {
+			// subscribe confirmations and the like
+			continue
+		}
+		ri.cache.Delete(key)
+	}
+}
+
+/*Run keeps Listen going, reconnecting after the given
+delay whenever redis goes away, until Close is called*/
+func (ri *RedisInvalidator) Run(retryDelay time.Duration) {
+	for !ri.isClosed() {
+		err := ri.Listen()
+		if ri.isClosed() {
+			return
+		}
+		ri.logger.Println("WARNING: redis invalidation listener stopped: ", err)
+		time.Sleep(retryDelay)
+	}
+}
+
+/*Close stops listening*/
+func (ri *RedisInvalidator) Close() {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+	ri.closed = true
+	if ri.conn != nil {
+		ri.conn.Close()
+	}
+}
+
+/*invalidationTarget is a cache that wants invalidations
+delivered somewhere other than itself (see TwoLevel)*/
+type invalidationTarget interface {
+	InvalidationTarget() Cache
+}
+
+/*NewRedisInvalidator builds a listener that deletes keys
+from the cache as redis announces them on the channel*/
+func NewRedisInvalidator(addr string, channel string, cache Cache, logger *log.Logger) *RedisInvalidator {
+	if target, ok := cache.(invalidationTarget); ok {
+		cache = target.InvalidationTarget()
+	}
+	return &RedisInvalidator{addr: addr, channel: channel, cache: cache, logger: logger}
+}
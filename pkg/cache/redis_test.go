@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+/*fakeRedis accepts one subscriber and sends it the frames*/
+func fakeRedis(t *testing.T, frames [][]string) (string, chan []string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	commands := make(chan []string, 1)
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		command, err := readResp(bufio.NewReader(conn))
+		if err != nil {
+			return
+		}
+		commands <- command
+		for _, frame := range frames {
+			reply := "*" + strconv.Itoa(len(frame)) + "\r\n"
+			for _, item := range frame {
+				reply += "$" + strconv.Itoa(len(item)) + "\r\n" + item + "\r\n"
+			}
+			conn.Write([]byte(reply))
+		}
+		// hold the connection open until the client hangs up
+		io.Copy(io.Discard, conn)
+	}()
+	return ln.Addr().String(), commands
+}
+
+func TestRedisKeyInMessage(t *testing.T) {
+	cases := []struct {
+		msg []string
+		key string
+	}{
+		{[]string{"message", "invalidations", "user:1"}, "user:1"},
+		{[]string{"message", "__keyspace@0__:user:1", "set"}, "user:1"},
+		{[]string{"pmessage", "__keyspace@0__:*", "__keyspace@0__:user:1", "del"}, "user:1"},
+		{[]string{"pmessage", "__keyevent@0__:*", "__keyevent@0__:expired", "user:1"}, "user:1"},
+		{[]string{"pmessage", "inv:*", "inv:users", "user:1"}, "user:1"},
+	}
+	ri := NewRedisInvalidator("", "", nil, nil)
+	for _, tc := range cases {
+		key, ok := ri.keyFor(tc.msg)
+		if !ok || key != tc.key {
+			t.Fatalf("%v: got %q, expected %q", tc.msg, key, tc.key)
+		}
+	}
+	if _, ok := ri.keyFor([]string{"subscribe", "invalidations", "1"}); ok {
+		t.Fatal("subscribe confirmations aren't about a key")
+	}
+}
+
+func TestRedisInvalidatorDeletesKeys(t *testing.T) {
+	lru, _ := NewCache(LRU, 10)
+	shared := NewBatched(lru)
+	for _, key := range []string{"user:1", "user:2", "set"} {
+		shared.SetValue(key, NewEntry("v", 1))
+	}
+	addr, commands := fakeRedis(t, [][]string{
+		{"subscribe", "__keyspace@0__:user:1", "1"},
+		{"message", "__keyspace@0__:user:1", "set"},
+	})
+	ri := NewRedisInvalidator(addr, "__keyspace@0__:user:1", shared, log.New(io.Discard, "", 0))
+	go ri.Run(time.Millisecond)
+	defer ri.Close()
+	if command := <-commands; command[0] != "SUBSCRIBE" {
+		t.Fatalf("plain channel should SUBSCRIBE, sent %v", command)
+	}
+	deadline := time.Now().Add(time.Second)
+	for shared.KeyPresent("user:1") && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if shared.KeyPresent("user:1") {
+		t.Fatal("user:1 was never invalidated")
+	}
+	if !shared.KeyPresent("set") || !shared.KeyPresent("user:2") {
+		t.Fatal("the event name was taken for a key")
+	}
+}
+
+func TestRedisInvalidatorCloseWhileRunning(t *testing.T) {
+	// run with -race: Close races the listener goroutine
+	addr, _ := fakeRedis(t, nil)
+	lru, _ := NewCache(LRU, 10)
+	ri := NewRedisInvalidator(addr, "invalidations", NewBatched(lru), log.New(io.Discard, "", 0))
+	done := make(chan bool)
+	go func() {
+		ri.Run(time.Millisecond)
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	ri.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run didn't stop after Close")
+	}
+}
+
+func TestRedisInvalidatorOnlyClearsLocalTier(t *testing.T) {
+	localLru, _ := NewCache(LRU, 10)
+	remoteLru, _ := NewCache(LRU, 10)
+	local := NewBatched(localLru)
+	remote := NewBatched(remoteLru)
+	twoLevel := NewTwoLevel(local, remote)
+	twoLevel.SetValue("user:1", NewEntry("v", 1))
+	addr, _ := fakeRedis(t, [][]string{
+		{"message", "invalidations", "user:1"},
+	})
+	ri := NewRedisInvalidator(addr, "invalidations", twoLevel, log.New(io.Discard, "", 0))
+	go ri.Run(time.Millisecond)
+	defer ri.Close()
+	deadline := time.Now().Add(time.Second)
+	for local.KeyPresent("user:1") && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if local.KeyPresent("user:1") {
+		t.Fatal("local copy was never invalidated")
+	}
+	if !remote.KeyPresent("user:1") {
+		t.Fatal("invalidation reached the remote tier")
+	}
+}
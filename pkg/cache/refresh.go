@@ -0,0 +1,188 @@
+package cache
+
+import (
+	"sort"
+	"time"
+)
+
+/*refreshStat is what the refresher knows about a resident
+entry without having to look it up (which would disturb the
+policy's ordering)*/
+type refreshStat struct {
+	hits int
+	cost int
+}
+
+/*Refresher wraps a cache and re-runs the loader for hot,
+expensive entries while the cache is idle, so the keys that
+would hurt most to miss are recomputed before anyone has to
+wait for them.  Entries are scored by cost times the number
+of hits since they were last refreshed*/
+type Refresher struct {
+	lockedInner
+	loader   Loader
+	tracked  map[string]*refreshStat
+	lastOp   time.Time
+	idle     time.Duration
+	interval time.Duration
+	perRound int
+	clock    Clock
+	stop     chan bool
+	done     chan bool
+}
+
+func (r *Refresher) touch() {
+	r.lastOp = r.clock.Now()
+}
+
+/*KeyPresent is true if the key is cached right now*/
+func (r *Refresher) KeyPresent(k string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.touch()
+	return r.cache.KeyPresent(k)
+}
+
+/*GetValue reads from the wrapped cache, counting the hit*/
+func (r *Refresher) GetValue(k string) (Entry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.touch()
+	entry, err := r.cache.GetValue(k)
+	if err == nil {
+		r.track(k, entry).hits++
+	}
+	return entry, err
+}
+
+/*SetValue writes to the wrapped cache*/
+func (r *Refresher) SetValue(k string, v Entry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.touch()
+	err := r.cache.SetValue(k, v)
+	if err == nil {
+		r.track(k, v)
+	}
+	return err
+}
+
+func (r *Refresher) track(k string, v Entry) *refreshStat {
+	stat, ok := r.tracked[k]
+	if !ok {
+		stat = &refreshStat{}
+		r.tracked[k] = stat
+	}
+	stat.cost = v.cost
+	return stat
+}
+
+/*Delete removes the key from the wrapped cache*/
+func (r *Refresher) Delete(k string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.touch()
+	return r.cache.Delete(k)
+}
+
+/*Unwrap is the cache being refreshed*/
+func (r *Refresher) Unwrap() Cache {
+	return r.cache
+}
+
+func (r *Refresher) onRemoval(key string, entry Entry, reason RemovalReason) {
+	// called from inside the wrapped cache, so the lock is already held
+	if reason != Replaced {
+		delete(r.tracked, key)
+	}
+}
+
+/*candidates is the highest scoring keys, most valuable first*/
+func (r *Refresher) candidates() []string {
+	type scored struct {
+		key   string
+		score int
+	}
+	scores := []scored{}
+	for key, stat := range r.tracked {
+		if stat.hits > 0 {
+			scores = append(scores, scored{key: key, score: stat.cost * stat.hits})
+		}
+	}
+	sort.Slice(scores, func(i, j int) bool { return scores[i].score > scores[j].score })
+	keys := []string{}
+	for i := 0; i < len(scores) && i < r.perRound; i++ {
+		keys = append(keys, scores[i].key)
+	}
+	return keys
+}
+
+/*RefreshNow recomputes the best candidates regardless of
+whether the cache is idle, returning how many were refreshed*/
+func (r *Refresher) RefreshNow() int {
+	r.mu.Lock()
+	keys := r.candidates()
+	r.mu.Unlock()
+	refreshed := 0
+	for _, key := range keys {
+		entry, err := r.loader.Load(key)
+		if err != nil {
+			continue
+		}
+		r.mu.Lock()
+		// don't resurrect something deleted while we were loading
+		if _, ok := r.tracked[key]; ok {
+			r.cache.SetValue(key, entry)
+			r.track(key, entry).hits = 0
+			refreshed++
+		}
+		r.mu.Unlock()
+	}
+	return refreshed
+}
+
+func (r *Refresher) run() {
+	defer close(r.done)
+	for {
+		select {
+		case <-r.clock.After(r.interval):
+			r.mu.Lock()
+			idle := r.clock.Now().Sub(r.lastOp) >= r.idle
+			r.mu.Unlock()
+			if idle {
+				r.RefreshNow()
+			}
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+/*Close stops the background refreshing, waiting out a round
+already under way*/
+func (r *Refresher) Close() {
+	close(r.stop)
+	<-r.done
+}
+
+/*NewRefresher wraps the cache, checking every interval
+whether it has been idle for at least idle and, if so,
+reloading up to perRound of the most valuable entries*/
+func NewRefresher(c Cache, loader Loader, idle time.Duration, interval time.Duration, perRound int) *Refresher {
+	clock := clockOf(c)
+	r := &Refresher{
+		lockedInner: lockedInner{cache: c},
+		loader:      loader,
+		tracked:     make(map[string]*refreshStat),
+		lastOp:      clock.Now(),
+		idle:        idle,
+		interval:    interval,
+		perRound:    perRound,
+		clock:       clock,
+		stop:        make(chan bool),
+		done:        make(chan bool),
+	}
+	AddRemovalListener(c, r.onRemoval)
+	go r.run()
+	return r
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*countingLoader records every key it is asked to load*/
+type countingLoader struct {
+	mu    sync.Mutex
+	loads []string
+}
+
+func (l *countingLoader) Load(key string) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.loads = append(l.loads, key)
+	return NewEntry("fresh "+key, 1), nil
+}
+
+func (l *countingLoader) loaded() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	keys := append([]string{}, l.loads...)
+	sort.Strings(keys)
+	return strings.Join(keys, " ")
+}
+
+func TestRefresherReloadsTheTopKeysWhenIdle(t *testing.T) {
+	clock := useManualClock(t)
+	plain, _ := NewCache(LRU, 10)
+	loader := &countingLoader{}
+	r := NewRefresher(plain, loader, time.Minute, 10*time.Second, 2)
+	defer r.Close()
+	// scored cost times hits: a 30, b 10, c 100, d 5, e never read
+	for _, set := range []struct {
+		key   string
+		cost  int
+		reads int
+	}{{"a", 10, 3}, {"b", 5, 2}, {"c", 100, 1}, {"d", 1, 5}, {"e", 50, 0}} {
+		r.SetValue(set.key, NewEntry("stale", set.cost))
+		for i := 0; i < set.reads; i++ {
+			r.GetValue(set.key)
+		}
+	}
+	waitFor(t, func() bool { return clock.Waiting() == 1 })
+	clock.Advance(10 * time.Second)
+	// the worker checks and re-arms without reloading, the cache isn't idle yet
+	waitFor(t, func() bool { return clock.Waiting() == 1 })
+	if loader.loaded() != "" {
+		t.Fatalf("reloaded %q before the cache went idle", loader.loaded())
+	}
+	clock.Advance(time.Minute)
+	// re-arming comes after the round, so the reloads have all landed
+	waitFor(t, func() bool { return loader.loaded() == "a c" && clock.Waiting() == 1 })
+	for _, key := range []string{"a", "c"} {
+		if entry, _ := plain.(Peeker).Peek(key); entry.Value() != "fresh "+key {
+			t.Fatalf("expected %s reloaded, got %v", key, entry.Value())
+		}
+	}
+	if entry, _ := plain.(Peeker).Peek("b"); entry.Value() != "stale" {
+		t.Fatalf("b was outscored and shouldn't have been reloaded, got %v", entry.Value())
+	}
+	// a and c start over at no hits, so the next idle round takes b and d
+	clock.Advance(10 * time.Second)
+	waitFor(t, func() bool { return loader.loaded() == "a b c d" })
+}
+
+func TestRefresherCloseStopsTheWorker(t *testing.T) {
+	clock := useManualClock(t)
+	loader := &countingLoader{}
+	r := NewRefresher(newLru(4), loader, time.Minute, 10*time.Second, 2)
+	r.SetValue("a", NewEntry("stale", 5))
+	r.GetValue("a")
+	waitFor(t, func() bool { return clock.Waiting() == 1 })
+	r.Close()
+	clock.Advance(time.Hour)
+	time.Sleep(10 * time.Millisecond)
+	if loader.loaded() != "" || clock.Waiting() != 0 {
+		t.Fatalf("expected no reloads and no timer after Close, got %q and %d waiting", loader.loaded(), clock.Waiting())
+	}
+}
@@ -0,0 +1,115 @@
+package cache
+
+/*Releaser frees whatever an entry's value stands for (a file
+handle, an mmap, a C buffer) once the cache is done with it*/
+type Releaser func(key string, entry Entry)
+
+/*Releasing calls its Releaser exactly once for every value
+that leaves the cache for good, whether it was evicted,
+deleted, expired or overwritten.  Overwriting a key with the
+value it already holds isn't the value leaving, so that one
+isn't released.  Values are told apart by their string, so
+each resource needs a value of its own (its handle, or a
+path).  A value the cache refused never entered it and is
+still the caller's to free.  Like Indexed it holds its lock
+around every call (the Releaser is called under it, so it
+must not use the cache), and the wrapped cache has to report
+its removals*/
+type Releasing struct {
+	lockedInner
+	release Releaser
+	writing *Entry
+}
+
+/*KeyPresent is true if the key is cached right now*/
+func (r *Releasing) KeyPresent(k string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cache.KeyPresent(k)
+}
+
+/*GetValue reads from the wrapped cache*/
+func (r *Releasing) GetValue(k string) (Entry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cache.GetValue(k)
+}
+
+/*SetValue writes the entry, releasing the value it replaces
+if that's a different one*/
+func (r *Releasing) SetValue(k string, v Entry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writing = &v
+	defer func() { r.writing = nil }()
+	return r.cache.SetValue(k, v)
+}
+
+/*Delete removes the key, releasing its value*/
+func (r *Releasing) Delete(k string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cache.Delete(k)
+}
+
+/*ReleaseAll deletes every entry, releasing each value, for
+shutting down without leaking what the cache still holds.  It
+returns how many it released*/
+func (r *Releasing) ReleaseAll() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	exporter, ok := r.cache.(Exporter)
+	if !ok {
+		return 0
+	}
+	released := 0
+	for _, record := range exporter.Export() {
+		if r.cache.Delete(record.Key) == nil {
+			released++
+		}
+	}
+	return released
+}
+
+func (r *Releasing) onRemoval(key string, entry Entry, reason RemovalReason) {
+	// called from inside the wrapped cache, so the lock is already held
+	if reason == Replaced && r.writing != nil && r.writing.value == entry.value {
+		// the same value is going straight back in
+		return
+	}
+	r.release(key, entry)
+}
+
+/*Export lists the wrapped cache's entries, if it can*/
+func (r *Releasing) Export() []Record {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	exporter, ok := r.cache.(Exporter)
+	if !ok {
+		return []Record{}
+	}
+	return exporter.Export()
+}
+
+/*Import puts the record into the wrapped cache, releasing the
+value it replaces like SetValue*/
+func (r *Releasing) Import(rec Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writing = &rec.Entry
+	defer func() { r.writing = nil }()
+	return ImportRecord(r.cache, rec)
+}
+
+/*Unwrap is the cache holding the values*/
+func (r *Releasing) Unwrap() Cache {
+	return r.cache
+}
+
+/*NewReleasing calls release for each value as it leaves the
+cache, which should be empty to start with*/
+func NewReleasing(c Cache, release Releaser) *Releasing {
+	r := &Releasing{lockedInner: lockedInner{cache: c}, release: release}
+	AddRemovalListener(c, r.onRemoval)
+	return r
+}
@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReleasingReleasesEachValueOnce(t *testing.T) {
+	clock := useManualClock(t)
+	released := map[string]int{}
+	lru, _ := NewCache(LRU, 2)
+	r := NewReleasing(lru, func(key string, entry Entry) {
+		released[entry.Value()]++
+	})
+	r.SetValue("a", NewEntry("fd:1", 1))
+	r.SetValue("a", NewEntry("fd:1", 1))
+	if released["fd:1"] != 0 {
+		t.Fatalf("expected setting the same value again to keep it")
+	}
+	r.SetValue("a", NewEntry("fd:2", 1))
+	r.SetValue("b", NewEntry("fd:3", 1).WithTTL(time.Minute))
+	r.SetValue("c", NewEntry("fd:4", 1))
+	r.Delete("c")
+	if released["fd:1"] != 1 || released["fd:2"] != 1 || released["fd:4"] != 1 {
+		t.Fatalf("expected replaced, evicted and deleted values released, got %v", released)
+	}
+	r.SetValue("d", NewEntry("fd:5", 1))
+	clock.Advance(2 * time.Minute)
+	r.SweepExpired(10)
+	if released["fd:3"] != 1 {
+		t.Fatalf("expected the expired value released, got %v", released)
+	}
+	if r.ReleaseAll() != 1 || released["fd:5"] != 1 {
+		t.Fatalf("expected shutting down to release what's left, got %v", released)
+	}
+	for value, times := range released {
+		if times != 1 {
+			t.Fatalf("%s released %d times", value, times)
+		}
+	}
+}
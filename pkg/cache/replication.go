@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+)
+
+/*replicationOp is one Set or Delete waiting to
+be shipped to the replicas*/
+type replicationOp struct {
+	command string
+	key     string
+	entry   Entry
+}
+
+func (op replicationOp) message() string {
+	if op.command == "replicate_set" {
+		// value goes last so commas inside it survive the split
+		return op.command + "," + op.key + "," + strconv.Itoa(op.entry.cost) + "," + ttlFields(op.entry) + "," + op.entry.value
+	}
+	return op.command + "," + op.key
+}
+
+/*Replicator ships the Set and Delete operations a primary
+performs to its replicas in the background, so a hot standby
+has a warm cache when it takes over.  Replication is
+asynchronous and best effort: if the queue is full the
+operation is dropped and logged rather than stalling the primary*/
+type Replicator struct {
+	replicas  []string
+	transport Transport
+	logger    *log.Logger
+	queue     chan replicationOp
+}
+
+/*ReplicateSet queues a cache fill for the replicas*/
+func (r *Replicator) ReplicateSet(key string, entry Entry) {
+	r.enqueue(replicationOp{command: "replicate_set", key: key, entry: entry})
+}
+
+/*ReplicateDelete queues a delete for the replicas*/
+func (r *Replicator) ReplicateDelete(key string) {
+	r.enqueue(replicationOp{command: "replicate_delete", key: key})
+}
+
+func (r *Replicator) enqueue(op replicationOp) {
+	if len(r.replicas) == 0 {
+		return
+	}
+	select {
+	case r.queue <- op:
+	default:
+		r.logger.Println("WARNING: replication queue full, dropping ", op.command, " for ", op.key)
+	}
+}
+
+func (r *Replicator) run() {
+	for op := range r.queue {
+		message := op.message()
+		for _, replica := range r.replicas {
+			_, err := r.transport.Send(replica, message)
+			if err != nil {
+				r.logger.Println("WARNING: failed to replicate ", op.key, " to ", replica, ": ", err)
+			}
+		}
+	}
+}
+
+/*Start launches the background worker that drains the queue*/
+func (r *Replicator) Start() {
+	if len(r.replicas) == 0 {
+		return
+	}
+	go r.run()
+}
+
+/*NewReplicator builds a replicator for the given replica
+addresses (host:port).  A nil transport means plain tcp*/
+func NewReplicator(replicas []string, transport Transport, logger *log.Logger, queueSize int) *Replicator {
+	if transport == nil {
+		transport = defaultTransport()
+	}
+	return &Replicator{
+		replicas:  replicas,
+		transport: transport,
+		logger:    logger,
+		queue:     make(chan replicationOp, queueSize),
+	}
+}
+
+/*parseSetMessage pulls key, cost and value back out of
+a set message*/
+func parseSetMessage(message string) (string, Entry, error) {
+	parts := strings.SplitN(message, ",", 4)
+	if len(parts) < 4 {
+		return "", Entry{}, errors.New("Malformed set message")
+	}
+	cost, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return "", Entry{}, err
+	}
+	return strings.TrimSpace(parts[1]), Entry{value: strings.TrimRight(parts[3], "\n"), cost: cost}, nil
+}
+
+/*parseReplicateMessage pulls key, cost, deadlines and value
+back out of a replicate_set message*/
+func parseReplicateMessage(message string) (string, Entry, error) {
+	parts := strings.SplitN(message, ",", 7)
+	if len(parts) < 7 {
+		return "", Entry{}, errors.New("Malformed replication message")
+	}
+	cost, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return "", Entry{}, err
+	}
+	entry, err := withTTLFields(Entry{value: strings.TrimRight(parts[6], "\n"), cost: cost}, parts[3:6])
+	if err != nil {
+		return "", Entry{}, err
+	}
+	return strings.TrimSpace(parts[1]), entry, nil
+}
@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReplicateMessageCarriesDeadlines(t *testing.T) {
+	entry := NewEntry("a,b", 7).WithTTL(time.Minute).WithIdleTTL(time.Second)
+	message := replicationOp{command: "replicate_set", key: "k", entry: entry}.message()
+	key, parsed, err := parseReplicateMessage(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "k" || parsed.Value() != "a,b" || parsed.Cost() != 7 {
+		t.Fatalf("unexpected entry %q %+v", key, parsed)
+	}
+	if parsed.idle != int64(time.Second) || parsed.Expires().Sub(entry.Expires()).Abs() > 10*time.Millisecond {
+		t.Fatalf("deadlines lost: %v vs %v", parsed.Expires(), entry.Expires())
+	}
+	if parsed.expires == 0 || parsed.expires-entry.expires > int64(10*time.Millisecond) {
+		t.Fatal("absolute ttl lost")
+	}
+}
+
+func TestReplicateMessageWithoutTTL(t *testing.T) {
+	message := replicationOp{command: "replicate_set", key: "k", entry: NewEntry("v", 1)}.message()
+	_, parsed, err := parseReplicateMessage(message)
+	if err != nil || !parsed.Expires().IsZero() {
+		t.Fatalf("expected no deadline, got %v %v", parsed.Expires(), err)
+	}
+}
+
+func TestMigrateMessageCarriesDeadlines(t *testing.T) {
+	record := Record{Key: "k", Entry: NewEntry("v", 3).WithTTL(time.Minute), Hits: 4}
+	parsed, err := parseMigrateMessage(migrateMessage(record))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Key != "k" || parsed.Hits != 4 || parsed.Entry.Cost() != 3 {
+		t.Fatalf("unexpected record %+v", parsed)
+	}
+	if parsed.Entry.Expires().Sub(record.Entry.Expires()).Abs() > 10*time.Millisecond {
+		t.Fatalf("deadline moved from %v to %v", record.Entry.Expires(), parsed.Entry.Expires())
+	}
+}
+
+func TestExpiredEntryStaysExpiredOverTheWire(t *testing.T) {
+	entry := NewEntry("v", 1).WithTTL(time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	_, parsed, err := parseReplicateMessage(replicationOp{command: "replicate_set", key: "k", entry: entry}.message())
+	if err != nil || !parsed.expired(processClock{}) {
+		t.Fatalf("an expired entry should arrive expired, %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*ErrNotResizable is what Resize fails with when nothing
+under the cache can change its size: the adaptive policies,
+whose histories are sized with them, TIERED, SLRU and NONE*/
+var ErrNotResizable = errors.New("Cache can't be resized")
+
+/*resizer is a policy (or a composite) that can change its
+size in place, returning how many entries it evicted to fit*/
+type resizer interface {
+	resize(size int) (int, error)
+}
+
+/*victimRemover is a policy that can name its next victim and
+evict it*/
+type victimRemover interface {
+	nextEvicted() (string, bool)
+	remove(k string, reason RemovalReason) error
+}
+
+/*evictDown evicts count victims, or as many as there are*/
+func evictDown(p victimRemover, count int) int {
+	evicted := 0
+	for evicted < count {
+		k, ok := p.nextEvicted()
+		if !ok || p.remove(k, Evicted) != nil {
+			break
+		}
+		evicted++
+	}
+	return evicted
+}
+
+/*Resize changes the size of the policy under the cache,
+evicting its next victims (listeners hear Evicted) if it now
+holds more than fits.  It goes through the chain of wrappers,
+taking the lock of the first one that holds one around the
+cache it wraps (a Batched, an Indexed and so on), and a
+Sharded splits the size evenly between its shards.  It
+returns how many entries were evicted*/
+func Resize(c Cache, size int) (int, error) {
+	if size <= 0 {
+		return 0, fmt.Errorf("%w, size %d isn't positive", ErrNotResizable, size)
+	}
+	for c != nil {
+		r, ok := c.(resizer)
+		if ok {
+			return r.resize(size)
+		}
+		wrapper, ok := c.(unwrapper)
+		if !ok {
+			break
+		}
+		c = wrapper.Unwrap()
+	}
+	return 0, ErrNotResizable
+}
+
+func (ff *FiFo) resize(size int) (int, error) {
+	ff.maxSize = size
+	return evictDown(ff, ff.length-size), nil
+}
+
+func (l *Lru) resize(size int) (int, error) {
+	l.maxSize = size
+	return evictDown(l, l.length-size), nil
+}
+
+func (l *Lfu) resize(size int) (int, error) {
+	l.maxSize = size
+	return evictDown(l, l.length-size), nil
+}
+
+func (l *Lcr) resize(size int) (int, error) {
+	l.maxSize = size
+	return evictDown(l, l.length-size), nil
+}
+
+func (l *LcrTtl) resize(size int) (int, error) {
+	l.maxSize = size
+	return evictDown(l, len(l.lookup)-size), nil
+}
+
+/*resize resizes the wrapped cache under the write lock*/
+func (b *Batched) resize(size int) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return Resize(b.cache, size)
+}
+
+/*resize splits the size between the shards, the first ones
+taking the remainder like NewFromConfig*/
+func (s *Sharded) resize(size int) (int, error) {
+	if size < len(s.shards) {
+		return 0, fmt.Errorf("%w, %d shards can't share %d entries", ErrNotResizable, len(s.shards), size)
+	}
+	perShard := size / len(s.shards)
+	extra := size % len(s.shards)
+	evicted := 0
+	for i, shard := range s.shards {
+		shardSize := perShard
+		if i < extra {
+			shardSize++
+		}
+		n, err := shard.resize(shardSize)
+		evicted += n
+		if err != nil {
+			return evicted, err
+		}
+	}
+	return evicted, nil
+}
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestResizeShrinksAndGrowsInPlace(t *testing.T) {
+	for _, policy := range []CacheType{FIFO, LRU, LFU, LCR, LCRTTL} {
+		c, _ := NewCache(policy, 10)
+		heard := 0
+		AddRemovalListener(c, func(key string, entry Entry, reason RemovalReason) {
+			if reason == Evicted {
+				heard++
+			}
+		})
+		for i := 0; i < 10; i++ {
+			c.SetValue(strconv.Itoa(i), NewEntry("v", i+1))
+		}
+		metered := NewMetered(NewBatched(c))
+		evicted, err := Resize(metered, 4)
+		if err != nil || evicted != 6 || heard != 6 || len(metered.Export()) != 4 {
+			t.Fatalf("%s: expected 6 evicted to fit 4, got %d (%d heard), %v", policy, evicted, heard, err)
+		}
+		if err = CheckInvariants(c); err != nil {
+			t.Fatalf("%s: %v", policy, err)
+		}
+		if _, err = Resize(metered, 20); err != nil {
+			t.Fatalf("%s: unexpected error %v", policy, err)
+		}
+		for i := 10; i < 30; i++ {
+			metered.SetValue(strconv.Itoa(i), NewEntry("v", 1))
+		}
+		if len(metered.Export()) != 20 {
+			t.Fatalf("%s: expected room for 20, have %d", policy, len(metered.Export()))
+		}
+	}
+}
+
+func TestResizeRefusesWhatCantChange(t *testing.T) {
+	lecar, _ := NewCache(LECAR, 10)
+	if _, err := Resize(lecar, 5); !errors.Is(err, ErrNotResizable) {
+		t.Fatalf("expected ErrNotResizable, got %v", err)
+	}
+	lru, _ := NewCache(LRU, 10)
+	if _, err := Resize(lru, 0); !errors.Is(err, ErrNotResizable) {
+		t.Fatalf("expected a size of 0 refused, got %v", err)
+	}
+	sharded, _ := NewFromConfig(Config{Type: LRU, Size: 40, Shards: 4})
+	for i := 0; i < 40; i++ {
+		sharded.SetValue(strconv.Itoa(i), NewEntry("v", 1))
+	}
+	held := len(sharded.(Exporter).Export())
+	evicted, err := Resize(sharded, 8)
+	left := len(sharded.(Exporter).Export())
+	if err != nil || left > 8 || evicted != held-left {
+		t.Fatalf("expected the shards shrunk to 8 between them, got %d evicted and %d left, %v", evicted, left, err)
+	}
+}
+
+func TestServerResizeCommand(t *testing.T) {
+	s := testServer("")
+	for i := 0; i < 10; i++ {
+		s.setEverywhere(strconv.Itoa(i), NewEntry("v", 1))
+	}
+	var reply bytes.Buffer
+	s.handleCommand(&reply, "resize,3")
+	if reply.String() != "RESIZED:3\nEVICTED:7\n" || len(s.meter.Export()) != 3 {
+		t.Fatalf("unexpected reply %q", reply.String())
+	}
+	reply.Reset()
+	s.handleCommand(&reply, "resize,lots")
+	if reply.String() != "Bad Size\n" {
+		t.Fatalf("unexpected reply %q", reply.String())
+	}
+}
+
+func TestResizeTakesWrapperLocks(t *testing.T) {
+	wrappers := map[string]func(Cache) Cache{
+		"indexed": func(c Cache) Cache { return NewIndexed(c) },
+		"bytes":   func(c Cache) Cache { return NewByteBounded(c, 1<<20, nil) },
+		"metered": func(c Cache) Cache { return NewMetered(NewReleasing(c, func(string, Entry) {})) },
+	}
+	for name, wrap := range wrappers {
+		lru, _ := NewCache(LRU, 100)
+		c := wrap(lru)
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				c.SetValue(strconv.Itoa(i), NewEntry("v", 1))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if _, err := Resize(c, 50+i%50); err != nil {
+					t.Errorf("%s: unexpected error %v", name, err)
+					return
+				}
+			}
+		}()
+		wg.Wait()
+		if err := CheckInvariants(lru); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+}
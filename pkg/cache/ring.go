@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+/*Ring is a consistent hash ring mapping each key
+to the node that owns it.  Every node is placed on
+the ring many times (virtual nodes) so keys spread
+out evenly and only a small slice of them move when
+membership changes*/
+type Ring struct {
+	mu     sync.RWMutex
+	vnodes int
+	points []uint32
+	owners map[uint32]string
+	nodes  map[string]bool
+}
+
+func hashKey(key string) uint32 {
+	return crc32.ChecksumIEEE([]byte(key))
+}
+
+func (r *Ring) rebuild() {
+	r.points = r.points[:0]
+	r.owners = make(map[uint32]string)
+	for node := range r.nodes {
+		for i := 0; i < r.vnodes; i++ {
+			point := hashKey(strconv.Itoa(i) + "-" + node)
+			r.points = append(r.points, point)
+			r.owners[point] = node
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+}
+
+/*Add puts a node on the ring*/
+func (r *Ring) Add(node string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nodes[node] = true
+	r.rebuild()
+}
+
+/*Remove takes a node off the ring*/
+func (r *Ring) Remove(node string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.nodes, node)
+	r.rebuild()
+}
+
+/*Nodes lists the current members in sorted order*/
+func (r *Ring) Nodes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	nodes := []string{}
+	for node := range r.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+/*Owner is the node responsible for computing and
+caching the key, or "" if the ring is empty*/
+func (r *Ring) Owner(key string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.points) == 0 {
+		return ""
+	}
+	h := hashKey(key)
+	idx := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if idx == len(r.points) {
+		// wrap around to the start of the ring
+		idx = 0
+	}
+	return r.owners[r.points[idx]]
+}
+
+/*Owners is the key's owner followed by the next distinct
+nodes clockwise around the ring, up to n of them.  These
+are the nodes a hot key gets replicated to*/
+func (r *Ring) Owners(key string, n int) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	owners := []string{}
+	if len(r.points) == 0 {
+		return owners
+	}
+	if n > len(r.nodes) {
+		n = len(r.nodes)
+	}
+	h := hashKey(key)
+	idx := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	picked := make(map[string]bool)
+	for len(owners) < n {
+		if idx == len(r.points) {
+			idx = 0
+		}
+		node := r.owners[r.points[idx]]
+		if !picked[node] {
+			picked[node] = true
+			owners = append(owners, node)
+		}
+		idx++
+	}
+	return owners
+}
+
+/*NewRing builds a ring with the given members, each
+placed vnodes times*/
+func NewRing(nodes []string, vnodes int) *Ring {
+	r := &Ring{vnodes: vnodes, nodes: make(map[string]bool)}
+	for _, node := range nodes {
+		r.nodes[node] = true
+	}
+	r.rebuild()
+	return r
+}
@@ -1,90 +1,442 @@
 package cache
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 /*ServerConf holds the cmd flags and other
 config params for parameterizing the cache
 server*/
 type ServerConf struct {
-	LogFile   *string
-	DataFile  *string
-	CacheType *string
-	CacheSize int
-	Verbose   bool
+	LogFile       *string
+	DataFile      *string
+	CacheType     *string
+	CacheSize     int
+	Verbose       bool
+	Port          int
+	Peers         []string
+	Replicas      []string
+	Self          string
+	Cluster       []string
+	Transport     Transport
+	RedisAddr     string
+	RedisChannel  string
+	HotThreshold  int
+	HotReplicas   int
+	TraceFile     string
+	TraceRate     float64
+	DefaultTTL    time.Duration
+	Config        *Config
+	SnapshotFile  string
+	SnapshotEvery time.Duration
+	HTTPPort      int
 }
 
+const defaultPort = 1234
+
 /*Entry is the thing stored in a cache, both
 the actual value of the result and the measured
-cost to recompute it*/
+cost to recompute it.  Entries are plain values (the value
+an immutable string, the rest numbers), so the Entry a
+GetValue returns is a copy that can't reach back into the
+cache and there is nothing to clone on the way in or out*/
 type Entry struct {
-	value string
-	cost  int
+	value     string
+	cost      int
+	expires   int64
+	idle      int64
+	idleUntil int64
+	local     bool
+}
+
+/*NewEntry builds an entry for callers outside the
+package, cost in whatever unit the caller measures*/
+func NewEntry(value string, cost int) Entry {
+	return Entry{value: value, cost: cost}
 }
 
+/*Value is the cached result*/
+func (e Entry) Value() string { return e.value }
+
+/*Cost is what it took to compute the value*/
+func (e Entry) Cost() int { return e.cost }
+
 /*Server is the type that listens for
 fetch requests and returns them from the data file*/
 type Server struct {
-	config  *ServerConf
-	dataset *map[string]Entry
-	logger  *log.Logger
-	cache   Cache
+	config     *ServerConf
+	dataset    *map[string]Entry
+	logger     *log.Logger
+	cache      Cache
+	peers      *Broadcaster
+	replica    *Replicator
+	cluster    *Ring
+	loads      *flightGroup
+	transport  Transport
+	hotTracker *TopK
+	hot        *hotKeys
+	meter      *Metered
 }
 
+func commandKey(messageParts []string) string {
+	if len(messageParts) < 2 {
+		return ""
+	}
+	return strings.TrimSpace(strings.Replace(messageParts[1], "\n", "", -1))
+}
+
+/*handleConnection answers one command and hangs up, unless the
+client opens with "keepalive", in which case it keeps reading
+newline terminated commands and ends each answer with END*/
 func (s *Server) handleConnection(c net.Conn) {
+	defer c.Close()
 	buf := make([]byte, 1024)
-	_, err := c.Read(buf)
+	n, err := c.Read(buf)
 	if err != nil {
 		s.logger.Println("Conn error: ", err.Error())
 		c.Write([]byte("Read Failure, check logs..."))
-		c.Close()
 		return
 	}
-	messageValue := string(bytes.Trim(buf, "\x00"))
+	messageValue := string(bytes.Trim(buf[:n], "\x00"))
+	if !strings.HasPrefix(messageValue, "keepalive\n") {
+		s.handleCommand(c, messageValue)
+		return
+	}
+	pending := strings.NewReader(strings.TrimPrefix(messageValue, "keepalive\n"))
+	reader := bufio.NewReader(io.MultiReader(pending, c))
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		s.handleCommand(c, strings.TrimRight(line, "\r\n"))
+		c.Write([]byte("END\n"))
+	}
+}
+
+func (s *Server) handleCommand(c io.Writer, messageValue string) {
 	messageParts := strings.Split(messageValue, ",")
 	command := messageParts[0]
 	if command == "fetch" {
-		fetchKey := strings.TrimSpace(strings.Replace(messageParts[1], "\n", "", -1))
+		fetchKey := commandKey(messageParts)
 		if s.config.Verbose {
 			s.logger.Println("Fetching ", fetchKey)
 		}
-		if s.cache.KeyPresent(fetchKey) {
-			if s.config.Verbose {
-				s.logger.Println("Found in cache! ", fetchKey)
-			}
-			entry, err := s.cache.GetValue(fetchKey)
-			if err != nil {
-				s.logger.Println("ERROR IN CACHE: ", err)
-				return
-			}
-			c.Write([]byte("VALUE:" + entry.value + "\n"))
-			c.Write([]byte("COST:0\n"))
-			c.Close()
+		entry, cost, ok := s.fetch(fetchKey)
+		s.writeFetchResult(c, fetchKey, entry, cost, ok)
+	} else if command == "fill" {
+		// forwarded by a peer because we own this key
+		fillKey := commandKey(messageParts)
+		entry, cost, ok := s.fillForPeer(fillKey)
+		s.writeFetchResult(c, fillKey, entry, cost, ok)
+	} else if command == "hot" {
+		// the owner wants this key spread across its replicas
+		hotKey := commandKey(messageParts)
+		s.hot.mark(hotKey)
+		c.Write([]byte("HOT:" + hotKey + "\n"))
+	} else if command == "delete" {
+		// local write, every peer has to forget the key too
+		deleteKey := commandKey(messageParts)
+		s.deleteEverywhere(deleteKey)
+		c.Write([]byte("DELETED:" + deleteKey + "\n"))
+	} else if command == "keys" || command == "invalidate_match" {
+		s.handleMatch(c, command, messageValue)
+	} else if command == "present" {
+		presentKey := commandKey(messageParts)
+		c.Write([]byte("PRESENT:" + strconv.FormatBool(s.cache.KeyPresent(presentKey)) + "\n"))
+	} else if command == "get" {
+		// cache only, a miss never loads from the dataset
+		getKey := commandKey(messageParts)
+		entry, ok := s.cached(getKey)
+		if !ok {
+			c.Write([]byte("MISS:" + getKey + "\n"))
 			return
 		}
-		entry, ok := (*s.dataset)[fetchKey]
-		if !ok {
-			s.logger.Println("No Entry for |" + fetchKey + "|")
-			c.Write([]byte("No Entry For Key: " + fetchKey + "\n"))
-		} else {
-			c.Write([]byte("VALUE:" + entry.value + "\n"))
-			c.Write([]byte("COST:" + strconv.Itoa(entry.cost) + "\n"))
-			s.cache.SetValue(fetchKey, entry)
+		s.writeFetchResult(c, getKey, entry, entry.cost, true)
+	} else if command == "set" {
+		// local write, peers drop their copies and replicas get ours
+		key, entry, err := parseSetMessage(messageValue)
+		if err != nil {
+			s.logger.Println("Bad set message: ", err)
+			c.Write([]byte("Bad Set\n"))
+			return
 		}
-		c.Close()
+		s.setEverywhere(key, entry)
+		c.Write([]byte("SET:" + key + "\n"))
+	} else if command == "replicate_set" {
+		// sent by our primary, keep the standby warm
+		key, entry, err := parseReplicateMessage(messageValue)
+		if err != nil {
+			s.logger.Println("Bad replication message: ", err)
+			c.Write([]byte("Bad Replication\n"))
+			return
+		}
+		s.cache.SetValue(key, entry)
+		c.Write([]byte("REPLICATED:" + key + "\n"))
+	} else if command == "replicate_delete" {
+		replicatedKey := commandKey(messageParts)
+		s.deleteLocal(replicatedKey)
+		c.Write([]byte("REPLICATED:" + replicatedKey + "\n"))
+	} else if command == "join" || command == "leave" || command == "migrate" {
+		s.handleMembership(c, command, messageParts, messageValue)
+	} else if command == "resize" {
+		s.handleResize(c, commandKey(messageParts))
+	} else if command == "stats" {
+		s.writeStats(c)
+	} else if command == "memory" {
+		s.writeMemory(c)
+	} else if command == "invalidate" {
+		// sent by a peer, so don't broadcast it again
+		invalidateKey := commandKey(messageParts)
+		s.deleteLocal(invalidateKey)
+		c.Write([]byte("INVALIDATED:" + invalidateKey + "\n"))
 	} else {
 		s.logger.Println("No such command: ", command)
-		c.Write([]byte("Bad Command"))
-		c.Close()
+		c.Write([]byte("Bad Command\n"))
+	}
+}
+
+func (s *Server) cached(key string) (Entry, bool) {
+	if !s.cache.KeyPresent(key) {
+		return Entry{}, false
+	}
+	if s.config.Verbose {
+		s.logger.Println("Found in cache! ", key)
+	}
+	entry, err := s.cache.GetValue(key)
+	if err != nil {
+		s.logger.Println("ERROR IN CACHE: ", err)
+		return Entry{}, false
+	}
+	return entry, true
+}
+
+/*stamped gives an entry the default TTL up front, so the
+deadline replicas are sent is the one this node keeps*/
+func (s *Server) stamped(entry Entry) Entry {
+	if entry.expires == 0 && entry.idle == 0 && s.config.DefaultTTL > 0 {
+		return entry.WithTTL(s.config.DefaultTTL)
+	}
+	return entry
+}
+
+/*fill computes a missing key from the dataset and caches
+it.  Concurrent misses on the same key share one load*/
+func (s *Server) fill(key string) (Entry, int, bool) {
+	entry, cost, err := s.loads.Do(key, func() (Entry, int, error) {
+		entry, ok := (*s.dataset)[key]
+		if !ok {
+			return Entry{}, 0, errors.New("No entry in dataset")
+		}
+		entry = s.stamped(entry)
+		s.cache.SetValue(key, entry)
+		s.replica.ReplicateSet(key, entry)
+		return entry, entry.cost, nil
+	})
+	return entry, cost, err == nil
+}
+
+/*fetch answers from the cache if it can. On a miss in
+cluster mode the key's owner does the load, so only one
+node in the cluster ever pays to recompute it*/
+func (s *Server) fetch(key string) (Entry, int, bool) {
+	entry, ok := s.cached(key)
+	if s.cluster == nil {
+		if ok {
+			return entry, 0, true
+		}
+		return s.fill(key)
+	}
+	if s.cluster.Owner(key) == s.config.Self {
+		cost := 0
+		if !ok {
+			entry, cost, ok = s.fill(key)
+		}
+		if ok {
+			s.observeOwned(key, entry)
+		}
+		return entry, cost, ok
+	}
+	if ok {
+		return entry, 0, true
+	}
+	target := s.routeMiss(key)
+	entry, cost, ok, err := s.fetchFromOwner(target, key)
+	if err == nil {
+		return entry, cost, ok
+	}
+	s.logger.Println("WARNING: ", target, " unavailable for ", key, ", loading locally: ", err)
+	return s.fill(key)
+}
+
+func (s *Server) writeFetchResult(c io.Writer, key string, entry Entry, cost int, ok bool) {
+	if !ok {
+		s.logger.Println("No Entry for |" + key + "|")
+		c.Write([]byte("No Entry For Key: " + key + "\n"))
+		return
+	}
+	c.Write([]byte("VALUE:" + entry.value + "\n"))
+	c.Write([]byte("COST:" + strconv.Itoa(cost) + "\n"))
+}
+
+/*writeStats reports the policy's hits and misses and the
+recompute cost they saved and incurred*/
+func (s *Server) writeStats(c io.Writer) {
+	stats := s.meter.Stats()
+	c.Write([]byte("POLICY:" + *s.config.CacheType + "\n"))
+	c.Write([]byte("HITS:" + strconv.FormatInt(stats.Hits, 10) + "\n"))
+	c.Write([]byte("MISSES:" + strconv.FormatInt(stats.Misses, 10) + "\n"))
+	c.Write([]byte("HIT_RATIO:" + strconv.FormatFloat(stats.HitRatio(), 'f', 4, 64) + "\n"))
+	c.Write([]byte("COST_SAVED:" + strconv.FormatInt(stats.CostSaved, 10) + "\n"))
+	c.Write([]byte("COST_MISSED:" + strconv.FormatInt(stats.CostMissed, 10) + "\n"))
+	c.Write([]byte("COST_HIT_RATIO:" + strconv.FormatFloat(stats.CostHitRatio(), 'f', 4, 64) + "\n"))
+	c.Write([]byte("EVICTIONS:" + strconv.FormatInt(stats.Evictions, 10) + "\n"))
+}
+
+/*handleResize changes the cache's size in place, evicting
+what no longer fits*/
+func (s *Server) handleResize(c io.Writer, value string) {
+	size, err := strconv.Atoi(value)
+	if err != nil {
+		c.Write([]byte("Bad Size\n"))
+		return
+	}
+	evicted, err := Resize(s.meter, size)
+	if err != nil {
+		s.logger.Println("Resize failed: ", err)
+		c.Write([]byte("Resize Failed: " + err.Error() + "\n"))
+		return
+	}
+	s.config.CacheSize = size
+	c.Write([]byte("RESIZED:" + strconv.Itoa(size) + "\n"))
+	c.Write([]byte("EVICTED:" + strconv.Itoa(evicted) + "\n"))
+}
+
+/*writeMemory reports an estimate of what the cache takes,
+by component*/
+func (s *Server) writeMemory(c io.Writer) {
+	f := MemoryUsage(s.meter, nil)
+	c.Write([]byte("ENTRIES:" + strconv.Itoa(f.Entries) + "\n"))
+	c.Write([]byte("KEY_BYTES:" + strconv.FormatInt(f.Keys, 10) + "\n"))
+	c.Write([]byte("VALUE_BYTES:" + strconv.FormatInt(f.Values, 10) + "\n"))
+	c.Write([]byte("OVERHEAD_BYTES:" + strconv.FormatInt(f.Overhead, 10) + "\n"))
+	c.Write([]byte("HISTORY_BYTES:" + strconv.FormatInt(f.History, 10) + "\n"))
+	c.Write([]byte("TOTAL_BYTES:" + strconv.FormatInt(f.Total(), 10) + "\n"))
+}
+
+/*handleMembership deals with nodes joining and leaving
+the ring and the entries that move between them as a result*/
+func (s *Server) handleMembership(c io.Writer, command string, messageParts []string, messageValue string) {
+	if s.cluster == nil {
+		c.Write([]byte("Not Clustered\n"))
+		return
+	}
+	if command == "join" {
+		node := commandKey(messageParts)
+		s.cluster.Add(node)
+		c.Write([]byte("JOINED:" + node + "\n"))
+		go s.migrateAway()
+	} else if command == "leave" {
+		node := commandKey(messageParts)
+		c.Write([]byte("LEFT:" + node + "\n"))
+		if node == s.config.Self {
+			go s.leaveCluster()
+		} else {
+			s.cluster.Remove(node)
+		}
+	} else if command == "migrate" {
+		record, err := parseMigrateMessage(messageValue)
+		if err != nil {
+			s.logger.Println("Bad migrate message: ", err)
+			c.Write([]byte("Bad Migration\n"))
+			return
+		}
+		ImportRecord(s.cache, record)
+		c.Write([]byte("MIGRATED:" + record.Key + "\n"))
+	}
+}
+
+/*handleMatch lists or deletes the keys matching a pattern
+(everything after the first comma, so regexps can have commas
+in them).  Deletes go through like the delete command would*/
+func (s *Server) handleMatch(c io.Writer, command string, messageValue string) {
+	pattern := strings.TrimSpace(strings.TrimPrefix(messageValue, command+","))
+	keys, err := s.matching(pattern)
+	if err != nil {
+		s.logger.Println("Bad pattern: ", err)
+		c.Write([]byte("Bad Pattern\n"))
+		return
+	}
+	if command == "keys" {
+		for _, key := range keys {
+			c.Write([]byte("KEY:" + key + "\n"))
+		}
+		return
+	}
+	for _, key := range keys {
+		s.deleteEverywhere(key)
+	}
+	c.Write([]byte("MATCHED:" + strconv.Itoa(len(keys)) + "\n"))
+}
+
+/*matching scans an export of the cache, which is fine for
+the odd admin command and keeps an index off the hot path*/
+func (s *Server) matching(pattern string) ([]string, error) {
+	re, err := compilePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for _, record := range s.meter.Export() {
+		if re.MatchString(record.Key) {
+			keys = append(keys, record.Key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+/*setEverywhere caches a key written here, sending it to the
+replicas and telling every peer to drop its copy*/
+func (s *Server) setEverywhere(key string, entry Entry) {
+	entry = s.stamped(entry)
+	s.cache.SetValue(key, entry)
+	s.replica.ReplicateSet(key, entry)
+	s.invalidateHot(key)
+	err := s.peers.Invalidate(key)
+	if err != nil {
+		s.logger.Println("WARNING: ", err)
+	}
+}
+
+/*deleteEverywhere drops a key written here, telling the
+replicas and every peer to forget it too*/
+func (s *Server) deleteEverywhere(key string) {
+	s.deleteLocal(key)
+	s.replica.ReplicateDelete(key)
+	s.invalidateHot(key)
+	err := s.peers.Invalidate(key)
+	if err != nil {
+		s.logger.Println("WARNING: ", err)
+	}
+}
+
+func (s *Server) deleteLocal(key string) {
+	err := s.cache.Delete(key)
+	if err != nil && s.config.Verbose {
+		s.logger.Println("Nothing to delete for ", key, ": ", err)
 	}
 }
 
@@ -92,13 +444,28 @@ func (s *Server) handleConnection(c net.Conn) {
 loop to wait for incoing connections*/
 func (s *Server) Listen() {
 	s.logger.Println("Starting cache server...")
-	ln, err := net.Listen("tcp", ":1234")
+	ln, err := net.Listen("tcp", ":"+strconv.Itoa(s.config.Port))
 	if err != nil {
 		s.logger.Fatalln("Could not start server: ", err.Error())
 		os.Exit(-1)
 	}
+	if s.cluster != nil {
+		go s.announceJoin()
+	}
+	if s.config.HTTPPort != 0 {
+		go s.listenHTTP()
+	}
+	s.Serve(ln)
+}
+
+/*Serve answers connections on a listener someone else opened,
+until it's closed*/
+func (s *Server) Serve(ln net.Listener) {
 	for {
 		conn, err := ln.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
 		if err != nil {
 			s.logger.Println("WARNING: Failed to handle request: ", err.Error())
 			continue
@@ -108,6 +475,9 @@ func (s *Server) Listen() {
 }
 
 func buildLogger(logfile *string) *log.Logger {
+	if logfile == nil || *logfile == "" {
+		return log.New(os.Stdout, "", log.LstdFlags)
+	}
 	logFile, err := os.OpenFile(*logfile, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0666)
 	if err != nil {
 		fmt.Println("ERROR ", err)
@@ -120,6 +490,10 @@ func buildLogger(logfile *string) *log.Logger {
 
 func loadDataset(datafile *string) *map[string]Entry {
 	dataMap := make(map[string]Entry)
+	if datafile == nil || *datafile == "" {
+		// a plain cache, misses stay misses
+		return &dataMap
+	}
 	dFile, err := os.OpenFile(*datafile, os.O_RDONLY, 0666)
 	if err != nil {
 		fmt.Println("ERROR opening dataset: ", err)
@@ -147,18 +521,149 @@ func loadDataset(datafile *string) *map[string]Entry {
 	return &dataMap
 }
 
+/*startRecording appends the cache's accesses to the trace
+file, flushing every second*/
+func startRecording(c Cache, path string, rate float64, logger *log.Logger) Cache {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		logger.Fatalln("Error opening trace file: ", err)
+	}
+	recorder := NewRecorder(c, file, rate)
+	go func() {
+		for range time.Tick(time.Second) {
+			err := recorder.Flush()
+			if err != nil {
+				logger.Println("Error writing trace: ", err)
+			}
+		}
+	}()
+	return recorder
+}
+
+/*restoreSnapshot loads the snapshot into the cache if
+there's one, a server starting for the first time has none*/
+func restoreSnapshot(c Cache, path string, logger *log.Logger) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		logger.Fatalln("Error opening snapshot: ", err)
+	}
+	defer file.Close()
+	err = RestoreSnapshot(file, c)
+	if err != nil {
+		logger.Fatalln("Error restoring snapshot: ", err)
+	}
+	logger.Println("Restored snapshot from ", path)
+}
+
+/*SaveSnapshot writes the cache to the snapshot file, through
+a temporary file so a crash halfway leaves the last one
+whole*/
+func (s *Server) SaveSnapshot() error {
+	if s.config.SnapshotFile == "" {
+		return nil
+	}
+	tmp := s.config.SnapshotFile + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = WriteSnapshot(file, s.meter)
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	return os.Rename(tmp, s.config.SnapshotFile)
+}
+
+/*buildCache is the policy the conf asks for, from its Config
+if it has one*/
+func buildCache(conf *ServerConf) (Cache, error) {
+	if conf.Config != nil {
+		name := conf.Config.Type.String()
+		conf.CacheType = &name
+		conf.DefaultTTL = conf.Config.TTL
+		return NewFromConfig(*conf.Config)
+	}
+	cacheType, err := ParseCacheType(*conf.CacheType)
+	if err != nil {
+		return nil, err
+	}
+	return NewCache(cacheType, conf.CacheSize, WithDefaultTTL(conf.DefaultTTL))
+}
+
 /*NewServer is a constructor for building a new server
 with config onboard */
 func NewServer(conf *ServerConf) *Server {
 	logger := buildLogger(conf.LogFile)
-	cache, err := NewCache(*conf.CacheType, conf.CacheSize)
+	if conf.Port == 0 {
+		conf.Port = defaultPort
+	}
+	if conf.HotReplicas == 0 {
+		conf.HotReplicas = 3
+	}
+	cache, err := buildCache(conf)
 	if err != nil {
 		logger.Fatalln("Error while constructing cache: ", err)
 	}
-	return &Server{
-		config:  conf,
-		dataset: loadDataset(conf.DataFile),
-		logger:  logger,
-		cache:   cache,
+	if conf.TraceFile != "" {
+		cache = startRecording(cache, conf.TraceFile, conf.TraceRate, logger)
+	}
+	// every connection gets its own goroutine, so the policy has to be shared safely
+	shared := cache
+	if _, ok := cache.(*Sharded); !ok {
+		shared = NewBatched(cache)
+	}
+	if conf.DefaultTTL > 0 || (conf.Config != nil && conf.Config.IdleTTL > 0) {
+		NewWheelJanitor(shared, time.Second)
+	}
+	meter := NewMetered(shared)
+	transport := conf.Transport
+	if transport == nil {
+		transport = defaultTransport()
+	}
+	var cluster *Ring
+	if len(conf.Cluster) > 0 {
+		cluster = NewRing(conf.Cluster, 100)
+		cluster.Add(conf.Self)
+	}
+	replicator := NewReplicator(conf.Replicas, transport, logger, 10000)
+	replicator.Start()
+	if conf.RedisAddr != "" {
+		invalidator := NewRedisInvalidator(conf.RedisAddr, conf.RedisChannel, meter, logger)
+		go invalidator.Run(time.Second)
+	}
+	if conf.SnapshotFile != "" {
+		restoreSnapshot(meter, conf.SnapshotFile, logger)
+	}
+	s := &Server{
+		config:     conf,
+		dataset:    loadDataset(conf.DataFile),
+		logger:     logger,
+		cache:      meter,
+		peers:      NewBroadcaster(conf.Peers, transport, logger),
+		replica:    replicator,
+		cluster:    cluster,
+		loads:      &flightGroup{},
+		transport:  transport,
+		hotTracker: NewTopK(100, 10000),
+		hot:        newHotKeys(hotKeyLifetime),
+		meter:      meter,
+	}
+	if conf.SnapshotFile != "" && conf.SnapshotEvery > 0 {
+		go func() {
+			for range time.Tick(conf.SnapshotEvery) {
+				err := s.SaveSnapshot()
+				if err != nil {
+					s.logger.Println("Error writing snapshot: ", err)
+				}
+			}
+		}()
 	}
+	return s
 }
@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*httpStats is what GET /stats answers with*/
+type httpStats struct {
+	Policy       string
+	Hits         int64
+	Misses       int64
+	HitRatio     float64
+	CostSaved    int64
+	CostMissed   int64
+	CostHitRatio float64
+	Evictions    int64
+	Entries      int
+	Bytes        int64
+}
+
+/*HTTPHandler is the server over HTTP, for clients that would
+rather not speak the line protocol.  GET /cache/<key> answers
+from the cache only (404 on a miss) with the cost in X-Cost;
+PUT /cache/<key> sets the body, taking cost and ttl from the
+query ("?cost=20&ttl=10m"); DELETE /cache/<key> drops it.  Writes
+and deletes go to the replicas and peers just like the line
+protocol's.  GET /keys?match=<pattern> lists the matching keys
+a line each, and GET /stats is the stats and memory as JSON*/
+func (s *Server) HTTPHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cache/", s.serveKey)
+	mux.HandleFunc("/keys", s.serveKeys)
+	mux.HandleFunc("/stats", s.serveStats)
+	return mux
+}
+
+func (s *Server) serveKey(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/cache/")
+	if key == "" {
+		http.Error(w, "No key", http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodGet {
+		entry, ok := s.cached(key)
+		if !ok {
+			http.Error(w, "No entry for key: "+key, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("X-Cost", strconv.Itoa(entry.cost))
+		io.WriteString(w, entry.value)
+	} else if r.Method == http.MethodPut || r.Method == http.MethodPost {
+		entry, err := httpEntry(r)
+		if err != nil {
+			http.Error(w, "Bad set: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.setEverywhere(key, entry)
+		w.WriteHeader(http.StatusNoContent)
+	} else if r.Method == http.MethodDelete {
+		s.deleteEverywhere(key)
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+/*httpEntry builds the entry a PUT sets*/
+func httpEntry(r *http.Request) (Entry, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return Entry{}, err
+	}
+	cost := 0
+	if param := r.URL.Query().Get("cost"); param != "" {
+		cost, err = strconv.Atoi(param)
+		if err != nil {
+			return Entry{}, err
+		}
+	}
+	entry := NewEntry(string(body), cost)
+	if param := r.URL.Query().Get("ttl"); param != "" {
+		ttl, err := time.ParseDuration(param)
+		if err != nil {
+			return Entry{}, err
+		}
+		entry = entry.WithTTL(ttl)
+	}
+	return entry, nil
+}
+
+func (s *Server) serveKeys(w http.ResponseWriter, r *http.Request) {
+	pattern := r.URL.Query().Get("match")
+	if pattern == "" {
+		pattern = "*"
+	}
+	keys, err := s.matching(pattern)
+	if err != nil {
+		http.Error(w, "Bad pattern", http.StatusBadRequest)
+		return
+	}
+	for _, key := range keys {
+		io.WriteString(w, key+"\n")
+	}
+}
+
+func (s *Server) serveStats(w http.ResponseWriter, r *http.Request) {
+	stats := s.meter.Stats()
+	f := MemoryUsage(s.meter, nil)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(httpStats{
+		Policy:       *s.config.CacheType,
+		Hits:         stats.Hits,
+		Misses:       stats.Misses,
+		HitRatio:     stats.HitRatio(),
+		CostSaved:    stats.CostSaved,
+		CostMissed:   stats.CostMissed,
+		CostHitRatio: stats.CostHitRatio(),
+		Evictions:    stats.Evictions,
+		Entries:      f.Entries,
+		Bytes:        f.Total(),
+	})
+}
+
+/*listenHTTP serves HTTPHandler on the HTTP port*/
+func (s *Server) listenHTTP() {
+	s.logger.Println("Serving HTTP on ", s.config.HTTPPort)
+	err := http.ListenAndServe(":"+strconv.Itoa(s.config.HTTPPort), s.HTTPHandler())
+	if err != nil {
+		s.logger.Println("WARNING: HTTP server stopped: ", err)
+	}
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testServer(snapshot string) *Server {
+	logger := log.New(io.Discard, "", 0)
+	lru, _ := NewCache(LRU, 10)
+	meter := NewMetered(NewBatched(lru))
+	cacheType := "LRU"
+	transport := &localTransport{nodes: make(map[string]*Server)}
+	return &Server{
+		config:     &ServerConf{CacheType: &cacheType, SnapshotFile: snapshot},
+		dataset:    &map[string]Entry{},
+		logger:     logger,
+		cache:      meter,
+		peers:      NewBroadcaster(nil, transport, logger),
+		replica:    NewReplicator(nil, transport, logger, 10),
+		loads:      &flightGroup{},
+		transport:  transport,
+		hotTracker: NewTopK(100, 0),
+		hot:        newHotKeys(hotKeyLifetime),
+		meter:      meter,
+	}
+}
+
+func TestHTTPHandlerServesTheCache(t *testing.T) {
+	s := testServer("")
+	front := httptest.NewServer(s.HTTPHandler())
+	defer front.Close()
+	req, _ := http.NewRequest(http.MethodPut, front.URL+"/cache/user:1?cost=20&ttl=1m", strings.NewReader("alice"))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected the set to succeed, got %v, %v", resp, err)
+	}
+	resp, _ = http.Get(front.URL + "/cache/user:1")
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "alice" || resp.Header.Get("X-Cost") != "20" {
+		t.Fatalf("expected alice at cost 20, got %q, %s", body, resp.Header.Get("X-Cost"))
+	}
+	resp, _ = http.Get(front.URL + "/keys?match=user:*")
+	body, _ = io.ReadAll(resp.Body)
+	if string(body) != "user:1\n" {
+		t.Fatalf("expected the key listed, got %q", body)
+	}
+	req, _ = http.NewRequest(http.MethodDelete, front.URL+"/cache/user:1", nil)
+	http.DefaultClient.Do(req)
+	resp, _ = http.Get(front.URL + "/cache/user:1")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected a miss after the delete, got %d", resp.StatusCode)
+	}
+	resp, _ = http.Get(front.URL + "/stats")
+	stats := httpStats{}
+	json.NewDecoder(resp.Body).Decode(&stats)
+	if stats.Policy != "LRU" || stats.Hits != 1 || stats.Misses != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestServerSnapshotsSurviveARestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	s := testServer(path)
+	s.setEverywhere("a", NewEntry("1", 5))
+	if err := s.SaveSnapshot(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("expected the temporary file renamed away")
+	}
+	restarted := testServer(path)
+	restoreSnapshot(restarted.meter, path, restarted.logger)
+	entry, ok := restarted.cached("a")
+	if !ok || entry.Value() != "1" || entry.Cost() != 5 {
+		t.Fatalf("expected a restored, got %v, %v", entry, ok)
+	}
+}
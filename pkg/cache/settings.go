@@ -0,0 +1,169 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*Settings is what a config file or the environment can set:
+the cache's Config and the port a server listens on*/
+type Settings struct {
+	Config
+	Port int
+}
+
+/*settingKeys are the names settings go by, in files as they
+are and in the environment upper cased after a prefix*/
+var settingKeys = []string{"type", "size", "unbounded", "max_bytes", "ttl", "idle_ttl", "shards", "learning_rate", "discount", "segment_ratio", "cost_decay", "port"}
+
+/*set parses one setting into place*/
+func (s *Settings) set(key string, value string) error {
+	var err error
+	value = strings.TrimSpace(value)
+	if key == "type" {
+		s.Type, err = ParseCacheType(value)
+	} else if key == "size" {
+		s.Size, err = strconv.Atoi(value)
+	} else if key == "unbounded" {
+		s.Unbounded, err = strconv.ParseBool(value)
+	} else if key == "max_bytes" {
+		s.MaxBytes, err = strconv.ParseInt(value, 10, 64)
+	} else if key == "ttl" {
+		s.TTL, err = time.ParseDuration(value)
+	} else if key == "idle_ttl" {
+		s.IdleTTL, err = time.ParseDuration(value)
+	} else if key == "shards" {
+		s.Shards, err = strconv.Atoi(value)
+	} else if key == "learning_rate" {
+		s.Tuning.LearningRate, err = strconv.ParseFloat(value, 64)
+	} else if key == "discount" {
+		s.Tuning.Discount, err = strconv.ParseFloat(value, 64)
+	} else if key == "segment_ratio" {
+		s.Tuning.SegmentRatio, err = strconv.ParseFloat(value, 64)
+	} else if key == "cost_decay" {
+		s.Tuning.CostDecay, err = strconv.ParseFloat(value, 64)
+	} else if key == "port" {
+		s.Port, err = strconv.Atoi(value)
+	} else {
+		return errors.New("Unknown setting '" + key + "'")
+	}
+	if err != nil {
+		return errors.New("Bad value for " + key + ": " + err.Error())
+	}
+	return nil
+}
+
+/*settingsFrom applies the values over the defaults (an LRU
+of 1000 entries on the default port), in key order so the
+first error reported doesn't depend on map order*/
+func settingsFrom(values map[string]string) (Settings, error) {
+	s := Settings{Config: Config{Type: LRU, Size: 1000}, Port: defaultPort}
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		err := s.set(key, values[key])
+		if err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+/*SettingsFromEnv reads settings from environment variables
+named by prefix and key, LCR_SIZE and LCR_TTL for the prefix
+"LCR".  Anything not set keeps its default*/
+func SettingsFromEnv(prefix string) (Settings, error) {
+	values := make(map[string]string)
+	for _, key := range settingKeys {
+		value, ok := os.LookupEnv(prefix + "_" + strings.ToUpper(key))
+		if ok {
+			values[key] = value
+		}
+	}
+	return settingsFrom(values)
+}
+
+/*LoadSettings reads settings from a json file, or a yaml one
+(by extension) holding a flat map of keys to values, which
+is all settings need.  Durations are written like "10m".
+Unknown keys are an error rather than silently ignored*/
+func LoadSettings(path string) (Settings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Settings{}, err
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	var values map[string]string
+	if ext == ".yaml" || ext == ".yml" {
+		values, err = flatYaml(data)
+	} else {
+		values, err = flatJSON(data)
+	}
+	if err != nil {
+		return Settings{}, err
+	}
+	return settingsFrom(values)
+}
+
+func flatJSON(data []byte) (map[string]string, error) {
+	raw := make(map[string]interface{})
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err := decoder.Decode(&raw)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	for key, value := range raw {
+		if s, ok := value.(string); ok {
+			values[key] = s
+		} else if n, ok := value.(json.Number); ok {
+			values[key] = n.String()
+		} else if b, ok := value.(bool); ok {
+			values[key] = strconv.FormatBool(b)
+		} else {
+			return nil, errors.New("Setting " + key + " has to be a string, number or bool")
+		}
+	}
+	return values, nil
+}
+
+/*flatYaml reads "key: value" lines, skipping blanks and #
+comments and taking quotes off values*/
+func flatYaml(data []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") || text == "---" {
+			continue
+		}
+		idx := strings.Index(text, ":")
+		if idx < 0 {
+			return nil, errors.New("Line " + strconv.Itoa(line) + " is not a key: value pair")
+		}
+		key := strings.TrimSpace(text[:idx])
+		value := strings.TrimSpace(text[idx+1:])
+		if comment := strings.Index(value, " #"); comment >= 0 {
+			value = strings.TrimSpace(value[:comment])
+		}
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[key] = value
+	}
+	return values, scanner.Err()
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSettingsFromEnv(t *testing.T) {
+	t.Setenv("LCRTEST_TYPE", "lcr")
+	t.Setenv("LCRTEST_SIZE", "500")
+	t.Setenv("LCRTEST_TTL", "10m")
+	t.Setenv("LCRTEST_SHARDS", "4")
+	t.Setenv("LCRTEST_PORT", "4321")
+	s, err := SettingsFromEnv("LCRTEST")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Settings{Config: Config{Type: LCR, Size: 500, TTL: 10 * time.Minute, Shards: 4}, Port: 4321}
+	if s != want {
+		t.Fatalf("expected %+v, got %+v", want, s)
+	}
+	t.Setenv("LCRTEST_SIZE", "lots")
+	if _, err := SettingsFromEnv("LCRTEST"); err == nil {
+		t.Fatalf("read a size that isn't a number")
+	}
+}
+
+func TestLoadSettings(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"cache.json": `{"type": "CALECAR", "size": 2000, "idle_ttl": "30m", "learning_rate": 0.2, "unbounded": false, "port": 9000}`,
+		"cache.yaml": "# the session cache\ntype: CALECAR\nsize: 2000 # entries\nidle_ttl: \"30m\"\nlearning_rate: 0.2\nport: 9000\n",
+	}
+	want := Settings{Config: Config{Type: CALECAR, Size: 2000, IdleTTL: 30 * time.Minute, Tuning: Tuning{LearningRate: 0.2}}, Port: 9000}
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(contents), 0644)
+		s, err := LoadSettings(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if s != want {
+			t.Fatalf("%s: expected %+v, got %+v", name, want, s)
+		}
+		if _, err := NewFromConfig(s.Config); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	bad := filepath.Join(dir, "bad.yml")
+	os.WriteFile(bad, []byte("type: LRU\nsise: 10\n"), 0644)
+	if _, err := LoadSettings(bad); err == nil {
+		t.Fatalf("accepted a misspelled key")
+	}
+}
@@ -0,0 +1,154 @@
+package cache
+
+import "sync"
+
+/*ShadowReport compares the cache with its shadow over the
+same reads: how many each hit and the cost those hits saved*/
+type ShadowReport struct {
+	Requests        int64
+	Hits            int64
+	ShadowHits      int64
+	CostSaved       int64
+	ShadowCostSaved int64
+}
+
+/*HitRatio is the share of reads the cache hit*/
+func (r ShadowReport) HitRatio() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	return float64(r.Hits) / float64(r.Requests)
+}
+
+/*ShadowHitRatio is the share of reads the shadow would have
+hit*/
+func (r ShadowReport) ShadowHitRatio() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	return float64(r.ShadowHits) / float64(r.Requests)
+}
+
+/*HitRatioDelta is how much better (or worse, if negative)
+the shadow's hit ratio is*/
+func (r ShadowReport) HitRatioDelta() float64 {
+	return r.ShadowHitRatio() - r.HitRatio()
+}
+
+/*CostSavedDelta is how much more recompute cost the shadow
+would have saved*/
+func (r ShadowReport) CostSavedDelta() int64 {
+	return r.ShadowCostSaved - r.CostSaved
+}
+
+/*Shadowed runs a second policy alongside the cache on the same
+operations, to see what switching to it would do before
+switching: every write goes to the shadow too, without its
+value (so it costs the shadow's bookkeeping, not another copy
+of the data), and every read is scored against both.  Report
+says how their hit ratios and the costs their hits saved
+compare.  The shadow is kept under the wrapper's own lock and
+the cache called as it is, so for concurrent use the cache
+should be safe on its own (a Batched)*/
+type Shadowed struct {
+	cache  Cache
+	mu     sync.Mutex
+	shadow Cache
+	report ShadowReport
+}
+
+/*metadata is the entry without its value, for the shadow*/
+func metadata(v Entry) Entry {
+	v.value = ""
+	return v
+}
+
+/*KeyPresent checks the cache, the shadow isn't asked since
+nothing is read*/
+func (s *Shadowed) KeyPresent(k string) bool {
+	return s.cache.KeyPresent(k)
+}
+
+/*GetValue reads from the cache, scoring the read against the
+shadow as well*/
+func (s *Shadowed) GetValue(k string) (Entry, error) {
+	entry, err := s.cache.GetValue(k)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.report.Requests++
+	if err == nil {
+		s.report.Hits++
+		s.report.CostSaved += int64(entry.cost)
+	}
+	shadowed, shadowErr := s.shadow.GetValue(k)
+	if shadowErr == nil {
+		s.report.ShadowHits++
+		s.report.ShadowCostSaved += int64(shadowed.cost)
+	}
+	return entry, err
+}
+
+/*SetValue writes to the cache and the entry's metadata to the
+shadow*/
+func (s *Shadowed) SetValue(k string, v Entry) error {
+	err := s.cache.SetValue(k, v)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shadow.SetValue(k, metadata(v))
+	return err
+}
+
+/*Delete removes the key from both*/
+func (s *Shadowed) Delete(k string) error {
+	err := s.cache.Delete(k)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shadow.Delete(k)
+	return err
+}
+
+/*Report reads the comparison so far*/
+func (s *Shadowed) Report() ShadowReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.report
+}
+
+/*Reset zeroes the comparison, leaving the shadow's contents
+as they are*/
+func (s *Shadowed) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.report = ShadowReport{}
+}
+
+/*Export lists the cache's entries, if it can*/
+func (s *Shadowed) Export() []Record {
+	exporter, ok := s.cache.(Exporter)
+	if !ok {
+		return []Record{}
+	}
+	return exporter.Export()
+}
+
+/*Import puts the record into the cache and its metadata into
+the shadow, so both start from the same snapshot*/
+func (s *Shadowed) Import(r Record) error {
+	err := ImportRecord(s.cache, r)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r.Entry = metadata(r.Entry)
+	ImportRecord(s.shadow, r)
+	return err
+}
+
+/*Unwrap is the cache being shadowed*/
+func (s *Shadowed) Unwrap() Cache {
+	return s.cache
+}
+
+/*NewShadowed runs the shadow, an empty policy of its own
+(NewCache(LCR, size) say), alongside the cache*/
+func NewShadowed(c Cache, shadow Cache) *Shadowed {
+	return &Shadowed{cache: c, shadow: shadow}
+}
@@ -0,0 +1,31 @@
+package cache
+
+import "testing"
+
+func TestShadowedComparesPolicies(t *testing.T) {
+	lru, _ := NewCache(LRU, 2)
+	lcr, _ := NewCache(LCR, 2)
+	c := NewShadowed(lru, lcr)
+	c.SetValue("report", NewEntry("big", 100))
+	for _, k := range []string{"a", "b", "report"} {
+		if k != "report" {
+			c.SetValue(k, NewEntry("small", 1))
+		}
+		c.GetValue(k)
+	}
+	report := c.Report()
+	// LRU dropped the report for b, LCR kept it and dropped a after its read
+	if report.Requests != 3 || report.Hits != 2 || report.ShadowHits != 3 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if report.CostSaved != 2 || report.ShadowCostSaved != 102 || report.CostSavedDelta() != 100 || report.HitRatioDelta() <= 0 {
+		t.Fatalf("expected the shadow to save the expensive report, got %+v", report)
+	}
+	if entry, _ := lcr.(Peeker).Peek("report"); entry.Value() != "" {
+		t.Fatalf("expected the shadow to hold no values")
+	}
+	c.Reset()
+	if c.Report().Requests != 0 {
+		t.Fatalf("expected the report reset")
+	}
+}
@@ -0,0 +1,110 @@
+package cache
+
+import "time"
+
+/*Sharded splits the keys across several independently
+locked caches by hash, so writes to different shards don't
+wait on each other.  Each shard is a Batched, so the whole
+thing is safe for concurrent use.  Removal listeners are
+called from whichever shard the key lives in, possibly from
+several goroutines at once*/
+type Sharded struct {
+	shards []*Batched
+}
+
+func (s *Sharded) shard(k string) *Batched {
+	return s.shards[hash64(k)%uint64(len(s.shards))]
+}
+
+/*KeyPresent checks the key's shard*/
+func (s *Sharded) KeyPresent(k string) bool {
+	return s.shard(k).KeyPresent(k)
+}
+
+/*GetValue reads from the key's shard*/
+func (s *Sharded) GetValue(k string) (Entry, error) {
+	return s.shard(k).GetValue(k)
+}
+
+/*SetValue writes to the key's shard*/
+func (s *Sharded) SetValue(k string, v Entry) error {
+	return s.shard(k).SetValue(k, v)
+}
+
+/*Delete removes the key from its shard*/
+func (s *Sharded) Delete(k string) error {
+	return s.shard(k).Delete(k)
+}
+
+/*OnRemoval adds the listener to every shard*/
+func (s *Sharded) OnRemoval(fn RemovalListener) {
+	for _, shard := range s.shards {
+		AddRemovalListener(shard, fn)
+	}
+}
+
+/*Export lists every shard's entries, shard by shard*/
+func (s *Sharded) Export() []Record {
+	records := []Record{}
+	for _, shard := range s.shards {
+		records = append(records, shard.Export()...)
+	}
+	return records
+}
+
+/*Import puts the record into its key's shard*/
+func (s *Sharded) Import(r Record) error {
+	return s.shard(r.Key).Import(r)
+}
+
+/*SweepExpired splits the limit between the shards*/
+func (s *Sharded) SweepExpired(limit int) int {
+	perShard := limit / len(s.shards)
+	if perShard < 1 {
+		perShard = 1
+	}
+	dropped := 0
+	for _, shard := range s.shards {
+		dropped += shard.SweepExpired(perShard)
+	}
+	return dropped
+}
+
+func (s *Sharded) startWheel(tick time.Duration) bool {
+	started := true
+	for _, shard := range s.shards {
+		started = shard.startWheel(tick) && started
+	}
+	return started
+}
+
+func (s *Sharded) sweepDue() int {
+	dropped := 0
+	for _, shard := range s.shards {
+		dropped += shard.sweepDue()
+	}
+	return dropped
+}
+
+func (s *Sharded) retime(k string, ttl time.Duration, shorten bool) error {
+	return s.shard(k).retime(k, ttl, shorten)
+}
+
+/*clock is the first shard's, build should give them all the
+same one*/
+func (s *Sharded) clock() Clock {
+	return clockOf(s.shards[0])
+}
+
+/*NewSharded builds each shard with build, which is told
+which shard it is building*/
+func NewSharded(shards int, build func(shard int) Cache) *Sharded {
+	if shards < 1 {
+		shards = 1
+	}
+	s := &Sharded{shards: make([]*Batched, shards)}
+	for i := range s.shards {
+		s.shards[i] = NewBatched(build(i))
+	}
+	return s
+}
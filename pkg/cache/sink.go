@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"errors"
+	"time"
+)
+
+/*MetricsSink is the backend a Reporting cache sends its
+metrics to, small enough to put in front of StatsD,
+OpenTelemetry or anything else.  Latencies go to Histogram in
+seconds.  It's called on every operation, from whichever
+goroutine made it, so it should be quick and safe for
+concurrent use*/
+type MetricsSink interface {
+	Counter(name string, delta int64)
+	Gauge(name string, value float64)
+	Histogram(name string, value float64)
+}
+
+/*Reporting sends a Counter for every operation's outcome and
+a Histogram of its latency to the sink, named prefix.op and
+prefix.op.outcome ("lcr.get.hit", "lcr.get.latency",
+"lcr.set.error").  Entries the policy evicts or expires are
+counted as prefix.evicted and prefix.expired.  The size is
+only gauged when ReportSize is called, since it takes an
+Export, so call it from a ticker.  It adds no locking of its
+own*/
+type Reporting struct {
+	cache  Cache
+	sink   MetricsSink
+	prefix string
+}
+
+func (r *Reporting) report(op string, outcome string, start time.Time) {
+	r.sink.Counter(r.prefix+"."+op+"."+outcome, 1)
+	r.sink.Histogram(r.prefix+"."+op+".latency", time.Since(start).Seconds())
+}
+
+/*KeyPresent checks the wrapped cache*/
+func (r *Reporting) KeyPresent(k string) bool {
+	start := time.Now()
+	present := r.cache.KeyPresent(k)
+	if present {
+		r.report("present", "hit", start)
+	} else {
+		r.report("present", "miss", start)
+	}
+	return present
+}
+
+/*GetValue reads from the wrapped cache*/
+func (r *Reporting) GetValue(k string) (Entry, error) {
+	start := time.Now()
+	entry, err := r.cache.GetValue(k)
+	if err == nil {
+		r.report("get", "hit", start)
+	} else {
+		r.report("get", "miss", start)
+	}
+	return entry, err
+}
+
+/*SetValue writes to the wrapped cache*/
+func (r *Reporting) SetValue(k string, v Entry) error {
+	start := time.Now()
+	err := r.cache.SetValue(k, v)
+	if err == nil {
+		r.report("set", "ok", start)
+	} else {
+		r.report("set", "error", start)
+	}
+	return err
+}
+
+/*Delete removes the key from the wrapped cache*/
+func (r *Reporting) Delete(k string) error {
+	start := time.Now()
+	err := r.cache.Delete(k)
+	if err == nil {
+		r.report("delete", "ok", start)
+	} else if errors.Is(err, ErrKeyNotFound) {
+		r.report("delete", "miss", start)
+	} else {
+		r.report("delete", "error", start)
+	}
+	return err
+}
+
+func (r *Reporting) onRemoval(key string, entry Entry, reason RemovalReason) {
+	if reason == Evicted {
+		r.sink.Counter(r.prefix+".evicted", 1)
+	} else if reason == Expired {
+		r.sink.Counter(r.prefix+".expired", 1)
+	}
+}
+
+/*ReportSize gauges how many entries the cache holds, as
+prefix.entries, and their bytes (as KeyValueSizer counts
+them) as prefix.bytes*/
+func (r *Reporting) ReportSize() {
+	records := r.Export()
+	bytes := int64(0)
+	for _, record := range records {
+		bytes += entryBytes(record.Key, record.Entry)
+	}
+	r.sink.Gauge(r.prefix+".entries", float64(len(records)))
+	r.sink.Gauge(r.prefix+".bytes", float64(bytes))
+}
+
+/*Export lists the wrapped cache's entries, if it can*/
+func (r *Reporting) Export() []Record {
+	exporter, ok := r.cache.(Exporter)
+	if !ok {
+		return []Record{}
+	}
+	return exporter.Export()
+}
+
+/*Import puts the record into the wrapped cache*/
+func (r *Reporting) Import(rec Record) error {
+	return ImportRecord(r.cache, rec)
+}
+
+/*Unwrap is the cache being reported on*/
+func (r *Reporting) Unwrap() Cache {
+	return r.cache
+}
+
+/*NewReporting sends the cache's metrics to the sink, every
+name starting with prefix*/
+func NewReporting(c Cache, sink MetricsSink, prefix string) *Reporting {
+	r := &Reporting{cache: c, sink: sink, prefix: prefix}
+	AddRemovalListener(c, r.onRemoval)
+	return r
+}
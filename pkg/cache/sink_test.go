@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+)
+
+/*memorySink keeps what it's sent*/
+type memorySink struct {
+	mu         sync.Mutex
+	counters   map[string]int64
+	gauges     map[string]float64
+	histograms map[string]int
+}
+
+func (s *memorySink) Counter(name string, delta int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters[name] += delta
+}
+
+func (s *memorySink) Gauge(name string, value float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gauges[name] = value
+}
+
+func (s *memorySink) Histogram(name string, value float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.histograms[name]++
+}
+
+func TestReportingSendsToTheSink(t *testing.T) {
+	sink := &memorySink{counters: map[string]int64{}, gauges: map[string]float64{}, histograms: map[string]int{}}
+	lru, _ := NewCache(LRU, 1)
+	c := NewReporting(lru, sink, "lcr")
+	c.SetValue("a", NewEntry("1", 1))
+	c.GetValue("a")
+	c.GetValue("b")
+	c.SetValue("b", NewEntry("2", 1))
+	c.Delete("c")
+	c.ReportSize()
+	if sink.counters["lcr.get.hit"] != 1 || sink.counters["lcr.get.miss"] != 1 || sink.counters["lcr.set.ok"] != 2 {
+		t.Fatalf("unexpected counters %v", sink.counters)
+	}
+	if sink.counters["lcr.evicted"] != 1 || sink.counters["lcr.delete.miss"] != 1 {
+		t.Fatalf("expected the eviction and the missing delete counted, got %v", sink.counters)
+	}
+	if sink.histograms["lcr.get.latency"] != 2 || sink.gauges["lcr.entries"] != 1 || sink.gauges["lcr.bytes"] != 2 {
+		t.Fatalf("unexpected histograms %v and gauges %v", sink.histograms, sink.gauges)
+	}
+}
@@ -0,0 +1,216 @@
+package cache
+
+import "time"
+
+/*defaultProtectedRatio is the share of SLRU's size the
+protected segment gets unless Tuning says otherwise*/
+const defaultProtectedRatio = 0.8
+
+/*Slru is a segmented LRU: new keys land in a probation
+segment, and a key read again there is promoted to a
+protected one, which holds up to its share of the size.  What
+the protected segment pushes out is demoted back to probation
+rather than lost, probation has whatever room protected isn't
+using, and only what probation evicts leaves the cache, so a
+burst of keys read once can't flush the ones read repeatedly.
+Like Tiered its
+removal listeners only hear about entries leaving both
+segments, and it isn't safe for concurrent use*/
+type Slru struct {
+	size      int
+	probation *Lru
+	protected *Lru
+	moving    bool
+	removalHooks
+}
+
+/*split caps the protected segment at ratio of the size,
+leaving probation at least one entry*/
+func (s *Slru) split(ratio float64) {
+	if s.size == unboundedSize {
+		return
+	}
+	protected := int(float64(s.size) * ratio)
+	if protected > s.size-1 {
+		protected = s.size - 1
+	}
+	if protected < 0 {
+		protected = 0
+	}
+	s.protected.maxSize = protected
+	s.fit()
+}
+
+/*fit gives probation the room protected isn't using,
+evicting from it if protected just took some*/
+func (s *Slru) fit() {
+	if s.size == unboundedSize {
+		return
+	}
+	s.probation.maxSize = s.size - s.protected.length
+	evictDown(s.probation, s.probation.length-s.probation.maxSize)
+}
+
+/*promote moves the entry from probation into the protected
+segment, if it has any room at all*/
+func (s *Slru) promote(k string, entry Entry) error {
+	if s.protected.maxSize == 0 {
+		return s.probation.SetValue(k, entry)
+	}
+	s.moving = true
+	s.probation.Delete(k)
+	s.moving = false
+	// the protected segment's eviction to make room is demoted as usual
+	err := s.protected.SetValue(k, entry)
+	s.fit()
+	return err
+}
+
+/*onProtectedRemoval demotes what the protected segment
+evicts*/
+func (s *Slru) onProtectedRemoval(key string, entry Entry, reason RemovalReason) {
+	if s.moving {
+		return
+	}
+	if reason == Evicted {
+		s.moving = true
+		s.probation.SetValue(key, entry)
+		s.moving = false
+		return
+	}
+	s.removed(key, entry, reason)
+	s.fit()
+}
+
+func (s *Slru) onProbationRemoval(key string, entry Entry, reason RemovalReason) {
+	if s.moving && reason != Evicted {
+		// promoted, or dropped to leave the cache through remove
+		return
+	}
+	s.removed(key, entry, reason)
+}
+
+/*KeyPresent is true if either segment has the key*/
+func (s *Slru) KeyPresent(k string) bool {
+	return s.protected.KeyPresent(k) || s.probation.KeyPresent(k)
+}
+
+/*GetValue reads from the protected segment, or promotes the
+entry from probation*/
+func (s *Slru) GetValue(k string) (Entry, error) {
+	entry, err := s.protected.GetValue(k)
+	if err == nil {
+		return entry, nil
+	}
+	entry, err = s.probation.GetValue(k)
+	if err != nil {
+		return entry, err
+	}
+	if s.protected.maxSize > 0 {
+		s.promote(k, entry)
+	}
+	return entry, nil
+}
+
+/*Peek finds the entry in either segment without moving it*/
+func (s *Slru) Peek(k string) (Entry, bool) {
+	entry, ok := s.protected.Peek(k)
+	if ok {
+		return entry, true
+	}
+	return s.probation.Peek(k)
+}
+
+/*SetValue replaces a protected key where it is, anything
+else goes on probation*/
+func (s *Slru) SetValue(k string, v Entry) error {
+	if s.protected.resident(k) != nil {
+		err := s.protected.SetValue(k, v)
+		s.fit()
+		return err
+	}
+	return s.probation.SetValue(k, v)
+}
+
+/*Delete removes the key from whichever segment has it*/
+func (s *Slru) Delete(k string) error {
+	return s.remove(k, Deleted)
+}
+
+/*remove takes the key out of whichever segment has it,
+telling listeners why*/
+func (s *Slru) remove(k string, reason RemovalReason) error {
+	entry, ok := s.Peek(k)
+	s.moving = true
+	err := s.protected.Delete(k)
+	if err != nil {
+		err = s.probation.Delete(k)
+	}
+	s.moving = false
+	s.fit()
+	if err == nil && ok {
+		s.removed(k, entry, reason)
+	}
+	return err
+}
+
+/*Export lists probation's entries and then the protected
+ones, the order they'd leave in.  Protected entries count two
+hits, so Import puts them back where they were*/
+func (s *Slru) Export() []Record {
+	records := s.probation.Export()
+	for _, record := range s.protected.Export() {
+		record.Hits = 2
+		records = append(records, record)
+	}
+	return records
+}
+
+/*Import puts a record read more than once in the protected
+segment, anything else on probation*/
+func (s *Slru) Import(r Record) error {
+	if r.Hits > 1 && s.protected.resident(r.Key) == nil {
+		return s.promote(r.Key, r.Entry)
+	}
+	return s.SetValue(r.Key, r.Entry)
+}
+
+/*SweepExpired splits the limit between the segments*/
+func (s *Slru) SweepExpired(limit int) int {
+	half := (limit + 1) / 2
+	return sweepExpired(s.probation, half) + sweepExpired(s.protected, half)
+}
+
+func (s *Slru) startWheel(tick time.Duration) bool {
+	return startWheel(s.probation, tick) && startWheel(s.protected, tick)
+}
+
+func (s *Slru) sweepDue() int {
+	return sweepDue(s.probation) + sweepDue(s.protected)
+}
+
+func (s *Slru) retime(k string, ttl time.Duration, shorten bool) error {
+	err := retime(s.protected, k, ttl, shorten)
+	if err == nil {
+		return nil
+	}
+	return retime(s.probation, k, ttl, shorten)
+}
+
+func (s *Slru) setDefaults(o cacheOptions) {
+	applyOptions(s.probation, o)
+	applyOptions(s.protected, o)
+}
+
+func (s *Slru) clock() Clock {
+	return s.probation.clock()
+}
+
+/*newSlru is the SLRU type, split by the default ratio*/
+func newSlru(size int) *Slru {
+	s := &Slru{size: size, probation: newLru(size), protected: newLru(size)}
+	s.split(defaultProtectedRatio)
+	s.probation.OnRemoval(s.onProbationRemoval)
+	s.protected.OnRemoval(s.onProtectedRemoval)
+	return s
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestSlruProtectsKeysReadAgain(t *testing.T) {
+	c, _ := NewCache(SLRU, 10)
+	evicted := []string{}
+	AddRemovalListener(c, func(key string, entry Entry, reason RemovalReason) {
+		if reason == Evicted {
+			evicted = append(evicted, key)
+		}
+	})
+	for _, k := range []string{"hot1", "hot2"} {
+		c.SetValue(k, NewEntry("v", 1))
+		c.GetValue(k)
+	}
+	// a scan of keys read once only churns the probation segment
+	for i := 0; i < 100; i++ {
+		c.SetValue("scan"+strconv.Itoa(i), NewEntry("v", 1))
+	}
+	if !c.KeyPresent("hot1") || !c.KeyPresent("hot2") {
+		t.Fatal("the scan flushed the protected keys")
+	}
+	if len(evicted) != 92 {
+		t.Fatalf("expected all but the 8 scanned keys probation has room for evicted, heard %d", len(evicted))
+	}
+	if err := CheckInvariants(c); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSlruDemotesWhatProtectedPushesOut(t *testing.T) {
+	c, _ := NewTunedCache(SLRU, 4, Tuning{SegmentRatio: 0.5})
+	slru := c.(*Slru)
+	if slru.protected.maxSize != 2 || slru.probation.maxSize != 4 {
+		t.Fatalf("expected 2 protected and probation the rest, got %d and %d", slru.protected.maxSize, slru.probation.maxSize)
+	}
+	for _, k := range []string{"a", "b", "c"} {
+		c.SetValue(k, NewEntry("v", 1))
+		c.GetValue(k)
+	}
+	// c's promotion pushed a out of protected, back on probation
+	if slru.protected.resident("a") != nil || slru.probation.resident("a") == nil {
+		t.Fatal("expected a demoted to probation")
+	}
+	c.SetValue("d", NewEntry("v", 1))
+	records := slru.Export()
+	restored := newSlru(4)
+	restored.split(0.5)
+	for _, record := range records {
+		restored.Import(record)
+	}
+	for _, k := range []string{"b", "c"} {
+		if restored.protected.resident(k) == nil {
+			t.Fatalf("expected %s back in the protected segment", k)
+		}
+	}
+	if exportedKeys(restored) != exportedKeys(slru) {
+		t.Fatalf("expected %q restored, got %q", exportedKeys(slru), exportedKeys(restored))
+	}
+	if err := CheckInvariants(restored); err != nil {
+		t.Fatal(err)
+	}
+}
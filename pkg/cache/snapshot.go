@@ -0,0 +1,246 @@
+package cache
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+/*ErrBadSnapshot is what reading a snapshot wraps when the
+stream isn't one, or is from a version this can't read*/
+var ErrBadSnapshot = errors.New("Not a cache snapshot")
+
+const snapshotMagic = "lcr-snapshot"
+const snapshotVersion = 1
+
+/*snapshotHeader opens a snapshot, Records is how many records
+the writer meant to follow it*/
+type snapshotHeader struct {
+	Magic   string
+	Version int
+	Records int
+}
+
+/*snapshotRecord is a Record on disk, with the entry's fields
+spelled out since gob can't see unexported ones*/
+type snapshotRecord struct {
+	Key       string
+	Value     string
+	Cost      int
+	Expires   int64
+	Idle      int64
+	IdleUntil int64
+	Hits      int
+}
+
+func toSnapshotRecord(r Record) snapshotRecord {
+	return snapshotRecord{
+		Key:       r.Key,
+		Value:     r.Entry.value,
+		Cost:      r.Entry.cost,
+		Expires:   r.Entry.expires,
+		Idle:      r.Entry.idle,
+		IdleUntil: r.Entry.idleUntil,
+		Hits:      r.Hits,
+	}
+}
+
+func (s snapshotRecord) record() Record {
+	entry := Entry{value: s.Value, cost: s.Cost, expires: s.Expires, idle: s.Idle, idleUntil: s.IdleUntil}
+	return Record{Key: s.Key, Entry: entry, Hits: s.Hits}
+}
+
+/*WriteSnapshot writes the cache's Export to w, in eviction
+order with each entry's deadlines and hits, so RestoreSnapshot
+can rebuild it later or somewhere else.  The cache has to be an
+Exporter; for one in use, take it from a locked wrapper*/
+func WriteSnapshot(w io.Writer, c Cache) error {
+	exporter, ok := c.(Exporter)
+	if !ok {
+		return fmt.Errorf("%w, the cache can't export its entries", ErrBadSnapshot)
+	}
+	records := exporter.Export()
+	enc := gob.NewEncoder(w)
+	err := enc.Encode(snapshotHeader{Magic: snapshotMagic, Version: snapshotVersion, Records: len(records)})
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		err = enc.Encode(toSnapshotRecord(record))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*readSnapshot decodes the header and then records until the
+stream ends, returning what it got before anything went wrong*/
+func readSnapshot(r io.Reader) (snapshotHeader, []Record, error) {
+	dec := gob.NewDecoder(r)
+	header := snapshotHeader{}
+	err := dec.Decode(&header)
+	if err != nil {
+		return header, nil, fmt.Errorf("%w, %s", ErrBadSnapshot, err.Error())
+	}
+	if header.Magic != snapshotMagic || header.Version != snapshotVersion {
+		return header, nil, fmt.Errorf("%w, header %q version %d", ErrBadSnapshot, header.Magic, header.Version)
+	}
+	records := []Record{}
+	for {
+		rec := snapshotRecord{}
+		err = dec.Decode(&rec)
+		if err == io.EOF {
+			return header, records, nil
+		}
+		if err != nil {
+			return header, records, err
+		}
+		records = append(records, rec.record())
+	}
+}
+
+/*ReadSnapshot reads the records WriteSnapshot wrote, in the
+order it wrote them*/
+func ReadSnapshot(r io.Reader) ([]Record, error) {
+	header, records, err := readSnapshot(r)
+	if err != nil {
+		return records, err
+	}
+	if len(records) != header.Records {
+		return records, fmt.Errorf("%w, %d of %d records", ErrBadSnapshot, len(records), header.Records)
+	}
+	return records, nil
+}
+
+/*RestoreSnapshot imports a snapshot's records into the cache
+in order, keeping their metadata where the cache knows how.
+Entries that expired while the snapshot sat on disk are
+skipped*/
+func RestoreSnapshot(r io.Reader, c Cache) error {
+	records, err := ReadSnapshot(r)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if record.Entry.expired(processClock{}) {
+			continue
+		}
+		err = ImportRecord(c, record)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*Distribution summarizes a set of values from a snapshot*/
+type Distribution struct {
+	Min  int
+	Max  int
+	Mean float64
+	P50  int
+	P90  int
+	P99  int
+}
+
+func distribution(values []int) Distribution {
+	if len(values) == 0 {
+		return Distribution{}
+	}
+	sorted := append([]int{}, values...)
+	sort.Ints(sorted)
+	total := 0
+	for _, v := range sorted {
+		total += v
+	}
+	at := func(p float64) int {
+		return sorted[int(p*float64(len(sorted)-1))]
+	}
+	return Distribution{
+		Min:  sorted[0],
+		Max:  sorted[len(sorted)-1],
+		Mean: float64(total) / float64(len(sorted)),
+		P50:  at(0.5),
+		P90:  at(0.9),
+		P99:  at(0.99),
+	}
+}
+
+/*SnapshotReport is what InspectSnapshot found in a snapshot.
+Declared is how many records the header promised and Records
+how many could be read, Truncated is set if they differ or
+the stream broke off (Error says how).  Duplicates are keys
+written more than once, which an Export never does.  Orphans
+are records that couldn't be restored as written: an empty
+key, a negative cost or hit count, or an idle deadline without
+an idle TTL.  Expired counts entries whose deadline passed
+since the snapshot was taken, which a restore skips*/
+type SnapshotReport struct {
+	Declared   int
+	Records    int
+	Truncated  bool
+	Error      string
+	Duplicates []string
+	Orphans    []string
+	Expired    int
+	WithTTL    int
+	Bytes      int64
+	Cost       Distribution
+	Hits       Distribution
+}
+
+/*Consistent is true if nothing in the snapshot needs looking
+at; expired entries are expected and don't count*/
+func (sr SnapshotReport) Consistent() bool {
+	return !sr.Truncated && len(sr.Duplicates) == 0 && len(sr.Orphans) == 0
+}
+
+func orphaned(r Record) bool {
+	return r.Key == "" || r.Entry.cost < 0 || r.Hits < 0 || (r.Entry.idle == 0 && r.Entry.idleUntil != 0)
+}
+
+/*InspectSnapshot reads a snapshot the way fsck reads a disk,
+reporting on everything it can read rather than stopping at
+the first problem.  The error is only for a stream that isn't
+a snapshot at all (ErrBadSnapshot); a damaged one comes back as
+a report*/
+func InspectSnapshot(r io.Reader) (SnapshotReport, error) {
+	header, records, err := readSnapshot(r)
+	if records == nil {
+		return SnapshotReport{}, err
+	}
+	report := SnapshotReport{Declared: header.Records, Records: len(records)}
+	if err != nil {
+		report.Truncated = true
+		report.Error = err.Error()
+	} else if len(records) != header.Records {
+		report.Truncated = true
+	}
+	seen := map[string]int{}
+	costs := make([]int, 0, len(records))
+	hits := make([]int, 0, len(records))
+	for _, record := range records {
+		seen[record.Key]++
+		if seen[record.Key] == 2 {
+			report.Duplicates = append(report.Duplicates, record.Key)
+		}
+		if orphaned(record) {
+			report.Orphans = append(report.Orphans, record.Key)
+		}
+		if record.Entry.deadline() != 0 {
+			report.WithTTL++
+			if record.Entry.expired(processClock{}) {
+				report.Expired++
+			}
+		}
+		report.Bytes += entryBytes(record.Key, record.Entry)
+		costs = append(costs, record.Entry.cost)
+		hits = append(hits, record.Hits)
+	}
+	report.Cost = distribution(costs)
+	report.Hits = distribution(hits)
+	return report, nil
+}
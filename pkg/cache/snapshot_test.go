@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRoundTrips(t *testing.T) {
+	clock := useManualClock(t)
+	lfu, _ := NewCache(LFU, 10)
+	lfu.SetValue("a", NewEntry("1", 3))
+	lfu.SetValue("b", NewEntry("2", 5).WithTTL(time.Minute))
+	lfu.SetValue("c", NewEntry("3", 1).WithTTL(time.Hour))
+	lfu.GetValue("a")
+	lfu.GetValue("a")
+	hits := lfu.(Exporter).Export()[2].Hits
+	buf := &bytes.Buffer{}
+	if err := WriteSnapshot(buf, lfu); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	clock.Advance(2 * time.Minute)
+	restored, _ := NewCache(LFU, 10)
+	if err := RestoreSnapshot(bytes.NewReader(buf.Bytes()), restored); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if restored.KeyPresent("b") || !restored.KeyPresent("c") {
+		t.Fatalf("expected only the expired entry skipped")
+	}
+	for _, record := range restored.(Exporter).Export() {
+		if record.Key == "a" && (record.Hits != hits || record.Entry.Cost() != 3) {
+			t.Fatalf("expected a's hits and cost kept, got %+v", record)
+		}
+	}
+}
+
+func TestInspectSnapshotReportsDamage(t *testing.T) {
+	useManualClock(t)
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+	enc.Encode(snapshotHeader{Magic: snapshotMagic, Version: snapshotVersion, Records: 5})
+	enc.Encode(snapshotRecord{Key: "a", Value: "1", Cost: 2, Hits: 1})
+	enc.Encode(snapshotRecord{Key: "b", Value: "22", Cost: 4, Hits: 3})
+	enc.Encode(snapshotRecord{Key: "a", Value: "1", Cost: 6, Hits: 5})
+	enc.Encode(snapshotRecord{Key: "", Value: "x", Cost: 8, IdleUntil: 10})
+	report, err := InspectSnapshot(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if report.Consistent() || !report.Truncated || report.Declared != 5 || report.Records != 4 {
+		t.Fatalf("expected a short snapshot, got %+v", report)
+	}
+	if len(report.Duplicates) != 1 || report.Duplicates[0] != "a" || len(report.Orphans) != 1 {
+		t.Fatalf("expected a duplicate and an orphan, got %+v", report)
+	}
+	if report.Cost.Min != 2 || report.Cost.Max != 8 || report.Cost.Mean != 5 || report.Hits.P50 != 1 || report.Bytes != 8 {
+		t.Fatalf("unexpected distributions %+v", report)
+	}
+	cut := buf.Bytes()[:buf.Len()-3]
+	report, err = InspectSnapshot(bytes.NewReader(cut))
+	if err != nil || report.Records != 3 || report.Error == "" {
+		t.Fatalf("expected the broken record reported, got %+v, %v", report, err)
+	}
+	if _, err = InspectSnapshot(strings.NewReader("not a snapshot")); !errors.Is(err, ErrBadSnapshot) {
+		t.Fatalf("expected ErrBadSnapshot, got %v", err)
+	}
+}
@@ -0,0 +1,196 @@
+package cache
+
+import "time"
+
+/*Tiered is a small fast tier in front of a big one, an LRU
+over an LCR usually.  New entries land in the hot tier, and
+what it evicts is demoted to the cold tier rather than lost; a
+hit in the cold tier promotes the entry back up.  Each entry
+lives in one tier at a time, so the whole holds both sizes'
+worth.  Its removal listeners only hear about entries leaving
+both tiers, not moving between them.  Like the policies it's
+built from it isn't safe for concurrent use, put it in a
+Batched*/
+type Tiered struct {
+	hot        Cache
+	cold       Cache
+	hotPeeker  Peeker
+	coldPeeker Peeker
+	moving     bool
+	replacing  bool
+	removalHooks
+}
+
+/*onHotRemoval demotes what the hot tier evicts*/
+func (t *Tiered) onHotRemoval(key string, entry Entry, reason RemovalReason) {
+	if t.moving {
+		return
+	}
+	if reason == Evicted {
+		t.moving = true
+		t.cold.SetValue(key, entry)
+		t.moving = false
+		return
+	}
+	t.removed(key, entry, reason)
+}
+
+func (t *Tiered) onColdRemoval(key string, entry Entry, reason RemovalReason) {
+	if reason == Deleted && t.replacing {
+		reason = Replaced
+	} else if t.moving && reason != Evicted {
+		// promoted, or a demotion replacing a stale copy
+		return
+	}
+	t.removed(key, entry, reason)
+}
+
+/*KeyPresent is true if either tier has the key*/
+func (t *Tiered) KeyPresent(k string) bool {
+	return t.hot.KeyPresent(k) || t.cold.KeyPresent(k)
+}
+
+/*GetValue reads from the hot tier, or promotes the entry from
+the cold one*/
+func (t *Tiered) GetValue(k string) (Entry, error) {
+	entry, err := t.hot.GetValue(k)
+	if err == nil {
+		return entry, nil
+	}
+	entry, err = t.cold.GetValue(k)
+	if err != nil {
+		return entry, err
+	}
+	t.moving = true
+	t.cold.Delete(k)
+	t.moving = false
+	// the hot tier's eviction to make room is demoted as usual
+	t.hot.SetValue(k, entry)
+	return entry, nil
+}
+
+/*Peek finds the entry in either tier without moving it*/
+func (t *Tiered) Peek(k string) (Entry, bool) {
+	if t.hotPeeker != nil {
+		entry, ok := t.hotPeeker.Peek(k)
+		if ok {
+			return entry, true
+		}
+	}
+	if t.coldPeeker != nil {
+		return t.coldPeeker.Peek(k)
+	}
+	return Entry{}, false
+}
+
+/*SetValue writes to the hot tier, dropping any older copy in
+the cold one*/
+func (t *Tiered) SetValue(k string, v Entry) error {
+	t.replacing = true
+	t.cold.Delete(k)
+	t.replacing = false
+	return t.hot.SetValue(k, v)
+}
+
+/*Delete removes the key from whichever tier has it*/
+func (t *Tiered) Delete(k string) error {
+	return t.remove(k, Deleted)
+}
+
+/*remove takes the key out of whichever tier has it for good,
+telling listeners why*/
+func (t *Tiered) remove(k string, reason RemovalReason) error {
+	entry, ok := t.Peek(k)
+	t.moving = true
+	err := t.hot.Delete(k)
+	if err != nil {
+		err = t.cold.Delete(k)
+	}
+	t.moving = false
+	if err == nil && ok {
+		t.removed(k, entry, reason)
+	}
+	return err
+}
+
+/*Export lists the cold tier's entries and then the hot
+tier's, the order they'd leave in*/
+func (t *Tiered) Export() []Record {
+	records := []Record{}
+	for _, tier := range []Cache{t.cold, t.hot} {
+		exporter, ok := tier.(Exporter)
+		if ok {
+			records = append(records, exporter.Export()...)
+		}
+	}
+	return records
+}
+
+/*Import puts the record in the hot tier, demoting what it
+pushes out, so a snapshot from Export comes back in the same
+tiers*/
+func (t *Tiered) Import(r Record) error {
+	t.replacing = true
+	t.cold.Delete(r.Key)
+	t.replacing = false
+	return ImportRecord(t.hot, r)
+}
+
+/*SweepExpired splits the limit between the tiers*/
+func (t *Tiered) SweepExpired(limit int) int {
+	half := (limit + 1) / 2
+	return sweepExpired(t.hot, half) + sweepExpired(t.cold, half)
+}
+
+func (t *Tiered) startWheel(tick time.Duration) bool {
+	return startWheel(t.hot, tick) && startWheel(t.cold, tick)
+}
+
+func (t *Tiered) sweepDue() int {
+	return sweepDue(t.hot) + sweepDue(t.cold)
+}
+
+func (t *Tiered) retime(k string, ttl time.Duration, shorten bool) error {
+	err := retime(t.hot, k, ttl, shorten)
+	if err == nil {
+		return nil
+	}
+	return retime(t.cold, k, ttl, shorten)
+}
+
+func (t *Tiered) setDefaults(o cacheOptions) {
+	applyOptions(t.hot, o)
+	applyOptions(t.cold, o)
+}
+
+func (t *Tiered) clock() Clock {
+	return clockOf(t.hot)
+}
+
+/*NewTiered puts the hot cache in front of the cold one, both
+should be empty and neither shared*/
+func NewTiered(hot Cache, cold Cache) *Tiered {
+	t := &Tiered{hot: hot, cold: cold}
+	t.hotPeeker, _ = hot.(Peeker)
+	t.coldPeeker, _ = cold.(Peeker)
+	AddRemovalListener(hot, t.onHotRemoval)
+	AddRemovalListener(cold, t.onColdRemoval)
+	return t
+}
+
+/*newTiered is the TIERED type: an LRU of a tenth of the size
+over an LCR of the rest, at least one entry in each*/
+func newTiered(size int) *Tiered {
+	if size == unboundedSize {
+		return NewTiered(newLru(size), newLcr(size))
+	}
+	hot := size / 10
+	if hot < 1 {
+		hot = 1
+	}
+	cold := size - hot
+	if cold < 1 {
+		cold = 1
+	}
+	return NewTiered(newLru(hot), newLcr(cold))
+}
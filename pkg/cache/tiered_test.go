@@ -0,0 +1,38 @@
+package cache
+
+import "testing"
+
+func TestTieredDemotesAndPromotes(t *testing.T) {
+	c, _ := NewCache(TIERED, 4)
+	tiered := c.(*Tiered)
+	removed := []string{}
+	AddRemovalListener(c, func(key string, entry Entry, reason RemovalReason) {
+		removed = append(removed, key+" "+reason.String())
+	})
+	c.SetValue("a", NewEntry("a", 50))
+	c.SetValue("b", NewEntry("b", 1))
+	if !tiered.cold.KeyPresent("a") || !tiered.hot.KeyPresent("b") {
+		t.Fatalf("expected a demoted to the cold tier when b came in")
+	}
+	if entry, err := c.GetValue("a"); err != nil || entry.Cost() != 50 {
+		t.Fatalf("expected a from the cold tier, got %v", err)
+	}
+	if !tiered.hot.KeyPresent("a") || !tiered.cold.KeyPresent("b") || tiered.cold.KeyPresent("a") {
+		t.Fatalf("expected a promoted and b demoted in its place")
+	}
+	c.SetValue("c", NewEntry("c", 10))
+	c.SetValue("d", NewEntry("d", 10))
+	c.SetValue("e", NewEntry("e", 10))
+	// the cold tier holds three and evicts the cheapest, b
+	if len(removed) != 1 || removed[0] != "b EVICTED" {
+		t.Fatalf("expected only b to leave both tiers, got %v", removed)
+	}
+	c.SetValue("c", NewEntry("c2", 10))
+	c.Delete("d")
+	if len(removed) != 3 || removed[1] != "c REPLACED" || removed[2] != "d DELETED" {
+		t.Fatalf("unexpected removals %v", removed)
+	}
+	if len(c.(Exporter).Export()) != 3 {
+		t.Fatalf("expected three entries left")
+	}
+}
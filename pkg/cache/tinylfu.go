@@ -0,0 +1,107 @@
+package cache
+
+/*sketchDepth is how many rows the count-min sketch has, each
+hashed differently*/
+const sketchDepth = 4
+
+/*sketchMax is where a counter stops, 4 bits' worth*/
+const sketchMax = 15
+
+/*countMinSketch estimates how often keys were seen in a
+fixed amount of space: each key bumps one counter per row,
+and its estimate is the lowest of them, so collisions can
+only push an estimate up*/
+type countMinSketch struct {
+	rows [sketchDepth][]uint8
+	mask uint64
+}
+
+func (s *countMinSketch) index(k string, row int) uint64 {
+	// double hashing, the two hashes don't collide together
+	return (hash64(k) + uint64(row)*uint64(fingerprint32(k)|1)) & s.mask
+}
+
+func (s *countMinSketch) add(k string) {
+	for row := range s.rows {
+		i := s.index(k, row)
+		if s.rows[row][i] < sketchMax {
+			s.rows[row][i]++
+		}
+	}
+}
+
+func (s *countMinSketch) estimate(k string) int {
+	min := sketchMax
+	for row := range s.rows {
+		count := int(s.rows[row][s.index(k, row)])
+		if count < min {
+			min = count
+		}
+	}
+	return min
+}
+
+/*halve ages every counter so old popularity fades*/
+func (s *countMinSketch) halve() {
+	for row := range s.rows {
+		for i := range s.rows[row] {
+			s.rows[row][i] /= 2
+		}
+	}
+}
+
+func newCountMinSketch(width int) *countMinSketch {
+	size := 16
+	for size < width {
+		size *= 2
+	}
+	s := &countMinSketch{mask: uint64(size - 1)}
+	for row := range s.rows {
+		s.rows[row] = make([]uint8, size)
+	}
+	return s
+}
+
+/*TinyLFU is an Admission that keeps a new key out of a full
+cache unless it has been asked for more often than the entry
+it would evict.  Accesses are counted in a count-min sketch
+sized for the cache, and every counter is halved once there
+have been ten times the capacity's worth of them, so the
+counts follow what's popular now.  Under LECAR and CALECAR,
+which evict by chance, it has to beat every entry they might
+pick.  It isn't safe for concurrent use on its own, the
+Admitting it's given to locks around it*/
+type TinyLFU struct {
+	sketch *countMinSketch
+	seen   int
+	window int
+}
+
+/*Record counts one access to the key*/
+func (t *TinyLFU) Record(key string) {
+	t.sketch.add(key)
+	t.seen++
+	if t.seen >= t.window {
+		t.sketch.halve()
+		t.seen = t.seen / 2
+	}
+}
+
+/*Admit is true if the candidate has been seen more often than
+the victim*/
+func (t *TinyLFU) Admit(candidate Record, victim Record) bool {
+	return t.sketch.estimate(candidate.Key) > t.sketch.estimate(victim.Key)
+}
+
+/*Estimate is about how often the key has been seen lately*/
+func (t *TinyLFU) Estimate(key string) int {
+	return t.sketch.estimate(key)
+}
+
+/*NewTinyLFU sizes the sketch for a cache of capacity entries*/
+func NewTinyLFU(capacity int) *TinyLFU {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &TinyLFU{sketch: newCountMinSketch(capacity), window: 10 * capacity}
+}
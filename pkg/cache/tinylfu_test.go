@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTinyLFUKeepsOneHitWondersOut(t *testing.T) {
+	for _, cacheType := range []CacheType{LRU, FIFO, LCR} {
+		policy, _ := NewCache(cacheType, 2)
+		c := NewAdmitting(policy, NewTinyLFU(2))
+		c.SetValue("a", NewEntry("a", 1))
+		c.SetValue("b", NewEntry("b", 1))
+		for i := 0; i < 3; i++ {
+			c.GetValue("a")
+			c.GetValue("b")
+		}
+		err := c.SetValue("scan", NewEntry("scan", 1))
+		if !errors.Is(err, ErrNotAdmitted) || !errors.Is(err, ErrCacheFull) || c.KeyPresent("scan") {
+			t.Fatalf("%s: expected a key seen once kept out, got %v", cacheType, err)
+		}
+		if !c.KeyPresent("a") || !c.KeyPresent("b") {
+			t.Fatalf("%s: expected the popular keys kept", cacheType)
+		}
+		for i := 0; i < 6; i++ {
+			c.GetValue("hot")
+		}
+		if err := c.SetValue("hot", NewEntry("hot", 1)); err != nil || !c.KeyPresent("hot") {
+			t.Fatalf("%s: expected a key asked for often admitted, got %v", cacheType, err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"sort"
+	"sync"
+)
+
+/*KeyCount is one tracked key and its estimated
+access count*/
+type KeyCount struct {
+	Key   string
+	Count int
+}
+
+/*TopK estimates the most frequently requested keys with
+the space-saving algorithm: it only ever tracks capacity keys,
+and a new key replaces the least counted one (inheriting its
+count, so estimates can only be over, never under).  Counts are
+halved every window observations so a key that cooled off
+eventually drops out*/
+type TopK struct {
+	mu       sync.Mutex
+	capacity int
+	window   int
+	seen     int
+	counts   map[string]int
+}
+
+func (t *TopK) decay() {
+	for key, count := range t.counts {
+		count = count / 2
+		if count == 0 {
+			delete(t.counts, key)
+		} else {
+			t.counts[key] = count
+		}
+	}
+}
+
+/*Observe records one access and returns the key's
+estimated count*/
+func (t *TopK) Observe(key string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.seen++
+	if t.window > 0 && t.seen%t.window == 0 {
+		t.decay()
+	}
+	if count, ok := t.counts[key]; ok {
+		t.counts[key] = count + 1
+		return count + 1
+	}
+	if len(t.counts) < t.capacity {
+		t.counts[key] = 1
+		return 1
+	}
+	// replace the least counted key
+	minKey := ""
+	minCount := -1
+	for k, count := range t.counts {
+		if minCount == -1 || count < minCount {
+			minKey = k
+			minCount = count
+		}
+	}
+	delete(t.counts, minKey)
+	t.counts[key] = minCount + 1
+	return minCount + 1
+}
+
+/*Count is the estimated count for a key, 0 if untracked*/
+func (t *TopK) Count(key string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.counts[key]
+}
+
+/*Top lists the tracked keys, most requested first*/
+func (t *TopK) Top() []KeyCount {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	top := []KeyCount{}
+	for key, count := range t.counts {
+		top = append(top, KeyCount{Key: key, Count: count})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count == top[j].Count {
+			return top[i].Key < top[j].Key
+		}
+		return top[i].Count > top[j].Count
+	})
+	return top
+}
+
+/*NewTopK builds a tracker for up to capacity keys that
+halves its counts every window observations (0 never decays)*/
+func NewTopK(capacity int, window int) *TopK {
+	return &TopK{capacity: capacity, window: window, counts: make(map[string]int)}
+}
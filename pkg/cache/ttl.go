@@ -0,0 +1,264 @@
+package cache
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+/*WithTTL is a copy of the entry that expires ttl from now.
+Every policy treats an expired entry as absent and drops it
+the next time it's looked up; 0 or less never expires*/
+func (e Entry) WithTTL(ttl time.Duration) Entry {
+	if ttl <= 0 {
+		e.expires = 0
+	} else {
+		e.expires = clockNow().Add(ttl).UnixNano()
+	}
+	return e
+}
+
+/*WithIdleTTL is a copy of the entry that expires once it
+goes idle long unread, every GetValue hit pushes the deadline
+back out (sliding expiration, for sessions and the like).  It
+combines with WithTTL, whichever deadline comes first wins;
+0 or less turns it off*/
+func (e Entry) WithIdleTTL(idle time.Duration) Entry {
+	if idle <= 0 {
+		e.idle = 0
+		e.idleUntil = 0
+	} else {
+		e.idle = int64(idle)
+		e.idleUntil = clockNow().UnixNano() + e.idle
+	}
+	return e
+}
+
+/*Expires is when the entry expires if nothing reads it
+first, the zero time if it never does*/
+func (e Entry) Expires() time.Time {
+	deadline := e.deadline()
+	if deadline == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, deadline)
+}
+
+/*deadline is the earlier of the absolute and idle deadlines
+in unix nanos, 0 for none*/
+func (e Entry) deadline() int64 {
+	deadline := e.expires
+	if e.idle != 0 && (deadline == 0 || e.idleUntil < deadline) {
+		deadline = e.idleUntil
+	}
+	return deadline
+}
+
+/*expired checks the clock only for entries with a TTL, so
+entries without one cost nothing extra on a hit*/
+func (e Entry) expired(clock Clock) bool {
+	if e.expires == 0 && e.idle == 0 {
+		return false
+	}
+	now := clock.Now().UnixNano()
+	return (e.expires != 0 && now >= e.expires) || (e.idle != 0 && now >= e.idleUntil)
+}
+
+/*touch slides an idle deadline out on a hit*/
+func (e *Entry) touch(clock Clock) {
+	if e.idle != 0 {
+		e.idleUntil = clock.Now().UnixNano() + e.idle
+	}
+}
+
+/*onClock moves the entry's deadlines from the process clock
+(WithTTL's) onto a cache's own, keeping the time left*/
+func (e Entry) onClock(clock Clock) Entry {
+	if e.expires != 0 || e.idle != 0 {
+		shift := clock.Now().UnixNano() - clockNow().UnixNano()
+		if e.expires != 0 {
+			e.expires += shift
+		}
+		if e.idle != 0 {
+			e.idleUntil += shift
+		}
+	}
+	e.local = true
+	return e
+}
+
+/*ttlFields writes the entry's deadlines for the wire as
+ttl,idle,idle left in nanoseconds (0 for none).  Times left
+rather than timestamps, so the nodes' clocks don't have to
+agree*/
+func ttlFields(e Entry) string {
+	now := clockNow().UnixNano()
+	ttl := int64(0)
+	if e.expires != 0 {
+		ttl = remaining(e.expires, now)
+	}
+	idleLeft := int64(0)
+	if e.idle != 0 {
+		idleLeft = remaining(e.idleUntil, now)
+	}
+	return strconv.FormatInt(ttl, 10) + "," + strconv.FormatInt(e.idle, 10) + "," + strconv.FormatInt(idleLeft, 10)
+}
+
+/*remaining is the time left until the deadline, at least
+1ns so a deadline that already passed stays set (and expired)*/
+func remaining(deadline int64, now int64) int64 {
+	if deadline <= now {
+		return 1
+	}
+	return deadline - now
+}
+
+/*withTTLFields puts deadlines read from ttlFields back on
+the entry*/
+func withTTLFields(e Entry, fields []string) (Entry, error) {
+	if len(fields) != 3 {
+		return e, errors.New("Malformed ttl fields")
+	}
+	values := [3]int64{}
+	for i, field := range fields {
+		value, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return e, err
+		}
+		values[i] = value
+	}
+	now := clockNow().UnixNano()
+	if values[0] > 0 {
+		e.expires = now + values[0]
+	}
+	if values[1] > 0 {
+		e.idle = values[1]
+		e.idleUntil = now + values[2]
+	}
+	return e, nil
+}
+
+/*expiry is embedded in every policy so they all apply the
+cache-wide default TTLs the same way, and feed a timing wheel
+when a janitor runs one*/
+type expiry struct {
+	defaultTTL  time.Duration
+	defaultIdle time.Duration
+	wheel       *timingWheel
+	own         Clock
+}
+
+/*clock is the one the cache was built with, the process's
+if none*/
+func (x *expiry) clock() Clock {
+	if x.own == nil {
+		return processClock{}
+	}
+	return x.own
+}
+
+/*stamp gives an entry without a TTL the defaults and
+schedules its expiry on the wheel, if there is one*/
+func (x *expiry) stamp(k string, v Entry) Entry {
+	if v.expires == 0 && v.idle == 0 {
+		if x.defaultTTL > 0 {
+			v = v.WithTTL(x.defaultTTL)
+		}
+		if x.defaultIdle > 0 {
+			v = v.WithIdleTTL(x.defaultIdle)
+		}
+	}
+	if x.own != nil && !v.local {
+		v = v.onClock(x.own)
+	}
+	if x.wheel != nil {
+		deadline := v.deadline()
+		if deadline != 0 {
+			x.wheel.schedule(k, deadline)
+		}
+	}
+	return v
+}
+
+func (x *expiry) setDefaults(o cacheOptions) {
+	x.defaultTTL = o.ttl
+	x.defaultIdle = o.idle
+	x.own = o.clock
+}
+
+func (x *expiry) expiryState() *expiry {
+	return x
+}
+
+/*retimer moves a resident entry's deadline in place.  For
+Touch the entry lives ttl from now (forever for 0 or less),
+for Expire it lives at most that long (0 or less drops it)*/
+type retimer interface {
+	retime(k string, ttl time.Duration, shorten bool) error
+}
+
+/*Touch gives the key ttl more to live from now (0 or less
+for no expiry) and slides its idle deadline, without
+rewriting the value or counting an access*/
+func Touch(c Cache, k string, ttl time.Duration) error {
+	return retime(c, k, ttl, false)
+}
+
+/*Expire makes the key expire within after, unless it was
+due sooner already; 0 or less drops it now*/
+func Expire(c Cache, k string, after time.Duration) error {
+	return retime(c, k, after, true)
+}
+
+/*retime goes through the outermost thing in the chain that
+can retime, so a wrapper's lock is held when it happens*/
+func retime(c Cache, k string, ttl time.Duration, shorten bool) error {
+	for c != nil {
+		r, ok := c.(retimer)
+		if ok {
+			return r.retime(k, ttl, shorten)
+		}
+		policy, ok := c.(expiringPolicy)
+		if ok {
+			return retimePolicy(policy, k, ttl, shorten)
+		}
+		wrapper, ok := c.(unwrapper)
+		if !ok {
+			break
+		}
+		c = wrapper.Unwrap()
+	}
+	return errors.New("Cache can't retime its entries")
+}
+
+func retimePolicy(p expiringPolicy, k string, ttl time.Duration, shorten bool) error {
+	entry := p.resident(k)
+	if entry == nil {
+		return ErrKeyNotFound
+	}
+	x := p.expiryState()
+	if entry.expired(x.clock()) {
+		p.remove(k, Expired)
+		return ErrKeyNotFound
+	}
+	if shorten && ttl <= 0 {
+		return p.remove(k, Expired)
+	}
+	deadline := x.clock().Now().UnixNano() + int64(ttl)
+	if shorten {
+		if entry.expires == 0 || deadline < entry.expires {
+			entry.expires = deadline
+		}
+	} else {
+		if ttl <= 0 {
+			entry.expires = 0
+		} else {
+			entry.expires = deadline
+		}
+		entry.touch(x.clock())
+	}
+	if x.wheel != nil && entry.deadline() != 0 {
+		x.wheel.schedule(k, entry.deadline())
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+var allPolicies = []CacheType{FIFO, LRU, LFU, LCR, LCRTTL, LECAR, CALECAR, SLRU}
+
+func TestLazyExpiry(t *testing.T) {
+	for _, policy := range allPolicies {
+		c, _ := NewCache(policy, 4)
+		reasons := []RemovalReason{}
+		AddRemovalListener(c, func(key string, entry Entry, reason RemovalReason) {
+			reasons = append(reasons, reason)
+		})
+		c.SetValue("short", NewEntry("1", 5).WithTTL(20*time.Millisecond))
+		c.SetValue("forever", NewEntry("2", 5))
+		c.SetValue("long", NewEntry("3", 5).WithTTL(time.Hour))
+		if !c.KeyPresent("short") {
+			t.Fatalf("%s: short expired early", policy)
+		}
+		time.Sleep(30 * time.Millisecond)
+		if _, ok := c.(Peeker).Peek("short"); ok {
+			t.Fatalf("%s: Peek served an expired entry", policy)
+		}
+		if c.KeyPresent("short") {
+			t.Fatalf("%s: short outlived its ttl", policy)
+		}
+		if _, err := c.GetValue("short"); err == nil {
+			t.Fatalf("%s: GetValue served an expired entry", policy)
+		}
+		if len(reasons) != 1 || reasons[0] != Expired {
+			t.Fatalf("%s: expected one Expired removal, got %v", policy, reasons)
+		}
+		for _, key := range []string{"forever", "long"} {
+			if _, err := c.GetValue(key); err != nil {
+				t.Fatalf("%s: lost %s", policy, key)
+			}
+		}
+	}
+}
+
+func TestExpiredEntriesFreeTheirSlot(t *testing.T) {
+	for _, policy := range allPolicies {
+		c, _ := NewCache(policy, 3)
+		c.SetValue("a", NewEntry("a", 1))
+		c.SetValue("b", NewEntry("b", 1))
+		c.SetValue("gone", NewEntry("g", 1).WithTTL(time.Millisecond))
+		time.Sleep(2 * time.Millisecond)
+		c.GetValue("gone")
+		c.SetValue("c", NewEntry("c", 1))
+		for _, key := range []string{"a", "b", "c"} {
+			if !c.KeyPresent(key) {
+				t.Fatalf("%s: %s was evicted although an expired entry had left room", policy, key)
+			}
+		}
+	}
+}
+
+func TestEntryDeadlines(t *testing.T) {
+	if !NewEntry("v", 1).Expires().IsZero() {
+		t.Fatal("entries don't expire unless asked to")
+	}
+	before := time.Now()
+	entry := NewEntry("v", 1).WithTTL(time.Minute)
+	if entry.Expires().Before(before.Add(time.Minute)) || entry.Expires().After(time.Now().Add(time.Minute)) {
+		t.Fatalf("unexpected deadline %v", entry.Expires())
+	}
+	if entry.expired(processClock{}) {
+		t.Fatal("a fresh entry isn't expired")
+	}
+}
+
+func TestIdleTTLSlides(t *testing.T) {
+	for _, policy := range allPolicies {
+		policy := policy
+		t.Run(policy.String(), func(t *testing.T) {
+			t.Parallel()
+			testIdleTTLSlides(t, policy)
+		})
+	}
+}
+
+func testIdleTTLSlides(t *testing.T, policy CacheType) {
+	plain, _ := NewCache(policy, 4)
+	for _, c := range []Cache{plain, NewBatched(plain)} {
+		c.SetValue("sliding", NewEntry("1", 5).WithIdleTTL(100*time.Millisecond))
+		c.SetValue("capped", NewEntry("1", 5).WithIdleTTL(100*time.Millisecond).WithTTL(150*time.Millisecond))
+		// each read lands well inside the idle window
+		for i := 0; i < 5; i++ {
+			time.Sleep(40 * time.Millisecond)
+			if _, err := c.GetValue("sliding"); err != nil {
+				t.Fatalf("%s: sliding expired although it was read %d times", policy, i)
+			}
+		}
+		// 200ms in, past the absolute cap whatever the reads did
+		if _, err := c.GetValue("capped"); err == nil {
+			t.Fatalf("%s: the absolute ttl should cap the idle one", policy)
+		}
+		time.Sleep(150 * time.Millisecond)
+		if c.KeyPresent("sliding") {
+			t.Fatalf("%s: sliding outlived its idle ttl", policy)
+		}
+	}
+}
+
+func TestPeekDoesNotSlide(t *testing.T) {
+	c, _ := NewCache(LRU, 4)
+	c.SetValue("k", NewEntry("1", 5).WithIdleTTL(50*time.Millisecond))
+	for i := 0; i < 4; i++ {
+		time.Sleep(20 * time.Millisecond)
+		c.(Peeker).Peek("k")
+	}
+	if c.KeyPresent("k") {
+		t.Fatal("Peek shouldn't count as a use")
+	}
+}
+
+func TestTouchAndExpire(t *testing.T) {
+	for _, policy := range allPolicies {
+		c, _ := NewCache(policy, 4)
+		c.SetValue("a", NewEntry("1", 5).WithTTL(30*time.Millisecond))
+		c.SetValue("b", NewEntry("2", 5))
+		c.SetValue("c", NewEntry("3", 5))
+		shared := NewBatched(c)
+		if err := Touch(shared, "a", time.Hour); err != nil {
+			t.Fatalf("%s: touch failed: %v", policy, err)
+		}
+		if err := Expire(shared, "b", 30*time.Millisecond); err != nil {
+			t.Fatalf("%s: expire failed: %v", policy, err)
+		}
+		if err := Expire(shared, "c", 0); err != nil || shared.KeyPresent("c") {
+			t.Fatalf("%s: expiring now should drop c, got %v", policy, err)
+		}
+		if Touch(shared, "missing", time.Hour) == nil {
+			t.Fatalf("%s: touched a key that isn't there", policy)
+		}
+		time.Sleep(40 * time.Millisecond)
+		entry, err := shared.GetValue("a")
+		if err != nil || entry.Value() != "1" {
+			t.Fatalf("%s: touch didn't keep a alive", policy)
+		}
+		if shared.KeyPresent("b") {
+			t.Fatalf("%s: b outlived its new deadline", policy)
+		}
+	}
+}
+
+func TestExpireOnlyShortens(t *testing.T) {
+	c, _ := NewCache(LRU, 4)
+	c.SetValue("a", NewEntry("1", 5).WithTTL(time.Minute))
+	Expire(c, "a", time.Hour)
+	entry, _ := c.(Peeker).Peek("a")
+	if entry.Expires().After(time.Now().Add(time.Minute)) {
+		t.Fatalf("expire pushed the deadline out to %v", entry.Expires())
+	}
+	Touch(c, "a", 0)
+	entry, _ = c.(Peeker).Peek("a")
+	if !entry.Expires().IsZero() {
+		t.Fatalf("touch with no ttl should clear the deadline, got %v", entry.Expires())
+	}
+}
@@ -0,0 +1,80 @@
+package cache
+
+import "time"
+
+/*TwoLevel puts a small in-process cache in front of a
+remote one (usually a client.Client).  Reads try the local
+tier first and fill it from the remote tier on a local miss.
+Writes go through to the remote tier before landing locally,
+and deletes clear both*/
+type TwoLevel struct {
+	local  Cache
+	remote Cache
+}
+
+/*KeyPresent is true if either tier has the key*/
+func (t *TwoLevel) KeyPresent(k string) bool {
+	return t.local.KeyPresent(k) || t.remote.KeyPresent(k)
+}
+
+/*GetValue reads locally if it can, otherwise from the
+remote tier, keeping a local copy of what it found*/
+func (t *TwoLevel) GetValue(k string) (Entry, error) {
+	if t.local.KeyPresent(k) {
+		entry, err := t.local.GetValue(k)
+		if err == nil {
+			return entry, nil
+		}
+	}
+	entry, err := t.remote.GetValue(k)
+	if err != nil {
+		return Entry{}, err
+	}
+	t.local.SetValue(k, entry)
+	return entry, nil
+}
+
+/*SetValue writes through to the remote tier, and only
+caches locally once the remote write succeeded*/
+func (t *TwoLevel) SetValue(k string, v Entry) error {
+	err := t.remote.SetValue(k, v)
+	if err != nil {
+		return err
+	}
+	return t.local.SetValue(k, v)
+}
+
+/*Delete removes the key from both tiers*/
+func (t *TwoLevel) Delete(k string) error {
+	t.local.Delete(k)
+	return t.remote.Delete(k)
+}
+
+/*retime moves the key's deadline in whichever tiers have it
+and can (a remote client usually can't)*/
+func (t *TwoLevel) retime(k string, ttl time.Duration, shorten bool) error {
+	localErr := retime(t.local, k, ttl, shorten)
+	err := retime(t.remote, k, ttl, shorten)
+	if err != nil && localErr == nil {
+		return nil
+	}
+	return err
+}
+
+/*Invalidate drops only the local copy, for when the remote
+tier announces that someone else changed the key*/
+func (t *TwoLevel) Invalidate(k string) {
+	t.local.Delete(k)
+}
+
+/*InvalidationTarget is the cache a RedisInvalidator built
+over the TwoLevel deletes from, so remote changes only evict
+the local copy*/
+func (t *TwoLevel) InvalidationTarget() Cache {
+	return t.local
+}
+
+/*NewTwoLevel layers the local cache over the remote one*/
+func NewTwoLevel(local Cache, remote Cache) *TwoLevel {
+	return &TwoLevel{local: local, remote: remote}
+}
@@ -0,0 +1,74 @@
+package cache
+
+import "time"
+
+/*UpstreamLoader fetches a value that comes with its own
+lifetime from wherever it came from: a DNS record's TTL, an
+OAuth token's expires_in, an API response's max-age*/
+type UpstreamLoader interface {
+	LoadWithTTL(key string) (string, time.Duration, error)
+}
+
+/*UpstreamLoaderFunc lets a plain function be used as an
+UpstreamLoader*/
+type UpstreamLoaderFunc func(key string) (string, time.Duration, error)
+
+/*LoadWithTTL calls the function*/
+func (f UpstreamLoaderFunc) LoadWithTTL(key string) (string, time.Duration, error) {
+	return f(key)
+}
+
+/*UpstreamTTLOptions bounds the lifetimes an upstream hands
+out.  Min and Max clamp them (0 for no bound), Margin comes
+off every one so an entry is gone a little before the
+upstream stops honouring it, a token is refreshed before it's
+rejected*/
+type UpstreamTTLOptions struct {
+	Min    time.Duration
+	Max    time.Duration
+	Margin time.Duration
+}
+
+/*UpstreamTTL is a Loader whose entries expire when the
+upstream said they would, so a ReadThrough in front of it
+keeps each one exactly as long as it's good.  A lifetime of 0
+or less (after the margin and the minimum) means don't cache,
+the value is returned but expires at once.  The entries carry
+no cost, so the ReadThrough measures it*/
+type UpstreamTTL struct {
+	loader UpstreamLoader
+	opts   UpstreamTTLOptions
+}
+
+/*ttlFor clamps the upstream's lifetime*/
+func (u *UpstreamTTL) ttlFor(ttl time.Duration) time.Duration {
+	ttl -= u.opts.Margin
+	if u.opts.Max > 0 && ttl > u.opts.Max {
+		ttl = u.opts.Max
+	}
+	if ttl < u.opts.Min {
+		ttl = u.opts.Min
+	}
+	if ttl <= 0 {
+		// WithTTL would keep it forever
+		return time.Nanosecond
+	}
+	return ttl
+}
+
+/*Load fetches the value and sets its expiry*/
+func (u *UpstreamTTL) Load(key string) (Entry, error) {
+	value, ttl, err := u.loader.LoadWithTTL(key)
+	if err != nil {
+		return Entry{}, err
+	}
+	return NewEntry(value, 0).WithTTL(u.ttlFor(ttl)), nil
+}
+
+/*NewUpstreamTTL turns the upstream's lifetimes into entry
+TTLs:
+
+	rt := cache.NewReadThrough(c, cache.NewUpstreamTTL(resolver, opts))*/
+func NewUpstreamTTL(loader UpstreamLoader, opts UpstreamTTLOptions) *UpstreamTTL {
+	return &UpstreamTTL{loader: loader, opts: opts}
+}
@@ -0,0 +1,39 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUpstreamTTLExpiresWithTheUpstream(t *testing.T) {
+	clock := useManualClock(t)
+	lifetimes := map[string]time.Duration{"short.example": 30 * time.Second, "long.example": 24 * time.Hour, "none.example": 0}
+	loads := 0
+	resolver := UpstreamLoaderFunc(func(k string) (string, time.Duration, error) {
+		loads++
+		return "10.0.0.1", lifetimes[k], nil
+	})
+	lru, _ := NewCache(LRU, 10)
+	rt := NewReadThrough(lru, NewUpstreamTTL(resolver, UpstreamTTLOptions{Max: time.Hour, Margin: 5 * time.Second}))
+	for _, name := range []string{"short.example", "long.example", "none.example"} {
+		if entry, err := rt.GetValue(name); err != nil || entry.Value() != "10.0.0.1" {
+			t.Fatalf("expected %s loaded, got %v", name, err)
+		}
+	}
+	clock.Advance(time.Millisecond)
+	if lru.KeyPresent("none.example") {
+		t.Fatalf("expected a zero lifetime not to be cached")
+	}
+	clock.Advance(25 * time.Second)
+	if lru.KeyPresent("short.example") || !lru.KeyPresent("long.example") {
+		t.Fatalf("expected the short entry gone before its upstream TTL")
+	}
+	clock.Advance(time.Hour)
+	if lru.KeyPresent("long.example") {
+		t.Fatalf("expected the long entry capped at the maximum")
+	}
+	rt.GetValue("short.example")
+	if loads != 4 {
+		t.Fatalf("expected the expired entry loaded again, got %d loads", loads)
+	}
+}
@@ -0,0 +1,285 @@
+package cache
+
+import "time"
+
+/*the wheel has wheelLevels levels of wheelSlots slots, each
+level's slots spanning wheelSlots times as long as the level
+below's.  With a one second tick that reaches about 194 days
+before deadlines are clamped (and rescheduled once they come
+round)*/
+const (
+	wheelBits   = 6
+	wheelSlots  = 1 << wheelBits
+	wheelLevels = 4
+)
+
+/*wheelItem is one scheduled deadline, at in unix nanos*/
+type wheelItem struct {
+	key string
+	at  int64
+}
+
+/*wheelPlace is a key's live deadline and the slot its item
+is filed in*/
+type wheelPlace struct {
+	at    int64
+	level int
+	slot  int64
+}
+
+/*timingWheel is a hierarchical timing wheel: a deadline
+goes in the slot for its tick on the lowest level whose span
+reaches it, and as time passes the slots of the higher
+levels are cascaded down, so finding what is due is O(1) per
+tick no matter how many deadlines are scheduled.  A key set
+again or slid (idle TTL) just gets scheduled again, the
+stale item is skipped when its slot comes round, or dropped
+early when most of its slot has gone stale*/
+type timingWheel struct {
+	tick      int64
+	now       int64
+	slots     [wheelLevels][wheelSlots][]wheelItem
+	stale     [wheelLevels][wheelSlots]int
+	scheduled map[string]wheelPlace
+}
+
+/*live is true if the item is still the key's deadline*/
+func (w *timingWheel) live(item wheelItem) bool {
+	place, ok := w.scheduled[item.key]
+	return ok && place.at == item.at
+}
+
+/*forget unschedules the key, leaving its item stale*/
+func (w *timingWheel) forget(key string) {
+	place, ok := w.scheduled[key]
+	if !ok {
+		return
+	}
+	delete(w.scheduled, key)
+	w.stale[place.level][place.slot]++
+	if 2*w.stale[place.level][place.slot] > len(w.slots[place.level][place.slot]) {
+		w.compact(place.level, place.slot)
+	}
+}
+
+/*compact drops the stale items from the slot*/
+func (w *timingWheel) compact(level int, slot int64) {
+	items := w.slots[level][slot]
+	kept := make([]wheelItem, 0, len(items)-w.stale[level][slot])
+	for _, item := range items {
+		if w.live(item) {
+			kept = append(kept, item)
+		}
+	}
+	w.slots[level][slot] = kept
+	w.stale[level][slot] = 0
+}
+
+/*schedule puts the key's deadline on the wheel, replacing
+any earlier one*/
+func (w *timingWheel) schedule(key string, at int64) {
+	if place, ok := w.scheduled[key]; ok && place.at == at {
+		return
+	}
+	w.forget(key)
+	t := at / w.tick
+	if t <= w.now {
+		// already due, fire on the next tick
+		t = w.now + 1
+	}
+	w.place(wheelItem{key: key, at: at}, t)
+}
+
+/*place files the item under tick t (no earlier than now)*/
+func (w *timingWheel) place(item wheelItem, t int64) {
+	for level := 0; level < wheelLevels; level++ {
+		shift := uint(wheelBits * level)
+		if (t>>shift)-(w.now>>shift) < wheelSlots || level == wheelLevels-1 {
+			if (t>>shift)-(w.now>>shift) >= wheelSlots {
+				// too far out, park it in the furthest slot and look again then
+				t = ((w.now >> shift) + wheelSlots - 1) << shift
+			}
+			slot := (t >> shift) & (wheelSlots - 1)
+			w.slots[level][slot] = append(w.slots[level][slot], item)
+			w.scheduled[item.key] = wheelPlace{at: item.at, level: level, slot: slot}
+			return
+		}
+	}
+}
+
+/*cascade moves the live items in the level's current slot
+down to the levels below*/
+func (w *timingWheel) cascade(level int) {
+	shift := uint(wheelBits * level)
+	slot := (w.now >> shift) & (wheelSlots - 1)
+	items := w.slots[level][slot]
+	w.slots[level][slot] = nil
+	w.stale[level][slot] = 0
+	for _, item := range items {
+		if !w.live(item) {
+			continue
+		}
+		t := item.at / w.tick
+		if t < w.now {
+			t = w.now
+		}
+		w.place(item, t)
+	}
+}
+
+/*advance turns the wheel up to the time and returns the
+keys whose deadlines have passed*/
+func (w *timingWheel) advance(now time.Time) []string {
+	due := []string{}
+	target := now.UnixNano() / w.tick
+	for w.now < target {
+		w.now++
+		for level := wheelLevels - 1; level > 0; level-- {
+			if w.now&(1<<uint(wheelBits*level)-1) == 0 {
+				w.cascade(level)
+			}
+		}
+		slot := w.now & (wheelSlots - 1)
+		items := w.slots[0][slot]
+		w.slots[0][slot] = nil
+		w.stale[0][slot] = 0
+		for _, item := range items {
+			if !w.live(item) {
+				// rescheduled since, or already fired
+				continue
+			}
+			if item.at/w.tick > w.now {
+				// was parked in a slot short of its deadline
+				w.place(item, item.at/w.tick)
+				continue
+			}
+			delete(w.scheduled, item.key)
+			due = append(due, item.key)
+		}
+	}
+	return due
+}
+
+func newTimingWheel(tick time.Duration, now time.Time) *timingWheel {
+	if tick <= 0 {
+		tick = time.Second
+	}
+	return &timingWheel{
+		tick:      int64(tick),
+		now:       now.UnixNano() / int64(tick),
+		scheduled: make(map[string]wheelPlace),
+	}
+}
+
+/*expiringPolicy is every policy from NewCache that can hold
+entries (all but NONE)*/
+type expiringPolicy interface {
+	Peeker
+	Exporter
+	RemovalNotifier
+	remove(k string, reason RemovalReason) error
+	resident(k string) *Entry
+	expiryState() *expiry
+}
+
+/*dueSweeper drops expired entries as the wheel says they
+come due instead of sampling for them*/
+type dueSweeper interface {
+	startWheel(tick time.Duration) bool
+	sweepDue() int
+}
+
+/*policyWheel drives the wheel of a policy used directly*/
+type policyWheel struct {
+	policy expiringPolicy
+}
+
+/*startWheel gives the policy a wheel and schedules the
+entries it already holds.  Keys the policy drops are
+forgotten, a replaced one was rescheduled as it was set*/
+func (pw policyWheel) startWheel(tick time.Duration) bool {
+	x := pw.policy.expiryState()
+	if x.wheel != nil {
+		return true
+	}
+	x.wheel = newTimingWheel(tick, x.clock().Now())
+	wheel := x.wheel
+	pw.policy.OnRemoval(func(key string, entry Entry, reason RemovalReason) {
+		if reason != Replaced {
+			wheel.forget(key)
+		}
+	})
+	for _, record := range pw.policy.Export() {
+		deadline := record.Entry.deadline()
+		if deadline != 0 {
+			x.wheel.schedule(record.Key, deadline)
+		}
+	}
+	return true
+}
+
+/*sweepDue drops the entries the wheel says are due that
+really have expired, rescheduling the ones whose deadline
+slid while they waited*/
+func (pw policyWheel) sweepDue() int {
+	x := pw.policy.expiryState()
+	if x.wheel == nil {
+		return 0
+	}
+	dropped := 0
+	for _, key := range x.wheel.advance(x.clock().Now()) {
+		entry, ok := pw.policy.Peek(key)
+		if ok {
+			deadline := entry.deadline()
+			if deadline != 0 {
+				x.wheel.schedule(key, deadline)
+			}
+			continue
+		}
+		// not there any more, or there and expired
+		if pw.policy.remove(key, Expired) == nil {
+			dropped++
+		}
+	}
+	return dropped
+}
+
+/*dueSweeperFor is the outermost thing in the wrapper chain
+that can run a wheel, so a wrapper with a lock of its own
+(Batched, WriteBack...) takes it before the policy's removal
+hooks call back into it*/
+func dueSweeperFor(c Cache) dueSweeper {
+	for c != nil {
+		sweeper, ok := c.(dueSweeper)
+		if ok {
+			return sweeper
+		}
+		policy, ok := c.(expiringPolicy)
+		if ok {
+			return policyWheel{policy: policy}
+		}
+		wrapper, ok := c.(unwrapper)
+		if !ok {
+			return nil
+		}
+		c = wrapper.Unwrap()
+	}
+	return nil
+}
+
+/*startWheel starts the wheel of whatever in the chain can
+run one*/
+func startWheel(c Cache, tick time.Duration) bool {
+	sweeper := dueSweeperFor(c)
+	return sweeper != nil && sweeper.startWheel(tick)
+}
+
+/*sweepDue drops what is due through whatever in the chain
+runs the wheel*/
+func sweepDue(c Cache) int {
+	sweeper := dueSweeperFor(c)
+	if sweeper == nil {
+		return 0
+	}
+	return sweeper.sweepDue()
+}
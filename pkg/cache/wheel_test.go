@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+/*wheelAt is a wheel with a 1ms tick starting at a known time*/
+func wheelAt() (*timingWheel, time.Time) {
+	w := newTimingWheel(time.Millisecond, time.Now())
+	return w, time.Unix(0, w.now*int64(time.Millisecond))
+}
+
+func TestTimingWheelFiresOnTime(t *testing.T) {
+	cases := []struct {
+		name  string
+		after time.Duration
+	}{
+		{"same tick", 0},
+		{"level 0", 10 * time.Millisecond},
+		{"last level 0 slot", 63 * time.Millisecond},
+		{"level 1", 64 * time.Millisecond},
+		{"level 1 far", 4000 * time.Millisecond},
+		{"level 2", 5 * time.Second},
+		{"level 3", 5 * time.Minute},
+	}
+	for _, tc := range cases {
+		w, start := wheelAt()
+		at := start.Add(tc.after)
+		w.schedule("k", at.UnixNano())
+		fired := time.Time{}
+		for now := start; now.Before(at.Add(10 * time.Millisecond)); now = now.Add(time.Millisecond) {
+			if due := w.advance(now); len(due) > 0 {
+				fired = now
+				break
+			}
+		}
+		if fired.IsZero() {
+			t.Fatalf("%s: never fired", tc.name)
+		}
+		if fired.Before(at) || fired.Sub(at) > time.Millisecond {
+			t.Fatalf("%s: fired %v after its deadline", tc.name, fired.Sub(at))
+		}
+	}
+}
+
+func TestTimingWheelCascadesManyKeys(t *testing.T) {
+	w, start := wheelAt()
+	deadlines := make(map[string]int64)
+	for i := 0; i < 5000; i++ {
+		key := strconv.Itoa(i)
+		// spread over the first three levels
+		deadlines[key] = start.Add(time.Duration(i*53) * time.Millisecond).UnixNano()
+		w.schedule(key, deadlines[key])
+	}
+	end := start.Add(5000*53*time.Millisecond + 97*time.Millisecond)
+	for now := start; !now.After(end); now = now.Add(97 * time.Millisecond) {
+		for _, key := range w.advance(now) {
+			at := deadlines[key]
+			if at > now.UnixNano() || now.UnixNano()-at > int64(97*time.Millisecond) {
+				t.Fatalf("%s fired %v off its deadline", key, time.Duration(now.UnixNano()-at))
+			}
+			delete(deadlines, key)
+		}
+	}
+	if len(deadlines) != 0 {
+		t.Fatalf("%d keys never fired", len(deadlines))
+	}
+}
+
+func TestTimingWheelSkipsRescheduled(t *testing.T) {
+	w, start := wheelAt()
+	w.schedule("moved", start.Add(10*time.Millisecond).UnixNano())
+	w.schedule("moved", start.Add(100*time.Millisecond).UnixNano())
+	if due := w.advance(start.Add(50 * time.Millisecond)); len(due) != 0 {
+		t.Fatalf("stale deadline fired: %v", due)
+	}
+	due := w.advance(start.Add(100 * time.Millisecond))
+	if len(due) != 1 || due[0] != "moved" {
+		t.Fatalf("expected the new deadline to fire, got %v", due)
+	}
+	if due := w.advance(start.Add(time.Second)); len(due) != 0 {
+		t.Fatalf("fired twice: %v", due)
+	}
+}
+
+func TestTimingWheelPastDeadlineFiresNextTick(t *testing.T) {
+	w, start := wheelAt()
+	w.schedule("late", start.Add(-time.Hour).UnixNano())
+	due := w.advance(start.Add(time.Millisecond))
+	if len(due) != 1 {
+		t.Fatalf("expected an overdue key on the next tick, got %v", due)
+	}
+}
+
+func TestTimingWheelClampsBeyondLastLevel(t *testing.T) {
+	w, start := wheelAt()
+	// past the 64^4 ticks the wheel can hold, so parked short and rescheduled
+	at := start.Add(time.Duration(1<<25) * time.Millisecond)
+	w.schedule("far", at.UnixNano())
+	if due := w.advance(at.Add(-time.Millisecond)); len(due) != 0 {
+		t.Fatalf("fired early: %v", due)
+	}
+	if due := w.advance(at); len(due) != 1 {
+		t.Fatalf("expected the far key to fire on time, got %v", due)
+	}
+}
+
+/*wheelItems counts the items filed on the wheel, stale or not*/
+func wheelItems(w *timingWheel) int {
+	items := 0
+	for level := range w.slots {
+		for slot := range w.slots[level] {
+			items += len(w.slots[level][slot])
+		}
+	}
+	return items
+}
+
+func TestTimingWheelCompactsStaleSlots(t *testing.T) {
+	w, start := wheelAt()
+	for i := 0; i < 1000; i++ {
+		// an idle session slid again and again, its deadlines all in one far slot
+		w.schedule("session", start.Add(5*time.Minute+time.Duration(i)*time.Millisecond).UnixNano())
+	}
+	if items := wheelItems(w); items > 2 {
+		t.Fatalf("expected the stale deadlines compacted away, %d items filed", items)
+	}
+	due := w.advance(start.Add(5*time.Minute + time.Second))
+	if len(due) != 1 || due[0] != "session" {
+		t.Fatalf("expected the last deadline to fire, got %v", due)
+	}
+}
+
+func TestTimingWheelForgetsRemovedKeys(t *testing.T) {
+	for _, policy := range []CacheType{FIFO, LRU, LFU, LCR, LCRTTL, LECAR, CALECAR} {
+		c, _ := NewCache(policy, 10)
+		startWheel(c, time.Millisecond)
+		wheel := c.(expiringPolicy).expiryState().wheel
+		for i := 0; i < 1000; i++ {
+			c.SetValue(strconv.Itoa(i), NewEntry("v", 1).WithTTL(time.Hour))
+		}
+		if len(wheel.scheduled) != 10 || wheelItems(wheel) > 20 {
+			t.Fatalf("%s: evicted keys kept on the wheel, %d scheduled and %d items", policy, len(wheel.scheduled), wheelItems(wheel))
+		}
+		for _, record := range c.(Exporter).Export() {
+			c.Delete(record.Key)
+		}
+		if len(wheel.scheduled) != 0 {
+			t.Fatalf("%s: deleted keys kept on the wheel, %d scheduled", policy, len(wheel.scheduled))
+		}
+	}
+}
+
+func TestWheelJanitorDropsExpired(t *testing.T) {
+	for _, policy := range []CacheType{FIFO, LRU, LFU, LCR, LECAR, CALECAR} {
+		c, _ := NewCache(policy, 100)
+		shared := NewBatched(c)
+		shared.SetValue("before", NewEntry("v", 1).WithTTL(10*time.Millisecond))
+		j := NewWheelJanitor(shared, time.Millisecond)
+		shared.SetValue("short", NewEntry("v", 1).WithTTL(10*time.Millisecond))
+		shared.SetValue("long", NewEntry("v", 1).WithTTL(time.Hour))
+		shared.SetValue("none", NewEntry("v", 1))
+		time.Sleep(50 * time.Millisecond)
+		j.Close()
+		if j.Swept() != 2 {
+			t.Fatalf("%s: swept %d, expected 2", policy, j.Swept())
+		}
+		if records := shared.Export(); len(records) != 2 {
+			t.Fatalf("%s: %d entries left, expected 2", policy, len(records))
+		}
+	}
+}
+
+func TestWheelJanitorReschedulesSlidEntries(t *testing.T) {
+	c, _ := NewCache(LRU, 10)
+	shared := NewBatched(c)
+	shared.SetValue("session", NewEntry("v", 1).WithIdleTTL(30*time.Millisecond))
+	j := NewWheelJanitor(shared, time.Millisecond)
+	defer j.Close()
+	for i := 0; i < 5; i++ {
+		time.Sleep(15 * time.Millisecond)
+		if _, err := shared.GetValue("session"); err != nil {
+			t.Fatalf("read %d: session dropped while in use", i)
+		}
+	}
+	time.Sleep(60 * time.Millisecond)
+	if shared.KeyPresent("session") || j.Swept() != 1 {
+		t.Fatalf("idle session still there, swept %d", j.Swept())
+	}
+}
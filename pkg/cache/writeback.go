@@ -0,0 +1,186 @@
+package cache
+
+import "time"
+
+/*Store is the backing store a write-back cache flushes
+dirty entries to*/
+type Store interface {
+	Flush(batch map[string]Entry) error
+}
+
+/*WriteBack wraps a cache so SetValue only marks the entry
+dirty, and a background worker writes dirty entries to the
+store in batches (when a batch fills up or on every tick of
+the interval).  A dirty entry the policy evicts is flushed
+right away before it can be lost, and Close flushes whatever
+is left.  Deletes drop a pending write, they don't remove
+anything from the store*/
+type WriteBack struct {
+	lockedInner
+	store     Store
+	dirty     map[string]Entry
+	evicted   map[string]Entry
+	batchSize int
+	interval  time.Duration
+	clock     Clock
+	kick      chan bool
+	stop      chan bool
+	done      chan bool
+	lastErr   error
+}
+
+/*KeyPresent is true if the key is cached right now*/
+func (wb *WriteBack) KeyPresent(k string) bool {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	return wb.cache.KeyPresent(k)
+}
+
+/*GetValue reads from the wrapped cache*/
+func (wb *WriteBack) GetValue(k string) (Entry, error) {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	return wb.cache.GetValue(k)
+}
+
+/*SetValue caches the entry and marks it dirty*/
+func (wb *WriteBack) SetValue(k string, v Entry) error {
+	wb.mu.Lock()
+	err := wb.cache.SetValue(k, v)
+	if err != nil {
+		wb.mu.Unlock()
+		return err
+	}
+	wb.dirty[k] = v
+	full := len(wb.dirty) >= wb.batchSize
+	evicted := wb.evicted
+	wb.evicted = make(map[string]Entry)
+	wb.mu.Unlock()
+	if len(evicted) > 0 {
+		// flush-on-evict: these aren't anywhere else yet
+		err = wb.store.Flush(evicted)
+		if err != nil {
+			wb.redirty(evicted)
+			return err
+		}
+	}
+	if full {
+		select {
+		case wb.kick <- true:
+		default:
+		}
+	}
+	return nil
+}
+
+/*Delete removes the key and forgets its pending write*/
+func (wb *WriteBack) Delete(k string) error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	delete(wb.dirty, k)
+	return wb.cache.Delete(k)
+}
+
+/*Unwrap is the cache being written back*/
+func (wb *WriteBack) Unwrap() Cache {
+	return wb.cache
+}
+
+func (wb *WriteBack) onRemoval(key string, entry Entry, reason RemovalReason) {
+	// called from inside the wrapped cache, so the lock is already held
+	pending, ok := wb.dirty[key]
+	if !ok || reason != Evicted {
+		return
+	}
+	delete(wb.dirty, key)
+	wb.evicted[key] = pending
+}
+
+/*redirty puts back entries whose flush failed, unless a
+newer write for the key came in meanwhile*/
+func (wb *WriteBack) redirty(batch map[string]Entry) {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	for k, v := range batch {
+		if _, ok := wb.dirty[k]; !ok {
+			wb.dirty[k] = v
+		}
+	}
+}
+
+/*Flush writes every dirty entry to the store now, along with
+any evicted ones still waiting (evictions outside SetValue,
+from a Resize, a promotion or an Import, only get flushed
+here)*/
+func (wb *WriteBack) Flush() error {
+	wb.mu.Lock()
+	batch := wb.dirty
+	for k, v := range wb.evicted {
+		if _, ok := batch[k]; !ok {
+			// a newer write for the key wins
+			batch[k] = v
+		}
+	}
+	wb.dirty = make(map[string]Entry)
+	wb.evicted = make(map[string]Entry)
+	wb.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+	err := wb.store.Flush(batch)
+	if err != nil {
+		wb.redirty(batch)
+		wb.mu.Lock()
+		wb.lastErr = err
+		wb.mu.Unlock()
+	}
+	return err
+}
+
+func (wb *WriteBack) run() {
+	for {
+		select {
+		case <-wb.clock.After(wb.interval):
+			wb.Flush()
+		case <-wb.kick:
+			wb.Flush()
+		case <-wb.stop:
+			close(wb.done)
+			return
+		}
+	}
+}
+
+/*Close stops the background worker and flushes anything
+still dirty, returning the last flush error seen*/
+func (wb *WriteBack) Close() error {
+	close(wb.stop)
+	<-wb.done
+	err := wb.Flush()
+	if err != nil {
+		return err
+	}
+	return wb.lastErr
+}
+
+/*NewWriteBack wraps the cache, flushing to the store every
+interval or whenever batchSize entries are dirty.  The
+wrapped cache has to report removals (every policy from
+NewCache does) for flush-on-evict to work*/
+func NewWriteBack(c Cache, store Store, batchSize int, interval time.Duration) *WriteBack {
+	wb := &WriteBack{
+		lockedInner: lockedInner{cache: c},
+		store:       store,
+		dirty:       make(map[string]Entry),
+		evicted:     make(map[string]Entry),
+		batchSize:   batchSize,
+		interval:    interval,
+		clock:       clockOf(c),
+		kick:        make(chan bool, 1),
+		stop:        make(chan bool),
+		done:        make(chan bool),
+	}
+	AddRemovalListener(c, wb.onRemoval)
+	go wb.run()
+	return wb
+}
@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingStore struct {
+	mu      sync.Mutex
+	flushed map[string]Entry
+	batches int
+	fail    bool
+}
+
+func (s *recordingStore) Flush(batch map[string]Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return errors.New("Store unavailable")
+	}
+	s.batches++
+	for k, v := range batch {
+		s.flushed[k] = v
+	}
+	return nil
+}
+
+func (s *recordingStore) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.flushed)
+}
+
+func TestWriteBackFlushesOnEvict(t *testing.T) {
+	lru, _ := NewCache(LRU, 5)
+	store := &recordingStore{flushed: make(map[string]Entry)}
+	wb := NewWriteBack(lru, store, 100, time.Hour)
+	for i := 0; i < 20; i++ {
+		wb.SetValue(strconv.Itoa(i), NewEntry("v"+strconv.Itoa(i), 1))
+	}
+	// the 15 evicted entries can't wait for the next batch
+	if store.count() != 15 {
+		t.Fatalf("expected the evicted entries flushed, store has %d", store.count())
+	}
+	if err := wb.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if store.count() != 20 || store.flushed["19"].Value() != "v19" {
+		t.Fatalf("Close should flush the rest, store has %d", store.count())
+	}
+}
+
+func TestWriteBackBatches(t *testing.T) {
+	lru, _ := NewCache(LRU, 100)
+	store := &recordingStore{flushed: make(map[string]Entry)}
+	wb := NewWriteBack(lru, store, 10, time.Hour)
+	defer wb.Close()
+	for i := 0; i < 9; i++ {
+		wb.SetValue(strconv.Itoa(i), NewEntry("v", 1))
+	}
+	wb.Delete("0")
+	wb.SetValue("9", NewEntry("v", 1))
+	wb.SetValue("10", NewEntry("v", 1))
+	deadline := time.Now().Add(time.Second)
+	for store.count() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if len(store.flushed) != 10 || store.batches != 1 {
+		t.Fatalf("expected one full batch, got %d entries in %d batches", len(store.flushed), store.batches)
+	}
+	if _, ok := store.flushed["0"]; ok {
+		t.Fatal("a deleted key's pending write should be dropped")
+	}
+}
+
+func TestWriteBackKeepsFailedWrites(t *testing.T) {
+	lru, _ := NewCache(LRU, 100)
+	store := &recordingStore{flushed: make(map[string]Entry), fail: true}
+	wb := NewWriteBack(lru, store, 100, time.Hour)
+	wb.SetValue("a", NewEntry("old", 1))
+	if err := wb.Flush(); err == nil {
+		t.Fatal("expected the store's error")
+	}
+	wb.SetValue("a", NewEntry("new", 1))
+	store.mu.Lock()
+	store.fail = false
+	store.mu.Unlock()
+	if err := wb.Close(); err == nil {
+		t.Fatal("Close should report the earlier failure")
+	}
+	// the newer write wins over the one being retried
+	if store.flushed["a"].Value() != "new" {
+		t.Fatalf("expected the retried write to be the newest, got %+v", store.flushed["a"])
+	}
+}
+
+func TestWriteBackFlushesEvictionsOutsideSetOnClose(t *testing.T) {
+	lru, _ := NewCache(LRU, 2)
+	store := &recordingStore{flushed: make(map[string]Entry)}
+	wb := NewWriteBack(lru, store, 100, time.Hour)
+	wb.SetValue("a", NewEntry("1", 1))
+	wb.SetValue("b", NewEntry("2", 1))
+	if _, err := Resize(wb, 1); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := wb.Close(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if store.count() != 2 || store.flushed["a"].Value() != "1" {
+		t.Fatalf("expected the evicted write flushed on close, got %v", store.flushed)
+	}
+}
@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+/*ErrBusy is returned by a write that found the write queue
+full*/
+var ErrBusy = errors.New("Write queue full")
+
+/*queuedWrite is a write (or delete, or flush marker) waiting
+for the worker*/
+type queuedWrite struct {
+	key    string
+	entry  Entry
+	delete bool
+	done   chan error
+}
+
+/*WriteQueue hands writes to a background worker through a
+bounded queue, so a burst of writers never holds the wrapped
+cache's lock long enough to stall readers.  SetValue returns
+ErrBusy straight away when the queue is full, SetValueContext
+waits for room instead.  Reads go straight to the wrapped
+cache and don't see writes still queued.  Deletes queue
+behind the writes before them and wait to be applied, so a
+delete can't be undone by an older write.  Errors from queued
+writes are kept for Close.  The wrapped cache is used from
+the worker and the readers at once, so it must be safe for
+concurrent use (a Batched)*/
+type WriteQueue struct {
+	cache   Cache
+	queue   chan queuedWrite
+	mu      sync.RWMutex
+	closed  bool
+	errMu   sync.Mutex
+	lastErr error
+	done    chan bool
+}
+
+/*KeyPresent is true if the key is cached right now*/
+func (wq *WriteQueue) KeyPresent(k string) bool {
+	return wq.cache.KeyPresent(k)
+}
+
+/*GetValue reads from the wrapped cache*/
+func (wq *WriteQueue) GetValue(k string) (Entry, error) {
+	return wq.cache.GetValue(k)
+}
+
+/*SetValue queues the write, or returns ErrBusy if the queue
+is full*/
+func (wq *WriteQueue) SetValue(k string, v Entry) error {
+	wq.mu.RLock()
+	defer wq.mu.RUnlock()
+	if wq.closed {
+		return wq.cache.SetValue(k, v)
+	}
+	select {
+	case wq.queue <- queuedWrite{key: k, entry: v}:
+		return nil
+	default:
+		return ErrBusy
+	}
+}
+
+/*SetValueContext queues the write, waiting for room until
+the context is done*/
+func (wq *WriteQueue) SetValueContext(ctx context.Context, k string, v Entry) error {
+	wq.mu.RLock()
+	defer wq.mu.RUnlock()
+	if wq.closed {
+		return wq.cache.SetValue(k, v)
+	}
+	select {
+	case wq.queue <- queuedWrite{key: k, entry: v}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+/*Delete queues the delete and waits for it to be applied*/
+func (wq *WriteQueue) Delete(k string) error {
+	return wq.wait(queuedWrite{key: k, delete: true, done: make(chan error, 1)})
+}
+
+/*Flush waits until every write queued before it is applied*/
+func (wq *WriteQueue) Flush() {
+	wq.wait(queuedWrite{done: make(chan error, 1)})
+}
+
+func (wq *WriteQueue) wait(op queuedWrite) error {
+	wq.mu.RLock()
+	if wq.closed {
+		wq.mu.RUnlock()
+		return wq.apply(op)
+	}
+	wq.queue <- op
+	wq.mu.RUnlock()
+	return <-op.done
+}
+
+/*Pending is how many writes are queued right now*/
+func (wq *WriteQueue) Pending() int {
+	return len(wq.queue)
+}
+
+func (wq *WriteQueue) apply(op queuedWrite) error {
+	if op.delete {
+		return wq.cache.Delete(op.key)
+	} else if op.done != nil {
+		// a flush, everything before it is applied
+		return nil
+	}
+	err := wq.cache.SetValue(op.key, op.entry)
+	if err != nil {
+		wq.errMu.Lock()
+		wq.lastErr = err
+		wq.errMu.Unlock()
+	}
+	return err
+}
+
+func (wq *WriteQueue) run() {
+	for op := range wq.queue {
+		err := wq.apply(op)
+		if op.done != nil {
+			op.done <- err
+		}
+	}
+	close(wq.done)
+}
+
+/*Export lists the wrapped cache's entries, if it can*/
+func (wq *WriteQueue) Export() []Record {
+	exporter, ok := wq.cache.(Exporter)
+	if !ok {
+		return []Record{}
+	}
+	return exporter.Export()
+}
+
+/*Import puts the record straight into the wrapped cache*/
+func (wq *WriteQueue) Import(r Record) error {
+	return ImportRecord(wq.cache, r)
+}
+
+/*Unwrap is the cache the writes are applied to*/
+func (wq *WriteQueue) Unwrap() Cache {
+	return wq.cache
+}
+
+/*Close applies everything still queued and stops the worker,
+returning the last error a queued write ran into.  Writes
+after that go straight to the cache*/
+func (wq *WriteQueue) Close() error {
+	wq.mu.Lock()
+	if !wq.closed {
+		wq.closed = true
+		close(wq.queue)
+	}
+	wq.mu.Unlock()
+	<-wq.done
+	wq.errMu.Lock()
+	defer wq.errMu.Unlock()
+	return wq.lastErr
+}
+
+/*NewWriteQueue queues up to depth writes (at least 1) for
+the cache*/
+func NewWriteQueue(c Cache, depth int) *WriteQueue {
+	if depth < 1 {
+		depth = 1
+	}
+	wq := &WriteQueue{cache: c, queue: make(chan queuedWrite, depth), done: make(chan bool)}
+	go wq.run()
+	return wq
+}
@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+/*gatedCache holds every write until the gate lets it through*/
+type gatedCache struct {
+	Cache
+	gate chan bool
+}
+
+func (g *gatedCache) SetValue(k string, v Entry) error {
+	<-g.gate
+	return g.Cache.SetValue(k, v)
+}
+
+func TestWriteQueuePushesBack(t *testing.T) {
+	lru, _ := NewCache(LRU, 10)
+	gated := &gatedCache{Cache: NewBatched(lru), gate: make(chan bool)}
+	wq := NewWriteQueue(gated, 2)
+	wq.SetValue("a", NewEntry("1", 1))
+	// the worker takes a and waits at the gate, leaving room for two
+	waitFor(t, func() bool { return wq.Pending() == 0 })
+	wq.SetValue("b", NewEntry("2", 1))
+	wq.SetValue("c", NewEntry("3", 1))
+	if err := wq.SetValue("d", NewEntry("4", 1)); !errors.Is(err, ErrBusy) {
+		t.Fatalf("expected a full queue to push back, got %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := wq.SetValueContext(ctx, "d", NewEntry("4", 1)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected waiting for room to give up with the context, got %v", err)
+	}
+	if wq.KeyPresent("a") || wq.KeyPresent("b") {
+		t.Fatalf("expected reads to go on without waiting for queued writes")
+	}
+	close(gated.gate)
+	wq.Flush()
+	if !wq.KeyPresent("a") || !wq.KeyPresent("c") || wq.KeyPresent("d") {
+		t.Fatalf("expected the queued writes applied in order")
+	}
+	wq.SetValue("e", NewEntry("5", 1))
+	if err := wq.Delete("e"); err != nil || wq.KeyPresent("e") {
+		t.Fatalf("expected the delete applied after the write before it, got %v", err)
+	}
+	if err := wq.Close(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	wq.SetValue("f", NewEntry("6", 1))
+	if !wq.KeyPresent("f") {
+		t.Fatalf("expected writes after Close to go straight through")
+	}
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+/*XFetch wraps a cache so entries expire after a ttl, but
+are recomputed a little early with a probability that grows
+as expiry gets closer and with how long the entry takes to
+compute (the "XFetch" scheme).  One reader redoes a hot
+entry shortly before it expires instead of every reader
+missing at once when it does.  beta above 1 refreshes
+earlier, below 1 later.  Expiry is the entries' own TTL
+(entries set with one keep it), and the compute time is
+their cost taken as microseconds*/
+type XFetch struct {
+	lockedInner
+	loader Loader
+	loads  *flightGroup
+	ttl    time.Duration
+	beta   float64
+	clock  Clock
+}
+
+/*KeyPresent is true if the key is cached and not expired*/
+func (x *XFetch) KeyPresent(k string) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.cache.KeyPresent(k)
+}
+
+/*early rolls the dice on recomputing before expiry*/
+func (x *XFetch) early(entry Entry, now time.Time) bool {
+	expires := entry.Expires()
+	if expires.IsZero() {
+		return false
+	}
+	delta := time.Duration(entry.cost) * time.Microsecond
+	// -log of a uniform (0,1] draw, so usually small and occasionally large
+	gap := -float64(delta) * x.beta * math.Log(1-rand.Float64())
+	return !now.Add(time.Duration(gap)).Before(expires)
+}
+
+/*GetValue returns the cached entry, loading it if it is
+missing or expired, and sometimes recomputing it early*/
+func (x *XFetch) GetValue(k string) (Entry, error) {
+	x.mu.Lock()
+	var entry Entry
+	err := ErrKeyNotFound
+	if x.cache.KeyPresent(k) {
+		entry, err = x.cache.GetValue(k)
+		if err == nil && !x.early(entry, x.clock.Now()) {
+			x.mu.Unlock()
+			return entry, nil
+		}
+	}
+	x.mu.Unlock()
+	fresh, loadErr := x.load(k)
+	if loadErr != nil && err == nil {
+		// the early refresh failed, what we have is still good
+		return entry, nil
+	}
+	return fresh, loadErr
+}
+
+/*withTTL gives an entry without a TTL of its own the ttl*/
+func (x *XFetch) withTTL(v Entry) Entry {
+	if v.expires == 0 && v.idle == 0 {
+		return v.WithTTL(x.ttl)
+	}
+	return v
+}
+
+func (x *XFetch) load(k string) (Entry, error) {
+	entry, _, err := x.loads.Do(k, func() (Entry, int, error) {
+		start := time.Now()
+		entry, err := x.loader.Load(k)
+		if err != nil {
+			return Entry{}, 0, err
+		}
+		if entry.cost == 0 {
+			entry.cost = int(time.Since(start) / time.Microsecond)
+		}
+		entry = x.withTTL(entry)
+		x.mu.Lock()
+		defer x.mu.Unlock()
+		x.cache.SetValue(k, entry)
+		return entry, entry.cost, nil
+	})
+	return entry, err
+}
+
+/*SetValue caches the entry for a full ttl*/
+func (x *XFetch) SetValue(k string, v Entry) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.cache.SetValue(k, x.withTTL(v))
+}
+
+/*Delete removes the key from the wrapped cache*/
+func (x *XFetch) Delete(k string) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.cache.Delete(k)
+}
+
+/*Unwrap is the cache being expired*/
+func (x *XFetch) Unwrap() Cache {
+	return x.cache
+}
+
+/*NewXFetch wraps the cache so entries live for ttl and are
+recomputed early through the loader, beta scaling how early
+(1 is the usual choice)*/
+func NewXFetch(c Cache, loader Loader, ttl time.Duration, beta float64) *XFetch {
+	if beta <= 0 {
+		beta = 1
+	}
+	return &XFetch{
+		lockedInner: lockedInner{cache: c},
+		loader:      loader,
+		loads:       &flightGroup{},
+		ttl:         ttl,
+		beta:        beta,
+		clock:       clockOf(c),
+	}
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestXFetchRefreshesBeforeExpiry(t *testing.T) {
+	lru, _ := NewCache(LRU, 10)
+	var loads int32
+	x := NewXFetch(lru, LoaderFunc(func(k string) (Entry, error) {
+		atomic.AddInt32(&loads, 1)
+		time.Sleep(5 * time.Millisecond)
+		return NewEntry("v", 0), nil
+	}), 100*time.Millisecond, 1)
+	for i := 0; i < 50; i++ {
+		x.GetValue("a")
+	}
+	if loads != 1 {
+		t.Fatalf("refreshed early %d times right after loading", loads-1)
+	}
+	entry, _ := lru.(Peeker).Peek("a")
+	if until := time.Until(entry.Expires()); until <= 0 || until > 100*time.Millisecond {
+		t.Fatalf("entry should carry the ttl, expires in %v", until)
+	}
+	time.Sleep(98 * time.Millisecond)
+	for i := 0; i < 200; i++ {
+		x.GetValue("a")
+	}
+	if loads < 2 {
+		t.Fatal("never refreshed close to expiry")
+	}
+}
+
+func TestXFetchKeepsEntryTTL(t *testing.T) {
+	lru, _ := NewCache(LRU, 10)
+	x := NewXFetch(lru, LoaderFunc(func(k string) (Entry, error) {
+		return NewEntry("v", 1), nil
+	}), time.Hour, 1)
+	x.SetValue("short", NewEntry("v", 1).WithTTL(10*time.Millisecond))
+	x.SetValue("default", NewEntry("v", 1))
+	time.Sleep(20 * time.Millisecond)
+	if x.KeyPresent("short") {
+		t.Fatal("an entry's own ttl should win over the wrapper's")
+	}
+	if !x.KeyPresent("default") {
+		t.Fatal("entry without a ttl should get the hour")
+	}
+}
@@ -0,0 +1,245 @@
+package cachetest
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+/*Call is one call the code under test made on a Mock*/
+type Call struct {
+	Op    string
+	Key   string
+	Entry cache.Entry
+}
+
+/*Expectation is a call the test expects, and what the Mock
+answers it with*/
+type Expectation struct {
+	op    string
+	key   string
+	entry cache.Entry
+	err   error
+	set   bool
+	met   bool
+}
+
+/*Return is what an expected GetValue answers*/
+func (e *Expectation) Return(entry cache.Entry, err error) *Expectation {
+	e.entry = entry
+	e.err = err
+	e.set = true
+	return e
+}
+
+/*ReturnError is what an expected SetValue or Delete answers*/
+func (e *Expectation) ReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+/*Mock is a Cache for testing code that uses one, without a
+real policy.  Calls nothing was expected for behave like an
+unbounded map, which never evicts; an expected call is
+answered the way the expectation says (once, for the next
+matching call) and marked met.  Every call is recorded, and
+removal listeners hear about deletes, replacements and the
+evictions ExpectEviction schedules.  It's safe for concurrent
+use, listeners are called with its lock released*/
+type Mock struct {
+	mu           sync.Mutex
+	entries      map[string]cache.Entry
+	calls        []Call
+	expectations []*Expectation
+	evictions    []*Expectation
+	listeners    []cache.RemovalListener
+}
+
+/*expect adds an expectation*/
+func (m *Mock) expect(op string, k string) *Expectation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := &Expectation{op: op, key: k}
+	m.expectations = append(m.expectations, e)
+	return e
+}
+
+/*ExpectGet expects a GetValue of the key, answered with the
+map's entry unless Return says otherwise*/
+func (m *Mock) ExpectGet(k string) *Expectation {
+	return m.expect("get", k)
+}
+
+/*ExpectSet expects a SetValue of the key, which is stored
+unless ReturnError fails it*/
+func (m *Mock) ExpectSet(k string) *Expectation {
+	return m.expect("set", k)
+}
+
+/*ExpectDelete expects a Delete of the key, which is removed
+unless ReturnError fails it*/
+func (m *Mock) ExpectDelete(k string) *Expectation {
+	return m.expect("delete", k)
+}
+
+/*ExpectEviction makes the next SetValue evict the key, as a
+full cache would, telling the listeners.  It's met once the
+key has been evicted*/
+func (m *Mock) ExpectEviction(k string) *Expectation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := &Expectation{op: "evict", key: k}
+	m.evictions = append(m.evictions, e)
+	return e
+}
+
+/*match is the first unmet expectation for the call, the lock
+must be held*/
+func (m *Mock) match(op string, k string) *Expectation {
+	for _, e := range m.expectations {
+		if !e.met && e.op == op && e.key == k {
+			e.met = true
+			return e
+		}
+	}
+	return nil
+}
+
+/*removal is a listener call to make once the lock is
+released*/
+type removal struct {
+	key    string
+	entry  cache.Entry
+	reason cache.RemovalReason
+}
+
+func (m *Mock) notify(removals []removal) {
+	m.mu.Lock()
+	listeners := m.listeners
+	m.mu.Unlock()
+	for _, r := range removals {
+		for _, fn := range listeners {
+			fn(r.key, r.entry, r.reason)
+		}
+	}
+}
+
+/*KeyPresent is true if the map has the key*/
+func (m *Mock) KeyPresent(k string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, Call{Op: "present", Key: k})
+	_, ok := m.entries[k]
+	return ok
+}
+
+/*GetValue answers as expected, or from the map*/
+func (m *Mock) GetValue(k string) (cache.Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, Call{Op: "get", Key: k})
+	e := m.match("get", k)
+	if e != nil && e.set {
+		return e.entry, e.err
+	}
+	entry, ok := m.entries[k]
+	if !ok {
+		return cache.Entry{}, cache.ErrKeyNotFound
+	}
+	return entry, nil
+}
+
+/*SetValue stores the entry unless an expectation fails it,
+evicting whatever ExpectEviction scheduled*/
+func (m *Mock) SetValue(k string, v cache.Entry) error {
+	m.mu.Lock()
+	m.calls = append(m.calls, Call{Op: "set", Key: k, Entry: v})
+	e := m.match("set", k)
+	if e != nil && e.err != nil {
+		m.mu.Unlock()
+		return e.err
+	}
+	removals := []removal{}
+	for _, eviction := range m.evictions {
+		old, ok := m.entries[eviction.key]
+		if !eviction.met && ok && eviction.key != k {
+			eviction.met = true
+			delete(m.entries, eviction.key)
+			removals = append(removals, removal{eviction.key, old, cache.Evicted})
+		}
+	}
+	if old, ok := m.entries[k]; ok {
+		removals = append(removals, removal{k, old, cache.Replaced})
+	}
+	m.entries[k] = v
+	m.mu.Unlock()
+	m.notify(removals)
+	return nil
+}
+
+/*Delete removes the key unless an expectation fails it*/
+func (m *Mock) Delete(k string) error {
+	m.mu.Lock()
+	m.calls = append(m.calls, Call{Op: "delete", Key: k})
+	e := m.match("delete", k)
+	if e != nil && e.err != nil {
+		m.mu.Unlock()
+		return e.err
+	}
+	old, ok := m.entries[k]
+	if !ok {
+		m.mu.Unlock()
+		return cache.ErrKeyNotFound
+	}
+	delete(m.entries, k)
+	m.mu.Unlock()
+	m.notify([]removal{{k, old, cache.Deleted}})
+	return nil
+}
+
+/*OnRemoval adds a removal listener*/
+func (m *Mock) OnRemoval(fn cache.RemovalListener) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, fn)
+}
+
+/*Calls lists every call made so far, in order*/
+func (m *Mock) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call{}, m.calls...)
+}
+
+/*CallsTo counts the calls made of one kind ("get", "set",
+"delete" or "present") on the key*/
+func (m *Mock) CallsTo(op string, k string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, call := range m.calls {
+		if call.Op == op && call.Key == k {
+			count++
+		}
+	}
+	return count
+}
+
+/*Verify fails the test for every expectation that wasn't
+met*/
+func (m *Mock) Verify(t testing.TB) {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range append(append([]*Expectation{}, m.expectations...), m.evictions...) {
+		if !e.met {
+			t.Errorf("expected a %s of %s that never came", e.op, e.key)
+		}
+	}
+}
+
+/*NewMock is an empty Mock*/
+func NewMock() *Mock {
+	return &Mock{entries: map[string]cache.Entry{}}
+}
@@ -0,0 +1,60 @@
+package cachetest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+/*recordingT catches what Verify reports*/
+type recordingT struct {
+	testing.TB
+	errors int
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) { r.errors++ }
+
+func TestMockAnswersAsExpected(t *testing.T) {
+	m := NewMock()
+	m.ExpectGet("a").Return(cache.NewEntry("stub", 3), nil)
+	m.ExpectSet("b").ReturnError(cache.ErrCacheFull)
+	if entry, err := m.GetValue("a"); err != nil || entry.Value() != "stub" {
+		t.Fatalf("expected the programmed entry, got %v %v", entry, err)
+	}
+	if _, err := m.GetValue("a"); !errors.Is(err, cache.ErrKeyNotFound) {
+		t.Fatalf("expected the expectation used up and the map empty")
+	}
+	if err := m.SetValue("b", cache.NewEntry("1", 1)); !errors.Is(err, cache.ErrCacheFull) || m.KeyPresent("b") {
+		t.Fatalf("expected the programmed failure, got %v", err)
+	}
+	m.SetValue("b", cache.NewEntry("2", 1))
+	if entry, _ := m.GetValue("b"); entry.Value() != "2" || m.CallsTo("set", "b") != 2 || len(m.Calls()) != 6 {
+		t.Fatalf("expected unprogrammed calls to behave like a map and all of them recorded")
+	}
+	m.Verify(t)
+}
+
+func TestMockEvictsForListeners(t *testing.T) {
+	m := NewMock()
+	evicted := []string{}
+	cache.AddRemovalListener(m, func(key string, entry cache.Entry, reason cache.RemovalReason) {
+		if reason == cache.Evicted {
+			evicted = append(evicted, key)
+		}
+	})
+	m.SetValue("a", cache.NewEntry("1", 1))
+	m.ExpectEviction("a")
+	m.ExpectDelete("z")
+	m.SetValue("b", cache.NewEntry("2", 1))
+	if len(evicted) != 1 || evicted[0] != "a" || m.KeyPresent("a") {
+		t.Fatalf("expected a evicted by the next write, got %v", evicted)
+	}
+	r := &recordingT{TB: t}
+	m.Verify(r)
+	if r.errors != 1 {
+		t.Fatalf("expected the delete that never came reported, got %d errors", r.errors)
+	}
+}
@@ -0,0 +1,229 @@
+package client
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+/*Config describes how to reach a cache server.
+Addrs is the primary followed by any replicas to fail
+over to, in the order they should be tried*/
+type Config struct {
+	Addrs    []string
+	PoolSize int
+	Timeout  time.Duration
+	Retries  int
+	Backoff  time.Duration
+}
+
+/*poolConn is a kept-alive connection with its reader,
+so buffered bytes aren't lost between commands*/
+type poolConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+/*Client talks to cache servers over the tcp protocol and
+implements cache.Cache, so remote and in-process caches are
+interchangeable.  Connections are kept alive and pooled per
+server; each operation gets its own deadline and is retried
+with exponential backoff, trying the replicas in order when
+the primary doesn't answer*/
+type Client struct {
+	conf  Config
+	mu    sync.Mutex
+	pools map[string]chan *poolConn
+}
+
+func (cl *Client) pool(addr string) chan *poolConn {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	p, ok := cl.pools[addr]
+	if !ok {
+		p = make(chan *poolConn, cl.conf.PoolSize)
+		cl.pools[addr] = p
+	}
+	return p
+}
+
+func (cl *Client) checkout(addr string) (*poolConn, error) {
+	select {
+	case pc := <-cl.pool(addr):
+		return pc, nil
+	default:
+	}
+	conn, err := net.DialTimeout("tcp", addr, cl.conf.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	_, err = conn.Write([]byte("keepalive\n"))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &poolConn{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+func (cl *Client) checkin(addr string, pc *poolConn) {
+	select {
+	case cl.pool(addr) <- pc:
+	default:
+		// pool is full
+		pc.conn.Close()
+	}
+}
+
+/*send runs one command against one server and returns
+the lines of its answer*/
+func (cl *Client) send(addr string, message string) ([]string, error) {
+	pc, err := cl.checkout(addr)
+	if err != nil {
+		return nil, err
+	}
+	pc.conn.SetDeadline(time.Now().Add(cl.conf.Timeout))
+	_, err = pc.conn.Write([]byte(message + "\n"))
+	if err != nil {
+		pc.conn.Close()
+		return nil, err
+	}
+	lines := []string{}
+	for {
+		line, err := pc.reader.ReadString('\n')
+		if err != nil {
+			pc.conn.Close()
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\n")
+		if line == "END" {
+			break
+		}
+		lines = append(lines, line)
+	}
+	cl.checkin(addr, pc)
+	return lines, nil
+}
+
+/*do sends the command to the first server that answers,
+retrying the whole list with backoff*/
+func (cl *Client) do(message string) ([]string, error) {
+	var lastErr error
+	backoff := cl.conf.Backoff
+	for attempt := 0; attempt <= cl.conf.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff = backoff * 2
+		}
+		for _, addr := range cl.conf.Addrs {
+			lines, err := cl.send(addr, message)
+			if err == nil {
+				return lines, nil
+			}
+			lastErr = err
+		}
+	}
+	return nil, lastErr
+}
+
+func parseEntry(lines []string) (cache.Entry, int, error) {
+	value := ""
+	found := false
+	cost := 0
+	for _, line := range lines {
+		if strings.HasPrefix(line, "VALUE:") {
+			value = strings.TrimPrefix(line, "VALUE:")
+			found = true
+		} else if strings.HasPrefix(line, "COST:") {
+			parsed, err := strconv.Atoi(strings.TrimPrefix(line, "COST:"))
+			if err != nil {
+				return cache.Entry{}, 0, err
+			}
+			cost = parsed
+		}
+	}
+	if !found {
+		return cache.Entry{}, 0, fmt.Errorf("%w on server", cache.ErrKeyNotFound)
+	}
+	return cache.NewEntry(value, cost), cost, nil
+}
+
+/*KeyPresent asks the server whether the key is cached.
+An unreachable server counts as not present*/
+func (cl *Client) KeyPresent(key string) bool {
+	lines, err := cl.do("present," + key)
+	if err != nil || len(lines) == 0 {
+		return false
+	}
+	return lines[0] == "PRESENT:true"
+}
+
+/*GetValue returns the cached entry without making the
+server load it on a miss*/
+func (cl *Client) GetValue(key string) (cache.Entry, error) {
+	lines, err := cl.do("get," + key)
+	if err != nil {
+		return cache.Entry{}, err
+	}
+	entry, _, err := parseEntry(lines)
+	return entry, err
+}
+
+/*SetValue stores the entry on the server.  Values
+can't contain newlines, the protocol is line based*/
+func (cl *Client) SetValue(key string, value cache.Entry) error {
+	if strings.Contains(value.Value(), "\n") {
+		return errors.New("Values can not contain newlines")
+	}
+	_, err := cl.do("set," + key + "," + strconv.Itoa(value.Cost()) + "," + value.Value())
+	return err
+}
+
+/*Delete drops the key on the server (and its peers)*/
+func (cl *Client) Delete(key string) error {
+	_, err := cl.do("delete," + key)
+	return err
+}
+
+/*Fetch asks the server for the key, letting it load the
+value on a miss.  The returned cost is 0 for a cache hit*/
+func (cl *Client) Fetch(key string) (cache.Entry, int, error) {
+	lines, err := cl.do("fetch," + key)
+	if err != nil {
+		return cache.Entry{}, 0, err
+	}
+	return parseEntry(lines)
+}
+
+/*Close hangs up every pooled connection*/
+func (cl *Client) Close() {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	for _, p := range cl.pools {
+		for len(p) > 0 {
+			pc := <-p
+			pc.conn.Close()
+		}
+	}
+}
+
+/*New builds a client, filling in defaults for anything
+left zero in the config*/
+func New(conf Config) *Client {
+	if conf.PoolSize == 0 {
+		conf.PoolSize = 4
+	}
+	if conf.Timeout == 0 {
+		conf.Timeout = time.Second
+	}
+	if conf.Backoff == 0 {
+		conf.Backoff = 50 * time.Millisecond
+	}
+	return &Client{conf: conf, pools: make(map[string]chan *poolConn)}
+}
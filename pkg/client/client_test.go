@@ -0,0 +1,137 @@
+package client
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+/*trackingListener remembers every connection it accepts, so a
+test can count them or hang them all up*/
+type trackingListener struct {
+	net.Listener
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func (l *trackingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.mu.Lock()
+		l.conns = append(l.conns, conn)
+		l.mu.Unlock()
+	}
+	return conn, err
+}
+
+func (l *trackingListener) accepted() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.conns)
+}
+
+func (l *trackingListener) drop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, conn := range l.conns {
+		conn.Close()
+	}
+}
+
+/*startServer runs an in-process server on a free port*/
+func startServer(t *testing.T) (string, *trackingListener) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracked := &trackingListener{Listener: ln}
+	cacheType := "LRU"
+	s := cache.NewServer(&cache.ServerConf{CacheType: &cacheType, CacheSize: 10})
+	go s.Serve(tracked)
+	t.Cleanup(func() { ln.Close() })
+	return ln.Addr().String(), tracked
+}
+
+/*deadAddr is a port nothing is listening on*/
+func deadAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func TestClientReusesPooledConnections(t *testing.T) {
+	addr, server := startServer(t)
+	cl := New(Config{Addrs: []string{addr}})
+	defer cl.Close()
+	if err := cl.SetValue("k", cache.NewEntry("v", 7)); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := cl.GetValue("k")
+	if err != nil || entry.Value() != "v" || entry.Cost() != 7 {
+		t.Fatalf("expected v at cost 7, got %v, %v", entry, err)
+	}
+	if !cl.KeyPresent("k") {
+		t.Fatal("expected k present")
+	}
+	if err := cl.Delete("k"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cl.GetValue("k"); err == nil {
+		t.Fatal("expected a miss after the delete")
+	}
+	if server.accepted() != 1 || len(cl.pool(addr)) != 1 {
+		t.Fatalf("expected one connection, back in the pool, got %d accepted and %d pooled", server.accepted(), len(cl.pool(addr)))
+	}
+}
+
+func TestClientRetriesAfterADroppedConnection(t *testing.T) {
+	addr, server := startServer(t)
+	cl := New(Config{Addrs: []string{addr}, Retries: 1, Backoff: time.Millisecond})
+	defer cl.Close()
+	cl.SetValue("k", cache.NewEntry("v", 1))
+	// the pooled connection is dead, the first try reads EOF off it
+	server.drop()
+	entry, err := cl.GetValue("k")
+	if err != nil || entry.Value() != "v" {
+		t.Fatalf("expected the retry to get v, got %v, %v", entry, err)
+	}
+	if server.accepted() != 2 {
+		t.Fatalf("expected the retry to dial again, got %d connections", server.accepted())
+	}
+}
+
+func TestClientGivesUpWithoutRetries(t *testing.T) {
+	addr, server := startServer(t)
+	cl := New(Config{Addrs: []string{addr}})
+	defer cl.Close()
+	cl.SetValue("k", cache.NewEntry("v", 1))
+	server.drop()
+	if _, err := cl.GetValue("k"); err == nil {
+		t.Fatal("expected the dropped connection to fail the only try")
+	}
+}
+
+func TestClientFailsOverToTheNextAddress(t *testing.T) {
+	addr, server := startServer(t)
+	cl := New(Config{Addrs: []string{deadAddr(t), addr}})
+	defer cl.Close()
+	if err := cl.SetValue("k", cache.NewEntry("v", 1)); err != nil {
+		t.Fatalf("expected the replica to take the write, got %v", err)
+	}
+	direct := New(Config{Addrs: []string{addr}})
+	defer direct.Close()
+	entry, err := direct.GetValue("k")
+	if err != nil || entry.Value() != "v" {
+		t.Fatalf("expected the write on the replica, got %v, %v", entry, err)
+	}
+	if server.accepted() != 2 {
+		t.Fatalf("expected one connection from each client, got %d", server.accepted())
+	}
+}
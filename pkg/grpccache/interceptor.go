@@ -0,0 +1,75 @@
+package grpccache
+
+import (
+	"context"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+/*Options configures the interceptor.  Methods lists the RPCs
+that are safe to cache, by full method name
+("/inventory.Stock/Get"), with how long to keep a response (0
+until it's evicted); anything not listed goes straight
+through*/
+type Options struct {
+	Methods map[string]time.Duration
+}
+
+var marshal = proto.MarshalOptions{Deterministic: true}
+
+/*Key is the cache key for a call: the method and the request
+message's encoding*/
+func Key(method string, req proto.Message) (string, error) {
+	encoded, err := marshal.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	return method + "\n" + string(encoded), nil
+}
+
+/*UnaryClientInterceptor answers calls to the listed methods
+from c when it can, and caches the replies of the ones it
+can't, each costing the microseconds the RPC took so LCR keeps
+the slow ones.  Only successful replies are cached, and only
+messages are, anything else goes straight through.  c must be
+safe for concurrent use (a Batched or a Sharded):
+
+	conn, err := grpc.Dial(addr, grpc.WithUnaryInterceptor(
+		grpccache.UnaryClientInterceptor(c, opts)))*/
+func UnaryClientInterceptor(c cache.Cache, opts Options) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		ttl, cached := opts.Methods[method]
+		request, isRequest := req.(proto.Message)
+		response, isResponse := reply.(proto.Message)
+		if !cached || !isRequest || !isResponse {
+			return invoker(ctx, method, req, reply, cc, callOpts...)
+		}
+		key, err := Key(method, request)
+		if err != nil {
+			return invoker(ctx, method, req, reply, cc, callOpts...)
+		}
+		entry, err := c.GetValue(key)
+		if err == nil && proto.Unmarshal([]byte(entry.Value()), response) == nil {
+			return nil
+		}
+		start := time.Now()
+		err = invoker(ctx, method, req, reply, cc, callOpts...)
+		if err != nil {
+			return err
+		}
+		took := time.Since(start)
+		encoded, err := proto.Marshal(response)
+		if err != nil {
+			return nil
+		}
+		entry = cache.NewEntry(string(encoded), int(took/time.Microsecond))
+		if ttl > 0 {
+			entry = entry.WithTTL(ttl)
+		}
+		c.SetValue(key, entry)
+		return nil
+	}
+}
@@ -0,0 +1,46 @@
+package grpccache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestInterceptorCachesListedMethods(t *testing.T) {
+	calls := 0
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		calls++
+		if req.(*wrapperspb.StringValue).Value == "broken" {
+			return errors.New("Unavailable")
+		}
+		reply.(*wrapperspb.StringValue).Value = "stock of " + req.(*wrapperspb.StringValue).Value
+		return nil
+	}
+	lru, _ := cache.NewCache(cache.LRU, 10)
+	intercept := UnaryClientInterceptor(cache.NewBatched(lru), Options{Methods: map[string]time.Duration{"/inventory.Stock/Get": time.Minute}})
+	call := func(method string, sku string) (string, error) {
+		reply := &wrapperspb.StringValue{}
+		err := intercept(context.Background(), method, wrapperspb.String(sku), reply, nil, invoker)
+		return reply.Value, err
+	}
+	call("/inventory.Stock/Get", "a")
+	reply, err := call("/inventory.Stock/Get", "a")
+	if err != nil || reply != "stock of a" || calls != 1 {
+		t.Fatalf("expected the second call answered from the cache, got %q after %d calls", reply, calls)
+	}
+	call("/inventory.Stock/Get", "b")
+	call("/inventory.Stock/Reserve", "a")
+	call("/inventory.Stock/Reserve", "a")
+	if calls != 4 {
+		t.Fatalf("expected other requests and unlisted methods to go through, got %d calls", calls)
+	}
+	call("/inventory.Stock/Get", "broken")
+	if _, err := call("/inventory.Stock/Get", "broken"); err == nil || calls != 6 {
+		t.Fatalf("expected failed calls not to be cached")
+	}
+}
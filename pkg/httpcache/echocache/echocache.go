@@ -0,0 +1,37 @@
+package echocache
+
+import (
+	"net/http"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+	"github.com/evizitei/lcr-cache/pkg/httpcache"
+	"github.com/labstack/echo/v4"
+)
+
+/*Middleware caches the responses of the routes it's put on
+in c, configured per route:
+
+	e.GET("/report", report, echocache.Middleware(c, httpcache.Options{TTL: time.Minute}))
+
+A handler's error goes to echo's error handler while the
+response is still being held back, so an error page is cached
+if its status is one httpcache caches (a 404) and not if it
+isn't (a 500)*/
+func Middleware(c cache.Cache, opts httpcache.Options) echo.MiddlewareFunc {
+	caching := httpcache.Middleware(c, opts)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			response := ctx.Response()
+			original := response.Writer
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response.Writer = w
+				if err := next(ctx); err != nil {
+					ctx.Error(err)
+				}
+				response.Writer = original
+			})
+			caching(handler).ServeHTTP(original, ctx.Request())
+			return nil
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package gincache
+
+import (
+	"net/http"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+	"github.com/evizitei/lcr-cache/pkg/httpcache"
+	"github.com/gin-gonic/gin"
+)
+
+/*writer sends what gin's handlers write to the response
+httpcache is holding back, leaving the rest of gin's
+ResponseWriter alone*/
+type writer struct {
+	gin.ResponseWriter
+	w      http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *writer) Header() http.Header { return w.w.Header() }
+
+func (w *writer) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.w.WriteHeader(status)
+	}
+}
+
+func (w *writer) WriteHeaderNow() { w.WriteHeader(http.StatusOK) }
+
+func (w *writer) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	n, err := w.w.Write(p)
+	w.size += n
+	return n, err
+}
+
+func (w *writer) WriteString(s string) (int, error) { return w.Write([]byte(s)) }
+
+func (w *writer) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *writer) Size() int { return w.size }
+
+func (w *writer) Written() bool { return w.status != 0 }
+
+/*Middleware caches the responses of the routes it's put on
+in c, configured per route:
+
+	r.GET("/report", gincache.Middleware(c, httpcache.Options{TTL: time.Minute}), report)
+
+A hit aborts the chain, so the handlers after it don't run*/
+func Middleware(c cache.Cache, opts httpcache.Options) gin.HandlerFunc {
+	caching := httpcache.Middleware(c, opts)
+	return func(ctx *gin.Context) {
+		ran := false
+		original := ctx.Writer
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ran = true
+			ctx.Writer = &writer{ResponseWriter: original, w: w}
+			ctx.Next()
+			ctx.Writer = original
+		})
+		caching(next).ServeHTTP(original, ctx.Request)
+		if !ran {
+			ctx.Abort()
+		}
+	}
+}
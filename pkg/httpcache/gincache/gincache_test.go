@@ -0,0 +1,44 @@
+package gincache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+	"github.com/evizitei/lcr-cache/pkg/httpcache"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddlewareCachesRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	lru, _ := cache.NewCache(cache.LRU, 10)
+	c := cache.NewBatched(lru)
+	calls := 0
+	byUser := httpcache.Options{TTL: time.Minute, KeyFunc: func(r *http.Request) string { return "report:" + r.URL.Query().Get("user") }}
+	router := gin.New()
+	router.GET("/report", Middleware(c, byUser), func(ctx *gin.Context) {
+		calls++
+		ctx.String(http.StatusOK, "report for %s", ctx.Query("user"))
+	})
+	router.GET("/live", func(ctx *gin.Context) {
+		calls++
+		ctx.String(http.StatusOK, "live")
+	})
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w
+	}
+	get("/report?user=ann&page=1")
+	w := get("/report?user=ann&page=2")
+	if calls != 1 || w.Body.String() != "report for ann" || w.Header().Get("X-Cache") != "HIT" || w.Code != http.StatusOK {
+		t.Fatalf("expected the route's key function to share the response, got %q after %d calls", w.Body.String(), calls)
+	}
+	get("/live")
+	get("/live")
+	if calls != 3 || !lru.KeyPresent("report:ann") {
+		t.Fatalf("expected routes without the middleware not to be cached")
+	}
+}
@@ -0,0 +1,200 @@
+package httpcache
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+/*Options configures the middleware.  Headers are the request
+headers that pick a different response (Accept-Encoding,
+Authorization...), besides the method and URL, unless KeyFunc
+is set to work out the key itself.  Principal, if set, names
+the user a request is for (from its session or token), so
+each user gets their own copy.  TTL is how long a response is
+kept (0 until it's evicted).  With HonorCacheControl a
+response's no-store, private and max-age (or s-maxage) are
+obeyed, and a request's no-cache skips the cached copy while
+no-store skips the cache altogether*/
+type Options struct {
+	Headers           []string
+	KeyFunc           func(r *http.Request) string
+	Principal         func(r *http.Request) string
+	TTL               time.Duration
+	HonorCacheControl bool
+}
+
+/*storedResponse is what's cached for a request*/
+type storedResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+/*cacheable are the statuses cached without being told they
+can be*/
+var cacheable = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+/*Key is the cache key for the request: method, host and URL,
+and the value of each of the headers*/
+func Key(r *http.Request, headers []string) string {
+	key := r.Method + " " + r.Host + r.URL.RequestURI()
+	for _, name := range headers {
+		key += "\n" + http.CanonicalHeaderKey(name) + ": " + strings.Join(r.Header.Values(name), ", ")
+	}
+	return key
+}
+
+/*directives reads a Cache-Control header into its directives
+and their values, lower cased*/
+func directives(header string) map[string]string {
+	found := map[string]string{}
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			found[strings.ToLower(name)] = strings.Trim(value, "\"")
+		}
+	}
+	return found
+}
+
+/*recorder holds a response back until it's complete, so it
+can be cached*/
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *recorder) Header() http.Header { return rec.header }
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+	rec.WriteHeader(http.StatusOK)
+	return rec.body.Write(p)
+}
+
+func write(w http.ResponseWriter, stored storedResponse, outcome string) {
+	for name, values := range stored.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("X-Cache", outcome)
+	w.WriteHeader(stored.Status)
+	w.Write(stored.Body)
+}
+
+/*ttlFor is how long to keep the response, false if it
+mustn't be kept at all*/
+func (opts Options) ttlFor(stored storedResponse) (time.Duration, bool) {
+	if !cacheable[stored.Status] {
+		return 0, false
+	}
+	if !opts.HonorCacheControl {
+		return opts.TTL, true
+	}
+	cc := directives(stored.Header.Get("Cache-Control"))
+	_, noStore := cc["no-store"]
+	_, private := cc["private"]
+	if noStore || private {
+		return 0, false
+	}
+	for _, name := range []string{"s-maxage", "max-age"} {
+		value, ok := cc[name]
+		if !ok {
+			continue
+		}
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	return opts.TTL, true
+}
+
+/*key is where the response to the request is cached, given
+the headers its URL's responses vary on*/
+func (opts Options) key(r *http.Request, vary []string) string {
+	if opts.KeyFunc != nil {
+		return opts.KeyFunc(r)
+	}
+	key := Key(r, union(opts.Headers, vary))
+	if opts.Principal != nil {
+		key += "\nPrincipal: " + opts.Principal(r)
+	}
+	return key
+}
+
+/*Middleware caches GET and HEAD responses in c, which must be
+safe for concurrent use (a Batched, a Sharded or an Indexed),
+each entry costing the microseconds the handler took to
+produce it so LCR keeps the slow pages.  A response's Vary
+header is remembered for its URL, so later requests are keyed
+on those headers too, and Vary: * isn't cached.  Given an
+Indexed, every variant is tagged with its URL for Invalidate.
+Responses are held back until they're complete, so a handler
+that streams doesn't.  Every response says X-Cache: HIT or
+MISS*/
+func Middleware(c cache.Cache, opts Options) func(http.Handler) http.Handler {
+	responses := cache.NewTyped(c, cache.GobCodec)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			vary := []string{}
+			responses.Get(varyKey(r), &vary)
+			key := opts.key(r, vary)
+			request := map[string]string{}
+			if opts.HonorCacheControl {
+				request = directives(r.Header.Get("Cache-Control"))
+			}
+			_, noCache := request["no-cache"]
+			_, noStore := request["no-store"]
+			if !noCache && !noStore {
+				var stored storedResponse
+				if _, err := responses.Get(key, &stored); err == nil {
+					write(w, stored, "HIT")
+					return
+				}
+			}
+			rec := &recorder{header: http.Header{}}
+			start := time.Now()
+			next.ServeHTTP(rec, r)
+			took := time.Since(start)
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			stored := storedResponse{Status: rec.status, Header: rec.header, Body: rec.body.Bytes()}
+			write(w, stored, "MISS")
+			ttl, keep := opts.ttlFor(stored)
+			names, varies := varyNames(stored.Header)
+			if !keep || !varies || noStore {
+				return
+			}
+			cost := int(took / time.Microsecond)
+			if len(names) > 0 && opts.KeyFunc == nil {
+				put(c, varyKey(r), location(r), names, cost, ttl)
+				key = opts.key(r, names)
+			}
+			put(c, key, location(r), stored, cost, ttl)
+		})
+	}
+}
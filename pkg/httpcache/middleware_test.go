@@ -0,0 +1,55 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+func get(h http.Handler, url string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, url, nil)
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestMiddlewareCachesResponses(t *testing.T) {
+	calls := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path == "/private" {
+			w.Header().Set("Cache-Control", "private")
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("report for " + r.Header.Get("Accept-Language")))
+	})
+	lru, _ := cache.NewCache(cache.LRU, 10)
+	h := Middleware(cache.NewBatched(lru), Options{Headers: []string{"Accept-Language"}, TTL: time.Minute, HonorCacheControl: true})(handler)
+	english := http.Header{"Accept-Language": {"en"}}
+	get(h, "/report", english)
+	w := get(h, "/report", english)
+	if calls != 1 || w.Header().Get("X-Cache") != "HIT" || w.Body.String() != "report for en" || w.Header().Get("Content-Type") != "text/plain" {
+		t.Fatalf("expected the second request served from the cache, got %q after %d calls", w.Body.String(), calls)
+	}
+	if w := get(h, "/report", http.Header{"Accept-Language": {"fr"}}); w.Body.String() != "report for fr" || calls != 2 {
+		t.Fatalf("expected a different header to get its own response")
+	}
+	get(h, "/report", http.Header{"Accept-Language": {"en"}, "Cache-Control": {"no-cache"}})
+	if calls != 3 {
+		t.Fatalf("expected no-cache to skip the cached copy")
+	}
+	get(h, "/private", nil)
+	get(h, "/private", nil)
+	if calls != 5 {
+		t.Fatalf("expected a private response not to be cached")
+	}
+	if !lru.KeyPresent(Key(httptest.NewRequest(http.MethodGet, "/report", http.NoBody), nil) + "\nAccept-Language: en") {
+		t.Fatalf("expected the response keyed by method, URL and header")
+	}
+}
@@ -0,0 +1,136 @@
+package httpcache
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+/*revalidated is what a Transport keeps per URL: the response
+and until when it can be used without asking the server*/
+type revalidated struct {
+	Response storedResponse
+	Fresh    time.Time
+}
+
+/*Transport is a caching http.RoundTripper for clients, CLI
+tools and crawlers: GET responses are kept in the cache and
+handed back while their max-age lasts, after which they're
+revalidated with If-None-Match or If-Modified-Since when the
+server sent an ETag or a Last-Modified, so a 304 only costs a
+round trip and not the body.  Each entry costs the
+microseconds the first round trip took.  The cache must be
+safe for concurrent use, as an http.Client is.  Responses
+carry X-Cache: HIT, REVALIDATED or MISS*/
+type Transport struct {
+	base      http.RoundTripper
+	responses *cache.Typed
+}
+
+/*freshUntil is when the response should be checked again, now
+if it says no-cache or doesn't say*/
+func freshUntil(header http.Header, now time.Time) time.Time {
+	cc := directives(header.Get("Cache-Control"))
+	if _, noCache := cc["no-cache"]; noCache {
+		return now
+	}
+	seconds, err := strconv.Atoi(cc["max-age"])
+	if err != nil || seconds <= 0 {
+		return now
+	}
+	return now.Add(time.Duration(seconds) * time.Second)
+}
+
+func (t *Transport) respond(req *http.Request, stored storedResponse, outcome string) *http.Response {
+	header := stored.Header.Clone()
+	header.Set("X-Cache", outcome)
+	return &http.Response{
+		Status:        strconv.Itoa(stored.Status) + " " + http.StatusText(stored.Status),
+		StatusCode:    stored.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(stored.Body)),
+		ContentLength: int64(len(stored.Body)),
+		Request:       req,
+	}
+}
+
+/*RoundTrip answers GET requests from the cache when it can,
+and sends everything else to the base transport*/
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.base.RoundTrip(req)
+	}
+	requested := directives(req.Header.Get("Cache-Control"))
+	if _, noStore := requested["no-store"]; noStore {
+		return t.base.RoundTrip(req)
+	}
+	key := req.URL.String()
+	var cached revalidated
+	entry, err := t.responses.Get(key, &cached)
+	found := err == nil
+	_, noCache := requested["no-cache"]
+	if found && !noCache && time.Now().Before(cached.Fresh) {
+		return t.respond(req, cached.Response, "HIT"), nil
+	}
+	outgoing := req
+	if found {
+		etag := cached.Response.Header.Get("ETag")
+		modified := cached.Response.Header.Get("Last-Modified")
+		if etag != "" || modified != "" {
+			outgoing = req.Clone(req.Context())
+			if etag != "" {
+				outgoing.Header.Set("If-None-Match", etag)
+			}
+			if modified != "" {
+				outgoing.Header.Set("If-Modified-Since", modified)
+			}
+		}
+	}
+	start := time.Now()
+	resp, err := t.base.RoundTrip(outgoing)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && outgoing != req {
+		resp.Body.Close()
+		for name, values := range resp.Header {
+			cached.Response.Header[name] = values
+		}
+		cached.Fresh = freshUntil(cached.Response.Header, time.Now())
+		t.responses.Set(key, cached, entry.Cost())
+		return t.respond(req, cached.Response, "REVALIDATED"), nil
+	}
+	if !cacheable[resp.StatusCode] {
+		return resp, nil
+	}
+	if _, noStore := directives(resp.Header.Get("Cache-Control"))["no-store"]; noStore {
+		return resp, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	took := time.Since(start)
+	stored := storedResponse{Status: resp.StatusCode, Header: resp.Header, Body: body}
+	t.responses.Set(key, revalidated{Response: stored, Fresh: freshUntil(resp.Header, time.Now())}, int(took/time.Microsecond))
+	return t.respond(req, stored, "MISS"), nil
+}
+
+/*NewTransport caches base's GET responses in c, base is
+http.DefaultTransport if nil:
+
+	client := &http.Client{Transport: httpcache.NewTransport(c, nil)}*/
+func NewTransport(c cache.Cache, base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{base: base, responses: cache.NewTyped(c, cache.GobCodec)}
+}
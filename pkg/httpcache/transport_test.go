@@ -0,0 +1,47 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+func TestTransportRevalidates(t *testing.T) {
+	full, conditional := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fresh" {
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			conditional++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		w.Write([]byte("page " + r.URL.Path))
+	}))
+	defer server.Close()
+	lru, _ := cache.NewCache(cache.LRU, 10)
+	client := &http.Client{Transport: NewTransport(cache.NewBatched(lru), nil)}
+	fetch := func(path string) (string, string) {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body), resp.Header.Get("X-Cache")
+	}
+	fetch("/fresh")
+	if body, outcome := fetch("/fresh"); body != "page /fresh" || outcome != "HIT" || full != 1 {
+		t.Fatalf("expected a fresh response from the cache, got %q %s", body, outcome)
+	}
+	fetch("/stale")
+	if body, outcome := fetch("/stale"); body != "page /stale" || outcome != "REVALIDATED" || full != 2 || conditional != 1 {
+		t.Fatalf("expected a stale response revalidated with its ETag, got %q %s", body, outcome)
+	}
+}
@@ -0,0 +1,88 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+/*location is the part of the key every variant of a URL
+shares*/
+func location(r *http.Request) string {
+	return r.Host + r.URL.RequestURI()
+}
+
+/*urlTag is what every variant of a URL is tagged with when
+the cache is an Indexed*/
+func urlTag(location string) string {
+	return "url:" + location
+}
+
+/*varyKey is where the headers a URL's responses vary on are
+kept*/
+func varyKey(r *http.Request) string {
+	return "vary " + r.Method + " " + location(r)
+}
+
+/*varyNames are the request headers the response says it
+varies on, false for Vary: * (it can't be cached)*/
+func varyNames(header http.Header) ([]string, bool) {
+	names := []string{}
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return nil, false
+			}
+			if name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names, true
+}
+
+/*union is the configured headers and the ones a response
+varies on, each once*/
+func union(headers []string, names []string) []string {
+	all := []string{}
+	seen := map[string]bool{}
+	for _, name := range append(append([]string{}, headers...), names...) {
+		name = http.CanonicalHeaderKey(name)
+		if !seen[name] {
+			seen[name] = true
+			all = append(all, name)
+		}
+	}
+	return all
+}
+
+/*put caches the value, tagged with its URL if the cache keeps
+tags*/
+func put(c cache.Cache, key string, location string, v interface{}, cost int, ttl time.Duration) error {
+	data, err := cache.GobCodec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	entry := cache.NewEntry(string(data), cost).WithTTL(ttl)
+	indexed, ok := c.(*cache.Indexed)
+	if ok {
+		return indexed.SetTagged(key, entry, urlTag(location))
+	}
+	return c.SetValue(key, entry)
+}
+
+/*Invalidate drops every cached variant of the URL (whatever
+method, headers or principal it was cached for) from a cache
+the middleware was given as an Indexed, returning how many
+entries went*/
+func Invalidate(ix *cache.Indexed, rawURL string) (int, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return 0, err
+	}
+	return ix.InvalidateTag(urlTag(u.Host + u.RequestURI())), nil
+}
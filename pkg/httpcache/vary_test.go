@@ -0,0 +1,46 @@
+package httpcache
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+func TestMiddlewareKeysOnVary(t *testing.T) {
+	calls := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Vary", "Accept-Encoding")
+		if r.URL.Path == "/anything" {
+			w.Header().Set("Vary", "*")
+		}
+		w.Write([]byte(r.URL.Path + " as " + r.Header.Get("Accept-Encoding") + " for " + r.Header.Get("X-User")))
+	})
+	lru, _ := cache.NewCache(cache.LRU, 20)
+	c := cache.NewIndexed(lru)
+	h := Middleware(c, Options{Principal: func(r *http.Request) string { return r.Header.Get("X-User") }})(handler)
+	gzip := http.Header{"Accept-Encoding": {"gzip"}, "X-User": {"ann"}}
+	get(h, "http://example.com/report", gzip)
+	if w := get(h, "http://example.com/report", http.Header{"Accept-Encoding": {"br"}, "X-User": {"ann"}}); w.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("expected another encoding to be another variant")
+	}
+	if w := get(h, "http://example.com/report", gzip); w.Header().Get("X-Cache") != "HIT" || w.Body.String() != "/report as gzip for ann" {
+		t.Fatalf("expected the gzip variant cached, got %q", w.Body.String())
+	}
+	if w := get(h, "http://example.com/report", http.Header{"Accept-Encoding": {"gzip"}, "X-User": {"bob"}}); w.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("expected each principal to get their own copy")
+	}
+	get(h, "http://example.com/anything", nil)
+	get(h, "http://example.com/anything", nil)
+	if calls != 5 {
+		t.Fatalf("expected Vary: * not to be cached, got %d calls", calls)
+	}
+	dropped, err := Invalidate(c, "http://example.com/report")
+	if err != nil || dropped != 4 {
+		t.Fatalf("expected the three variants and the vary record dropped, got %d %v", dropped, err)
+	}
+	if w := get(h, "http://example.com/report", gzip); w.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("expected the URL invalidated")
+	}
+}
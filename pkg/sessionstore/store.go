@@ -0,0 +1,103 @@
+package sessionstore
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+	"github.com/gorilla/sessions"
+)
+
+/*Store is a gorilla/sessions Store keeping sessions in a
+cache, an LRU usually, so web apps don't need Redis for them.
+The cookie only holds the session's random ID, the values stay
+server side, encoded with gob (so custom types need
+gob.Register, as with gorilla's own stores).  Sessions expire
+after going idle for the store's idle time, every request that
+loads one pushes that back (sliding expiration), and a full
+cache evicts them like any other entry, which logs the user
+out.  The cache must be safe for concurrent use (a Batched or
+a Sharded).  Options are copied into every new session, as
+with gorilla's stores*/
+type Store struct {
+	Options  *sessions.Options
+	sessions *cache.Typed
+	idle     time.Duration
+}
+
+/*newID is a random session ID*/
+func newID() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+/*Get is the request's session of that name, loading it once
+per request*/
+func (s *Store) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+/*New loads the session the request's cookie names, or starts
+a new one if there isn't one or it's gone from the cache*/
+func (s *Store) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	values := map[interface{}]interface{}{}
+	if _, err := s.sessions.Get(cookie.Value, &values); err != nil {
+		return session, nil
+	}
+	session.ID = cookie.Value
+	session.Values = values
+	session.IsNew = false
+	return session, nil
+}
+
+/*Save writes the session to the cache and its ID to the
+cookie.  A negative MaxAge deletes it*/
+func (s *Store) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			s.sessions.Delete(session.ID)
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+	if session.ID == "" {
+		id, err := newID()
+		if err != nil {
+			return err
+		}
+		session.ID = id
+	}
+	entry := cache.NewEntry("", 1).WithIdleTTL(s.idle)
+	if session.Options.MaxAge > 0 {
+		entry = entry.WithTTL(time.Duration(session.Options.MaxAge) * time.Second)
+	}
+	if err := s.sessions.SetEntry(session.ID, session.Values, entry); err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), session.ID, session.Options))
+	return nil
+}
+
+/*NewStore keeps sessions in c until they've gone idle for
+idle (0 until they're evicted), with cookies for the whole
+site that scripts can't read*/
+func NewStore(c cache.Cache, idle time.Duration) *Store {
+	return &Store{
+		Options:  &sessions.Options{Path: "/", HttpOnly: true, SameSite: http.SameSiteLaxMode},
+		sessions: cache.NewTyped(c, cache.GobCodec),
+		idle:     idle,
+	}
+}
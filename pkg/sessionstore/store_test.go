@@ -0,0 +1,58 @@
+package sessionstore
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+func TestStoreKeepsSessions(t *testing.T) {
+	lru, _ := cache.NewCache(cache.LRU, 10)
+	store := NewStore(cache.NewBatched(lru), time.Hour)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, err := store.Get(r, "app")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.URL.Path == "/logout" {
+			session.Options.MaxAge = -1
+		} else {
+			visits, _ := session.Values["visits"].(int)
+			session.Values["visits"] = visits + 1
+		}
+		if err := session.Save(r, w); err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte{byte('0' + session.Values["visits"].(int))})
+	})
+	visit := func(path string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		for _, cookie := range cookies {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	first := visit("/", nil)
+	cookies := first.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly {
+		t.Fatalf("expected an HttpOnly session cookie, got %v", cookies)
+	}
+	if w := visit("/", cookies); w.Body.String() != "2" {
+		t.Fatalf("expected the session loaded from the cache, got %s visits", w.Body.String())
+	}
+	if len(lru.(cache.Exporter).Export()) != 1 {
+		t.Fatalf("expected one session cached")
+	}
+	visit("/logout", cookies)
+	if len(lru.(cache.Exporter).Export()) != 0 {
+		t.Fatalf("expected the session deleted on logout")
+	}
+	if w := visit("/", cookies); w.Body.String() != "1" {
+		t.Fatalf("expected a new session after logout, got %s visits", w.Body.String())
+	}
+}
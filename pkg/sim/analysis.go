@@ -0,0 +1,150 @@
+package sim
+
+import (
+	"errors"
+	"io"
+)
+
+/*Bucket counts the reuse distances from Min up to (but not
+including) Max*/
+type Bucket struct {
+	Min   int
+	Max   int
+	Count int
+}
+
+/*ReuseHistogram is how far apart (in distinct keys) repeat
+requests for the same key are, in power of two buckets.  A
+cache of size n hits every request with a distance below n
+under LRU, so a histogram bunched at small distances favors
+LRU, and a long flat tail means recency says little and
+frequency or cost has to do the work*/
+type ReuseHistogram struct {
+	Requests int
+	Unique   int
+	OneHit   int
+	Buckets  []Bucket
+}
+
+/*Below is the share of requests with a reuse distance under
+the size, the hit ratio an LRU cache of that size would get.
+Only whole buckets count, so sizes between powers of two are
+rounded down to the one below*/
+func (h ReuseHistogram) Below(size int) float64 {
+	if h.Requests == 0 {
+		return 0
+	}
+	count := 0
+	for _, bucket := range h.Buckets {
+		if bucket.Max <= size {
+			count += bucket.Count
+		}
+	}
+	return float64(count) / float64(h.Requests)
+}
+
+/*bucketFor is the index of the power of two bucket holding
+the distance: 0 for 0, 1 for 1, 2 for 2-3, 3 for 4-7...*/
+func bucketFor(distance int) int {
+	index := 0
+	for distance > 0 {
+		distance >>= 1
+		index++
+	}
+	return index
+}
+
+/*ReuseDistances reads the trace's gets and builds their
+reuse distance histogram.  Unique is the number of distinct
+keys and OneHit how many were only ever asked for once*/
+func ReuseDistances(trace Trace) (ReuseHistogram, error) {
+	distances := newStackDistance()
+	requests := make(map[string]int)
+	histogram := ReuseHistogram{}
+	for {
+		access, err := trace.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return ReuseHistogram{}, err
+		}
+		if access.Op != Get {
+			continue
+		}
+		histogram.Requests++
+		requests[access.Key]++
+		distance, seen := distances.access(access.Key)
+		if !seen {
+			continue
+		}
+		index := bucketFor(distance)
+		for len(histogram.Buckets) <= index {
+			min := 0
+			if len(histogram.Buckets) > 0 {
+				min = 1 << (len(histogram.Buckets) - 1)
+			}
+			histogram.Buckets = append(histogram.Buckets, Bucket{Min: min, Max: 1 << len(histogram.Buckets)})
+		}
+		histogram.Buckets[index].Count++
+	}
+	histogram.Unique = len(requests)
+	for _, count := range requests {
+		if count == 1 {
+			histogram.OneHit++
+		}
+	}
+	return histogram, nil
+}
+
+/*WorkingSetPoint is how many distinct keys the last window
+requests touched, as of a point in the trace*/
+type WorkingSetPoint struct {
+	Request  int
+	Distinct int
+}
+
+/*WorkingSet slides a window of the given number of requests
+over the trace's gets and samples its distinct key count
+every step requests (0 for once a window).  A flat line is a
+stable working set a cache around that size can hold; steps
+and climbs mean the hot set shifts and recency matters more
+than history*/
+func WorkingSet(trace Trace, window int, step int) ([]WorkingSetPoint, error) {
+	if window <= 0 {
+		return nil, errors.New("Working set window has to be at least one request")
+	}
+	if step <= 0 {
+		step = window
+	}
+	recent := make([]string, window)
+	counts := make(map[string]int)
+	points := []WorkingSetPoint{}
+	request := 0
+	for {
+		access, err := trace.Next()
+		if err == io.EOF {
+			return points, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if access.Op != Get {
+			continue
+		}
+		slot := request % window
+		if request >= window {
+			old := recent[slot]
+			counts[old]--
+			if counts[old] == 0 {
+				delete(counts, old)
+			}
+		}
+		recent[slot] = access.Key
+		counts[access.Key]++
+		request++
+		if request%step == 0 {
+			points = append(points, WorkingSetPoint{Request: request, Distinct: len(counts)})
+		}
+	}
+}
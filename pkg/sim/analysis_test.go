@@ -0,0 +1,66 @@
+package sim
+
+import (
+	"strings"
+	"testing"
+)
+
+func lines(keys string) Trace {
+	return NewLineTrace(strings.NewReader(strings.ReplaceAll(keys, " ", "\n") + "\n"))
+}
+
+func TestReuseDistances(t *testing.T) {
+	// a b c a b: both repeats have two other keys in between
+	histogram, err := ReuseDistances(lines("a b c a b a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if histogram.Requests != 6 || histogram.Unique != 3 || histogram.OneHit != 1 {
+		t.Fatalf("unexpected totals %+v", histogram)
+	}
+	// a, b at distance 2 (bucket 2-3), the last a at distance 1
+	if len(histogram.Buckets) != 3 || histogram.Buckets[1].Count != 1 || histogram.Buckets[2].Count != 2 {
+		t.Fatalf("unexpected buckets %+v", histogram.Buckets)
+	}
+	if below := histogram.Below(2); below != 1.0/6 {
+		t.Fatalf("Below(2) = %f", below)
+	}
+}
+
+func TestReuseDistancesMatchesLRU(t *testing.T) {
+	keys := []string{}
+	for i := 0; i < 5000; i++ {
+		keys = append(keys, string(rune('a'+(i*i+i/7)%23)))
+	}
+	histogram, _ := ReuseDistances(lines(strings.Join(keys, " ")))
+	for _, size := range []int{4, 8, 16} {
+		curve, _ := EstimateCurve(lines(strings.Join(keys, " ")), 1, []int{size})
+		if histogram.Below(size) != curve[0].HitRatio {
+			t.Fatalf("size %d: histogram %f, exact curve %f", size, histogram.Below(size), curve[0].HitRatio)
+		}
+	}
+}
+
+func TestWorkingSet(t *testing.T) {
+	points, err := WorkingSet(lines("a a b b c d c d e e"), 4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int{1, 2, 3, 2, 3}
+	if len(points) != len(want) {
+		t.Fatalf("expected %d points, got %+v", len(want), points)
+	}
+	for i, point := range points {
+		if point.Request != 2*(i+1) || point.Distinct != want[i] {
+			t.Fatalf("point %d: %+v, expected %d distinct", i, point, want[i])
+		}
+	}
+}
+
+func TestWorkingSetRejectsEmptyWindow(t *testing.T) {
+	for _, window := range []int{0, -1} {
+		if _, err := WorkingSet(lines("a b"), window, 0); err == nil {
+			t.Fatalf("window %d: expected an error", window)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package sim
+
+import (
+	"container/heap"
+	"io"
+)
+
+/*neverUsed marks an access whose key isn't read again (or
+is overwritten or deleted first)*/
+const neverUsed = int(^uint(0) >> 1)
+
+/*pricedAccesses reads the whole trace, filling in costs the
+same way Replay does*/
+func pricedAccesses(trace Trace, costFn CostFunc) ([]Access, error) {
+	accesses := []Access{}
+	for {
+		access, err := trace.Next()
+		if err == io.EOF {
+			return accesses, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if access.Cost == 0 && costFn != nil {
+			access.Cost = costFn(access.Key)
+		}
+		if access.Cost == 0 {
+			access.Cost = 1
+		}
+		accesses = append(accesses, access)
+	}
+}
+
+/*nextUses is, for every access, the index of the next read
+of the same key that would still see this value*/
+func nextUses(accesses []Access) []int {
+	next := make([]int, len(accesses))
+	upcoming := make(map[string]int)
+	for i := len(accesses) - 1; i >= 0; i-- {
+		access := accesses[i]
+		use, ok := upcoming[access.Key]
+		if !ok {
+			use = neverUsed
+		}
+		next[i] = use
+		if access.Op == Get {
+			upcoming[access.Key] = i
+		} else {
+			// a write or delete hides the old value from later reads
+			delete(upcoming, access.Key)
+		}
+	}
+	return next
+}
+
+/*futureUse is a resident key and when it's next read*/
+type futureUse struct {
+	key  string
+	next int
+}
+
+/*futureHeap puts the resident key read furthest in the
+future on top, stale entries are skipped when popped*/
+type futureHeap []futureUse
+
+func (h futureHeap) Len() int            { return len(h) }
+func (h futureHeap) Less(i, j int) bool  { return h[i].next > h[j].next }
+func (h futureHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *futureHeap) Push(x interface{}) { *h = append(*h, x.(futureUse)) }
+
+func (h *futureHeap) Pop() interface{} {
+	old := *h
+	use := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return use
+}
+
+/*chooser decides, for a key that isn't resident, whether to
+cache it and what to evict for it*/
+type chooser interface {
+	admit(key string, cost int, next int, now int)
+	touch(key string, cost int, next int)
+	drop(key string)
+	resident(key string) bool
+}
+
+/*furthestFirst is Belady's MIN: when full, drop whichever
+of the residents and the newcomer is read furthest away*/
+type furthestFirst struct {
+	size      int
+	residents map[string]int
+	future    futureHeap
+}
+
+func (ff *furthestFirst) resident(key string) bool {
+	_, ok := ff.residents[key]
+	return ok
+}
+
+func (ff *furthestFirst) touch(key string, cost int, next int) {
+	ff.residents[key] = next
+	heap.Push(&ff.future, futureUse{key: key, next: next})
+}
+
+func (ff *furthestFirst) drop(key string) {
+	delete(ff.residents, key)
+}
+
+/*furthest pops stale heap entries until the top is a real
+resident*/
+func (ff *furthestFirst) furthest() futureUse {
+	for {
+		top := ff.future[0]
+		next, ok := ff.residents[top.key]
+		if ok && next == top.next {
+			return top
+		}
+		heap.Pop(&ff.future)
+	}
+}
+
+func (ff *furthestFirst) admit(key string, cost int, next int, now int) {
+	if next == neverUsed || ff.size <= 0 {
+		return
+	}
+	if len(ff.residents) >= ff.size {
+		victim := ff.furthest()
+		if victim.next <= next {
+			// the newcomer is the one to give up on
+			return
+		}
+		heap.Pop(&ff.future)
+		delete(ff.residents, victim.key)
+	}
+	ff.touch(key, cost, next)
+}
+
+/*cheapestPerWait evicts whatever saves the least cost per
+request until its next read (never read again being free)*/
+type cheapestPerWait struct {
+	size      int
+	residents map[string]futureUse
+	costs     map[string]int
+}
+
+func (cw *cheapestPerWait) resident(key string) bool {
+	_, ok := cw.residents[key]
+	return ok
+}
+
+func (cw *cheapestPerWait) touch(key string, cost int, next int) {
+	cw.residents[key] = futureUse{key: key, next: next}
+	cw.costs[key] = cost
+}
+
+func (cw *cheapestPerWait) drop(key string) {
+	delete(cw.residents, key)
+	delete(cw.costs, key)
+}
+
+func keepValue(cost int, next int, now int) float64 {
+	if next == neverUsed {
+		return 0
+	}
+	return float64(cost) / float64(next-now)
+}
+
+func (cw *cheapestPerWait) admit(key string, cost int, next int, now int) {
+	value := keepValue(cost, next, now)
+	if value == 0 || cw.size <= 0 {
+		return
+	}
+	if len(cw.residents) >= cw.size {
+		victim := ""
+		lowest := value
+		for residentKey, use := range cw.residents {
+			residentValue := keepValue(cw.costs[residentKey], use.next, now)
+			if residentValue < lowest {
+				victim = residentKey
+				lowest = residentValue
+			}
+		}
+		if victim == "" {
+			return
+		}
+		cw.drop(victim)
+	}
+	cw.touch(key, cost, next)
+}
+
+/*replayOffline runs the accesses against an offline chooser*/
+func replayOffline(accesses []Access, choose chooser) Result {
+	next := nextUses(accesses)
+	result := Result{}
+	for i, access := range accesses {
+		size := int64(access.Size)
+		if size == 0 {
+			size = 1
+		}
+		if access.Op == Get {
+			result.Requests++
+			result.Bytes += size
+			if choose.resident(access.Key) {
+				result.Hits++
+				result.BytesHit += size
+				result.CostSaved += int64(access.Cost)
+				choose.touch(access.Key, access.Cost, next[i])
+				continue
+			}
+			result.Misses++
+			result.CostMissed += int64(access.Cost)
+			choose.admit(access.Key, access.Cost, next[i], i)
+		} else if access.Op == Set {
+			result.Sets++
+			choose.drop(access.Key)
+			choose.admit(access.Key, access.Cost, next[i], i)
+		} else if access.Op == Delete {
+			result.Deletes++
+			choose.drop(access.Key)
+		}
+	}
+	return result
+}
+
+/*Belady is the best hit ratio any policy of the size could
+get on the trace (Belady's offline MIN, allowed to not cache
+a key at all).  It reads the whole trace into memory*/
+func Belady(trace Trace, size int, costFn CostFunc) (Result, error) {
+	accesses, err := pricedAccesses(trace, costFn)
+	if err != nil {
+		return Result{}, err
+	}
+	return replayOffline(accesses, &furthestFirst{size: size, residents: make(map[string]int)}), nil
+}
+
+/*CostBelady is an offline reference for cost hit ratio: with
+the future known it keeps whatever saves the most cost per
+request of waiting.  The true cost optimum takes a min-cost
+flow to find, this is a strong practical stand-in for it,
+not a proven bound.  It reads the whole trace into memory*/
+func CostBelady(trace Trace, size int, costFn CostFunc) (Result, error) {
+	accesses, err := pricedAccesses(trace, costFn)
+	if err != nil {
+		return Result{}, err
+	}
+	return replayOffline(accesses, &cheapestPerWait{size: size, residents: make(map[string]futureUse), costs: make(map[string]int)}), nil
+}
@@ -0,0 +1,177 @@
+package sim
+
+import (
+	"io"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+/*sliceTrace replays a fixed list of accesses*/
+type sliceTrace struct {
+	accesses []Access
+}
+
+func (st *sliceTrace) Next() (Access, error) {
+	if len(st.accesses) == 0 {
+		return Access{}, io.EOF
+	}
+	access := st.accesses[0]
+	st.accesses = st.accesses[1:]
+	return access, nil
+}
+
+func TestBelady(t *testing.T) {
+	cases := []struct {
+		keys string
+		size int
+		hits int
+	}{
+		// the textbook reference string, 7 faults with 3 frames
+		{"1 2 3 4 1 2 5 1 2 3 4 5", 3, 5},
+		{"1 2 3 4 1 2 5 1 2 3 4 5", 4, 6},
+		{"a a a a", 1, 3},
+		{"a b a b a b", 0, 0},
+		// keeping b would push a out for nothing
+		{"a b a b", 1, 1},
+		{"a b c d", 2, 0},
+	}
+	for _, tc := range cases {
+		result, err := Belady(lines(tc.keys), tc.size, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Hits != tc.hits || result.Requests != len(strings.Fields(tc.keys)) {
+			t.Fatalf("%q size %d: %d hits of %d, expected %d", tc.keys, tc.size, result.Hits, result.Requests, tc.hits)
+		}
+	}
+}
+
+func TestBeladyWritesHideOldValues(t *testing.T) {
+	trace := &sliceTrace{accesses: []Access{
+		{Key: "a", Op: Get}, {Key: "a", Op: Set}, {Key: "a", Op: Get},
+		{Key: "b", Op: Get}, {Key: "b", Op: Delete}, {Key: "b", Op: Get},
+	}}
+	result, err := Belady(trace, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the set is what the following get hits, the delete leaves a miss
+	if result.Hits != 1 || result.Sets != 1 || result.Deletes != 1 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
+
+/*bestHits searches every choice of what to keep for the most
+hits a cache of the size could get*/
+func bestHits(keys []string, size int) int {
+	memo := map[string]int{}
+	var search func(i int, residents []string) int
+	search = func(i int, residents []string) int {
+		if i == len(keys) {
+			return 0
+		}
+		state := strconv.Itoa(i) + ":" + strings.Join(residents, " ")
+		if best, ok := memo[state]; ok {
+			return best
+		}
+		best := 0
+		key := keys[i]
+		if containsKey(residents, key) {
+			best = 1 + search(i+1, residents)
+		} else {
+			best = search(i+1, residents)
+			if len(residents) < size {
+				best = max(best, search(i+1, sortedWith(residents, key, "")))
+			} else {
+				for _, victim := range residents {
+					best = max(best, search(i+1, sortedWith(residents, key, victim)))
+				}
+			}
+		}
+		memo[state] = best
+		return best
+	}
+	return search(0, []string{})
+}
+
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedWith(keys []string, add string, remove string) []string {
+	out := []string{add}
+	for _, k := range keys {
+		if k != remove {
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestBeladyIsOptimal(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	for round := 0; round < 200; round++ {
+		keys := make([]string, 10+rng.Intn(6))
+		for i := range keys {
+			keys[i] = strconv.Itoa(rng.Intn(5))
+		}
+		size := 1 + rng.Intn(3)
+		result, err := Belady(lines(strings.Join(keys, " ")), size, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if best := bestHits(keys, size); result.Hits != best {
+			t.Fatalf("%v size %d: Belady got %d hits, the best is %d", keys, size, result.Hits, best)
+		}
+	}
+}
+
+func TestBeladyBeatsOnlinePolicies(t *testing.T) {
+	rng := rand.New(rand.NewSource(6))
+	keys := make([]string, 5000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(int(rng.ExpFloat64() * 20))
+	}
+	trace := strings.Join(keys, " ")
+	optimal, err := Belady(lines(trace), 20, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, policy := range []cache.CacheType{cache.FIFO, cache.LRU, cache.LFU, cache.LCR, cache.LECAR, cache.CALECAR} {
+		c, _ := cache.NewCache(policy, 20)
+		result, err := Replay(c, lines(trace), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Hits > optimal.Hits {
+			t.Fatalf("%s got %d hits, more than Belady's %d", policy, result.Hits, optimal.Hits)
+		}
+	}
+}
+
+func TestCostBeladyKeepsTheExpensiveKey(t *testing.T) {
+	costs := func(key string) int {
+		if key == "a" {
+			return 100
+		}
+		return 1
+	}
+	result, err := CostBelady(lines("a b a b a b"), 1, costs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.CostSaved != 200 || result.CostMissed != 103 {
+		t.Fatalf("expected a kept throughout, got %+v", result)
+	}
+}
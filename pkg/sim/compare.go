@@ -0,0 +1,148 @@
+package sim
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+/*TraceSource opens a fresh copy of a trace, so the same
+requests can be replayed once per policy*/
+type TraceSource func() (Trace, error)
+
+/*FileSource reopens the trace file for every replay*/
+func FileSource(path string, format string) TraceSource {
+	return func() (Trace, error) { return OpenTrace(path, format) }
+}
+
+/*Row is one policy's line in a comparison report.  The
+percentages compare it to the offline baselines for the same
+trace and size (Belady for hit ratio, CostBelady for cost)*/
+type Row struct {
+	Policy             cache.CacheType `json:"policy"`
+	Size               int             `json:"size"`
+	Requests           int             `json:"requests"`
+	HitRatio           float64         `json:"hit_ratio"`
+	ByteHitRatio       float64         `json:"byte_hit_ratio"`
+	CostHitRatio       float64         `json:"cost_hit_ratio"`
+	Evictions          int             `json:"evictions"`
+	OptimalHitRatio    float64         `json:"optimal_hit_ratio"`
+	PercentOptimal     float64         `json:"percent_optimal"`
+	OptimalCostRatio   float64         `json:"optimal_cost_hit_ratio"`
+	PercentCostOptimal float64         `json:"percent_cost_optimal"`
+}
+
+func percentOf(value float64, best float64) float64 {
+	if best == 0 {
+		return 0
+	}
+	return 100 * value / best
+}
+
+/*baselines works out the offline optimal hit and cost hit
+ratios for the trace at the size*/
+func baselines(source TraceSource, size int, costFn CostFunc) (float64, float64, error) {
+	trace, err := source()
+	if err != nil {
+		return 0, 0, err
+	}
+	accesses, err := pricedAccesses(trace, costFn)
+	if err != nil {
+		return 0, 0, err
+	}
+	best := replayOffline(accesses, &furthestFirst{size: size, residents: make(map[string]int)})
+	bestCost := replayOffline(accesses, &cheapestPerWait{size: size, residents: make(map[string]futureUse), costs: make(map[string]int)})
+	return best.HitRatio(), bestCost.CostHitRatio(), nil
+}
+
+/*Compare replays the trace through a fresh cache of each
+policy at each size*/
+func Compare(source TraceSource, policies []cache.CacheType, sizes []int, costFn CostFunc) ([]Row, error) {
+	rows := []Row{}
+	for _, size := range sizes {
+		optimal, optimalCost, err := baselines(source, size, costFn)
+		if err != nil {
+			return nil, err
+		}
+		for _, policy := range policies {
+			c, err := cache.NewCache(policy, size)
+			if err != nil {
+				return nil, err
+			}
+			trace, err := source()
+			if err != nil {
+				return nil, err
+			}
+			result, err := Replay(c, trace, costFn)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, Row{
+				Policy:             policy,
+				Size:               size,
+				Requests:           result.Requests,
+				HitRatio:           result.HitRatio(),
+				ByteHitRatio:       result.ByteHitRatio(),
+				CostHitRatio:       result.CostHitRatio(),
+				Evictions:          result.Evictions,
+				OptimalHitRatio:    optimal,
+				PercentOptimal:     percentOf(result.HitRatio(), optimal),
+				OptimalCostRatio:   optimalCost,
+				PercentCostOptimal: percentOf(result.CostHitRatio(), optimalCost),
+			})
+		}
+	}
+	return rows, nil
+}
+
+func ratio(f float64) string {
+	return strconv.FormatFloat(f, 'f', 4, 64)
+}
+
+/*WriteCSV writes the report as csv with a header row*/
+func WriteCSV(w io.Writer, rows []Row) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"policy", "size", "requests", "hit_ratio", "byte_hit_ratio", "cost_hit_ratio", "evictions",
+		"optimal_hit_ratio", "percent_optimal", "optimal_cost_hit_ratio", "percent_cost_optimal"})
+	for _, row := range rows {
+		out.Write([]string{
+			row.Policy.String(),
+			strconv.Itoa(row.Size),
+			strconv.Itoa(row.Requests),
+			ratio(row.HitRatio),
+			ratio(row.ByteHitRatio),
+			ratio(row.CostHitRatio),
+			strconv.Itoa(row.Evictions),
+			ratio(row.OptimalHitRatio),
+			strconv.FormatFloat(row.PercentOptimal, 'f', 1, 64),
+			ratio(row.OptimalCostRatio),
+			strconv.FormatFloat(row.PercentCostOptimal, 'f', 1, 64),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+/*WriteJSON writes the report as a json array*/
+func WriteJSON(w io.Writer, rows []Row) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(rows)
+}
+
+/*WriteTable writes the report as aligned columns for reading
+in a terminal*/
+func WriteTable(w io.Writer, rows []Row) error {
+	out := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	io.WriteString(out, "policy\tsize\trequests\thit ratio\tbyte hit\tcost hit\tevictions\t% of MIN\t% of cost MIN\t\n")
+	for _, row := range rows {
+		io.WriteString(out, row.Policy.String()+"\t"+strconv.Itoa(row.Size)+"\t"+strconv.Itoa(row.Requests)+"\t"+
+			ratio(row.HitRatio)+"\t"+ratio(row.ByteHitRatio)+"\t"+ratio(row.CostHitRatio)+"\t"+strconv.Itoa(row.Evictions)+"\t"+
+			strconv.FormatFloat(row.PercentOptimal, 'f', 1, 64)+"\t"+strconv.FormatFloat(row.PercentCostOptimal, 'f', 1, 64)+"\t\n")
+	}
+	return out.Flush()
+}
@@ -0,0 +1,64 @@
+package sim
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+/*compareRows is LRU and FIFO at size 2 on a b a c a b: LRU
+keeps a through c and hits it twice, FIFO drops it for c and
+hits once, and MIN hits three times by not caching c at all*/
+func compareRows(t *testing.T) []Row {
+	source := func() (Trace, error) { return lines("a b a c a b"), nil }
+	rows, err := Compare(source, []cache.CacheType{cache.LRU, cache.FIFO}, []int{2}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestWriteCSVColumns(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteCSV(&out, compareRows(t)); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"policy,size,requests,hit_ratio,byte_hit_ratio,cost_hit_ratio,evictions,optimal_hit_ratio,percent_optimal,optimal_cost_hit_ratio,percent_cost_optimal",
+		"LRU,2,6,0.3333,0.3333,0.3333,2,0.5000,66.7,0.5000,66.7",
+		"FIFO,2,6,0.1667,0.1667,0.1667,3,0.5000,33.3,0.5000,33.3",
+	}, "\n") + "\n"
+	if out.String() != want {
+		t.Fatalf("expected\n%s\ngot\n%s", want, out.String())
+	}
+}
+
+func TestWriteJSONFields(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteJSON(&out, compareRows(t)); err != nil {
+		t.Fatal(err)
+	}
+	decoded := []map[string]interface{}{}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(decoded))
+	}
+	fields := []string{}
+	for field := range decoded[0] {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	want := "byte_hit_ratio cost_hit_ratio evictions hit_ratio optimal_cost_hit_ratio optimal_hit_ratio percent_cost_optimal percent_optimal policy requests size"
+	if strings.Join(fields, " ") != want {
+		t.Fatalf("expected the fields %s, got %s", want, strings.Join(fields, " "))
+	}
+	if decoded[1]["policy"] != "FIFO" || decoded[1]["evictions"] != 3.0 || decoded[1]["optimal_hit_ratio"] != 0.5 {
+		t.Fatalf("unexpected FIFO row %v", decoded[1])
+	}
+}
@@ -0,0 +1,87 @@
+package sim
+
+/*stackDistance measures reuse distance: for each access, how
+many other distinct keys were asked for since the key's last
+access (its depth in an LRU stack).  Last access times are
+kept in a Fenwick tree, so each access is O(log n)*/
+type stackDistance struct {
+	last  map[string]int
+	slots []string
+	tree  []int
+	now   int
+}
+
+func (sd *stackDistance) add(slot int, delta int) {
+	for ; slot < len(sd.tree); slot += slot & -slot {
+		sd.tree[slot] += delta
+	}
+}
+
+func (sd *stackDistance) prefix(slot int) int {
+	sum := 0
+	for ; slot > 0; slot -= slot & -slot {
+		sum += sd.tree[slot]
+	}
+	return sum
+}
+
+/*compact renumbers the keys' last access times 1..n in
+order, so the tree only ever needs about twice as many slots
+as there are distinct keys*/
+func (sd *stackDistance) compact() {
+	live := []string{}
+	for _, key := range sd.slots {
+		if key != "" {
+			live = append(live, key)
+		}
+	}
+	size := len(sd.slots)
+	if 2*(len(live)+1) > size {
+		size = 2 * (len(live) + 1)
+	}
+	sd.slots = make([]string, size)
+	sd.tree = make([]int, size)
+	for i, key := range live {
+		sd.slots[i+1] = key
+		sd.last[key] = i + 1
+		sd.add(i+1, 1)
+	}
+	sd.now = len(live)
+}
+
+/*access records the key and returns its reuse distance,
+false for its first access*/
+func (sd *stackDistance) access(key string) (int, bool) {
+	distance := 0
+	slot, seen := sd.last[key]
+	if seen {
+		distance = len(sd.last) - sd.prefix(slot)
+		sd.add(slot, -1)
+		sd.slots[slot] = ""
+	}
+	if sd.now+1 >= len(sd.slots) {
+		if seen {
+			// keep the map and tree in step while renumbering
+			delete(sd.last, key)
+		}
+		sd.compact()
+	}
+	sd.now++
+	sd.slots[sd.now] = key
+	sd.last[key] = sd.now
+	sd.add(sd.now, 1)
+	return distance, seen
+}
+
+/*distinct is how many keys have been seen*/
+func (sd *stackDistance) distinct() int {
+	return len(sd.last)
+}
+
+func newStackDistance() *stackDistance {
+	return &stackDistance{
+		last:  make(map[string]int),
+		slots: make([]string, 1024),
+		tree:  make([]int, 1024),
+	}
+}
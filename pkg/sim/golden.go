@@ -0,0 +1,97 @@
+package sim
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+/*ErrGoldenMismatch is what CheckGolden wraps when a
+transcript has drifted from its golden file*/
+var ErrGoldenMismatch = errors.New("Transcript differs from the golden file")
+
+/*Transcript replays the trace through the cache the way
+Replay does and writes down what happened, a line per event:
+"hit key", "miss key", "set key", "delete key", and "evict
+key" for every entry the policy pushes out, in the order it
+does.  Two runs of a deterministic policy over the same trace
+give the same transcript, so any change to its ordering shows
+up as a changed line*/
+func Transcript(c cache.Cache, trace Trace, costFn CostFunc) ([]string, error) {
+	lines := []string{}
+	cache.AddRemovalListener(c, func(key string, entry cache.Entry, reason cache.RemovalReason) {
+		if reason == cache.Evicted {
+			lines = append(lines, "evict "+key)
+		}
+	})
+	for {
+		access, err := trace.Next()
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return lines, err
+		}
+		cost := access.Cost
+		if cost == 0 && costFn != nil {
+			cost = costFn(access.Key)
+		}
+		if cost == 0 {
+			cost = 1
+		}
+		if access.Op == Get {
+			if c.KeyPresent(access.Key) {
+				if _, err := c.GetValue(access.Key); err == nil {
+					lines = append(lines, "hit "+access.Key)
+					continue
+				}
+			}
+			lines = append(lines, "miss "+access.Key)
+			c.SetValue(access.Key, cache.NewEntry("", cost))
+		} else if access.Op == Set {
+			lines = append(lines, "set "+access.Key)
+			c.SetValue(access.Key, cache.NewEntry("", cost))
+		} else if access.Op == Delete {
+			lines = append(lines, "delete "+access.Key)
+			c.Delete(access.Key)
+		}
+	}
+}
+
+/*CheckGolden compares the transcript with the golden file at
+path, naming the first line that differs.  With update it
+writes the transcript to the file instead, for when a change
+in behavior is meant (go test -run Golden -update)*/
+func CheckGolden(path string, transcript []string, update bool) error {
+	if update {
+		return os.WriteFile(path, []byte(strings.Join(transcript, "\n")+"\n"), 0644)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		if line >= len(transcript) {
+			return fmt.Errorf("%w, %s has %q at line %d past the end of the transcript", ErrGoldenMismatch, path, scanner.Text(), line+1)
+		}
+		if scanner.Text() != transcript[line] {
+			return fmt.Errorf("%w, %s line %d is %q, got %q", ErrGoldenMismatch, path, line+1, scanner.Text(), transcript[line])
+		}
+		line++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if line < len(transcript) {
+		return fmt.Errorf("%w, %s ends at line %d, the transcript has %q next", ErrGoldenMismatch, path, line, transcript[line])
+	}
+	return nil
+}
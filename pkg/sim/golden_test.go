@@ -0,0 +1,44 @@
+package sim
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden transcripts")
+
+func TestGoldenTranscripts(t *testing.T) {
+	for _, policy := range []cache.CacheType{cache.FIFO, cache.LRU, cache.LFU, cache.LCR, cache.LCRTTL} {
+		f, err := os.Open("testdata/golden.trace")
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, _ := cache.NewCache(policy, 8)
+		transcript, err := Transcript(c, NewLineTrace(f), nil)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		golden := filepath.Join("testdata", "golden", strings.ToLower(policy.String())+".golden")
+		if err := CheckGolden(golden, transcript, *update); err != nil {
+			t.Fatalf("%s: %v", policy, err)
+		}
+	}
+}
+
+func TestCheckGoldenNamesTheLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lru.golden")
+	if err := CheckGolden(path, []string{"miss a", "hit a"}, true); err != nil {
+		t.Fatal(err)
+	}
+	err := CheckGolden(path, []string{"miss a", "miss a"}, false)
+	if !errors.Is(err, ErrGoldenMismatch) || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected the second line named, got %v", err)
+	}
+}
@@ -0,0 +1,254 @@
+package sim
+
+import (
+	"container/heap"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+/*LatencyModel describes the system a latency simulation
+runs: how many clients issue requests back to back, how
+many recomputes the backend can run at once (0 for no
+limit), what a hit costs, and how long a miss takes per
+unit of entry cost.  With Coalesce, clients missing on a key
+already being recomputed wait for that load*/
+type LatencyModel struct {
+	Clients    int
+	Workers    int
+	HitLatency time.Duration
+	CostUnit   time.Duration
+	Coalesce   bool
+}
+
+/*LatencyResult is the distribution of request latencies a
+simulation saw, Elapsed being the simulated time it took*/
+type LatencyResult struct {
+	Requests int
+	Hits     int
+	Mean     time.Duration
+	P50      time.Duration
+	P90      time.Duration
+	P99      time.Duration
+	Max      time.Duration
+	Elapsed  time.Duration
+}
+
+/*pendingLoad is a recompute in progress and the requests
+waiting on it*/
+type pendingLoad struct {
+	key     string
+	cost    int
+	clients []int
+	issued  []time.Duration
+}
+
+/*simEvent is a client ready to issue its next request, or
+a load finishing when load is set*/
+type simEvent struct {
+	at     time.Duration
+	seq    int
+	client int
+	load   *pendingLoad
+}
+
+type eventQueue []simEvent
+
+func (q eventQueue) Len() int { return len(q) }
+
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].at != q[j].at {
+		return q[i].at < q[j].at
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *eventQueue) Push(x interface{}) { *q = append(*q, x.(simEvent)) }
+
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	event := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return event
+}
+
+/*latencySim is the state of one discrete event run*/
+type latencySim struct {
+	cache     cache.Cache
+	trace     Trace
+	model     LatencyModel
+	costFn    CostFunc
+	events    eventQueue
+	seq       int
+	now       time.Duration
+	busy      int
+	waiting   []*pendingLoad
+	inflight  map[string]*pendingLoad
+	latencies []time.Duration
+	hits      int
+}
+
+func (ls *latencySim) schedule(at time.Duration, client int, load *pendingLoad) {
+	ls.seq++
+	heap.Push(&ls.events, simEvent{at: at, seq: ls.seq, client: client, load: load})
+}
+
+/*startLoad runs the recompute now if a worker is free,
+otherwise queues it*/
+func (ls *latencySim) startLoad(load *pendingLoad) {
+	if ls.model.Workers > 0 && ls.busy >= ls.model.Workers {
+		ls.waiting = append(ls.waiting, load)
+		return
+	}
+	ls.busy++
+	ls.schedule(ls.now+time.Duration(load.cost)*ls.model.CostUnit, 0, load)
+}
+
+func (ls *latencySim) finishLoad(load *pendingLoad) {
+	ls.busy--
+	ls.cache.SetValue(load.key, cache.NewEntry("", load.cost))
+	if ls.inflight[load.key] == load {
+		delete(ls.inflight, load.key)
+	}
+	for i, client := range load.clients {
+		ls.latencies = append(ls.latencies, ls.now-load.issued[i])
+		ls.schedule(ls.now, client, nil)
+	}
+	if len(ls.waiting) > 0 {
+		next := ls.waiting[0]
+		ls.waiting = ls.waiting[1:]
+		ls.startLoad(next)
+	}
+}
+
+/*issue has the client send its next request*/
+func (ls *latencySim) issue(client int) error {
+	access, err := ls.trace.Next()
+	if err == io.EOF {
+		// this client is done
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	cost := access.Cost
+	if cost == 0 && ls.costFn != nil {
+		cost = ls.costFn(access.Key)
+	}
+	if cost == 0 {
+		cost = 1
+	}
+	if access.Op == Set {
+		ls.cache.SetValue(access.Key, cache.NewEntry("", cost))
+		ls.schedule(ls.now, client, nil)
+		return nil
+	} else if access.Op == Delete {
+		ls.cache.Delete(access.Key)
+		ls.schedule(ls.now, client, nil)
+		return nil
+	}
+	if ls.cache.KeyPresent(access.Key) {
+		_, err := ls.cache.GetValue(access.Key)
+		if err == nil {
+			ls.hits++
+			ls.latencies = append(ls.latencies, ls.model.HitLatency)
+			ls.schedule(ls.now+ls.model.HitLatency, client, nil)
+			return nil
+		}
+	}
+	if ls.model.Coalesce {
+		load, ok := ls.inflight[access.Key]
+		if ok {
+			load.clients = append(load.clients, client)
+			load.issued = append(load.issued, ls.now)
+			return nil
+		}
+	}
+	load := &pendingLoad{key: access.Key, cost: cost, clients: []int{client}, issued: []time.Duration{ls.now}}
+	if ls.model.Coalesce {
+		ls.inflight[access.Key] = load
+	}
+	ls.startLoad(load)
+	return nil
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(p * float64(len(sorted)-1))
+	return sorted[i]
+}
+
+/*SimulateLatency replays the trace through the cache as a
+discrete event simulation of the model, so a policy's
+effect shows up as request latency (and queueing at the
+backend) rather than just a hit ratio*/
+func SimulateLatency(c cache.Cache, trace Trace, model LatencyModel, costFn CostFunc) (LatencyResult, error) {
+	if model.Clients <= 0 {
+		model.Clients = 1
+	}
+	ls := &latencySim{cache: c, trace: trace, model: model, costFn: costFn, inflight: make(map[string]*pendingLoad)}
+	for client := 0; client < model.Clients; client++ {
+		ls.schedule(0, client, nil)
+	}
+	for ls.events.Len() > 0 {
+		event := heap.Pop(&ls.events).(simEvent)
+		ls.now = event.at
+		if event.load != nil {
+			ls.finishLoad(event.load)
+			continue
+		}
+		err := ls.issue(event.client)
+		if err != nil {
+			return LatencyResult{}, err
+		}
+	}
+	result := LatencyResult{Requests: len(ls.latencies), Hits: ls.hits, Elapsed: ls.now}
+	if len(ls.latencies) == 0 {
+		return result, nil
+	}
+	sort.Slice(ls.latencies, func(i, j int) bool { return ls.latencies[i] < ls.latencies[j] })
+	total := time.Duration(0)
+	for _, latency := range ls.latencies {
+		total += latency
+	}
+	result.Mean = total / time.Duration(len(ls.latencies))
+	result.P50 = percentile(ls.latencies, 0.5)
+	result.P90 = percentile(ls.latencies, 0.9)
+	result.P99 = percentile(ls.latencies, 0.99)
+	result.Max = ls.latencies[len(ls.latencies)-1]
+	return result, nil
+}
+
+/*LatencyRow is one policy's latency simulation*/
+type LatencyRow struct {
+	Policy cache.CacheType
+	Size   int
+	LatencyResult
+}
+
+/*CompareLatency simulates the trace under each policy*/
+func CompareLatency(source TraceSource, policies []cache.CacheType, size int, model LatencyModel, costFn CostFunc) ([]LatencyRow, error) {
+	rows := []LatencyRow{}
+	for _, policy := range policies {
+		c, err := cache.NewCache(policy, size)
+		if err != nil {
+			return nil, err
+		}
+		trace, err := source()
+		if err != nil {
+			return nil, err
+		}
+		result, err := SimulateLatency(c, trace, model, costFn)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, LatencyRow{Policy: policy, Size: size, LatencyResult: result})
+	}
+	return rows, nil
+}
@@ -0,0 +1,39 @@
+package sim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+func TestSimulateLatencyByHand(t *testing.T) {
+	costs := func(key string) int {
+		if key == "a" {
+			return 3
+		}
+		return 1
+	}
+	cases := []struct {
+		coalesce bool
+		want     LatencyResult
+	}{
+		// both clients miss on a at 0 and share the one 30ms load, then
+		// client 0 loads b 30-40 while client 1 hits a at 30
+		{true, LatencyResult{Requests: 4, Hits: 1, Mean: 17750 * time.Microsecond, P50: 10 * time.Millisecond, P90: 30 * time.Millisecond, P99: 30 * time.Millisecond, Max: 30 * time.Millisecond, Elapsed: 40 * time.Millisecond}},
+		// client 1's load of a queues behind client 0's (0-30, 30-60), and
+		// client 0's b behind that (60-70) while client 1 hits a at 60
+		{false, LatencyResult{Requests: 4, Hits: 1, Mean: 32750 * time.Microsecond, P50: 30 * time.Millisecond, P90: 40 * time.Millisecond, P99: 40 * time.Millisecond, Max: 60 * time.Millisecond, Elapsed: 70 * time.Millisecond}},
+	}
+	for _, tc := range cases {
+		model := LatencyModel{Clients: 2, Workers: 1, HitLatency: time.Millisecond, CostUnit: 10 * time.Millisecond, Coalesce: tc.coalesce}
+		c, _ := cache.NewCache(cache.LRU, 4)
+		got, err := SimulateLatency(c, lines("a a b a"), model, costs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Fatalf("coalesce %v: expected %+v, got %+v", tc.coalesce, tc.want, got)
+		}
+	}
+}
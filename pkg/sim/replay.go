@@ -0,0 +1,134 @@
+package sim
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+/*Result is how a cache did over a replayed trace.  Costs
+are recompute costs: saved by hits, paid by misses.  Bytes
+are the sizes of the requested entries (1 each when the
+trace doesn't give sizes)*/
+type Result struct {
+	Requests   int
+	Hits       int
+	Misses     int
+	Sets       int
+	Deletes    int
+	Evictions  int
+	CostSaved  int64
+	CostMissed int64
+	Bytes      int64
+	BytesHit   int64
+}
+
+/*HitRatio is the share of gets that hit*/
+func (r Result) HitRatio() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	return float64(r.Hits) / float64(r.Requests)
+}
+
+/*ByteHitRatio is the share of requested bytes served from
+the cache*/
+func (r Result) ByteHitRatio() float64 {
+	if r.Bytes == 0 {
+		return 0
+	}
+	return float64(r.BytesHit) / float64(r.Bytes)
+}
+
+/*CostHitRatio is the share of total recompute cost the hits
+saved, the number cost aware policies try to maximize*/
+func (r Result) CostHitRatio() float64 {
+	total := r.CostSaved + r.CostMissed
+	if total == 0 {
+		return 0
+	}
+	return float64(r.CostSaved) / float64(total)
+}
+
+/*CostFunc prices keys the trace has no cost for*/
+type CostFunc func(key string) int
+
+/*DatasetCosts prices keys from a dataset file in the
+server's format (key, value, cost rows)*/
+func DatasetCosts(r io.Reader) (CostFunc, error) {
+	costs := make(map[string]int)
+	reader := csv.NewReader(r)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		cost, err := strconv.Atoi(row[2])
+		if err != nil {
+			return nil, err
+		}
+		costs[row[0]] = cost
+	}
+	return func(key string) int { return costs[key] }, nil
+}
+
+/*Replay runs every access in the trace through the cache.
+Gets that miss are filled like the server fills them, Sets
+and Deletes go straight through.  Keys without a cost in the
+trace are priced by costFn (1 each if nil).  The cache should
+be fresh, its evictions from here on are counted*/
+func Replay(c cache.Cache, trace Trace, costFn CostFunc) (Result, error) {
+	result := Result{}
+	cache.AddRemovalListener(c, func(key string, entry cache.Entry, reason cache.RemovalReason) {
+		if reason == cache.Evicted {
+			result.Evictions++
+		}
+	})
+	for {
+		access, err := trace.Next()
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return result, err
+		}
+		cost := access.Cost
+		if cost == 0 && costFn != nil {
+			cost = costFn(access.Key)
+		}
+		if cost == 0 {
+			cost = 1
+		}
+		size := int64(access.Size)
+		if size == 0 {
+			size = 1
+		}
+		if access.Op == Get {
+			result.Requests++
+			result.Bytes += size
+			if c.KeyPresent(access.Key) {
+				_, err := c.GetValue(access.Key)
+				if err == nil {
+					result.Hits++
+					result.BytesHit += size
+					result.CostSaved += int64(cost)
+					continue
+				}
+			}
+			result.Misses++
+			result.CostMissed += int64(cost)
+			c.SetValue(access.Key, cache.NewEntry("", cost))
+		} else if access.Op == Set {
+			result.Sets++
+			c.SetValue(access.Key, cache.NewEntry("", cost))
+		} else if access.Op == Delete {
+			result.Deletes++
+			c.Delete(access.Key)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package sim
+
+import (
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+/*CurvePoint is the estimated hit ratio at one cache size*/
+type CurvePoint struct {
+	Size     int
+	HitRatio float64
+}
+
+/*Shards estimates an LRU cache's hit ratio at every size
+at once from one pass over the traffic (the SHARDS scheme).
+Only keys whose hash falls under the sampling rate are
+tracked; for those it measures reuse distance (how many
+other distinct keys were asked for since the last time) and
+scales it up by the rate.  A rate of 0.01 is usually within
+a point or two of the exact curve while tracking 1% of the
+keys.  Sizes below about 1/rate are too small to resolve.
+Safe to feed from live traffic*/
+type Shards struct {
+	mu        sync.Mutex
+	rate      float64
+	threshold uint64
+	distances *stackDistance
+	hist      []int64
+	cold      int64
+	total     int64
+	requests  int64
+}
+
+/*Observe counts one request for the key*/
+func (s *Shards) Observe(key string) {
+	atomic.AddInt64(&s.requests, 1)
+	if s.threshold != math.MaxUint64 && cache.SampleHash(key) > s.threshold {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.total++
+	distance, seen := s.distances.access(key)
+	if !seen {
+		s.cold++
+		return
+	}
+	for len(s.hist) <= distance {
+		s.hist = append(s.hist, 0)
+	}
+	s.hist[distance]++
+}
+
+/*HitRatio is the estimated LRU hit ratio at the size*/
+func (s *Shards) HitRatio(size int) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hitRatio(size)
+}
+
+func (s *Shards) hitRatio(size int) float64 {
+	expected := float64(atomic.LoadInt64(&s.requests)) * s.rate
+	if s.total == 0 || expected == 0 || size <= 0 {
+		return 0
+	}
+	// a sampled distance d stands for d/rate distinct keys
+	limit := float64(size) * s.rate
+	// one very hot key in or out of the sample skews everything,
+	// so the gap between the requests sampled and the requests
+	// expected is credited to the shortest distance (SHARDS-adj)
+	hits := expected - float64(s.total)
+	for distance, count := range s.hist {
+		if float64(distance) >= limit {
+			break
+		}
+		hits += float64(count)
+	}
+	ratio := hits / expected
+	if ratio < 0 {
+		return 0
+	} else if ratio > 1 {
+		return 1
+	}
+	return ratio
+}
+
+/*Curve is the estimated hit ratio at each of the sizes*/
+func (s *Shards) Curve(sizes []int) []CurvePoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	points := []CurvePoint{}
+	for _, size := range sizes {
+		points = append(points, CurvePoint{Size: size, HitRatio: s.hitRatio(size)})
+	}
+	return points
+}
+
+/*NewShards samples keys at the rate (1 tracks every key)*/
+func NewShards(rate float64) *Shards {
+	s := &Shards{
+		rate:      rate,
+		distances: newStackDistance(),
+	}
+	if rate >= 1 {
+		s.rate = 1
+		s.threshold = math.MaxUint64
+	} else {
+		s.threshold = uint64(rate * float64(1<<63) * 2)
+	}
+	return s
+}
+
+/*EstimateCurve makes one pass over the trace and returns the
+estimated LRU hit ratio at each size*/
+func EstimateCurve(trace Trace, rate float64, sizes []int) ([]CurvePoint, error) {
+	s := NewShards(rate)
+	for {
+		access, err := trace.Next()
+		if err == io.EOF {
+			return s.Curve(sizes), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if access.Op == Get {
+			s.Observe(access.Key)
+		}
+	}
+}
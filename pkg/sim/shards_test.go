@@ -0,0 +1,56 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+/*zipfKeys is a skewed trace over the number of keys*/
+func zipfKeys(seed int64, requests int, keys uint64) string {
+	rng := rand.New(rand.NewSource(seed))
+	zipf := rand.NewZipf(rng, 1.1, 1, keys-1)
+	out := make([]string, requests)
+	for i := range out {
+		out[i] = "key" + strconv.FormatUint(zipf.Uint64(), 10)
+	}
+	return strings.Join(out, " ")
+}
+
+func TestShardsAtFullRateIsExact(t *testing.T) {
+	keys := zipfKeys(1, 20000, 2000)
+	histogram, err := ReuseDistances(lines(keys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the reuse distance buckets are powers of two, exact at those sizes
+	sizes := []int{1, 2, 16, 128, 512, 2048}
+	curve, err := EstimateCurve(lines(keys), 1, sizes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, size := range sizes {
+		lru, _ := cache.NewCache(cache.LRU, size)
+		replayed, _ := Replay(lru, lines(keys), nil)
+		if curve[i].HitRatio != histogram.Below(size) || curve[i].HitRatio != replayed.HitRatio() {
+			t.Fatalf("size %d: curve %f, reuse distances %f, a real LRU %f", size, curve[i].HitRatio, histogram.Below(size), replayed.HitRatio())
+		}
+	}
+}
+
+func TestShardsSampledCurveIsClose(t *testing.T) {
+	keys := zipfKeys(2, 200000, 50000)
+	// 5% of 50000 keys, sizes well above the 1/rate it can resolve
+	sizes := []int{2000, 5000, 10000}
+	exact, _ := EstimateCurve(lines(keys), 1, sizes)
+	sampled, _ := EstimateCurve(lines(keys), 0.05, sizes)
+	for i, size := range sizes {
+		if diff := math.Abs(sampled[i].HitRatio - exact[i].HitRatio); diff > 0.02 {
+			t.Fatalf("size %d: sampled %f is %f off the exact %f", size, sampled[i].HitRatio, diff, exact[i].HitRatio)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package sim
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+/*SweepGrid is every combination of parameters to try.  An
+empty list of parameters tries just the policy default, and
+each only makes a difference for some policies (see
+cache.Tuning): LearningRates and Discounts for LECAR and
+CALECAR, SegmentRatios for SLRU and CostDecays for LCR*/
+type SweepGrid struct {
+	Policies      []cache.CacheType
+	Sizes         []int
+	LearningRates []float64
+	Discounts     []float64
+	SegmentRatios []float64
+	CostDecays    []float64
+}
+
+/*SweepResult is how one configuration did on the trace*/
+type SweepResult struct {
+	Policy       cache.CacheType `json:"policy"`
+	Size         int             `json:"size"`
+	LearningRate float64         `json:"learning_rate"`
+	Discount     float64         `json:"discount"`
+	SegmentRatio float64         `json:"segment_ratio"`
+	CostDecay    float64         `json:"cost_decay"`
+	HitRatio     float64         `json:"hit_ratio"`
+	CostHitRatio float64         `json:"cost_hit_ratio"`
+}
+
+/*orDefault is the values to try, just the default if none*/
+func orDefault(values []float64) []float64 {
+	if len(values) == 0 {
+		return []float64{0}
+	}
+	return values
+}
+
+/*configs expands the grid, skipping repeats for policies that
+ignore a parameter*/
+func (g SweepGrid) configs() []SweepResult {
+	configs := []SweepResult{}
+	for _, policy := range g.Policies {
+		for _, size := range g.Sizes {
+			if policy == cache.LECAR || policy == cache.CALECAR {
+				for _, rate := range orDefault(g.LearningRates) {
+					for _, discount := range orDefault(g.Discounts) {
+						configs = append(configs, SweepResult{Policy: policy, Size: size, LearningRate: rate, Discount: discount})
+					}
+				}
+			} else if policy == cache.SLRU {
+				for _, ratio := range orDefault(g.SegmentRatios) {
+					configs = append(configs, SweepResult{Policy: policy, Size: size, SegmentRatio: ratio})
+				}
+			} else if policy == cache.LCR {
+				for _, decay := range orDefault(g.CostDecays) {
+					configs = append(configs, SweepResult{Policy: policy, Size: size, CostDecay: decay})
+				}
+			} else {
+				configs = append(configs, SweepResult{Policy: policy, Size: size})
+			}
+		}
+	}
+	return configs
+}
+
+func runConfig(source TraceSource, config SweepResult, costFn CostFunc) (SweepResult, error) {
+	tuning := cache.Tuning{
+		LearningRate: config.LearningRate,
+		Discount:     config.Discount,
+		SegmentRatio: config.SegmentRatio,
+		CostDecay:    config.CostDecay,
+	}
+	c, err := cache.NewTunedCache(config.Policy, config.Size, tuning)
+	if err != nil {
+		return config, err
+	}
+	trace, err := source()
+	if err != nil {
+		return config, err
+	}
+	result, err := Replay(c, trace, costFn)
+	if err != nil {
+		return config, err
+	}
+	config.HitRatio = result.HitRatio()
+	config.CostHitRatio = result.CostHitRatio()
+	return config, nil
+}
+
+/*Sweep replays the trace through every configuration in the
+grid, workers at a time (0 for one per cpu), and returns the
+results best first: by cost hit ratio, then hit ratio.  The
+source is opened once per configuration and has to be safe
+to call from several goroutines*/
+func Sweep(source TraceSource, grid SweepGrid, costFn CostFunc, workers int) ([]SweepResult, error) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	configs := grid.configs()
+	results := make([]SweepResult, len(configs))
+	errs := make([]error, len(configs))
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i], errs[i] = runConfig(source, configs[i], costFn)
+			}
+		}()
+	}
+	for i := range configs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].CostHitRatio != results[j].CostHitRatio {
+			return results[i].CostHitRatio > results[j].CostHitRatio
+		}
+		return results[i].HitRatio > results[j].HitRatio
+	})
+	return results, nil
+}
@@ -0,0 +1,70 @@
+package sim
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+func TestSweepGridExpandsPerPolicy(t *testing.T) {
+	grid := SweepGrid{
+		Policies:      []cache.CacheType{cache.LRU, cache.LECAR, cache.SLRU, cache.LCR},
+		Sizes:         []int{4, 8},
+		LearningRates: []float64{0.1, 0.5},
+		Discounts:     []float64{0.9},
+		SegmentRatios: []float64{0.2, 0.5, 0.8},
+		CostDecays:    []float64{0, 1},
+	}
+	configs := grid.configs()
+	// LRU once a size, LECAR 2 rates by 1 discount, SLRU 3 ratios, LCR 2 decays
+	if len(configs) != 2*(1+2+3+2) {
+		t.Fatalf("expected 16 configurations, got %d", len(configs))
+	}
+	seen := map[SweepResult]bool{}
+	for _, config := range configs {
+		if seen[config] {
+			t.Fatalf("%+v tried twice", config)
+		}
+		seen[config] = true
+		tuned := config.LearningRate != 0 || config.Discount != 0 || config.SegmentRatio != 0 || config.CostDecay != 0
+		if config.Policy == cache.LRU && tuned {
+			t.Fatalf("LRU has nothing to tune, got %+v", config)
+		}
+		if config.Policy == cache.SLRU && (config.SegmentRatio == 0 || config.LearningRate != 0 || config.CostDecay != 0) {
+			t.Fatalf("SLRU should only vary its segment ratio, got %+v", config)
+		}
+	}
+	plain := SweepGrid{Policies: grid.Policies, Sizes: []int{4}}.configs()
+	if len(plain) != 4 {
+		t.Fatalf("expected the defaults once per policy, got %+v", plain)
+	}
+}
+
+func TestSweepReturnsBestFirst(t *testing.T) {
+	keys := strings.Repeat("a b c d ", 50)
+	source := func() (Trace, error) { return lines(strings.TrimSpace(keys)), nil }
+	grid := SweepGrid{
+		Policies:      []cache.CacheType{cache.LRU, cache.SLRU, cache.LCR},
+		Sizes:         []int{1, 2, 4},
+		SegmentRatios: []float64{0.5},
+		CostDecays:    []float64{0.5},
+	}
+	results, err := Sweep(source, grid, nil, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 9 {
+		t.Fatalf("expected a result for each of 9 configurations, got %d", len(results))
+	}
+	// four keys round and round: only a cache holding all four hits, all but the first round
+	if results[0].Size != 4 || results[0].HitRatio < 0.97 {
+		t.Fatalf("expected a size 4 cache first, got %+v", results[0])
+	}
+	for i := 1; i < len(results); i++ {
+		prev, next := results[i-1], results[i]
+		if next.CostHitRatio > prev.CostHitRatio || (next.CostHitRatio == prev.CostHitRatio && next.HitRatio > prev.HitRatio) {
+			t.Fatalf("%+v ranked after the worse %+v", next, prev)
+		}
+	}
+}
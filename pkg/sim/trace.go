@@ -0,0 +1,245 @@
+package sim
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/*Op is what a trace record asks the cache to do*/
+type Op int
+
+const (
+	/*Get looks the key up, filling it on a miss*/
+	Get Op = iota
+	/*Set writes the key*/
+	Set
+	/*Delete drops the key*/
+	Delete
+)
+
+/*Access is one request from a trace.  Cost is 0 when the
+trace doesn't say what recomputing the key costs*/
+type Access struct {
+	Key  string
+	Op   Op
+	Cost int
+	Size int
+}
+
+/*Trace is a stream of accesses, returning io.EOF after the
+last one*/
+type Trace interface {
+	Next() (Access, error)
+}
+
+/*lineTrace reads one key per line, optionally followed by a
+comma and its cost (the format of the files in data/client)*/
+type lineTrace struct {
+	scanner *bufio.Scanner
+}
+
+func (lt *lineTrace) Next() (Access, error) {
+	for lt.scanner.Scan() {
+		line := strings.TrimSpace(lt.scanner.Text())
+		if line == "" {
+			continue
+		}
+		parts := strings.SplitN(line, ",", 2)
+		access := Access{Key: parts[0], Op: Get}
+		if len(parts) == 2 {
+			cost, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+			if err != nil {
+				return Access{}, err
+			}
+			access.Cost = cost
+		}
+		return access, nil
+	}
+	if err := lt.scanner.Err(); err != nil {
+		return Access{}, err
+	}
+	return Access{}, io.EOF
+}
+
+/*NewLineTrace reads a key (or key,cost) per line*/
+func NewLineTrace(r io.Reader) Trace {
+	return &lineTrace{scanner: bufio.NewScanner(r)}
+}
+
+/*arcTrace reads the ARC paper's ".lis" traces, where each
+line is "start_block block_count ignored request_number" and
+stands for reads of block_count consecutive blocks*/
+type arcTrace struct {
+	scanner *bufio.Scanner
+	next    int64
+	left    int64
+}
+
+func (at *arcTrace) Next() (Access, error) {
+	for at.left == 0 {
+		if !at.scanner.Scan() {
+			if err := at.scanner.Err(); err != nil {
+				return Access{}, err
+			}
+			return Access{}, io.EOF
+		}
+		fields := strings.Fields(at.scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return Access{}, errors.New("Malformed ARC trace line: " + at.scanner.Text())
+		}
+		start, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return Access{}, err
+		}
+		count, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return Access{}, err
+		}
+		at.next = start
+		at.left = count
+	}
+	access := Access{Key: strconv.FormatInt(at.next, 10), Op: Get}
+	at.next++
+	at.left--
+	return access, nil
+}
+
+/*NewARCTrace reads an ARC ".lis" trace*/
+func NewARCTrace(r io.Reader) Trace {
+	return &arcTrace{scanner: bufio.NewScanner(r)}
+}
+
+/*twitterTrace reads Twitter's production cache traces,
+csv rows of timestamp, key, key size, value size, client id,
+operation and ttl*/
+type twitterTrace struct {
+	reader *csv.Reader
+}
+
+func (tt *twitterTrace) Next() (Access, error) {
+	for {
+		row, err := tt.reader.Read()
+		if err != nil {
+			return Access{}, err
+		}
+		if len(row) < 6 {
+			return Access{}, errors.New("Malformed twitter trace row")
+		}
+		access := Access{Key: row[1]}
+		keySize, _ := strconv.Atoi(row[2])
+		valueSize, _ := strconv.Atoi(row[3])
+		access.Size = keySize + valueSize
+		op := row[5]
+		if op == "get" || op == "gets" {
+			access.Op = Get
+		} else if op == "delete" {
+			access.Op = Delete
+		} else if op == "set" || op == "add" || op == "replace" || op == "cas" ||
+			op == "append" || op == "prepend" || op == "incr" || op == "decr" {
+			access.Op = Set
+		} else {
+			// unknown operations don't touch the cache
+			continue
+		}
+		return access, nil
+	}
+}
+
+/*NewTwitterTrace reads a Twitter cache trace csv*/
+func NewTwitterTrace(r io.Reader) Trace {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	return &twitterTrace{reader: reader}
+}
+
+/*recordedTrace reads what cache.Recorder writes: timestamp,
+op, key, hit or miss, cost*/
+type recordedTrace struct {
+	reader *csv.Reader
+}
+
+func (rt *recordedTrace) Next() (Access, error) {
+	for {
+		row, err := rt.reader.Read()
+		if err != nil {
+			return Access{}, err
+		}
+		if len(row) < 5 {
+			return Access{}, errors.New("Malformed recorded trace row")
+		}
+		access := Access{Key: row[2]}
+		access.Cost, err = strconv.Atoi(row[4])
+		if err != nil {
+			return Access{}, err
+		}
+		if row[1] == "get" {
+			access.Op = Get
+		} else if row[1] == "set" {
+			access.Op = Set
+		} else if row[1] == "delete" {
+			access.Op = Delete
+		} else {
+			continue
+		}
+		return access, nil
+	}
+}
+
+/*NewRecordedTrace reads a trace written by cache.Recorder*/
+func NewRecordedTrace(r io.Reader) Trace {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	return &recordedTrace{reader: reader}
+}
+
+/*NewTrace reads the named format: "lines", "arc", "twitter"
+or "recorded"*/
+func NewTrace(format string, r io.Reader) (Trace, error) {
+	if format == "recorded" {
+		return NewRecordedTrace(r), nil
+	} else if format == "lines" {
+		return NewLineTrace(r), nil
+	} else if format == "arc" {
+		return NewARCTrace(r), nil
+	} else if format == "twitter" {
+		return NewTwitterTrace(r), nil
+	}
+	return nil, errors.New("No trace format '" + format + "'")
+}
+
+/*fileTrace closes its file once the trace runs out*/
+type fileTrace struct {
+	trace Trace
+	file  *os.File
+}
+
+func (ft *fileTrace) Next() (Access, error) {
+	access, err := ft.trace.Next()
+	if err != nil {
+		ft.file.Close()
+	}
+	return access, err
+}
+
+/*OpenTrace reads a trace file in the named format*/
+func OpenTrace(path string, format string) (Trace, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	trace, err := NewTrace(format, file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &fileTrace{trace: trace, file: file}, nil
+}
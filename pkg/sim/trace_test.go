@@ -0,0 +1,145 @@
+package sim
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+func collect(t *testing.T, trace Trace) []Access {
+	accesses := []Access{}
+	for {
+		access, err := trace.Next()
+		if err == io.EOF {
+			return accesses
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		accesses = append(accesses, access)
+	}
+}
+
+func TestTraceFormats(t *testing.T) {
+	cases := []struct {
+		format string
+		input  string
+		want   []Access
+	}{
+		{"lines", "a\n\nb, 7\n a \n", []Access{{Key: "a"}, {Key: "b", Cost: 7}, {Key: "a"}}},
+		{"lines", "", []Access{}},
+		// start block, block count, then fields we don't use
+		{"arc", "10 3 0 1\n\n4 1 0 2\n", []Access{{Key: "10"}, {Key: "11"}, {Key: "12"}, {Key: "4"}}},
+		{"arc", "7 0 0 1\n8 1 0 2\n", []Access{{Key: "8"}}},
+		{"twitter", strings.Join([]string{
+			"0,k1,3,10,1,get,0",
+			"1,k2,3,20,1,set,60",
+			"2,k1,3,0,1,delete,0",
+			"3,k1,3,10,1,flush,0",
+			"4,k3,2,5,1,incr,0",
+		}, "\n"), []Access{
+			{Key: "k1", Op: Get, Size: 13},
+			{Key: "k2", Op: Set, Size: 23},
+			{Key: "k1", Op: Delete, Size: 3},
+			{Key: "k3", Op: Set, Size: 7},
+		}},
+	}
+	for _, tc := range cases {
+		trace, err := NewTrace(tc.format, strings.NewReader(tc.input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := collect(t, trace)
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s %q: got %+v, expected %+v", tc.format, tc.input, got, tc.want)
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Fatalf("%s %q: got %+v, expected %+v", tc.format, tc.input, got, tc.want)
+			}
+		}
+	}
+}
+
+func TestMalformedTraces(t *testing.T) {
+	cases := []struct {
+		format string
+		input  string
+	}{
+		{"lines", "a,notacost\n"},
+		{"arc", "10\n"},
+		{"arc", "x 1 0 1\n"},
+		{"twitter", "0,k1,3\n"},
+	}
+	for _, tc := range cases {
+		trace, _ := NewTrace(tc.format, strings.NewReader(tc.input))
+		_, err := trace.Next()
+		if err == nil || err == io.EOF {
+			t.Fatalf("%s %q should fail to parse, got %v", tc.format, tc.input, err)
+		}
+	}
+	if _, err := NewTrace("nope", strings.NewReader("")); err == nil {
+		t.Fatal("unknown formats should be an error")
+	}
+}
+
+func TestRecordedTraceRoundTrip(t *testing.T) {
+	lru, _ := cache.NewCache(cache.LRU, 10)
+	var out strings.Builder
+	recorder := cache.NewRecorder(lru, &out, 1)
+	// a read-through miss: probe, failed read, fill
+	recorder.KeyPresent("k")
+	recorder.GetValue("k")
+	recorder.SetValue("k", cache.NewEntry("v", 9))
+	recorder.GetValue("k")
+	recorder.SetValue("k", cache.NewEntry("w", 4))
+	recorder.Delete("k")
+	// a miss nobody fills is written without a cost
+	recorder.GetValue("gone")
+	if err := recorder.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	got := collect(t, NewRecordedTrace(strings.NewReader(out.String())))
+	want := []Access{
+		{Key: "k", Op: Get, Cost: 9},
+		{Key: "k", Op: Get, Cost: 9},
+		{Key: "k", Op: Set, Cost: 4},
+		{Key: "k", Op: Delete},
+		{Key: "gone", Op: Get},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, expected %+v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got %+v, expected %+v", got, want)
+		}
+	}
+}
+
+func TestRecorderSamplesWholeKeys(t *testing.T) {
+	lru, _ := cache.NewCache(cache.LRU, 1000)
+	var out strings.Builder
+	recorder := cache.NewRecorder(lru, &out, 0.5)
+	for i := 0; i < 1000; i++ {
+		key := "key" + strconv.Itoa(i%100)
+		recorder.SetValue(key, cache.NewEntry("v", 1))
+		recorder.GetValue(key)
+	}
+	recorder.Flush()
+	perKey := map[string]int{}
+	for _, access := range collect(t, NewRecordedTrace(strings.NewReader(out.String()))) {
+		perKey[access.Key]++
+	}
+	if len(perKey) < 30 || len(perKey) > 70 {
+		t.Fatalf("expected about half of 100 keys sampled, got %d", len(perKey))
+	}
+	for key, count := range perKey {
+		if count != 20 {
+			t.Fatalf("%s: only %d of its 20 accesses were recorded", key, count)
+		}
+	}
+}
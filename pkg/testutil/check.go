@@ -0,0 +1,94 @@
+package testutil
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+/*ErrMismatch is what Run wraps when the cache and the model
+disagree*/
+var ErrMismatch = errors.New("Cache disagrees with the model")
+
+/*compare checks one operation's outcome on the cache against
+the model's*/
+func compare(m *Model, got cache.Entry, gotErr error, want cache.Entry, wantErr error) error {
+	if m.exact {
+		if (gotErr == nil) != (wantErr == nil) {
+			return fmt.Errorf("%w, cache said %v and the model %v", ErrMismatch, gotErr, wantErr)
+		}
+		if got.Value() != want.Value() || got.Cost() != want.Cost() {
+			return fmt.Errorf("%w, cache had %q and the model %q", ErrMismatch, got.Value(), want.Value())
+		}
+		return nil
+	}
+	// a policy that may have evicted the key can miss, but can't have anything the model doesn't
+	if gotErr == nil && wantErr != nil {
+		return fmt.Errorf("%w, cache had %q for a key the model doesn't", ErrMismatch, got.Value())
+	}
+	if gotErr == nil && got.Value() != want.Value() {
+		return fmt.Errorf("%w, cache had %q and the model %q", ErrMismatch, got.Value(), want.Value())
+	}
+	return nil
+}
+
+/*present turns KeyPresent into an outcome to compare*/
+func present(ok bool) (cache.Entry, error) {
+	if ok {
+		return cache.Entry{}, nil
+	}
+	return cache.Entry{}, cache.ErrKeyNotFound
+}
+
+/*Run makes every operation on both the cache and the model,
+checking they agree and that the cache's structure holds up
+after each, and returns the first thing wrong with the index
+of the operation that went wrong*/
+func Run(c cache.Cache, m *Model, ops []Op) error {
+	for i, op := range ops {
+		var got, want cache.Entry
+		var gotErr, wantErr error
+		if op.Kind == OpGet {
+			got, gotErr = c.GetValue(op.Key)
+			want, wantErr = m.GetValue(op.Key)
+		} else if op.Kind == OpSet {
+			entry := cache.NewEntry(op.Value, op.Cost)
+			gotErr = c.SetValue(op.Key, entry)
+			wantErr = m.SetValue(op.Key, entry)
+		} else if op.Kind == OpDelete {
+			gotErr = c.Delete(op.Key)
+			wantErr = m.Delete(op.Key)
+		} else {
+			got, gotErr = present(c.KeyPresent(op.Key))
+			want, wantErr = present(m.KeyPresent(op.Key))
+		}
+		err := compare(m, got, gotErr, want, wantErr)
+		if err == nil {
+			err = cache.CheckInvariants(c)
+		}
+		if err != nil {
+			return fmt.Errorf("op %d (%s): %w", i, op, err)
+		}
+	}
+	return nil
+}
+
+/*CrossCheck builds the policy and its model and runs n
+generated operations over twice as many keys as the size
+through both, the seed in any error so it can be replayed*/
+func CrossCheck(policy cache.CacheType, size int, seed int64, n int) error {
+	c, err := cache.NewCache(policy, size)
+	if err != nil {
+		return err
+	}
+	m, err := NewModel(policy, size)
+	if err != nil {
+		return err
+	}
+	err = Run(c, m, Generate(seed, n, 2*size))
+	if err != nil {
+		return fmt.Errorf("%s seed %d: %w", policy, seed, err)
+	}
+	return nil
+}
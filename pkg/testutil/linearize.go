@@ -0,0 +1,191 @@
+package testutil
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+/*ErrNotLinearizable is what CheckLinearizable wraps when no
+order of a key's operations explains what its callers saw*/
+var ErrNotLinearizable = errors.New("History is not linearizable")
+
+/*Event is one operation from a concurrent run, when it was
+called and returned (since the run started) and what it got
+back: Found for a hit (or a Delete that found the key) and the
+Value read*/
+type Event struct {
+	Client int
+	Op     Op
+	Start  time.Duration
+	End    time.Duration
+	Found  bool
+	Value  string
+}
+
+/*History is everything a concurrent run did*/
+type History []Event
+
+/*StressOptions configures Stress: Goroutines each make Ops
+generated operations over Keys keys, from Seed (plus the
+goroutine's number)*/
+type StressOptions struct {
+	Goroutines int
+	Ops        int
+	Keys       int
+	Seed       int64
+}
+
+/*Stress runs operations on the cache from many goroutines at
+once and records every one.  Every write is of a value no
+other write uses, so each read can be traced to the write it
+came from*/
+func Stress(c cache.Cache, opts StressOptions) History {
+	start := time.Now()
+	histories := make([]History, opts.Goroutines)
+	var wg sync.WaitGroup
+	for client := 0; client < opts.Goroutines; client++ {
+		wg.Add(1)
+		go func(client int) {
+			defer wg.Done()
+			for i, op := range Generate(opts.Seed+int64(client), opts.Ops, opts.Keys) {
+				if op.Kind == OpSet {
+					op.Value = "c" + strconv.Itoa(client) + "-" + strconv.Itoa(i)
+				}
+				event := Event{Client: client, Op: op, Start: time.Since(start)}
+				if op.Kind == OpGet {
+					entry, err := c.GetValue(op.Key)
+					event.Found = err == nil
+					event.Value = entry.Value()
+				} else if op.Kind == OpSet {
+					event.Found = c.SetValue(op.Key, cache.NewEntry(op.Value, op.Cost)) == nil
+				} else if op.Kind == OpDelete {
+					event.Found = c.Delete(op.Key) == nil
+				} else {
+					event.Found = c.KeyPresent(op.Key)
+				}
+				event.End = time.Since(start)
+				histories[client] = append(histories[client], event)
+			}
+		}(client)
+	}
+	wg.Wait()
+	all := History{}
+	for _, h := range histories {
+		all = append(all, h...)
+	}
+	return all
+}
+
+/*register is a key's state as a linearization goes: absent,
+or holding the value of one write*/
+type register struct {
+	present bool
+	value   string
+}
+
+/*apply is the state after the event, false if the event
+couldn't have happened in that state.  A cache may evict the
+key at any moment, so a miss is always possible, and a write
+that failed may or may not have landed*/
+func apply(state register, event Event) ([]register, bool) {
+	absent := register{}
+	if event.Op.Kind == OpSet {
+		if !event.Found {
+			return []register{state, absent}, true
+		}
+		return []register{{present: true, value: event.Op.Value}}, true
+	} else if event.Op.Kind == OpDelete {
+		if event.Found && !state.present {
+			return nil, false
+		}
+		return []register{absent}, true
+	}
+	if !event.Found {
+		return []register{absent}, true
+	}
+	if !state.present || (event.Op.Kind == OpGet && state.value != event.Value) {
+		return nil, false
+	}
+	return []register{state}, true
+}
+
+/*linearizer searches for an order of one key's events that
+respects real time and explains every result*/
+type linearizer struct {
+	events []Event
+	seen   map[string]bool
+}
+
+func (l *linearizer) search(done []bool, left int, state register) bool {
+	if left == 0 {
+		return true
+	}
+	memo := fmt.Sprint(done, state)
+	if l.seen[memo] {
+		return false
+	}
+	l.seen[memo] = true
+	// an event can go next if nothing still to place returned before it was called
+	earliestEnd := time.Duration(-1)
+	for i, event := range l.events {
+		if !done[i] && (earliestEnd < 0 || event.End < earliestEnd) {
+			earliestEnd = event.End
+		}
+	}
+	for i, event := range l.events {
+		if done[i] || event.Start > earliestEnd {
+			continue
+		}
+		states, ok := apply(state, event)
+		if !ok {
+			continue
+		}
+		done[i] = true
+		for _, next := range states {
+			if l.search(done, left-1, next) {
+				return true
+			}
+		}
+		done[i] = false
+	}
+	return false
+}
+
+/*CheckLinearizable checks that every key's operations could
+have happened one at a time, each somewhere between its call
+and its return, given that the cache may evict at any moment.
+Keys are independent, so each is checked on its own*/
+func CheckLinearizable(h History) error {
+	byKey := map[string][]Event{}
+	for _, event := range h {
+		byKey[event.Op.Key] = append(byKey[event.Op.Key], event)
+	}
+	keys := []string{}
+	for k := range byKey {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		events := byKey[k]
+		sort.Slice(events, func(i, j int) bool { return events[i].Start < events[j].Start })
+		l := &linearizer{events: events, seen: map[string]bool{}}
+		if !l.search(make([]bool, len(events)), len(events), register{}) {
+			return fmt.Errorf("%w, key %s over %d operations", ErrNotLinearizable, k, len(events))
+		}
+	}
+	return nil
+}
+
+/*CheckConcurrent stresses the cache, which has to be safe for
+concurrent use, and checks its history is linearizable.  Use
+it on a custom policy in a Batched or a Sharded, or on any
+cache claiming to be safe on its own*/
+func CheckConcurrent(c cache.Cache, opts StressOptions) error {
+	return CheckLinearizable(Stress(c, opts))
+}
@@ -0,0 +1,51 @@
+package testutil
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+func TestSynchronizedCachesAreLinearizable(t *testing.T) {
+	opts := StressOptions{Goroutines: 4, Ops: 300, Keys: 16, Seed: 1}
+	for _, policy := range []cache.CacheType{cache.LRU, cache.LFU, cache.LCR, cache.LECAR} {
+		c, _ := cache.NewCache(policy, 8)
+		if err := CheckConcurrent(cache.NewBatched(c), opts); err != nil {
+			t.Fatalf("%s: %v", policy, err)
+		}
+	}
+	sharded := cache.NewSharded(4, func(shard int) cache.Cache {
+		c, _ := cache.NewCache(cache.LRU, 4)
+		return c
+	})
+	if err := CheckConcurrent(sharded, opts); err != nil {
+		t.Fatalf("sharded: %v", err)
+	}
+}
+
+func TestCheckLinearizableCatchesStaleReads(t *testing.T) {
+	set := func(value string, start, end int) Event {
+		return Event{Op: Op{Kind: OpSet, Key: "a", Value: value}, Start: ms(start), End: ms(end), Found: true}
+	}
+	get := func(value string, start, end int) Event {
+		return Event{Op: Op{Kind: OpGet, Key: "a"}, Start: ms(start), End: ms(end), Found: true, Value: value}
+	}
+	overlapping := History{set("v1", 0, 10), set("v2", 5, 15), get("v1", 12, 20)}
+	if err := CheckLinearizable(overlapping); err != nil {
+		t.Fatalf("expected writes that overlap to go in either order, got %v", err)
+	}
+	stale := History{set("v1", 0, 10), set("v2", 11, 15), get("v1", 16, 20)}
+	if err := CheckLinearizable(stale); !errors.Is(err, ErrNotLinearizable) {
+		t.Fatalf("expected a read of an overwritten value caught, got %v", err)
+	}
+	deleted := History{set("v1", 0, 10), {Op: Op{Kind: OpDelete, Key: "a"}, Start: ms(11), End: ms(12), Found: true}, get("v1", 13, 14)}
+	if err := CheckLinearizable(deleted); !errors.Is(err, ErrNotLinearizable) {
+		t.Fatalf("expected a read after a delete caught, got %v", err)
+	}
+}
+
+func ms(n int) time.Duration {
+	return time.Duration(n) * time.Millisecond
+}
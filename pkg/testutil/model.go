@@ -0,0 +1,122 @@
+package testutil
+
+import (
+	"errors"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+/*modelEntry is what the model knows about a key*/
+type modelEntry struct {
+	value   string
+	cost    int
+	seq     int
+	lastUse int
+	count   int
+	reached int
+}
+
+/*Model is a reference cache kept as simply as possible, a map
+and a scan for the victim, to check the real policies against.
+For FIFO, LRU, LFU, LCR and LCRTTL (without TTLs) it's exact:
+it evicts what the policy should and every call must come out
+the same.  LECAR and CALECAR evict at random and TIERED and
+SLRU move entries between their tiers, so for those it only
+knows what was last written to each key, and a policy may have
+evicted it*/
+type Model struct {
+	policy  cache.CacheType
+	size    int
+	exact   bool
+	tick    int
+	entries map[string]*modelEntry
+}
+
+/*Exact is true if the model says exactly what the policy
+should hold*/
+func (m *Model) Exact() bool {
+	return m.exact
+}
+
+/*cheaper is the policy's eviction order*/
+func (m *Model) cheaper(a *modelEntry, b *modelEntry) bool {
+	if m.policy == cache.FIFO {
+		return a.seq < b.seq
+	} else if m.policy == cache.LRU {
+		return a.lastUse < b.lastUse
+	} else if m.policy == cache.LFU {
+		if a.count != b.count {
+			return a.count < b.count
+		}
+		return a.reached < b.reached
+	}
+	// LCR and LCRTTL, whose entries have no TTL
+	if a.cost != b.cost {
+		return a.cost < b.cost
+	}
+	return a.seq < b.seq
+}
+
+/*victim is the key the policy should evict next*/
+func (m *Model) victim() string {
+	var min *modelEntry
+	victim := ""
+	for k, entry := range m.entries {
+		if min == nil || m.cheaper(entry, min) {
+			min = entry
+			victim = k
+		}
+	}
+	return victim
+}
+
+/*KeyPresent is true if the model has the key*/
+func (m *Model) KeyPresent(k string) bool {
+	_, ok := m.entries[k]
+	return ok
+}
+
+/*GetValue is the key's entry, counting the access*/
+func (m *Model) GetValue(k string) (cache.Entry, error) {
+	entry, ok := m.entries[k]
+	if !ok {
+		return cache.Entry{}, cache.ErrKeyNotFound
+	}
+	m.tick++
+	entry.lastUse = m.tick
+	entry.count++
+	entry.reached = m.tick
+	return cache.NewEntry(entry.value, entry.cost), nil
+}
+
+/*SetValue replaces the key's entry as a new one, evicting the
+victim first if the model is exact and full*/
+func (m *Model) SetValue(k string, v cache.Entry) error {
+	delete(m.entries, k)
+	if m.exact && len(m.entries) == m.size {
+		delete(m.entries, m.victim())
+	}
+	m.tick++
+	m.entries[k] = &modelEntry{value: v.Value(), cost: v.Cost(), seq: m.tick, lastUse: m.tick, count: 1, reached: m.tick}
+	return nil
+}
+
+/*Delete drops the key*/
+func (m *Model) Delete(k string) error {
+	if _, ok := m.entries[k]; !ok {
+		return cache.ErrKeyNotFound
+	}
+	delete(m.entries, k)
+	return nil
+}
+
+/*NewModel is the reference for a policy of the given size*/
+func NewModel(policy cache.CacheType, size int) (*Model, error) {
+	m := &Model{policy: policy, size: size, entries: map[string]*modelEntry{}}
+	if policy == cache.FIFO || policy == cache.LRU || policy == cache.LFU || policy == cache.LCR || policy == cache.LCRTTL {
+		m.exact = true
+	} else if policy != cache.LECAR && policy != cache.CALECAR && policy != cache.TIERED && policy != cache.SLRU {
+		return nil, errors.New("No model of type '" + policy.String() + "'")
+	}
+	return m, nil
+}
@@ -0,0 +1,69 @@
+package testutil
+
+import (
+	"math/rand"
+	"strconv"
+)
+
+/*OpKind is which Cache method an Op calls*/
+type OpKind int
+
+const (
+	/*OpGet calls GetValue*/
+	OpGet OpKind = iota
+	/*OpSet calls SetValue*/
+	OpSet
+	/*OpDelete calls Delete*/
+	OpDelete
+	/*OpPresent calls KeyPresent*/
+	OpPresent
+)
+
+func (kind OpKind) String() string {
+	if kind == OpGet {
+		return "get"
+	} else if kind == OpSet {
+		return "set"
+	} else if kind == OpDelete {
+		return "delete"
+	}
+	return "present"
+}
+
+/*Op is one call to make on a cache*/
+type Op struct {
+	Kind  OpKind
+	Key   string
+	Value string
+	Cost  int
+}
+
+func (op Op) String() string {
+	if op.Kind == OpSet {
+		return "set " + op.Key + " " + op.Value + " " + strconv.Itoa(op.Cost)
+	}
+	return op.Kind.String() + " " + op.Key
+}
+
+/*Generate makes n random operations over keys distinct keys,
+the same ones for the same seed so a failure can be replayed.
+Reads are skewed towards the low keys so access counts differ,
+and costs are drawn from a small range so some tie*/
+func Generate(seed int64, n int, keys int) []Op {
+	rnd := rand.New(rand.NewSource(seed))
+	ops := make([]Op, n)
+	for i := range ops {
+		k := "k" + strconv.Itoa(rnd.Intn(rnd.Intn(keys)+1))
+		roll := rnd.Intn(20)
+		if roll < 9 {
+			ops[i] = Op{Kind: OpGet, Key: k}
+		} else if roll < 16 {
+			ops[i] = Op{Kind: OpSet, Key: k, Value: "v" + strconv.Itoa(i), Cost: rnd.Intn(20)}
+		} else if roll < 18 {
+			ops[i] = Op{Kind: OpDelete, Key: k}
+		} else {
+			ops[i] = Op{Kind: OpPresent, Key: k}
+		}
+	}
+	return ops
+}
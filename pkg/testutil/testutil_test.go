@@ -0,0 +1,52 @@
+package testutil
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/evizitei/lcr-cache/pkg/cache"
+)
+
+func TestPoliciesMatchTheModel(t *testing.T) {
+	policies := []cache.CacheType{cache.FIFO, cache.LRU, cache.LFU, cache.LCR, cache.LCRTTL, cache.LECAR, cache.CALECAR, cache.TIERED, cache.SLRU}
+	for _, policy := range policies {
+		for seed := int64(1); seed <= 5; seed++ {
+			if err := CrossCheck(policy, 16, seed, 5000); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestSmallSizesMatchTheModel(t *testing.T) {
+	// the edges: a single entry is both list head and tail, and the first eviction empties a list
+	policies := []cache.CacheType{cache.FIFO, cache.LRU, cache.LFU, cache.LCR, cache.LCRTTL, cache.LECAR, cache.CALECAR, cache.TIERED, cache.SLRU}
+	for _, policy := range policies {
+		for size := 1; size <= 3; size++ {
+			for seed := int64(1); seed <= 5; seed++ {
+				if err := CrossCheck(policy, size, seed, 2000); err != nil {
+					t.Fatalf("size %d: %v", size, err)
+				}
+			}
+		}
+	}
+}
+
+func TestRunCatchesAWrongPolicy(t *testing.T) {
+	// an LRU checked against the FIFO model disagrees as soon as a read saves a key
+	lru, _ := cache.NewCache(cache.LRU, 2)
+	m, _ := NewModel(cache.FIFO, 2)
+	ops := []Op{{Kind: OpSet, Key: "a"}, {Kind: OpSet, Key: "b"}, {Kind: OpGet, Key: "a"}, {Kind: OpSet, Key: "c"}, {Kind: OpGet, Key: "a"}}
+	if err := Run(lru, m, ops); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("expected a mismatch, got %v", err)
+	}
+}
+
+func TestGenerateIsSeeded(t *testing.T) {
+	a, b := Generate(7, 100, 10), Generate(7, 100, 10)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("expected the same operations for the same seed, %s and %s at %d", a[i], b[i], i)
+		}
+	}
+}
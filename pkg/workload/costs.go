@@ -0,0 +1,47 @@
+package workload
+
+import "math"
+
+/*CostDist turns a uniform draw in [0,1) into a cost.  The
+draw is fixed per key, so a key costs the same every time
+it's asked for*/
+type CostDist func(u float64) int
+
+/*ConstantCost prices every key the same*/
+func ConstantCost(cost int) CostDist {
+	return func(u float64) int { return cost }
+}
+
+/*UniformCost prices keys evenly between min and max*/
+func UniformCost(min int, max int) CostDist {
+	return func(u float64) int { return min + int(u*float64(max-min+1)) }
+}
+
+/*BimodalCost makes a share of the keys expensive and the
+rest cheap, the case LCR is built for*/
+func BimodalCost(cheap int, expensive int, share float64) CostDist {
+	return func(u float64) int {
+		if u < share {
+			return expensive
+		}
+		return cheap
+	}
+}
+
+/*ParetoCost is a heavy tailed cost, min or more, where a
+smaller alpha makes the rare expensive keys more extreme*/
+func ParetoCost(min int, alpha float64) CostDist {
+	return func(u float64) int {
+		return int(float64(min) / math.Pow(1-u, 1/alpha))
+	}
+}
+
+/*keyDraw is a stable pseudo random draw in [0,1) for a key
+id (splitmix64 of the seed and id)*/
+func keyDraw(seed int64, id int) float64 {
+	z := uint64(seed) + uint64(id)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	z = z ^ (z >> 31)
+	return float64(z>>11) / float64(1<<53)
+}
@@ -0,0 +1,70 @@
+package workload
+
+import (
+	"math/rand"
+)
+
+/*Distribution starts a stream of key ids (0 to n-1) for one
+run, drawing on rng*/
+type Distribution func(rng *rand.Rand) func() int
+
+/*Uniform picks any of n keys with equal probability*/
+func Uniform(n int) Distribution {
+	return func(rng *rand.Rand) func() int {
+		return func() int { return rng.Intn(n) }
+	}
+}
+
+/*Zipf picks from n keys with a zipfian skew, s > 1 (bigger
+is more skewed), key 0 being the most popular*/
+func Zipf(n int, s float64) Distribution {
+	return func(rng *rand.Rand) func() int {
+		zipf := rand.NewZipf(rng, s, 1, uint64(n-1))
+		return func() int { return int(zipf.Uint64()) }
+	}
+}
+
+/*Scan walks all n keys in order, over and over*/
+func Scan(n int) Distribution {
+	return func(rng *rand.Rand) func() int {
+		next := -1
+		return func() int {
+			next = (next + 1) % n
+			return next
+		}
+	}
+}
+
+/*Mix draws from scan a share of the time and from base the
+rest, the LFU-killer pattern of a hot set polluted by scans.
+Scan ids are offset by offset so they can miss the hot set*/
+func Mix(base Distribution, scan Distribution, share float64, offset int) Distribution {
+	return func(rng *rand.Rand) func() int {
+		nextBase := base(rng)
+		nextScan := scan(rng)
+		return func() int {
+			if rng.Float64() < share {
+				return nextScan() + offset
+			}
+			return nextBase()
+		}
+	}
+}
+
+/*TemporalShift moves base's popular keys every period
+requests, rotating ids by shift within n keys, so what was
+hot goes cold and frequency alone stops being a good guide*/
+func TemporalShift(base Distribution, n int, period int, shift int) Distribution {
+	return func(rng *rand.Rand) func() int {
+		next := base(rng)
+		count := 0
+		offset := 0
+		return func() int {
+			if period > 0 && count > 0 && count%period == 0 {
+				offset = (offset + shift) % n
+			}
+			count++
+			return (next() + offset) % n
+		}
+	}
+}
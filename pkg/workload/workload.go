@@ -0,0 +1,88 @@
+package workload
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+
+	"github.com/evizitei/lcr-cache/pkg/sim"
+)
+
+/*Spec describes a synthetic workload: which keys are asked
+for, what each costs, and how many requests there are*/
+type Spec struct {
+	Keys  Distribution
+	Costs CostDist
+	Ops   int
+	Seed  int64
+}
+
+/*Generator produces a spec's requests.  It is a sim.Trace,
+so it can be replayed against a policy directly*/
+type Generator struct {
+	spec Spec
+	next func() int
+	done int
+}
+
+/*Key is the name used for a key id, matching the keys in
+the bundled datasets*/
+func Key(id int) string {
+	return "key" + strconv.Itoa(id)
+}
+
+/*Cost is what the spec charges for a key id*/
+func (g *Generator) Cost(id int) int {
+	if g.spec.Costs == nil {
+		return 1
+	}
+	return g.spec.Costs(keyDraw(g.spec.Seed, id))
+}
+
+/*Next is the following request, io.EOF after Ops of them*/
+func (g *Generator) Next() (sim.Access, error) {
+	if g.done >= g.spec.Ops {
+		return sim.Access{}, io.EOF
+	}
+	g.done++
+	id := g.next()
+	return sim.Access{Key: Key(id), Op: sim.Get, Cost: g.Cost(id)}, nil
+}
+
+/*New starts generating the spec's requests*/
+func New(spec Spec) *Generator {
+	return &Generator{spec: spec, next: spec.Keys(rand.New(rand.NewSource(spec.Seed)))}
+}
+
+/*WriteTrace writes the trace as "key,cost" lines, the format
+sim.NewLineTrace reads back*/
+func WriteTrace(w io.Writer, trace sim.Trace) error {
+	for {
+		access, err := trace.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s,%d\n", access.Key, access.Cost)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+/*WriteDataset writes n keys priced like the spec in the
+server's data_file format, so the server charges the same
+costs the simulation does*/
+func WriteDataset(w io.Writer, spec Spec, n int) error {
+	g := New(spec)
+	for id := 0; id < n; id++ {
+		_, err := fmt.Fprintf(w, "\"%s\",\"val%d\",%d\n", Key(id), id, g.Cost(id))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
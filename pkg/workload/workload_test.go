@@ -0,0 +1,68 @@
+package workload
+
+import (
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func ids(d Distribution, n int) []int {
+	next := d(rand.New(rand.NewSource(1)))
+	out := make([]int, n)
+	for i := range out {
+		out[i] = next()
+	}
+	return out
+}
+
+func TestDistributions(t *testing.T) {
+	cases := []struct {
+		name string
+		d    Distribution
+		want []int
+	}{
+		{"scan", Scan(3), []int{0, 1, 2, 0, 1, 2, 0}},
+		{"shift", TemporalShift(Scan(2), 10, 2, 5), []int{0, 1, 5, 6, 0, 1, 5}},
+		{"mix all scan", Mix(Uniform(5), Scan(2), 1, 100), []int{100, 101, 100, 101, 100, 101, 100}},
+	}
+	for _, tc := range cases {
+		got := ids(tc.d, len(tc.want))
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Fatalf("%s: got %v, expected %v", tc.name, got, tc.want)
+			}
+		}
+	}
+	for _, id := range ids(Zipf(10, 1.5), 1000) {
+		if id < 0 || id >= 10 {
+			t.Fatalf("zipf id %d out of range", id)
+		}
+	}
+}
+
+func TestGeneratorCostsStayFixedPerKey(t *testing.T) {
+	spec := Spec{Keys: Uniform(20), Costs: ParetoCost(1, 1.2), Ops: 2000, Seed: 7}
+	g := New(spec)
+	costs := map[string]int{}
+	count := 0
+	for {
+		access, err := g.Next()
+		if err == io.EOF {
+			break
+		}
+		count++
+		if cost, ok := costs[access.Key]; ok && cost != access.Cost {
+			t.Fatalf("%s cost %d then %d", access.Key, cost, access.Cost)
+		}
+		costs[access.Key] = access.Cost
+	}
+	if count != spec.Ops {
+		t.Fatalf("expected %d requests, got %d", spec.Ops, count)
+	}
+	// same seed, same trace
+	first, _ := New(spec).Next()
+	again, _ := New(spec).Next()
+	if first != again {
+		t.Fatalf("seeded generators differ: %+v vs %+v", first, again)
+	}
+}